@@ -0,0 +1,126 @@
+"""Prefix index over policy addresses, to find the policies a flow's source and destination can match."""
+from __future__ import annotations
+
+from bisect import bisect_left, bisect_right
+from ipaddress import IPv4Network, IPv6Network, summarize_address_range
+from typing import Iterable, Optional, Sequence
+
+from .models import AddressBook, AddressType, PolicyRule
+
+
+Network = IPv4Network | IPv6Network
+
+
+class PrefixIndex:
+    """Positions keyed by CIDR block, looked up by any network the blocks overlap.
+
+    Blocks holding the query network are found by masking its address at each
+    indexed prefix length; blocks inside it by a range search over block starts.
+    """
+
+    def __init__(self) -> None:
+        self._by_prefix: dict[int, dict[int, dict[int, list[int]]]] = {4: {}, 6: {}}
+        self._starts: dict[int, list[tuple[int, int, int]]] = {4: [], 6: []}
+
+    def add(self, position: int, blocks: Iterable[Network]) -> None:
+        for block in blocks:
+            start = int(block.network_address)
+            table = self._by_prefix[block.version].setdefault(block.prefixlen, {})
+            table.setdefault(start, []).append(position)
+            self._starts[block.version].append((start, block.prefixlen, position))
+
+    def freeze(self) -> None:
+        """Sort block starts; call once after the last ``add``."""
+        for starts in self._starts.values():
+            starts.sort()
+
+    def overlapping(self, network: Network) -> set[int]:
+        """Return the positions of every block sharing an address with ``network``."""
+        found: set[int] = set()
+        bits = network.max_prefixlen
+        address = int(network.network_address)
+        for prefixlen, table in self._by_prefix[network.version].items():
+            if prefixlen <= network.prefixlen:
+                mask = ((1 << bits) - 1) ^ ((1 << (bits - prefixlen)) - 1)
+                found.update(table.get(address & mask, ()))
+        found.update(position for _, _, position in self._inside(network))
+        return found
+
+    def _inside(self, network: Network) -> list[tuple[int, int, int]]:
+        starts = self._starts[network.version]
+        low = bisect_left(starts, (int(network.network_address), 0, 0))
+        high = bisect_right(starts, (int(network.broadcast_address), network.max_prefixlen + 1, 0))
+        return [entry for entry in starts[low:high] if entry[1] > network.prefixlen]
+
+    def blocks_inside(self, network: Network) -> set[Network]:
+        """Return the indexed blocks strictly inside ``network``, where a policy boundary splits it."""
+        make = IPv4Network if network.version == 4 else IPv6Network
+        return {make((start, prefixlen)) for start, prefixlen, _ in self._inside(network)}
+
+
+def _blocks(
+    address_book: AddressBook, names: Iterable[str], version: int, visited: Optional[set[str]] = None
+) -> Optional[list[Network]]:
+    # None means the names cannot be reduced to fixed blocks: FQDN, geography, MAC, dynamic, unknown or
+    # empty groups, all of which the evaluator may report as UNKNOWN rather than NO_MATCH.
+    objects = address_book.objects6 if version == 6 else address_book.objects
+    groups = address_book.groups6 if version == 6 else address_book.groups
+    visited = visited if visited is not None else set()
+    blocks: list[Network] = []
+    for name in names:
+        if name in objects:
+            obj = objects[name]
+            if obj.address_type == AddressType.IPMASK and obj.subnet is not None:
+                blocks.append(obj.subnet)
+            elif obj.address_type == AddressType.IPRANGE and obj.start_ip is not None and obj.end_ip is not None:
+                blocks.extend(summarize_address_range(obj.start_ip, obj.end_ip))
+            else:
+                return None
+        elif name in groups:
+            if name in visited:
+                continue
+            visited.add(name)
+            members = _blocks(address_book, groups[name].members, version, visited)
+            if not members:
+                return None
+            blocks.extend(members)
+        else:
+            return None
+    return blocks
+
+
+class PolicyIndex:
+    """Source and destination prefix indexes over a policy list, per IP version.
+
+    Policies whose addresses cannot be reduced to CIDR blocks, or which match
+    on ISDB entries, are kept as always-possible candidates.
+    """
+
+    def __init__(self, policies: Sequence[PolicyRule], address_book: AddressBook) -> None:
+        self._indexes = {(side, version): PrefixIndex() for side in ("src", "dst") for version in (4, 6)}
+        self._broad: dict[tuple[str, int], set[int]] = {key: set() for key in self._indexes}
+        for position, policy in enumerate(policies):
+            for version in (4, 6):
+                for side, names, isdb in (
+                    ("src", policy.source_for(version), policy.internet_services_src),
+                    ("dst", policy.destination_for(version), policy.internet_services),
+                ):
+                    blocks = None if isdb else _blocks(address_book, names, version)
+                    if blocks is None:
+                        self._broad[(side, version)].add(position)
+                    else:
+                        self._indexes[(side, version)].add(position, blocks)
+        for index in self._indexes.values():
+            index.freeze()
+
+    def _side(self, side: str, network: Network) -> set[int]:
+        key = (side, network.version)
+        return self._indexes[key].overlapping(network) | self._broad[key]
+
+    def boundaries(self, side: str, network: Network) -> set[Network]:
+        """Return the policy source (``src``) or destination (``dst``) blocks strictly inside ``network``."""
+        return self._indexes[(side, network.version)].blocks_inside(network)
+
+    def candidates(self, src_network: Network, dst_network: Network) -> set[int]:
+        """Return the positions of policies whose source and destination may both match the flow."""
+        return self._side("src", src_network) & self._side("dst", dst_network)
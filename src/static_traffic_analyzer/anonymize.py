@@ -0,0 +1,99 @@
+"""Keyed, prefix-preserving pseudonymization of IP data in output rows."""
+from __future__ import annotations
+
+import hashlib
+import hmac
+import re
+from ipaddress import IPv4Address, IPv4Network, IPv6Address, IPv6Network, ip_address, ip_network
+from typing import Iterable, Mapping
+
+from .utils import parse_network
+
+
+ANONYMIZED_NETWORK_FIELDS = ("src_network_segment", "dst_network_segment", "src_subrange", "dst_subrange")
+# NAT columns embed addresses, ranges and networks in labels such as `pool (a-b)` or `src -> dst tcp/80`.
+ANONYMIZED_TEXT_FIELDS = ("translated_source", "translated_destination", "original_flow", "translated_flow")
+ADDRESS_TOKEN = re.compile(r"[0-9A-Fa-f:.]+(?:/[0-9]{1,3})?")
+REDACTED_METADATA_FIELDS = ("dst_gn", "dst_site", "dst_location")
+
+
+class IPAnonymizer:
+    """Map IP addresses to stable pseudonyms that preserve shared prefixes.
+
+    Each output bit is the input bit XOR a keyed PRF of the preceding input
+    bits, so two addresses sharing an n-bit prefix map to pseudonyms sharing
+    an n-bit prefix, and the same key always yields the same mapping. IPv6
+    addresses map to IPv6 pseudonyms under a PRF domain of their own.
+    """
+
+    def __init__(self, key: str) -> None:
+        self._key = key.encode("utf-8")
+        self._cache: dict[IPv4Address | IPv6Address, IPv4Address | IPv6Address] = {}
+
+    def _flip_bit(self, prefix: int, length: int, version: int) -> int:
+        label = f"{length}:{prefix}" if version == 4 else f"v6:{length}:{prefix}"
+        digest = hmac.new(self._key, label.encode("ascii"), hashlib.sha256).digest()
+        return digest[0] & 1
+
+    def anonymize_ip(self, ip: IPv4Address | IPv6Address) -> IPv4Address | IPv6Address:
+        """Return the pseudonym for a single address, in the same address family."""
+        cached = self._cache.get(ip)
+        if cached is not None:
+            return cached
+        value = int(ip)
+        bits = ip.max_prefixlen
+        result = 0
+        for length in range(bits):
+            bit = (value >> (bits - 1 - length)) & 1
+            prefix = value >> (bits - length) if length else 0
+            result = (result << 1) | (bit ^ self._flip_bit(prefix, length, ip.version))
+        anonymized = IPv4Address(result) if ip.version == 4 else IPv6Address(result)
+        self._cache[ip] = anonymized
+        return anonymized
+
+    def anonymize_network(self, network: IPv4Network | IPv6Network) -> IPv4Network | IPv6Network:
+        """Return the pseudonym for a network, keeping its prefix length."""
+        anonymized = self.anonymize_ip(network.network_address)
+        return ip_network(f"{anonymized}/{network.prefixlen}", strict=False)
+
+
+def _anonymize_token(match: re.Match[str], anonymizer: IPAnonymizer) -> str:
+    token = match.group(0)
+    if "." not in token and ":" not in token:
+        return token
+    try:
+        if "/" in token:
+            return str(anonymizer.anonymize_network(ip_network(token, strict=False)))
+        return str(anonymizer.anonymize_ip(ip_address(token)))
+    except ValueError:
+        return token
+
+
+def anonymize_text(value: str, anonymizer: IPAnonymizer) -> str:
+    """Return a label with every address or network in it pseudonymized."""
+    return ADDRESS_TOKEN.sub(lambda match: _anonymize_token(match, anonymizer), value)
+
+
+def anonymize_rows(
+    rows: Iterable[Mapping[str, str | int | None]],
+    anonymizer: IPAnonymizer,
+    redact_metadata: bool = False,
+) -> list[dict[str, str | int | None]]:
+    """Return copies of output rows with network and NAT columns pseudonymized."""
+    anonymized_rows: list[dict[str, str | int | None]] = []
+    for row in rows:
+        updated = dict(row)
+        for field in ANONYMIZED_NETWORK_FIELDS:
+            value = updated.get(field)
+            if value:
+                updated[field] = str(anonymizer.anonymize_network(parse_network(str(value))))
+        for field in ANONYMIZED_TEXT_FIELDS:
+            value = updated.get(field)
+            if value:
+                updated[field] = anonymize_text(str(value), anonymizer)
+        if redact_metadata:
+            for field in REDACTED_METADATA_FIELDS:
+                if field in updated:
+                    updated[field] = ""
+        anonymized_rows.append(updated)
+    return anonymized_rows
@@ -0,0 +1,265 @@
+"""Static audits over parsed policies, independent of input traffic."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from enum import Enum
+from ipaddress import IPv4Network
+from pathlib import Path
+from typing import Iterable
+
+from .models import AddressBook, PolicyRule, ServiceBook
+from .overlap import covers, interfaces_cover, policy_space, subtract_networks
+
+
+ANY_NETWORK = IPv4Network("0.0.0.0/0")
+
+RFC1918_NETWORKS = (IPv4Network("10.0.0.0/8"), IPv4Network("172.16.0.0/12"), IPv4Network("192.168.0.0/16"))
+
+# Addresses that are never reached across the internet; whatever else a destination holds is internet-facing.
+NON_INTERNET_NETWORKS = (
+    *RFC1918_NETWORKS,
+    IPv4Network("0.0.0.0/8"),
+    IPv4Network("100.64.0.0/10"),
+    IPv4Network("127.0.0.0/8"),
+    IPv4Network("169.254.0.0/16"),
+    IPv4Network("224.0.0.0/4"),
+    IPv4Network("240.0.0.0/4"),
+)
+
+
+class Severity(str, Enum):
+    """Audit finding severity levels."""
+
+    CRITICAL = "CRITICAL"
+    HIGH = "HIGH"
+    MEDIUM = "MEDIUM"
+    LOW = "LOW"
+
+
+@dataclass(frozen=True)
+class AuditFinding:
+    """A single audit observation about a policy."""
+
+    policy_id: str
+    policy_name: str
+    severity: Severity
+    check: str
+    detail: str
+
+
+def find_dead_service_policies(policies: Iterable[PolicyRule], service_book: ServiceBook) -> list[AuditFinding]:
+    """Flag enabled policies whose negated service list leaves nothing to match."""
+    findings: list[AuditFinding] = []
+    for policy in policies:
+        if not policy.enabled or not policy.service_negate:
+            continue
+        negated = [
+            service.name
+            for name in policy.services
+            for service in service_book.resolve_group_members(name)
+            if any(entry.protocol is None for entry in service.entries)
+        ]
+        if negated:
+            findings.append(
+                AuditFinding(
+                    policy_id=policy.policy_id,
+                    policy_name=policy.name,
+                    severity=Severity.MEDIUM,
+                    check="EMPTY_EFFECTIVE_SERVICE",
+                    detail=f"service-negate excludes every service via {', '.join(sorted(set(negated)))}",
+                )
+            )
+    return findings
+
+
+def _covers_any_address(address_book: AddressBook, names: Iterable[str]) -> bool:
+    """Return True if the referenced addresses include the whole IPv4 space."""
+    return any(
+        obj.contains_network(ANY_NETWORK) for name in names for obj in address_book.resolve_group_members(name)
+    )
+
+
+def _covers_any_service(service_book: ServiceBook, policy: PolicyRule) -> bool:
+    """Return True if the policy's services match every protocol and port."""
+    if policy.service_negate:
+        return False
+    return any(
+        entry.protocol is None
+        for name in policy.services
+        for service in service_book.resolve_group_members(name)
+        for entry in service.entries
+    )
+
+
+def find_any_any_accept(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+) -> list[AuditFinding]:
+    """Flag reachable, enabled all/all/all accept policies as critical findings.
+
+    A catch-all only hides later policies, and is only hidden by earlier
+    catch-alls, whose srcintf/dstintf admit the same interfaces.
+    """
+    rules = [policy for policy in policies if policy.enabled]
+    findings: list[AuditFinding] = []
+    catch_alls: list[PolicyRule] = []
+    for index, policy in enumerate(rules):
+        is_catch_all = (
+            _covers_any_address(address_book, policy.source)
+            and _covers_any_address(address_book, policy.destination)
+            and _covers_any_service(service_book, policy)
+        )
+        if not is_catch_all:
+            continue
+        reachable = not any(_interfaces_cover(earlier, policy) for earlier in catch_alls)
+        catch_alls.append(policy)
+        if not reachable or policy.action.lower() != "accept":
+            continue
+        following = sum(1 for later in rules[index + 1 :] if _interfaces_cover(policy, later))
+        if following == 0:
+            position = "shadows no later policy" if index + 1 < len(rules) else "is the last enabled policy"
+        else:
+            position = f"shadows {following} later {'policy' if following == 1 else 'policies'}"
+        findings.append(
+            AuditFinding(
+                policy_id=policy.policy_id,
+                policy_name=policy.name,
+                severity=Severity.CRITICAL,
+                check="ANY_ANY_ANY_ACCEPT",
+                detail=f"accepts all sources, destinations and services and {position}",
+            )
+        )
+    return findings
+
+
+def _interfaces_cover(outer: PolicyRule, inner: PolicyRule) -> bool:
+    """Return True if every flow ``inner`` admits by interface also reaches ``outer``."""
+    return interfaces_cover(outer.src_interfaces, inner.src_interfaces) and interfaces_cover(
+        outer.dst_interfaces, inner.dst_interfaces
+    )
+
+
+def find_risky_accepts(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+) -> list[AuditFinding]:
+    """Flag enabled accept policies matching risky patterns short of a full catch-all.
+
+    Covers any-to-any accepts limited to some services (high), accepts of
+    every service (medium), accepts with logging disabled (medium) and
+    RFC1918-only sources allowed to internet-facing destinations (medium).
+    All/all/all accepts are left to ``find_any_any_accept``.
+    """
+    findings: list[AuditFinding] = []
+
+    def flag(policy: PolicyRule, severity: Severity, check: str, detail: str) -> None:
+        findings.append(
+            AuditFinding(
+                policy_id=policy.policy_id, policy_name=policy.name, severity=severity, check=check, detail=detail
+            )
+        )
+
+    for policy in policies:
+        if not policy.enabled or policy.action.lower() != "accept":
+            continue
+        any_source = _covers_any_address(address_book, policy.source)
+        any_destination = _covers_any_address(address_book, policy.destination)
+        any_service = _covers_any_service(service_book, policy)
+        if any_source and any_destination and not any_service:
+            flag(policy, Severity.HIGH, "ANY_ANY_ACCEPT", "accepts all sources to all destinations")
+        if any_service and not (any_source and any_destination):
+            flag(policy, Severity.MEDIUM, "ACCEPT_ALL_SERVICES", "accepts every protocol and port")
+        if (policy.logtraffic or "").lower() == "disable":
+            flag(policy, Severity.MEDIUM, "ACCEPT_WITHOUT_LOGGING", "accepted traffic is not logged")
+        space = policy_space(policy, address_book, service_book)
+        if space is None:
+            continue
+        sources = [block for block in space.source if block.version == 4]
+        private = bool(sources) and all(
+            any(block.subnet_of(network) for network in RFC1918_NETWORKS) for block in sources
+        )
+        destinations = [block for block in space.destination if block.version == 4]
+        if private and subtract_networks(destinations, NON_INTERNET_NETWORKS):
+            nat = "with NAT" if policy.nat else "without NAT"
+            flag(policy, Severity.MEDIUM, "PRIVATE_TO_INTERNET", f"RFC1918 sources reach internet addresses {nat}")
+    return findings
+
+
+def find_shadowed_policies(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+) -> list[AuditFinding]:
+    """Flag enabled policies that an earlier, always-active policy fully covers, so they never match.
+
+    Only a single earlier policy is considered as the cover; policies using
+    FQDN, geography, ISDB or identity conditions are never reported.
+    """
+    rules = [policy for policy in policies if policy.enabled]
+    spaces = [policy_space(policy, address_book, service_book) for policy in rules]
+    findings: list[AuditFinding] = []
+    for index, (policy, space) in enumerate(zip(rules, spaces)):
+        if space is None:
+            continue
+        for earlier, earlier_space in zip(rules[:index], spaces[:index]):
+            if earlier_space is None or earlier.schedule not in (None, "always"):
+                continue
+            if not interfaces_cover(earlier.src_interfaces, policy.src_interfaces):
+                continue
+            if not interfaces_cover(earlier.dst_interfaces, policy.dst_interfaces):
+                continue
+            if not covers(earlier_space, space):
+                continue
+            conflicting = earlier.action.lower() != policy.action.lower()
+            findings.append(
+                AuditFinding(
+                    policy_id=policy.policy_id,
+                    policy_name=policy.name,
+                    severity=Severity.HIGH if conflicting else Severity.LOW,
+                    check="SHADOWED_POLICY",
+                    detail=(
+                        f"never matches: earlier policy {earlier.policy_id} ({earlier.action}) "
+                        "covers all of its sources, destinations and services"
+                    ),
+                )
+            )
+            break
+    return findings
+
+
+def audit_policies(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+) -> list[AuditFinding]:
+    """Run all policy audits and return findings, most severe first."""
+    rules = list(policies)
+    findings = [
+        *find_any_any_accept(rules, address_book, service_book),
+        *find_risky_accepts(rules, address_book, service_book),
+        *find_dead_service_policies(rules, service_book),
+        *find_shadowed_policies(rules, address_book, service_book),
+    ]
+    severity_order = list(Severity)
+    return sorted(findings, key=lambda finding: severity_order.index(finding.severity))
+
+
+def write_audit(output_path: Path, findings: Iterable[AuditFinding]) -> None:
+    """Write audit findings to CSV."""
+    fieldnames = ["policy_id", "policy_name", "severity", "check", "detail"]
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=fieldnames)
+        writer.writeheader()
+        for finding in findings:
+            writer.writerow(
+                {
+                    "policy_id": finding.policy_id,
+                    "policy_name": finding.policy_name,
+                    "severity": finding.severity.value,
+                    "check": finding.check,
+                    "detail": finding.detail,
+                }
+            )
@@ -10,4 +10,21 @@ DEFAULT_SERVICES: dict[str, ServiceObject] = {
     "HTTPS": ServiceObject("HTTPS", (ServiceEntry(protocol=Protocol.TCP, start_port=443, end_port=443),)),
     "SSH": ServiceObject("SSH", (ServiceEntry(protocol=Protocol.TCP, start_port=22, end_port=22),)),
     "SMTP": ServiceObject("SMTP", (ServiceEntry(protocol=Protocol.TCP, start_port=25, end_port=25),)),
+    "PING": ServiceObject("PING", (ServiceEntry(protocol=Protocol.ICMP, start_port=8, end_port=8),)),
+    "ALL_ICMP": ServiceObject("ALL_ICMP", (ServiceEntry(protocol=Protocol.ICMP, start_port=0, end_port=255),)),
+}
+
+# Services opened on an interface by `set allowaccess`, at FortiOS default admin ports.
+ADMIN_ACCESS_SERVICES: dict[str, ServiceObject] = {
+    "ping": ServiceObject("ping", (ServiceEntry(protocol=Protocol.ICMP, start_port=8, end_port=8),)),
+    "https": ServiceObject("https", (ServiceEntry(protocol=Protocol.TCP, start_port=443, end_port=443),)),
+    "http": ServiceObject("http", (ServiceEntry(protocol=Protocol.TCP, start_port=80, end_port=80),)),
+    "ssh": ServiceObject("ssh", (ServiceEntry(protocol=Protocol.TCP, start_port=22, end_port=22),)),
+    "telnet": ServiceObject("telnet", (ServiceEntry(protocol=Protocol.TCP, start_port=23, end_port=23),)),
+    "snmp": ServiceObject("snmp", (ServiceEntry(protocol=Protocol.UDP, start_port=161, end_port=161),)),
+    "fgfm": ServiceObject("fgfm", (ServiceEntry(protocol=Protocol.TCP, start_port=541, end_port=541),)),
+    "radius-acct": ServiceObject(
+        "radius-acct", (ServiceEntry(protocol=Protocol.UDP, start_port=1813, end_port=1813),)
+    ),
+    "capwap": ServiceObject("capwap", (ServiceEntry(protocol=Protocol.UDP, start_port=5246, end_port=5247),)),
 }
@@ -0,0 +1,76 @@
+"""End-to-end reachability across firewalls traversed in sequence."""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from ipaddress import IPv4Network
+from typing import Callable, Iterable, Optional
+
+from .evaluator import Evaluator
+from .models import Decision, MatchDetail, Protocol
+
+
+# Rewrites (src, dst, port) after a hop allows a flow, e.g. to apply that hop's NAT.
+Translate = Callable[[IPv4Network, IPv4Network, Protocol, int, MatchDetail], tuple[IPv4Network, IPv4Network, int]]
+
+
+@dataclass(frozen=True)
+class Hop:
+    """One firewall in a chain, in the order traffic reaches it.
+
+    ``ingress`` and ``egress`` name the interfaces the flow crosses on this
+    hop, when known, for policies that match on srcintf/dstintf.
+    """
+
+    name: str
+    evaluator: Evaluator
+    translate: Optional[Translate] = None
+    ingress: Optional[str] = None
+    egress: Optional[str] = None
+
+
+@dataclass(frozen=True)
+class ChainResult:
+    """Outcome of a flow across a chain of firewalls.
+
+    ``hops`` holds the detail for each hop evaluated; evaluation stops at the
+    first hop that does not allow the flow, which is reported as ``blocking_hop``.
+    """
+
+    decision: Decision
+    hops: tuple[tuple[str, MatchDetail], ...]
+    blocking_hop: Optional[str] = None
+
+    @property
+    def blocking_detail(self) -> Optional[MatchDetail]:
+        """Return the detail of the blocking hop, if any."""
+        if self.blocking_hop is None:
+            return None
+        return self.hops[-1][1]
+
+
+def evaluate_chain(
+    hops: Iterable[Hop],
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+    first: Optional[MatchDetail] = None,
+) -> ChainResult:
+    """Evaluate a flow through each hop; it is reachable only if every hop allows it.
+
+    ``first`` may carry an already computed result for the first hop.
+    """
+    results: list[tuple[str, MatchDetail]] = []
+    for index, hop in enumerate(hops):
+        if index == 0 and first is not None:
+            detail = first
+        else:
+            detail = hop.evaluator.evaluate(
+                src_network, dst_network, protocol, port, ingress=hop.ingress, egress=hop.egress
+            )
+        results.append((hop.name, detail))
+        if detail.decision != Decision.ALLOW:
+            return ChainResult(decision=detail.decision, hops=tuple(results), blocking_hop=hop.name)
+        if hop.translate is not None:
+            src_network, dst_network, port = hop.translate(src_network, dst_network, protocol, port, detail)
+    return ChainResult(decision=Decision.ALLOW, hops=tuple(results))
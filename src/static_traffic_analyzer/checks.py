@@ -0,0 +1,143 @@
+"""Post-run assertions over evaluated output rows."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from ipaddress import IPv4Network, IPv6Network
+from pathlib import Path
+from typing import Iterable, Mapping, Optional
+
+from .models import Decision, Protocol
+from .utils import ParseError, parse_network
+
+
+Row = Mapping[str, str | int | None]
+
+
+@dataclass(frozen=True)
+class ForbiddenFlow:
+    """A flow that must never evaluate to ALLOW."""
+
+    source: IPv4Network | IPv6Network
+    destination: IPv4Network | IPv6Network
+    service: Optional[str] = None
+
+    def covers(self, row: Row) -> bool:
+        """Return True if the output row falls inside this forbidden flow.
+
+        Rows of the other IP version never fall inside it.
+        """
+        src = parse_network(str(row["src_network_segment"]))
+        dst = parse_network(str(row["dst_network_segment"]))
+        if src.version != self.source.version or dst.version != self.destination.version:
+            return False
+        if not (src.overlaps(self.source) and dst.overlaps(self.destination)):
+            return False
+        if self.service is None:
+            return True
+        if "/" in self.service:
+            port, protocol = self.service.split("/", 1)
+            return str(row["port"]) == port and str(row["protocol"]) == protocol
+        return str(row["service_label"]).lower() == self.service
+
+    def describe(self) -> str:
+        """Return a short human readable form of the flow."""
+        return f"{self.source} -> {self.destination} ({self.service or 'ALL'})"
+
+
+def load_denylist(path: Path) -> list[ForbiddenFlow]:
+    """Load forbidden flows from a CSV with Source, Destination and optional Service."""
+    flows: list[ForbiddenFlow] = []
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        for header in ("Source", "Destination"):
+            if header not in (reader.fieldnames or []):
+                raise ParseError(f"Denylist file missing required header: {header}")
+        for row in reader:
+            service = (row.get("Service") or "").strip().lower()
+            if "/" in service:
+                port, protocol = service.split("/", 1)
+                if not port.isdigit():
+                    raise ParseError(f"Invalid denylist port: {service}")
+                try:
+                    Protocol(protocol)
+                except ValueError as exc:
+                    raise ParseError(f"Unsupported denylist protocol: {service}") from exc
+            flows.append(
+                ForbiddenFlow(
+                    source=parse_network(row["Source"].strip()),
+                    destination=parse_network(row["Destination"].strip()),
+                    service=service if service and service != "all" else None,
+                )
+            )
+    return flows
+
+
+def find_denylist_violations(
+    rows: Iterable[Row],
+    denylist: Iterable[ForbiddenFlow],
+) -> list[tuple[ForbiddenFlow, Row]]:
+    """Return (forbidden flow, row) pairs for every allowed row on the denylist."""
+    flows = list(denylist)
+    violations: list[tuple[ForbiddenFlow, Row]] = []
+    for row in rows:
+        if row["decision"] != Decision.ALLOW.value:
+            continue
+        for flow in flows:
+            if flow.covers(row):
+                violations.append((flow, row))
+    return violations
+
+
+GOLDEN_KEY_FIELDS = ("src_network_segment", "dst_network_segment", "service_label", "protocol", "port")
+# Exact match mode splits a segment pair into subranges; files written without it read these as empty.
+GOLDEN_OPTIONAL_KEY_FIELDS = ("src_subrange", "dst_subrange")
+GOLDEN_COMPARED_FIELDS = ("decision", "matched_policy_id")
+
+
+def _golden_key(row: Row) -> tuple[str, ...]:
+    required = tuple(str(row[field]) for field in GOLDEN_KEY_FIELDS)
+    return required + tuple(str(row.get(field) or "") for field in GOLDEN_OPTIONAL_KEY_FIELDS)
+
+
+def _flow_label(key: tuple[str, ...]) -> str:
+    return " ".join(part for part in key if part)
+
+
+def load_golden(path: Path) -> dict[tuple[str, ...], dict[str, str]]:
+    """Load a previously written results CSV keyed by flow."""
+    golden: dict[tuple[str, ...], dict[str, str]] = {}
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        for header in (*GOLDEN_KEY_FIELDS, *GOLDEN_COMPARED_FIELDS):
+            if header not in (reader.fieldnames or []):
+                raise ParseError(f"Golden file missing required header: {header}")
+        for row in reader:
+            golden[_golden_key(row)] = row
+    return golden
+
+
+def compare_golden(rows: Iterable[Row], golden: Mapping[tuple[str, ...], Mapping[str, str]]) -> list[str]:
+    """Return human readable differences between current rows and a golden file.
+
+    Rows are matched by flow key rather than position, so the comparison does
+    not depend on output ordering.
+    """
+    mismatches: list[str] = []
+    seen: set[tuple[str, ...]] = set()
+    for row in rows:
+        key = _golden_key(row)
+        seen.add(key)
+        flow = _flow_label(key)
+        expected = golden.get(key)
+        if expected is None:
+            mismatches.append(f"{flow}: not present in golden file")
+            continue
+        for field in GOLDEN_COMPARED_FIELDS:
+            actual = str(row[field] or "")
+            if actual != (expected[field] or ""):
+                mismatches.append(f"{flow}: {field} expected {expected[field]!r}, got {actual!r}")
+    for key in golden:
+        if key not in seen:
+            mismatches.append(f"{_flow_label(key)}: missing from current run")
+    return mismatches
@@ -3,16 +3,167 @@ from __future__ import annotations
 
 import argparse
 import csv
-from dataclasses import asdict
+import os
+import sys
+from dataclasses import replace
+from datetime import datetime, timezone
+from ipaddress import IPv4Address, IPv4Network, IPv6Address, IPv6Network, summarize_address_range
 from pathlib import Path
-from typing import Iterable
+from typing import Iterable, Iterator, Mapping, Optional, Sequence
 
-from .evaluator import MatchMode, evaluate_policy
-from .models import Decision
-from .parsers.db import parse_database
-from .parsers.excel import parse_excel
-from .parsers.fortigate import parse_fortigate_config
-from .utils import ParseError, parse_ipv4_network, parse_ports_file
+from .anonymize import IPAnonymizer, anonymize_rows
+from .audit import Severity, audit_policies, find_shadowed_policies, write_audit
+from .chain import Hop, Translate, evaluate_chain
+from .checks import compare_golden, find_denylist_violations, load_denylist, load_golden
+from .configdiff import diff_configs, write_config_diff
+from .consolidate import find_consolidations, write_consolidations
+from .coverage import policy_coverage, space_coverage, write_policy_coverage, write_space_coverage
+from .exact import exact_subranges
+from .evaluator import (
+    Evaluator,
+    MatchMode,
+    evaluate_local_in_policy,
+    evaluate_multicast_policy,
+    find_dos_policy,
+    find_local_interface,
+    find_snat_rule,
+    find_vip,
+)
+from .geoip import GeoIPDatabase, load_geoip
+from .identity import UserIdentity, load_identity_map, load_mac_map
+from .isdb import load_isdb
+from .leastprivilege import recommend_least_privilege, write_least_privilege
+from .metrics import RunMetrics
+from .models import Decision, InternetService, MatchDetail, Protocol, VirtualIP
+from .output import (
+    AGGREGATE_FIELDS,
+    CHAIN_FIELDS,
+    COMMENT_FIELDS,
+    DEFAULT_SHARD_SIZE,
+    DNAT_FIELDS,
+    DOS_FIELDS,
+    EXACT_FIELDS,
+    HIT_COUNT_FIELDS,
+    INTERFACE_FIELDS,
+    MATCH_ALL_FIELDS,
+    METADATA_COLLISION_POLICIES,
+    NAT_FIELDS,
+    NAT_PATH_FIELDS,
+    NEAR_MISS_FIELDS,
+    OUTPUT_FIELDS,
+    OUTPUT_FORMATS,
+    RAW_REFERENCE_FIELDS,
+    REVERSE_FIELDS,
+    ROUTE_FIELDS,
+    SECTION_FIELDS,
+    SESSION_FIELDS,
+    SOURCE_SET_CSV,
+    SOURCE_SET_FIELDS,
+    SOURCE_SET_THREAT_FEED,
+    SQLITE_SUFFIXES,
+    TOPOLOGY_FIELDS,
+    UTM_FIELDS,
+    VDOM_FIELDS,
+    aggregate_rows,
+    chain_columns,
+    comment_columns,
+    dnat_columns,
+    dos_columns,
+    exact_columns,
+    hit_count_columns,
+    interface_columns,
+    match_all_columns,
+    metadata_columns,
+    metadata_fields,
+    nat_columns,
+    nat_path_columns,
+    near_miss_columns,
+    policy_nat_columns,
+    raw_reference_columns,
+    reverse_columns,
+    route_columns,
+    section_columns,
+    session_columns,
+    topology_columns,
+    utm_columns,
+    vdom_columns,
+    write_output,
+    write_partitioned_output,
+    write_sqlite_output,
+)
+from .parsers.asa import parse_asa_config
+from .parsers.azure_nsg import parse_azure_nsg
+from .parsers.checkpoint import parse_checkpoint_package
+from .parsers.common import RuleSet
+from .parsers.csv_rules import parse_csv_rules
+from .parsers.db import DatabaseData, check_schema, parse_database, parse_sqlite
+from .parsers.excel import ExcelData, parse_excel
+from .parsers.fmc import parse_fmc_export
+from .parsers.fortigate import FortiGateData, parse_fortigate_config
+from .parsers.fortigate_api import APIOptions, parse_fortigate_api
+from .parsers.fortigate_ssh import SSHOptions, parse_fortigate_ssh
+from .parsers.nftables import parse_nftables_ruleset
+from .parsers.normalized import parse_normalized_rules
+from .parsers.panos import parse_panos_config
+from .parsers.pfsense import parse_pfsense_config
+from .parsers.srx import parse_srx_config
+from .parsers.terraform import parse_terraform_fortios
+from .nat import NATPath, find_dnat_vip, flow_label, snat_source, vip_destination
+from .overlap import find_overlaps, format_services, write_overlaps
+from .query import find_candidate_rules, format_check, parse_flow_port, query_space, write_candidate_rules
+from .reports import (
+    build_dead_rule_report,
+    build_hit_count_report,
+    build_logging_report,
+    build_policy_stats,
+    build_section_report,
+    build_service_matrix,
+    write_dead_rule_report,
+    write_hit_count_report,
+    write_logging_report,
+    write_policy_stats,
+    write_section_report,
+    write_service_matrix,
+)
+from .resolver import FQDNResolver, load_hosts_file, no_lookup, system_lookup
+from .routing import RouteDecision, connected_routes, route_flow, rpf_reason
+from .sdn import apply_dynamic_map, load_dynamic_map
+from .sweep import find_schedule_changes, parse_step, sweep_times, write_schedule_changes
+from .topology import Topology, load_topology
+from .trafficlog import load_hit_counts
+from .utils import (
+    ParseError,
+    PortSpec,
+    find_broad_networks,
+    iter_network_lines,
+    parse_network,
+    parse_ports_file,
+)
+from .vdom import VdomConfig, parse_vdom_config
+from .whatif import apply_policy_patch, find_decision_changes, parse_policy_patch, write_decision_changes
+
+
+RuleData = FortiGateData | ExcelData | DatabaseData | RuleSet
+
+# Parsers for the --config file, selected with --provider.
+CONFIG_PROVIDERS = {
+    "fortigate": parse_fortigate_config,
+    "azure-nsg": parse_azure_nsg,
+    "checkpoint": parse_checkpoint_package,
+    "cisco-asa": parse_asa_config,
+    "fmc": parse_fmc_export,
+    "nftables": parse_nftables_ruleset,
+    "normalized": parse_normalized_rules,
+    "panos": parse_panos_config,
+    "pfsense": parse_pfsense_config,
+    "srx": parse_srx_config,
+    "terraform-fortios": parse_terraform_fortios,
+}
+
+# Providers whose --config is a directory of files rather than a single file.
+DIRECTORY_PROVIDERS = {
+    "csv": parse_csv_rules,
+}
 
 
 def _load_csv_networks(path: Path, header_name: str) -> list[dict[str, str]]:
@@ -27,11 +178,56 @@ def _load_csv_networks(path: Path, header_name: str) -> list[dict[str, str]]:
     return records
 
 
-def _select_rule_source(config: str | None, excel: str | None, db_conn: str | None):
+def _select_rule_source(
+    config: str | None,
+    excel: str | None,
+    db_conn: str | None,
+    fortigate_api: str | None = None,
+    ssh_host: str | None = None,
+    sqlite: str | None = None,
+):
     """Ensure exactly one rules source is selected."""
-    provided = [value for value in (config, excel, db_conn) if value]
+    provided = [value for value in (config, excel, db_conn, fortigate_api, ssh_host, sqlite) if value]
     if len(provided) != 1:
-        raise ParseError("Specify exactly one of --config, --excel, or --db-conn")
+        raise ParseError(
+            "Specify exactly one of --config, --excel, --db-conn, --sqlite, --fortigate-api, or --ssh-host"
+        )
+
+
+def _parse_at(value: str) -> datetime:
+    """Parse an RFC3339 timestamp into the naive wall-clock time schedules are compared with."""
+    try:
+        parsed = datetime.fromisoformat(value.strip().replace("Z", "+00:00"))
+    except ValueError as exc:
+        raise argparse.ArgumentTypeError(f"invalid RFC3339 time: {value}") from exc
+    return parsed.replace(tzinfo=None)
+
+
+def _check_prefix_guard(records: list[dict[str, str]], min_prefix: int | None, label: str, warn_only: bool) -> None:
+    """Reject (or warn about) input CIDRs broader than the configured minimum prefix."""
+    if min_prefix is None:
+        return
+    networks = [parse_network(record["Network Segment"]) for record in records]
+    broad = find_broad_networks(networks, min_prefix)
+    if not broad:
+        return
+    message = f"{label} CIDRs broader than /{min_prefix}: {', '.join(str(network) for network in broad)}"
+    if not warn_only:
+        raise ParseError(f"{message} (use --warn-broad-inputs to proceed anyway)")
+    print(f"WARNING: {message}", file=sys.stderr)
+
+
+def _build_match_mode(args: argparse.Namespace) -> MatchMode:
+    """Combine --match-mode with the per-dimension --expand-src/--expand-dst flags."""
+    if not (args.expand_src or args.expand_dst):
+        return MatchMode(mode=args.match_mode or "segment", max_hosts=args.max_hosts)
+    if args.match_mode is not None:
+        raise ParseError("--match-mode cannot be combined with --expand-src or --expand-dst")
+    return MatchMode(
+        mode="expand" if args.expand_src else "sample-ip",
+        max_hosts=args.max_hosts,
+        dst_mode="expand" if args.expand_dst else "sample-ip",
+    )
 
 
 def _iter_ports(ports_path: Path):
@@ -41,107 +237,1441 @@ def _iter_ports(ports_path: Path):
             yield spec
 
 
-def _write_output(
-    output_path: Path,
-    rows: Iterable[dict[str, str | int | None]],
-) -> None:
-    """Write output rows to CSV file."""
-    fieldnames = [
-        "src_network_segment",
-        "dst_network_segment",
-        "dst_gn",
-        "dst_site",
-        "dst_location",
-        "service_label",
-        "protocol",
-        "port",
-        "decision",
-        "matched_policy_id",
-        "matched_policy_name",
-        "matched_policy_action",
-        "reason",
-    ]
-    with output_path.open("w", newline="", encoding="utf-8") as handle:
-        writer = csv.DictWriter(handle, fieldnames=fieldnames)
-        writer.writeheader()
-        for row in rows:
-            writer.writerow(row)
+def _iter_threat_feed(feed_path: Path) -> Iterator[IPv4Network | IPv6Network]:
+    """Yield threat feed networks without loading the whole feed into memory."""
+    with feed_path.open(encoding="utf-8") as handle:
+        yield from iter_network_lines(handle)
+
+
+def _iter_rows(
+    args: argparse.Namespace,
+    data: RuleData,
+    src_records: list[dict[str, str]],
+    dst_records: list[dict[str, str]],
+    ports: list[PortSpec],
+    match_mode: MatchMode,
+    threat_feed: Iterable[IPv4Network | IPv6Network] = (),
+    next_hops: Sequence[RuleData] = (),
+    geoip: Optional[GeoIPDatabase] = None,
+    isdb: Optional[Mapping[str, InternetService]] = None,
+    mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]] = None,
+    identities: Optional[Mapping[IPv4Address | IPv6Address, UserIdentity]] = None,
+    topology: Optional[Topology] = None,
+    vdoms: Optional[VdomConfig] = None,
+    src_metadata: Optional[Mapping[str, str]] = None,
+) -> Iterator[dict[str, str | int | None]]:
+    """Evaluate every src x dst x port combination and yield output rows.
+
+    Threat feed networks are evaluated as additional sources after the source
+    CSV and tagged so known-bad reachability can be told apart. ``next_hops``
+    are firewalls traversed after this one, numbered from 2 in chain columns.
+    With a ``topology`` each segment pair is instead evaluated across the
+    devices on its path, named as in the topology file. With ``vdoms`` flows
+    are also evaluated through every VDOM between the records' VDOM columns
+    (``--vdom`` when blank), crossing inter-VDOM links.
+    """
+    snat_rules = getattr(data, "snat_rules", [])
+    ippools = getattr(data, "ippools", {})
+    central_nat = getattr(data, "central_nat", False)
+    multicast_policies = getattr(data, "multicast_policies", None)
+    interfaces = getattr(data, "interfaces", {}) if args.local_in else {}
+    dos_policies = getattr(data, "dos_policies", [])
+    routes = None
+    if args.routing and hasattr(data, "static_routes"):
+        routes = [*data.static_routes, *connected_routes(getattr(data, "interfaces", {}))]
+    policy_routes = getattr(data, "policy_routes", [])
+    resolver = None
+    if args.resolve_fqdn or args.hosts_file:
+        resolver = FQDNResolver(
+            timeout=args.dns_timeout,
+            budget=args.dns_budget,
+            negative_ttl=args.dns_negative_ttl,
+            lookup=system_lookup if args.resolve_fqdn else no_lookup,
+            static_hosts=load_hosts_file(Path(args.hosts_file)) if args.hosts_file else None,
+        )
+    evaluator = Evaluator(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        match_mode,
+        args.ignore_schedule,
+        resolver=resolver,
+        schedules=getattr(data, "schedules", None),
+        at=args.at,
+        geoip=geoip,
+        isdb=isdb,
+        mac_map=mac_map,
+        identities=identities,
+        zones=getattr(data, "zones", None),
+    )
+    evaluator.warm_ports(ports)
+
+    def hop_evaluator(hop_data: RuleData) -> Evaluator:
+        hop = Evaluator(
+            hop_data.policies,
+            hop_data.address_book,
+            hop_data.service_book,
+            match_mode,
+            args.ignore_schedule,
+            resolver=resolver,
+            schedules=getattr(hop_data, "schedules", None),
+            at=args.at,
+            geoip=geoip,
+            isdb=isdb,
+            mac_map=mac_map,
+            identities=identities,
+            zones=getattr(hop_data, "zones", None),
+        )
+        hop.warm_ports(ports)
+        return hop
+
+    def hop_translate(hop_data: RuleData) -> Translate:
+        """Apply a hop's DNAT and SNAT to a flow it allows, giving the flow the next hop sees."""
+        hop_vips = hop_data.address_book.vips
+        hop_ippools = getattr(hop_data, "ippools", {})
+        hop_snat_rules = getattr(hop_data, "snat_rules", [])
+        hop_central_nat = getattr(hop_data, "central_nat", False)
+
+        def translate(
+            src_network: IPv4Network, dst_network: IPv4Network, protocol: Protocol, port: int, detail: MatchDetail
+        ) -> tuple[IPv4Network, IPv4Network, int]:
+            if dst_network.version != 4 or detail.policy is None:
+                return src_network, dst_network, port
+            if hop_central_nat:
+                vip = find_dnat_vip(hop_vips, dst_network, protocol, port)
+            else:
+                vip = find_vip(hop_data.address_book, detail.policy, dst_network, protocol, port, match_mode)
+            mapped = vip_destination(vip, dst_network) if vip is not None else None
+            if vip is not None and mapped is not None:
+                dst_network, port = mapped, vip.translate_port(port)
+            if hop_central_nat:
+                rule = find_snat_rule(
+                    hop_snat_rules, hop_data.address_book, src_network, dst_network, match_mode, protocol
+                )
+                source = snat_source(rule.nat, rule.nat_ippool, hop_ippools) if rule is not None else None
+            else:
+                source = snat_source(detail.policy.nat, detail.policy.ip_pools, hop_ippools)
+            # Pool names without a pool and egress interface addresses are not known here, so the source is kept.
+            if source is not None and source[:1].isdigit():
+                start, _, end = source.partition("-")
+                blocks = list(summarize_address_range(IPv4Address(start), IPv4Address(end or start)))
+                if len(blocks) == 1:
+                    src_network = blocks[0]
+            return src_network, dst_network, port
+
+        return translate
+
+    hops = [Hop("1", evaluator, translate=hop_translate(data))]
+    hops.extend(
+        Hop(str(index), hop_evaluator(hop_data), translate=hop_translate(hop_data))
+        for index, hop_data in enumerate(next_hops, start=2)
+    )
+    devices = topology.devices if topology is not None else ()
+    device_hops = {
+        device.name: Hop(device.name, hop_evaluator(device.data), translate=hop_translate(device.data))
+        for device in devices
+    }
+    vdom_evaluators = {name: hop_evaluator(vdom_data) for name, vdom_data in (vdoms.vdoms if vdoms else {}).items()}
+    vdom_translations = {name: hop_translate(vdom_data) for name, vdom_data in (vdoms.vdoms if vdoms else {}).items()}
+
+    all_interfaces = getattr(data, "interfaces", {})
+
+    def nat_path(
+        match: MatchDetail,
+        src_network: IPv4Network | IPv6Network,
+        dst_network: IPv4Network | IPv6Network,
+        lookup_dst: IPv4Network | IPv6Network,
+        port_spec: PortSpec,
+        vip: Optional[VirtualIP],
+        route: Optional[RouteDecision],
+    ) -> NATPath:
+        """Trace the flow through DNAT and, if it is allowed, SNAT."""
+        protocol, port = port_spec.protocol, port_spec.port
+        original = flow_label(str(src_network), str(dst_network), protocol, port)
+        allowed = match.decision == Decision.ALLOW and match.policy is not None
+        if vip is None and allowed and not central_nat:
+            # Without central NAT a VIP only translates flows whose policy names it.
+            vip = find_vip(data.address_book, match.policy, dst_network, protocol, port, match_mode)
+        steps: list[str] = []
+        translated_dst: str | int = str(dst_network)
+        translated_port: str | int = port
+        if vip is not None:
+            dnat = dnat_columns(vip, dst_network, port)
+            translated_dst, translated_port = dnat["translated_destination"], dnat["translated_port"]
+            steps.append(f"dnat:{vip.name}")
+        if not allowed:
+            return NATPath(original=original, translated="", steps=tuple(steps))
+        egress = all_interfaces.get(route.egress_interface) if route and route.egress_interface else None
+        egress_ip = egress.ip.ip if egress is not None and egress.ip is not None else None
+        translated_src = None
+        if central_nat:
+            rule = find_snat_rule(
+                snat_rules, data.address_book, src_network, lookup_dst, match_mode, protocol, port_spec.src_port
+            )
+            if rule is not None:
+                translated_src = snat_source(rule.nat, rule.nat_ippool, ippools, egress_ip)
+                if translated_src is not None:
+                    steps.append(f"snat:rule {rule.rule_id}")
+        else:
+            translated_src = snat_source(match.policy.nat, match.policy.ip_pools, ippools, egress_ip)
+            if translated_src is not None:
+                steps.append(f"snat:policy {match.policy.policy_id}")
+        translated = flow_label(translated_src or str(src_network), str(translated_dst), protocol, int(translated_port))
+        return NATPath(original=original, translated=translated, steps=tuple(steps))
+
+    def flow_pieces(
+        src_segment: IPv4Network | IPv6Network,
+        dst_segment: IPv4Network | IPv6Network,
+        src_record: dict[str, str],
+        dst_record: dict[str, str],
+    ) -> Iterator[tuple[PortSpec, IPv4Network | IPv6Network, IPv4Network | IPv6Network]]:
+        """Yield each port with the pieces of the segment pair to evaluate: the pair itself unless exact mode."""
+        for port_spec in ports:
+            if match_mode.mode != "exact":
+                yield port_spec, src_segment, dst_segment
+                continue
+            ingress = (src_record.get("Interface") or None) if args.match_interfaces else None
+            egress = (dst_record.get("Interface") or None) if args.match_interfaces else None
+            for src_piece, dst_piece in exact_subranges(
+                evaluator,
+                src_segment,
+                dst_segment,
+                port_spec.protocol,
+                port_spec.port,
+                port_spec.src_port,
+                ingress=ingress,
+                egress=egress,
+            ):
+                yield port_spec, src_piece, dst_piece
+
+    def rows_for_source(
+        src_segment: IPv4Network | IPv6Network,
+        src_record: dict[str, str],
+        source_set: str,
+    ) -> Iterator[dict[str, str | int | None]]:
+        for dst_record in dst_records:
+            dst_segment = parse_network(dst_record["Network Segment"])
+            if dst_segment.version != src_segment.version:
+                # An IPv4 source cannot reach an IPv6 destination (or vice versa) without NAT64.
+                continue
+            # Only the FortiGate source models a separate multicast policy table.
+            multicast = dst_segment.is_multicast and multicast_policies is not None
+            local_interface = find_local_interface(interfaces, dst_segment)
+            # `router static6` is not modelled, so IPv6 destinations skip the routing step.
+            routed = routes is not None and dst_segment.version == 4 and not multicast and local_interface is None
+            for port_spec, src_network, dst_network in flow_pieces(src_segment, dst_segment, src_record, dst_record):
+                route = None
+                dnat_vip = None
+                all_matches: list[MatchDetail] = []
+                reverse: Optional[MatchDetail] = None
+                reverse_port: Optional[int] = None
+                lookup_dst, lookup_port = dst_network, port_spec.port
+                if routed:
+                    route = route_flow(
+                        routes, policy_routes, src_network, dst_network, port_spec.protocol, port_spec.port
+                    )
+                no_route = route.unroutable if route is not None else None
+                if no_route is None and routed and args.rpf_check:
+                    # Strict RPF drops spoofed sources before any policy lookup.
+                    no_route = rpf_reason(routes, src_network, src_record.get("Interface") or None)
+                if no_route is not None:
+                    match = MatchDetail(
+                        decision=Decision.UNROUTABLE,
+                        matched_policy_id=None,
+                        matched_policy_name=None,
+                        matched_policy_action=None,
+                        reason=no_route,
+                    )
+                elif multicast:
+                    match = evaluate_multicast_policy(
+                        policies=multicast_policies,
+                        address_book=data.address_book,
+                        service_book=data.service_book,
+                        src_network=src_network,
+                        dst_network=dst_network,
+                        protocol=port_spec.protocol,
+                        port=port_spec.port,
+                        match_mode=match_mode,
+                        ignore_schedule=args.ignore_schedule,
+                        schedules=getattr(data, "schedules", None),
+                        at=args.at,
+                    )
+                elif local_interface is not None:
+                    match = evaluate_local_in_policy(
+                        policies=getattr(data, "local_in_policies", []),
+                        address_book=data.address_book,
+                        service_book=data.service_book,
+                        interface=local_interface,
+                        src_network=src_network,
+                        dst_network=dst_network,
+                        protocol=port_spec.protocol,
+                        port=port_spec.port,
+                        match_mode=match_mode,
+                        ignore_schedule=args.ignore_schedule,
+                        schedules=getattr(data, "schedules", None),
+                        at=args.at,
+                    )
+                else:
+                    ingress = egress = None
+                    if args.match_interfaces:
+                        # Interface columns in the CSVs win over what the routing table implies.
+                        ingress = src_record.get("Interface") or (route.ingress_interface if route else None)
+                        egress = dst_record.get("Interface") or (route.egress_interface if route else None)
+                    if args.nat_pipeline and central_nat:
+                        # Central NAT translates VIPs before the lookup, so policies name the mapped addresses.
+                        dnat_vip = find_dnat_vip(
+                            data.address_book.vips, dst_network, port_spec.protocol, port_spec.port
+                        )
+                        mapped = vip_destination(dnat_vip, dst_network) if dnat_vip is not None else None
+                        if mapped is not None:
+                            lookup_dst, lookup_port = mapped, dnat_vip.translate_port(port_spec.port)
+                        else:
+                            dnat_vip = None
+                    match = evaluator.evaluate(
+                        src_network,
+                        lookup_dst,
+                        port_spec.protocol,
+                        lookup_port,
+                        port_spec.src_port,
+                        ingress=ingress,
+                        egress=egress,
+                    )
+                    if args.bidirectional:
+                        # Replies come from the service port; ICMP has no ports to turn around.
+                        reverse_port, reverse_src_port = lookup_port, port_spec.src_port
+                        if args.return_port is not None and port_spec.protocol != Protocol.ICMP:
+                            reverse_port, reverse_src_port = args.return_port, lookup_port
+                        reverse = evaluator.evaluate(
+                            lookup_dst,
+                            src_network,
+                            port_spec.protocol,
+                            reverse_port,
+                            reverse_src_port,
+                            ingress=egress,
+                            egress=ingress,
+                        )
+                    if args.match_all:
+                        all_matches = evaluator.all_matches(
+                            src_network,
+                            lookup_dst,
+                            port_spec.protocol,
+                            lookup_port,
+                            port_spec.src_port,
+                            ingress=ingress,
+                            egress=egress,
+                        )
+                row: dict[str, str | int | None] = {
+                    "src_network_segment": str(src_segment),
+                    "dst_network_segment": str(dst_segment),
+                    "dst_gn": dst_record.get("GN") or "",
+                    "dst_site": dst_record.get("Site") or "",
+                    "dst_location": dst_record.get("Location") or "",
+                    "service_label": port_spec.label,
+                    "protocol": port_spec.protocol.value,
+                    "port": port_spec.port,
+                    "decision": match.decision.value,
+                    "matched_policy_id": match.matched_policy_id or "",
+                    "matched_policy_name": match.matched_policy_name or "",
+                    "matched_policy_action": match.matched_policy_action or "",
+                    "reason": match.reason,
+                }
+                if match_mode.mode == "exact":
+                    row.update(exact_columns(src_network, dst_network))
+                if args.match_all:
+                    row.update(match_all_columns(all_matches))
+                if args.bidirectional:
+                    row.update(reverse_columns(match, reverse, reverse_port))
+                if args.threat_feed:
+                    row["source_set"] = source_set
+                if src_metadata:
+                    row.update(metadata_columns(src_record, src_metadata))
+                if args.session_columns:
+                    row.update(session_columns(match.policy))
+                if args.raw_ref_columns:
+                    row.update(raw_reference_columns(match.policy))
+                if args.interface_columns:
+                    row.update(interface_columns(match.policy))
+                if args.comment_columns:
+                    row.update(comment_columns(match.policy))
+                if args.utm_columns:
+                    row.update(utm_columns(match.policy))
+                if args.section_columns:
+                    row.update(section_columns(match.policy))
+                if args.routing:
+                    row.update(route_columns(route))
+                if next_hops:
+                    chain = None
+                    if not multicast and local_interface is None and no_route is None:
+                        chain = evaluate_chain(
+                            hops, src_network, dst_network, port_spec.protocol, port_spec.port, first=match
+                        )
+                    row.update(chain_columns(chain))
+                if topology is not None:
+                    device_path = topology.path(src_segment, dst_segment)
+                    topology_chain = None
+                    if device_path is not None:
+                        topology_chain = evaluate_chain(
+                            [device_hops[device.name] for device in device_path],
+                            src_network,
+                            dst_network,
+                            port_spec.protocol,
+                            port_spec.port,
+                        )
+                    row.update(topology_columns(device_path, topology_chain))
+                if vdoms is not None:
+                    vdom_path = vdoms.path(src_record.get("VDOM") or args.vdom, dst_record.get("VDOM") or args.vdom)
+                    vdom_chain = None
+                    if vdom_path is not None and not multicast and local_interface is None and no_route is None:
+                        # The link interfaces between VDOMs are always known; the outer ends come from the CSVs.
+                        vdom_hops = [
+                            Hop(
+                                hop.vdom,
+                                vdom_evaluators[hop.vdom],
+                                translate=vdom_translations[hop.vdom],
+                                ingress=hop.ingress,
+                                egress=hop.egress,
+                            )
+                            for hop in vdom_path
+                        ]
+                        if args.match_interfaces:
+                            vdom_hops[0] = replace(vdom_hops[0], ingress=src_record.get("Interface") or None)
+                            vdom_hops[-1] = replace(vdom_hops[-1], egress=dst_record.get("Interface") or None)
+                        vdom_chain = evaluate_chain(
+                            vdom_hops, src_network, dst_network, port_spec.protocol, port_spec.port
+                        )
+                    row.update(vdom_columns(vdom_path, vdom_chain))
+                if args.dnat_columns:
+                    vip = None
+                    if match.decision == Decision.ALLOW and match.policy is not None:
+                        vip = find_vip(
+                            data.address_book, match.policy, dst_network, port_spec.protocol, port_spec.port, match_mode
+                        )
+                    row.update(dnat_columns(vip, dst_network, port_spec.port))
+                if args.dos_columns:
+                    dos_policy = find_dos_policy(
+                        dos_policies,
+                        data.address_book,
+                        data.service_book,
+                        src_network,
+                        dst_network,
+                        port_spec.protocol,
+                        port_spec.port,
+                        match_mode,
+                    )
+                    row.update(dos_columns(dos_policy))
+                if args.nat_columns:
+                    if match.decision != Decision.ALLOW:
+                        row.update(nat_columns(None))
+                    elif central_nat:
+                        snat_rule = find_snat_rule(
+                            snat_rules,
+                            data.address_book,
+                            src_network,
+                            dst_network,
+                            match_mode,
+                            port_spec.protocol,
+                            port_spec.src_port,
+                        )
+                        row.update(nat_columns(snat_rule, ippools))
+                    else:
+                        row.update(policy_nat_columns(match.policy, ippools))
+                if args.nat_pipeline:
+                    path = nat_path(match, src_network, dst_network, lookup_dst, port_spec, dnat_vip, route)
+                    row.update(nat_path_columns(path))
+                if args.near_miss_columns:
+                    misses = []
+                    if match.decision == Decision.DENY and not multicast and local_interface is None:
+                        misses = evaluator.near_misses(src_network, dst_network, port_spec.protocol, port_spec.port)
+                    row.update(near_miss_columns(misses))
+                yield row
+
+    for src_record in src_records:
+        yield from rows_for_source(parse_network(src_record["Network Segment"]), src_record, SOURCE_SET_CSV)
+    for src_network in threat_feed:
+        yield from rows_for_source(src_network, {}, SOURCE_SET_THREAT_FEED)
+
+
+def _run_db_check(argv: list[str]) -> None:
+    """Verify the MariaDB schema matches what the database parser expects."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer db-check",
+        description="Check MariaDB rule tables against the expected schema",
+    )
+    parser.add_argument("--db-conn", required=True, help="MariaDB DSN")
+    args = parser.parse_args(argv)
+
+    try:
+        problems = check_schema(args.db_conn)
+    except ParseError as exc:
+        raise SystemExit(str(exc)) from exc
+    for problem in problems:
+        print(problem, file=sys.stderr)
+    if problems:
+        raise SystemExit(1)
+    print("schema OK")
+
+
+def _run_shadow(argv: list[str]) -> None:
+    """Report policies that can never match because an earlier policy covers them."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer shadow",
+        description="Find policies fully covered by an earlier policy",
+    )
+    parser.add_argument("--config", required=True, help="Rule configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of --config"
+    )
+    parser.add_argument("--out", help="Also write the findings to CSV")
+    args = parser.parse_args(argv)
+
+    try:
+        with Path(args.config).open(encoding="utf-8") as handle:
+            data = CONFIG_PROVIDERS[args.provider](handle.readlines())
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    findings = find_shadowed_policies(data.policies, data.address_book, data.service_book)
+    if args.out:
+        write_audit(Path(args.out), findings)
+    for finding in findings:
+        print(f"{finding.severity.value}: policy {finding.policy_id} ({finding.policy_name}) {finding.detail}")
+    if not findings:
+        print("no shadowed policies")
+
+
+def _run_overlap(argv: list[str]) -> None:
+    """Report pairs of policies matching the same flows, highlighting accept/deny conflicts."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer overlap",
+        description="List policy pairs whose match spaces intersect, with the shared addresses and ports",
+    )
+    parser.add_argument("--config", required=True, help="Rule configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of --config"
+    )
+    parser.add_argument("--out", help="Write every overlapping pair to CSV, not just conflicts")
+    args = parser.parse_args(argv)
+
+    try:
+        with Path(args.config).open(encoding="utf-8") as handle:
+            data = CONFIG_PROVIDERS[args.provider](handle.readlines())
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    overlaps = find_overlaps(data.policies, data.address_book, data.service_book)
+    if args.out:
+        write_overlaps(Path(args.out), overlaps)
+    for overlap in overlaps:
+        if not overlap.conflict:
+            continue
+        print(
+            f"CONFLICT: policy {overlap.earlier.policy_id} ({overlap.earlier.action}) and "
+            f"policy {overlap.later.policy_id} ({overlap.later.action}) share "
+            f"{';'.join(map(str, overlap.shared.source))} -> {';'.join(map(str, overlap.shared.destination))} "
+            f"{format_services(overlap.shared.services)}"
+        )
+
+
+def _run_consolidate(argv: list[str]) -> None:
+    """Suggest adjacent policies that could be merged into one to shrink the rule base."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer consolidate",
+        description="Find runs of adjacent policies differing only in source, destination or service",
+    )
+    parser.add_argument("--config", required=True, help="Rule configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of --config"
+    )
+    parser.add_argument("--out", help="Also write the suggestions and proposed policies to CSV")
+    args = parser.parse_args(argv)
+
+    try:
+        with Path(args.config).open(encoding="utf-8") as handle:
+            data = CONFIG_PROVIDERS[args.provider](handle.readlines())
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    consolidations = find_consolidations(data.policies, data.address_book, data.service_book)
+    if args.out:
+        write_consolidations(Path(args.out), consolidations)
+    for consolidation in consolidations:
+        print(
+            f"MERGE: policies {', '.join(policy.policy_id for policy in consolidation.policies)} "
+            f"({consolidation.policies[0].action}, {consolidation.reason}) -> "
+            f"srcaddr {','.join(consolidation.source_names)} dstaddr {','.join(consolidation.destination_names)} "
+            f"service {','.join(consolidation.service_names)}"
+        )
+    if not consolidations:
+        print("no consolidation candidates")
+
+
+def _run_query(argv: list[str]) -> None:
+    """List every policy that could match a partially specified flow, or explain how one flow is decided."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer query",
+        description=(
+            "Search the rule base in reverse (rules; unspecified flow dimensions match anything), "
+            "or trace each policy evaluated for one flow (explain)"
+        ),
+    )
+    parser.add_argument("target", choices=["rules", "explain"], help="What to search for")
+    parser.add_argument("--config", required=True, help="Rule configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of --config"
+    )
+    parser.add_argument("--src", help="Source address or CIDR")
+    parser.add_argument("--dst", help="Destination address or CIDR")
+    parser.add_argument("--port", help="Service as port/protocol (443/tcp), a port range, a port or a protocol")
+    parser.add_argument("--include-disabled", action="store_true", help="Also list disabled policies")
+    parser.add_argument("--out", help="Also write the matching policies to CSV")
+    parser.add_argument("--src-port", type=int, help="explain: source port, for services with source port ranges")
+    parser.add_argument("--ingress", help="explain: interface or zone the flow arrives on")
+    parser.add_argument("--egress", help="explain: interface or zone the flow leaves through")
+    parser.add_argument("--ignore-schedule", action="store_true", help="explain: ignore policy schedules")
+    parser.add_argument(
+        "--at",
+        type=_parse_at,
+        help="explain: evaluate schedules at this RFC3339 time; without it only 'always' is active",
+    )
+    args = parser.parse_args(argv)
+
+    try:
+        if args.target == "explain" and not (args.src and args.dst and args.port):
+            raise ParseError("query explain requires --src, --dst and --port")
+        query = query_space(args.src, args.dst, args.port)
+        with Path(args.config).open(encoding="utf-8") as handle:
+            data = CONFIG_PROVIDERS[args.provider](handle.readlines())
+        if args.target == "explain":
+            protocol, port = parse_flow_port(args.port)
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    if args.target == "explain":
+        evaluator = Evaluator(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            MatchMode(mode="segment", max_hosts=256),
+            args.ignore_schedule,
+            schedules=getattr(data, "schedules", None),
+            at=args.at,
+            zones=getattr(data, "zones", None),
+        )
+        detail, explanation = evaluator.evaluate_explain(
+            parse_network(args.src),
+            parse_network(args.dst),
+            protocol,
+            port,
+            args.src_port,
+            ingress=args.ingress,
+            egress=args.egress,
+        )
+        for check in explanation.checks:
+            print(format_check(check))
+        deciding = f" by policy {detail.matched_policy_id}" if detail.matched_policy_id else ""
+        print(f"decision: {detail.decision.value} ({detail.reason}){deciding}")
+        return
+    candidates = find_candidate_rules(
+        data.policies, data.address_book, data.service_book, query, include_disabled=args.include_disabled
+    )
+    if args.out:
+        write_candidate_rules(Path(args.out), candidates)
+    for candidate in candidates:
+        policy = candidate.policy
+        scope = (
+            f"{';'.join(map(str, candidate.shared.source))} -> {';'.join(map(str, candidate.shared.destination))} "
+            f"{format_services(candidate.shared.services)}"
+            if candidate.shared
+            else "(uses FQDN, identity or ISDB objects; not narrowed)"
+        )
+        print(f"policy {policy.policy_id} ({policy.name}) {policy.action}: {scope}")
+    if not candidates:
+        print("no matching policies")
 
 
-def main() -> None:
+def _run_diff(argv: list[str]) -> None:
+    """Compare two configs by their parsed policies and objects rather than their text."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer diff",
+        description="Report policies and objects added, removed or changed between two configs",
+    )
+    parser.add_argument("before", help="Earlier configuration file")
+    parser.add_argument("after", help="Later configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of both configs"
+    )
+    parser.add_argument("--out", help="Also write the differences to CSV")
+    args = parser.parse_args(argv)
+
+    try:
+        configs = []
+        for path in (args.before, args.after):
+            with Path(path).open(encoding="utf-8") as handle:
+                configs.append(CONFIG_PROVIDERS[args.provider](handle.readlines()))
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    before, after = configs
+    changes = diff_configs(
+        before.policies,
+        before.address_book,
+        before.service_book,
+        after.policies,
+        after.address_book,
+        after.service_book,
+    )
+    if args.out:
+        write_config_diff(Path(args.out), changes)
+    for change in changes:
+        print(f"{change.change} {change.kind} {change.name}" + (f": {change.detail}" if change.detail else ""))
+    if not changes:
+        print("no differences")
+
+
+def _run_coverage(argv: list[str]) -> None:
+    """Report how much of the requested source × destination space each policy decides."""
+    parser = argparse.ArgumentParser(
+        prog="static-traffic-analyzer coverage",
+        description="Compute per-policy coverage of the input CIDRs and split the space into allowed and denied parts",
+    )
+    parser.add_argument("--config", required=True, help="Rule configuration file")
+    parser.add_argument(
+        "--provider", choices=sorted(CONFIG_PROVIDERS), default="fortigate", help="Vendor format of --config"
+    )
+    parser.add_argument("--src-csv", required=True, help="Source CSV")
+    parser.add_argument("--dst-csv", required=True, help="Destination CSV")
+    parser.add_argument("--ports", required=True, help="Ports file")
+    parser.add_argument("--policy-out", help="CSV of the fraction of each input CIDR every policy covers")
+    parser.add_argument("--space-out", help="CSV of the allowed, denied and implicitly denied regions")
+    args = parser.parse_args(argv)
+
+    try:
+        if not (args.policy_out or args.space_out):
+            raise ParseError("Specify --policy-out, --space-out, or both")
+        with Path(args.config).open(encoding="utf-8") as handle:
+            data = CONFIG_PROVIDERS[args.provider](handle.readlines())
+        src_networks = [
+            parse_network(record["Network Segment"])
+            for record in _load_csv_networks(Path(args.src_csv), "Network Segment")
+        ]
+        dst_networks = [
+            parse_network(record["Network Segment"])
+            for record in _load_csv_networks(Path(args.dst_csv), "Network Segment")
+        ]
+        ports = list(_iter_ports(Path(args.ports)))
+    except (OSError, ParseError) as exc:
+        raise SystemExit(str(exc)) from exc
+    if args.policy_out:
+        write_policy_coverage(
+            Path(args.policy_out),
+            policy_coverage(data.policies, data.address_book, data.service_book, src_networks, dst_networks),
+        )
+    if args.space_out:
+        write_space_coverage(
+            Path(args.space_out),
+            space_coverage(data.policies, data.address_book, data.service_book, src_networks, dst_networks, ports),
+        )
+
+
+SUBCOMMANDS = {
+    "consolidate": _run_consolidate,
+    "coverage": _run_coverage,
+    "db-check": _run_db_check,
+    "diff": _run_diff,
+    "overlap": _run_overlap,
+    "query": _run_query,
+    "shadow": _run_shadow,
+}
+
+
+def main(argv: list[str] | None = None) -> None:
     """CLI entrypoint."""
+    argv = sys.argv[1:] if argv is None else argv
+    if argv and argv[0] in SUBCOMMANDS:
+        SUBCOMMANDS[argv[0]](argv[1:])
+        return
+
     parser = argparse.ArgumentParser(description="Static Traffic Analyzer")
-    parser.add_argument("--config", help="FortiGate CLI config file")
+    parser.add_argument("--config", help="Firewall configuration file (FortiGate CLI unless --provider is given)")
+    parser.add_argument(
+        "--provider",
+        choices=sorted([*CONFIG_PROVIDERS, *DIRECTORY_PROVIDERS]),
+        default="fortigate",
+        help="Vendor format of the --config file (a directory of CSV files for csv)",
+    )
+    parser.add_argument(
+        "--strict-parse",
+        action="store_true",
+        help="Fail on unrecognized or malformed FortiGate config lines, listing each with its line number",
+    )
     parser.add_argument("--excel", help="Excel rules workbook")
     parser.add_argument("--db-conn", help="MariaDB DSN")
+    parser.add_argument("--sqlite", help="SQLite database file with the same cfg_* tables as --db-conn")
+    parser.add_argument("--fortigate-api", help="FortiGate base URL to read rules from the REST API")
+    parser.add_argument(
+        "--api-token",
+        default=os.environ.get("FORTIGATE_API_TOKEN"),
+        help="REST API token for --fortigate-api (default: $FORTIGATE_API_TOKEN)",
+    )
+    parser.add_argument("--api-vdom", help="VDOM to read with --fortigate-api (default: the token's VDOM)")
+    parser.add_argument("--api-ca-file", help="CA bundle used to verify the FortiGate certificate")
+    parser.add_argument("--api-insecure", action="store_true", help="Skip TLS certificate verification")
+    parser.add_argument("--ssh-host", help="FortiGate to fetch `show full-configuration` from over SSH")
+    parser.add_argument("--ssh-port", type=int, default=22, help="SSH port for --ssh-host")
+    parser.add_argument("--ssh-user", help="SSH username for --ssh-host")
+    parser.add_argument("--ssh-key", help="Private key file for --ssh-host")
+    parser.add_argument(
+        "--ssh-password",
+        default=os.environ.get("FORTIGATE_SSH_PASSWORD"),
+        help="SSH password for --ssh-host (default: $FORTIGATE_SSH_PASSWORD)",
+    )
+    parser.add_argument(
+        "--ssh-accept-unknown-host",
+        action="store_true",
+        help="Trust a host key missing from known_hosts instead of refusing to connect",
+    )
+    parser.add_argument(
+        "--next-hop-config",
+        action="append",
+        default=[],
+        help=(
+            "FortiGate config of a firewall traversed after the first; repeat in path order. Each hop sees the "
+            "flow after the previous hop's VIP and SNAT translations"
+        ),
+    )
+    parser.add_argument(
+        "--topology",
+        help="CSV of device,config,networks; evaluate each flow across the firewalls between its segments",
+    )
+    parser.add_argument(
+        "--vdom",
+        help="Evaluate against this VDOM of a multi-VDOM FortiGate --config instead of all VDOMs merged",
+    )
+    parser.add_argument(
+        "--vdom-links",
+        action="store_true",
+        help=(
+            "With --vdom, also evaluate each flow through every VDOM on its path across inter-VDOM links, "
+            "from the VDOM columns of the source/destination CSVs (default --vdom)"
+        ),
+    )
     parser.add_argument("--src-csv", required=True, help="Source CIDR list CSV")
     parser.add_argument("--dst-csv", required=True, help="Destination CIDR list CSV")
     parser.add_argument("--ports", required=True, help="Ports list file")
-    parser.add_argument("--out", required=True, help="Output CSV path")
+    parser.add_argument(
+        "--threat-feed",
+        help="File of known-bad IPs/CIDRs (one per line) to evaluate as extra, tagged sources",
+    )
+    parser.add_argument(
+        "--out",
+        help="Output CSV (or --format) path; a .db, .sqlite or .sqlite3 path is written as a SQLite database",
+    )
+    parser.add_argument("--out-dir", help="Write results partitioned by decision into sharded files here")
+    parser.add_argument(
+        "--shard-size",
+        type=int,
+        default=DEFAULT_SHARD_SIZE,
+        help="Rows per shard file with --out-dir",
+    )
+    parser.add_argument("--compress", action="store_true", help="Gzip shard files written with --out-dir")
+    parser.add_argument(
+        "--format",
+        choices=OUTPUT_FORMATS,
+        help="Result format for --out and --out-dir: CSV (default), or JSON Lines with one object per flow",
+    )
     parser.add_argument("--ignore-schedule", action="store_true", help="Ignore policy schedules")
+    parser.add_argument(
+        "--at",
+        type=_parse_at,
+        help="Evaluate schedules at this RFC3339 time (firewall local wall-clock); without it only 'always' is active",
+    )
+    parser.add_argument(
+        "--sweep",
+        nargs=2,
+        type=_parse_at,
+        metavar=("FROM", "TO"),
+        help="Also evaluate every flow from FROM to TO (RFC3339) and report decisions that change with schedules",
+    )
+    parser.add_argument("--sweep-step", default="1h", help="Interval between --sweep evaluations (e.g. 15m, 1h, 1d)")
+    parser.add_argument("--sweep-out", help="CSV written by --sweep listing each schedule-driven decision change")
+    parser.add_argument(
+        "--what-if",
+        metavar="PATCH",
+        help="FortiGate CLI fragment adding, replacing (edit) or removing (delete) policies to compare against",
+    )
+    parser.add_argument("--what-if-out", help="CSV written by --what-if listing only the flows whose decision changes")
     parser.add_argument(
         "--match-mode",
-        choices=["segment", "sample-ip", "expand"],
-        default="segment",
-        help="Address match mode",
+        choices=["segment", "sample-ip", "expand", "exact"],
+        help=(
+            "Address match mode (default: segment); exact splits segments at policy address boundaries "
+            "into uniform sub-ranges"
+        ),
     )
     parser.add_argument("--max-hosts", type=int, default=256, help="Max hosts for expand mode")
+    parser.add_argument(
+        "--expand-src",
+        action="store_true",
+        help="Expand source segments host by host; unexpanded dimensions are sampled",
+    )
+    parser.add_argument(
+        "--expand-dst",
+        action="store_true",
+        help="Expand destination segments host by host; unexpanded dimensions are sampled",
+    )
+    parser.add_argument("--resolve-fqdn", action="store_true", help="Resolve FQDN address objects via DNS")
+    parser.add_argument(
+        "--hosts-file",
+        help="/etc/hosts entries for FQDN and wildcard-FQDN objects; without --resolve-fqdn unlisted names never match",
+    )
+    parser.add_argument("--dns-timeout", type=float, default=2.0, help="Seconds to wait for each FQDN lookup")
+    parser.add_argument("--dns-budget", type=float, help="Total seconds allowed for all FQDN lookups")
+    parser.add_argument(
+        "--dns-negative-ttl",
+        type=float,
+        default=60.0,
+        help="Seconds to remember failed or timed-out lookups",
+    )
+    parser.add_argument(
+        "--geoip-db",
+        help="GeoIP database for geography address objects: MaxMind .mmdb or a network,country CSV",
+    )
+    parser.add_argument(
+        "--local-in",
+        action="store_true",
+        help="Evaluate flows to the firewall's own interface addresses against local-in policies and allowaccess",
+    )
+    parser.add_argument(
+        "--routing",
+        action="store_true",
+        help="Route IPv4 flows via policy routes and the routing table; flows with no route are UNROUTABLE",
+    )
+    parser.add_argument(
+        "--rpf-check",
+        action="store_true",
+        help=(
+            "With --routing, mark flows UNROUTABLE when strict reverse-path forwarding would drop their source: "
+            "no route back, or the route back leaves by another interface than the source CSV's Interface column"
+        ),
+    )
+    parser.add_argument(
+        "--match-interfaces",
+        action="store_true",
+        help=(
+            "Skip policies whose srcintf/dstintf do not include the flow's interfaces, taken from an Interface "
+            "column (interface or zone name) in the source/destination CSVs or, with --routing, the routes"
+        ),
+    )
+    parser.add_argument(
+        "--dynamic-map",
+        help="JSON mapping of dynamic (SDN connector) object names to their current addresses",
+    )
+    parser.add_argument(
+        "--mac-map",
+        help="CSV of ip,mac pairs so MAC address objects can match source addresses",
+    )
+    parser.add_argument(
+        "--identity-map",
+        help="CSV of ip,user,groups so policies naming users or groups can match source addresses",
+    )
+    parser.add_argument(
+        "--isdb-map",
+        help="CSV of name,network,protocol,ports mapping Internet Service Database entries used by policies",
+    )
+    parser.add_argument("--min-src-prefix", type=int, help="Reject source CIDRs broader than this prefix")
+    parser.add_argument("--min-dst-prefix", type=int, help="Reject destination CIDRs broader than this prefix")
+    parser.add_argument(
+        "--warn-broad-inputs",
+        action="store_true",
+        help="Only warn when inputs exceed --min-src-prefix/--min-dst-prefix",
+    )
+    parser.add_argument(
+        "--session-columns",
+        action="store_true",
+        help="Add matched policy session handling flags (tcp-session-without-syn, anti-replay, session-ttl)",
+    )
+    parser.add_argument(
+        "--raw-ref-columns",
+        action="store_true",
+        help="Add the matched policy's srcaddr/dstaddr/service object names before group flattening",
+    )
+    parser.add_argument(
+        "--interface-columns",
+        action="store_true",
+        help="Add the matched policy's srcintf/dstintf",
+    )
+    parser.add_argument(
+        "--comment-columns",
+        action="store_true",
+        help="Add the matched policy's comments and UUID",
+    )
+    parser.add_argument(
+        "--utm-columns",
+        action="store_true",
+        help="Add the AV, IPS, web filter and SSL inspection profiles of the matched policy",
+    )
+    parser.add_argument(
+        "--section-columns",
+        action="store_true",
+        help="Add the GUI section (global-label) of the matched policy",
+    )
+    parser.add_argument(
+        "--nat-columns",
+        action="store_true",
+        help="Annotate allowed flows with the source NAT (central SNAT rule or policy IP pool) and translated source",
+    )
+    parser.add_argument(
+        "--nat-pipeline",
+        action="store_true",
+        help=(
+            "Apply VIP DNAT before the policy lookup (central NAT) and SNAT after it, "
+            "adding the original and translated flow and the NAT steps taken"
+        ),
+    )
+    parser.add_argument(
+        "--dos-columns",
+        action="store_true",
+        help="Add the DoS policy (if any) each flow also traverses, for capacity and abuse reviews",
+    )
+    parser.add_argument(
+        "--dnat-columns",
+        action="store_true",
+        help="Annotate allowed flows sent to a VIP with the translated destination address and port",
+    )
+    parser.add_argument(
+        "--match-all",
+        action="store_true",
+        help="List every policy matching each flow in priority order, not just the first",
+    )
+    parser.add_argument(
+        "--bidirectional",
+        action="store_true",
+        help="Also evaluate each flow's return direction (dst to src) and flag flows allowed one way only",
+    )
+    parser.add_argument(
+        "--return-port",
+        type=int,
+        help=(
+            "With --bidirectional, send return flows from the service port to this client port "
+            "(e.g. 49152 for an ephemeral port) instead of reusing the service"
+        ),
+    )
+    parser.add_argument(
+        "--near-miss-columns",
+        action="store_true",
+        help="List accept policies that match two of src/dst/service for each denied flow",
+    )
+    parser.add_argument(
+        "--src-metadata",
+        action="store_true",
+        help="Copy extra source CSV columns into the output as src_<column>",
+    )
+    parser.add_argument(
+        "--metadata-collision",
+        choices=METADATA_COLLISION_POLICIES,
+        default="reject",
+        help=(
+            "What to do when a --src-metadata column would overwrite another output column: "
+            "reject the run, or prefix it as src_meta_<column>"
+        ),
+    )
+    parser.add_argument("--audit-out", help="Write static policy audit findings to CSV")
+    parser.add_argument("--service-matrix", help="Write allowed segment pairs grouped by service label to CSV")
+    parser.add_argument(
+        "--logging-report",
+        help="Write allowed flows matched by policies with logtraffic disabled to CSV",
+    )
+    parser.add_argument("--section-report", help="Write policy and matched flow counts per GUI section to CSV")
+    parser.add_argument(
+        "--least-privilege-report",
+        help="Write narrower replacements for broad accept policies, covering only the flows they allowed, to CSV",
+    )
+    parser.add_argument(
+        "--policy-stats",
+        help="Write per-policy counts of simulated flows matched, allowed, denied and unknown to CSV",
+    )
+    parser.add_argument(
+        "--dead-rule-report",
+        help="Write enabled policies that matched no simulated flow to CSV, marking those shadowed by earlier policies",
+    )
+    parser.add_argument(
+        "--traffic-log",
+        help="FortiGate/FortiAnalyzer traffic log export (key=value lines or CSV); adds observed policy hit counts",
+    )
+    parser.add_argument(
+        "--hit-count-report",
+        help="Write observed and simulated hits per policy to CSV, flagging enabled policies absent from --traffic-log",
+    )
+    parser.add_argument("--denylist", help="CSV of flows that must never be allowed; fail if any are")
+    parser.add_argument(
+        "--compare-golden",
+        help="Compare results against a known-good results CSV and fail on any difference",
+    )
+    parser.add_argument("--metrics-out", help="Write OpenMetrics run statistics to this file")
+    parser.add_argument(
+        "--metrics-interval",
+        type=int,
+        default=1000,
+        help="Refresh the metrics file every N evaluated flows",
+    )
+    parser.add_argument(
+        "--aggregate",
+        action="store_true",
+        help="Collapse identical result rows into one with a flow_count column before writing",
+    )
+    parser.add_argument(
+        "--anonymize", action="store_true", help="Pseudonymize network segments and NAT addresses in output"
+    )
+    parser.add_argument("--anon-key", help="Secret key for reproducible anonymization")
+    parser.add_argument(
+        "--anon-redact-metadata",
+        action="store_true",
+        help="Blank destination GN/Site/Location when anonymizing",
+    )
 
-    args = parser.parse_args()
+    args = parser.parse_args(argv)
 
     try:
-        _select_rule_source(args.config, args.excel, args.db_conn)
+        _select_rule_source(
+            args.config, args.excel, args.db_conn, args.fortigate_api, args.ssh_host, args.sqlite
+        )
+        if args.ssh_host and not args.ssh_user:
+            raise ParseError("--ssh-host requires --ssh-user")
+        if args.hosts_file and not Path(args.hosts_file).is_file():
+            raise ParseError(f"Hosts file not found: {args.hosts_file}")
+        if args.fortigate_api and not args.api_token:
+            raise ParseError("--fortigate-api requires --api-token or FORTIGATE_API_TOKEN")
+        if not (args.out or args.out_dir):
+            raise ParseError("Specify --out, --out-dir, or both")
+        if args.format and not args.out_dir and Path(args.out).suffix.lower() in SQLITE_SUFFIXES:
+            raise ParseError(f"--format does not apply to the SQLite output {args.out}")
+        output_format = args.format or "csv"
+        if args.shard_size < 1:
+            raise ParseError("--shard-size must be at least 1")
+        if args.anonymize and not args.anon_key:
+            raise ParseError("--anonymize requires --anon-key")
+        if args.anonymize and args.least_privilege_report:
+            # Suggestions name real addresses, which would undo the anonymization.
+            raise ParseError("--least-privilege-report cannot be combined with --anonymize")
+        if args.metrics_interval < 1:
+            raise ParseError("--metrics-interval must be at least 1")
+        if args.strict_parse and not (args.config and args.provider == "fortigate"):
+            raise ParseError("--strict-parse applies to FortiGate --config files")
+        sweep_step = parse_step(args.sweep_step)
+        if args.sweep and not args.sweep_out:
+            raise ParseError("--sweep requires --sweep-out")
+        if args.sweep and args.ignore_schedule:
+            raise ParseError("--sweep cannot be combined with --ignore-schedule")
+        if args.return_port is not None and not args.bidirectional:
+            raise ParseError("--return-port requires --bidirectional")
+        if args.return_port is not None and not 1 <= args.return_port <= 65535:
+            raise ParseError("--return-port must be between 1 and 65535")
+        if args.vdom and not (args.config and args.provider == "fortigate"):
+            raise ParseError("--vdom applies to FortiGate --config files")
+        if args.vdom_links and not args.vdom:
+            raise ParseError("--vdom-links requires --vdom")
+        if args.rpf_check and not args.routing:
+            raise ParseError("--rpf-check requires --routing")
+        if args.hit_count_report and not args.traffic_log:
+            raise ParseError("--hit-count-report requires --traffic-log")
+        if bool(args.what_if) != bool(args.what_if_out):
+            raise ParseError("--what-if and --what-if-out must be given together")
+        if args.what_if:
+            with Path(args.what_if).open(encoding="utf-8") as handle:
+                patch = parse_policy_patch(handle.readlines(), source=args.what_if)
 
-        if args.config:
+        vdoms = None
+        if args.vdom:
+            with Path(args.config).open(encoding="utf-8") as handle:
+                vdom_config = parse_vdom_config(handle.readlines(), strict=args.strict_parse, source=args.config)
+            if args.vdom not in vdom_config.vdoms:
+                raise ParseError(f"VDOM {args.vdom} not found; config has {', '.join(vdom_config.vdoms)}")
+            for warning in vdom_config.warnings:
+                print(f"WARNING: {warning}", file=sys.stderr)
+            data = vdom_config.vdoms[args.vdom]
+            vdoms = vdom_config if args.vdom_links else None
+        elif args.config and args.provider in DIRECTORY_PROVIDERS:
+            data = DIRECTORY_PROVIDERS[args.provider](Path(args.config))
+        elif args.config and args.strict_parse:
             with Path(args.config).open(encoding="utf-8") as handle:
-                data = parse_fortigate_config(handle.readlines())
+                data = parse_fortigate_config(handle.readlines(), strict=True, source=args.config)
+        elif args.config:
+            with Path(args.config).open(encoding="utf-8") as handle:
+                data = CONFIG_PROVIDERS[args.provider](handle.readlines())
         elif args.excel:
             data = parse_excel(args.excel)
+        elif args.sqlite:
+            data = parse_sqlite(args.sqlite)
+        elif args.fortigate_api:
+            data = parse_fortigate_api(
+                APIOptions(
+                    base_url=args.fortigate_api,
+                    token=args.api_token,
+                    verify_tls=not args.api_insecure,
+                    ca_file=args.api_ca_file,
+                    vdom=args.api_vdom,
+                )
+            )
+        elif args.ssh_host:
+            data = parse_fortigate_ssh(
+                SSHOptions(
+                    host=args.ssh_host,
+                    username=args.ssh_user,
+                    port=args.ssh_port,
+                    key_file=args.ssh_key,
+                    password=args.ssh_password,
+                    accept_unknown_host=args.ssh_accept_unknown_host,
+                )
+            )
         else:
             data = parse_database(args.db_conn)
+        for warning in getattr(data, "warnings", []):
+            print(f"WARNING: {warning}", file=sys.stderr)
+        if args.dynamic_map:
+            apply_dynamic_map(data.address_book, load_dynamic_map(Path(args.dynamic_map)))
+
+        if args.audit_out:
+            findings = audit_policies(data.policies, data.address_book, data.service_book)
+            write_audit(Path(args.audit_out), findings)
+            for finding in findings:
+                if finding.severity == Severity.CRITICAL:
+                    print(
+                        f"CRITICAL: policy {finding.policy_id} ({finding.policy_name}) {finding.check}: "
+                        f"{finding.detail}",
+                        file=sys.stderr,
+                    )
 
         src_records = _load_csv_networks(Path(args.src_csv), "Network Segment")
         dst_records = _load_csv_networks(Path(args.dst_csv), "Network Segment")
+        _check_prefix_guard(src_records, args.min_src_prefix, "Source", args.warn_broad_inputs)
+        _check_prefix_guard(dst_records, args.min_dst_prefix, "Destination", args.warn_broad_inputs)
         ports = list(_iter_ports(Path(args.ports)))
 
         output_rows: list[dict[str, str | int | None]] = []
-        match_mode = MatchMode(mode=args.match_mode, max_hosts=args.max_hosts)
-
-        for src_record in src_records:
-            src_network = parse_ipv4_network(src_record["Network Segment"])
-            for dst_record in dst_records:
-                dst_network = parse_ipv4_network(dst_record["Network Segment"])
-                for port_spec in ports:
-                    match = evaluate_policy(
-                        policies=data.policies,
-                        address_book=data.address_book,
-                        service_book=data.service_book,
-                        src_network=src_network,
-                        dst_network=dst_network,
-                        protocol=port_spec.protocol,
-                        port=port_spec.port,
-                        match_mode=match_mode,
-                        ignore_schedule=args.ignore_schedule,
-                    )
-                    output_rows.append(
-                        {
-                            "src_network_segment": str(src_network),
-                            "dst_network_segment": str(dst_network),
-                            "dst_gn": dst_record.get("GN") or "",
-                            "dst_site": dst_record.get("Site") or "",
-                            "dst_location": dst_record.get("Location") or "",
-                            "service_label": port_spec.label,
-                            "protocol": port_spec.protocol.value,
-                            "port": port_spec.port,
-                            "decision": match.decision.value,
-                            "matched_policy_id": match.matched_policy_id or "",
-                            "matched_policy_name": match.matched_policy_name or "",
-                            "matched_policy_action": match.matched_policy_action or "",
-                            "reason": match.reason,
-                        }
+        metrics = RunMetrics() if args.metrics_out else None
+        extra_fields = list(SESSION_FIELDS) if args.session_columns else []
+        if args.raw_ref_columns:
+            extra_fields.extend(RAW_REFERENCE_FIELDS)
+        if args.interface_columns:
+            extra_fields.extend(INTERFACE_FIELDS)
+        if args.comment_columns:
+            extra_fields.extend(COMMENT_FIELDS)
+        if args.utm_columns:
+            extra_fields.extend(UTM_FIELDS)
+        if args.section_columns:
+            extra_fields.extend(SECTION_FIELDS)
+        if args.routing:
+            extra_fields.extend(ROUTE_FIELDS)
+        if args.dnat_columns:
+            extra_fields.extend(DNAT_FIELDS)
+        if args.dos_columns:
+            extra_fields.extend(DOS_FIELDS)
+        if args.nat_columns:
+            extra_fields.extend(NAT_FIELDS)
+        if args.nat_pipeline:
+            extra_fields.extend(NAT_PATH_FIELDS)
+        if args.match_all:
+            extra_fields.extend(MATCH_ALL_FIELDS)
+        if args.bidirectional:
+            extra_fields.extend(REVERSE_FIELDS)
+        if args.near_miss_columns:
+            extra_fields.extend(NEAR_MISS_FIELDS)
+        hits = load_hit_counts(Path(args.traffic_log)) if args.traffic_log else None
+        if hits is not None:
+            extra_fields.extend(HIT_COUNT_FIELDS)
+        # Metadata columns go here but are named once every other output column is known.
+        metadata_index = len(extra_fields)
+        if args.threat_feed:
+            extra_fields.extend(SOURCE_SET_FIELDS)
+        next_hops = []
+        for hop_path in args.next_hop_config:
+            with Path(hop_path).open(encoding="utf-8") as handle:
+                next_hops.append(
+                    parse_fortigate_config(handle.readlines(), strict=args.strict_parse, source=hop_path)
+                )
+        if next_hops:
+            extra_fields.extend(CHAIN_FIELDS)
+        topology = load_topology(Path(args.topology), strict=args.strict_parse) if args.topology else None
+        if topology is not None:
+            extra_fields.extend(TOPOLOGY_FIELDS)
+        if vdoms is not None:
+            extra_fields.extend(VDOM_FIELDS)
+        match_mode = _build_match_mode(args)
+        if match_mode.mode == "exact":
+            extra_fields.extend(EXACT_FIELDS)
+        if args.aggregate:
+            extra_fields.extend(AGGREGATE_FIELDS)
+        src_metadata = None
+        if args.src_metadata and src_records:
+            src_metadata = metadata_fields(
+                src_records[0].keys(), "src_", [*OUTPUT_FIELDS, *extra_fields], args.metadata_collision
+            )
+            extra_fields[metadata_index:metadata_index] = src_metadata.values()
+
+        threat_feed = _iter_threat_feed(Path(args.threat_feed)) if args.threat_feed else ()
+        geoip = load_geoip(args.geoip_db) if args.geoip_db else None
+        isdb = load_isdb(Path(args.isdb_map)) if args.isdb_map else None
+        mac_map = load_mac_map(Path(args.mac_map)) if args.mac_map else None
+        identities = load_identity_map(Path(args.identity_map)) if args.identity_map else None
+        rows = _iter_rows(
+            args,
+            data,
+            src_records,
+            dst_records,
+            ports,
+            match_mode,
+            threat_feed,
+            next_hops,
+            geoip,
+            isdb,
+            mac_map,
+            identities,
+            topology,
+            vdoms,
+            src_metadata,
+        )
+        for row in rows:
+            if hits is not None:
+                row.update(hit_count_columns(str(row["matched_policy_id"] or ""), hits))
+            output_rows.append(row)
+            if metrics is not None:
+                metrics.record(row)
+                if metrics.flows_processed % args.metrics_interval == 0:
+                    metrics.write(Path(args.metrics_out))
+
+        if metrics is not None:
+            metrics.write(Path(args.metrics_out))
+
+        violations = []
+        if args.denylist:
+            violations = find_denylist_violations(output_rows, load_denylist(Path(args.denylist)))
+        golden_mismatches = []
+        if args.compare_golden:
+            golden_mismatches = compare_golden(output_rows, load_golden(Path(args.compare_golden)))
+
+        if args.anonymize:
+            output_rows = anonymize_rows(
+                output_rows,
+                IPAnonymizer(args.anon_key),
+                redact_metadata=args.anon_redact_metadata,
+            )
+
+        # Reports below still count every evaluated flow; only the written results are collapsed.
+        written_rows = (
+            aggregate_rows(output_rows, [*OUTPUT_FIELDS, *extra_fields]) if args.aggregate else output_rows
+        )
+        if args.out and Path(args.out).suffix.lower() in SQLITE_SUFFIXES:
+            metadata = {
+                "created_at": datetime.now(timezone.utc).isoformat(timespec="seconds"),
+                # Only where the rules came from: DSNs and API tokens stay out of the results file.
+                "rules": args.config or args.excel or args.sqlite or args.fortigate_api or args.ssh_host or "db-conn",
+                "provider": args.provider if args.config else "",
+                "src_csv": args.src_csv,
+                "dst_csv": args.dst_csv,
+                "ports": args.ports,
+                "match_mode": match_mode.mode,
+                "flows": str(len(output_rows)),
+                "aggregated": "yes" if args.aggregate else "no",
+                "anonymized": "yes" if args.anonymize else "no",
+            }
+            write_sqlite_output(Path(args.out), written_rows, extra_fields, metadata)
+        elif args.out:
+            write_output(Path(args.out), written_rows, extra_fields, output_format=output_format)
+        if args.out_dir:
+            write_partitioned_output(
+                Path(args.out_dir),
+                written_rows,
+                extra_fields,
+                shard_size=args.shard_size,
+                compress=args.compress,
+                output_format=output_format,
+            )
+        if args.service_matrix:
+            write_service_matrix(Path(args.service_matrix), build_service_matrix(output_rows))
+        if args.logging_report:
+            write_logging_report(Path(args.logging_report), build_logging_report(output_rows, data.policies))
+        if args.section_report:
+            write_section_report(Path(args.section_report), build_section_report(output_rows, data.policies))
+        if args.least_privilege_report:
+            suggestions = recommend_least_privilege(output_rows, data.policies, data.address_book, data.service_book)
+            write_least_privilege(Path(args.least_privilege_report), suggestions)
+        if args.policy_stats:
+            write_policy_stats(Path(args.policy_stats), build_policy_stats(output_rows, data.policies))
+        if args.dead_rule_report:
+            shadowed = {
+                finding.policy_id: finding.detail
+                for finding in find_shadowed_policies(data.policies, data.address_book, data.service_book)
+            }
+            write_dead_rule_report(
+                Path(args.dead_rule_report), build_dead_rule_report(output_rows, data.policies, shadowed)
+            )
+        if args.hit_count_report:
+            report = build_hit_count_report(output_rows, data.policies, hits)
+            write_hit_count_report(Path(args.hit_count_report), report)
+            for entry in report:
+                if entry["unused"] == "yes":
+                    print(
+                        f"UNUSED: policy {entry['policy_id']} ({entry['policy_name']}) is enabled but has no "
+                        "observed hits",
+                        file=sys.stderr,
                     )
+        # --sweep and --what-if re-evaluate the plain segment pairs without the per-row extras.
+        flows = (
+            [
+                (src_network, dst_network, port_spec)
+                for src_network in (parse_network(record["Network Segment"]) for record in src_records)
+                for dst_network in (parse_network(record["Network Segment"]) for record in dst_records)
+                if src_network.version == dst_network.version
+                for port_spec in ports
+            ]
+            if args.sweep or args.what_if
+            else []
+        )
+        if args.sweep:
+            evaluators = [
+                Evaluator(
+                    data.policies,
+                    data.address_book,
+                    data.service_book,
+                    match_mode,
+                    schedules=getattr(data, "schedules", None),
+                    at=at,
+                    geoip=geoip,
+                    isdb=isdb,
+                    mac_map=mac_map,
+                    identities=identities,
+                )
+                for at in sweep_times(args.sweep[0], args.sweep[1], sweep_step)
+            ]
+            write_schedule_changes(Path(args.sweep_out), find_schedule_changes(evaluators, flows))
+        if args.what_if:
+            before, after = (
+                Evaluator(
+                    policies,
+                    address_book,
+                    service_book,
+                    match_mode,
+                    ignore_schedule=args.ignore_schedule,
+                    schedules=getattr(data, "schedules", None),
+                    at=args.at,
+                    geoip=geoip,
+                    isdb=isdb,
+                    mac_map=mac_map,
+                    identities=identities,
+                )
+                for policies, address_book, service_book in (
+                    (data.policies, data.address_book, data.service_book),
+                    apply_policy_patch(data.policies, data.address_book, data.service_book, patch),
+                )
+            )
+            write_decision_changes(Path(args.what_if_out), find_decision_changes(before, after, flows))
 
-        _write_output(Path(args.out), output_rows)
+        for mismatch in golden_mismatches:
+            print(f"GOLDEN MISMATCH: {mismatch}", file=sys.stderr)
+        for flow, row in violations:
+            print(
+                f"DENYLIST VIOLATION: {flow.describe()} allowed for "
+                f"{row['src_network_segment']} -> {row['dst_network_segment']} "
+                f"{row['port']}/{row['protocol']} by policy {row['matched_policy_id']}",
+                file=sys.stderr,
+            )
+        if violations or golden_mismatches:
+            raise SystemExit(1)
     except ParseError as exc:
         raise SystemExit(str(exc)) from exc
 
@@ -0,0 +1,167 @@
+"""Semantic diff of two parsed configs: policies and objects added, removed or changed, and match-space changes."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass, fields
+from difflib import SequenceMatcher
+from enum import Enum
+from pathlib import Path
+from typing import Iterable, Mapping, Optional, Sequence
+
+from .models import AddressBook, PolicyRule, ServiceBook, ServiceEntry
+from .overlap import PolicySpace, format_services, policy_space, subtract_networks
+
+
+CONFIG_DIFF_FIELDS = ["kind", "name", "change", "detail"]
+
+ADDED = "added"
+REMOVED = "removed"
+CHANGED = "changed"
+MOVED = "moved"
+SPACE_CHANGED = "space-changed"
+
+# Where a policy sits is reported as a move, not as a priority change on every later policy.
+IGNORED_POLICY_FIELDS = ("policy_id", "priority")
+
+
+@dataclass(frozen=True)
+class ConfigChange:
+    """One semantic difference between two configs."""
+
+    kind: str
+    name: str
+    change: str
+    detail: str = ""
+
+
+def _format_value(value: object) -> str:
+    if value is None:
+        return ""
+    if isinstance(value, Enum):
+        return str(value.value)
+    if isinstance(value, ServiceEntry):
+        protocol = value.protocol.value if value.protocol is not None else "all"
+        if value.start_port is None:
+            return protocol
+        ports = str(value.start_port) if value.start_port == value.end_port else f"{value.start_port}-{value.end_port}"
+        return f"{protocol}/{ports}"
+    if isinstance(value, (tuple, list)):
+        return ",".join(_format_value(item) for item in value)
+    return str(value)
+
+
+def _field_changes(before: object, after: object, ignored: Sequence[str] = ("name",)) -> str:
+    changes = []
+    for entry in fields(before):
+        if entry.name in ignored:
+            continue
+        old, new = getattr(before, entry.name), getattr(after, entry.name)
+        if old != new:
+            changes.append(f"{entry.name}: {_format_value(old) or '-'} -> {_format_value(new) or '-'}")
+    return "; ".join(changes)
+
+
+def _diff_named(kind: str, before: Mapping[str, object], after: Mapping[str, object]) -> list[ConfigChange]:
+    changes: list[ConfigChange] = []
+    for name in sorted(before.keys() - after.keys()):
+        changes.append(ConfigChange(kind, name, REMOVED))
+    for name in sorted(after.keys() - before.keys()):
+        changes.append(ConfigChange(kind, name, ADDED))
+    for name in sorted(before.keys() & after.keys()):
+        if before[name] != after[name]:
+            changes.append(ConfigChange(kind, name, CHANGED, _field_changes(before[name], after[name])))
+    return changes
+
+
+def _space_changes(before: Optional[PolicySpace], after: Optional[PolicySpace]) -> str:
+    # Policies on FQDN, ISDB or identity objects have no fixed space to compare.
+    if before is None and after is None:
+        return ""
+    if before is None:
+        return "now resolves to fixed addresses and ports"
+    if after is None:
+        return "no longer resolves to fixed addresses and ports"
+    changes = []
+    for label, old, new in (("src", before.source, after.source), ("dst", before.destination, after.destination)):
+        gained = subtract_networks(new, old)
+        lost = subtract_networks(old, new)
+        if gained:
+            changes.append(f"{label} +{';'.join(map(str, gained))}")
+        if lost:
+            changes.append(f"{label} -{';'.join(map(str, lost))}")
+    if before.services != after.services:
+        changes.append(f"services {format_services(before.services)} -> {format_services(after.services)}")
+    return "; ".join(changes)
+
+
+def diff_configs(
+    before_policies: Sequence[PolicyRule],
+    before_addresses: AddressBook,
+    before_services: ServiceBook,
+    after_policies: Sequence[PolicyRule],
+    after_addresses: AddressBook,
+    after_services: ServiceBook,
+) -> list[ConfigChange]:
+    """Compare two configs object by object and policy by policy.
+
+    Policies are matched by ID. Besides field changes, a policy whose place in
+    the evaluation order differs is reported as moved, and one whose effective
+    match space changed, whether through its own fields or through an edited
+    object it references, as space-changed with the addresses gained and lost.
+    """
+    changes: list[ConfigChange] = []
+    for kind, before, after in (
+        ("address", before_addresses.objects, after_addresses.objects),
+        ("address6", before_addresses.objects6, after_addresses.objects6),
+        ("addrgrp", before_addresses.groups, after_addresses.groups),
+        ("addrgrp6", before_addresses.groups6, after_addresses.groups6),
+        ("vip", before_addresses.vips, after_addresses.vips),
+        ("service", before_services.services, after_services.services),
+        ("service-group", before_services.groups, after_services.groups),
+    ):
+        changes.extend(_diff_named(kind, before, after))
+
+    old = {policy.policy_id: policy for policy in before_policies}
+    new = {policy.policy_id: policy for policy in after_policies}
+    for policy in before_policies:
+        if policy.policy_id not in new:
+            changes.append(ConfigChange("policy", policy.policy_id, REMOVED, policy.name))
+    for policy in after_policies:
+        if policy.policy_id not in old:
+            changes.append(ConfigChange("policy", policy.policy_id, ADDED, policy.name))
+
+    old_order = [policy.policy_id for policy in before_policies if policy.policy_id in new]
+    new_order = [policy.policy_id for policy in after_policies if policy.policy_id in old]
+    matcher = SequenceMatcher(a=old_order, b=new_order, autojunk=False)
+    # Policies outside the longest common ordering are the ones that moved.
+    kept = {
+        policy_id for block in matcher.get_matching_blocks() for policy_id in new_order[block.b : block.b + block.size]
+    }
+    for index, policy_id in enumerate(new_order):
+        before, after = old[policy_id], new[policy_id]
+        if policy_id not in kept:
+            after_id = new_order[index - 1] if index else None
+            changes.append(
+                ConfigChange("policy", policy_id, MOVED, f"now after policy {after_id}" if after_id else "now first")
+            )
+        detail = _field_changes(before, after, IGNORED_POLICY_FIELDS)
+        if detail:
+            changes.append(ConfigChange("policy", policy_id, CHANGED, detail))
+        space = _space_changes(
+            policy_space(before, before_addresses, before_services),
+            policy_space(after, after_addresses, after_services),
+        )
+        if space:
+            changes.append(ConfigChange("policy", policy_id, SPACE_CHANGED, space))
+    return changes
+
+
+def write_config_diff(output_path: Path, changes: Iterable[ConfigChange]) -> None:
+    """Write config differences as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=CONFIG_DIFF_FIELDS)
+        writer.writeheader()
+        for change in changes:
+            writer.writerow(
+                {"kind": change.kind, "name": change.name, "change": change.change, "detail": change.detail}
+            )
@@ -0,0 +1,189 @@
+"""Rule consolidation: runs of adjacent policies that could be merged into one without changing any decision."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass, replace
+from pathlib import Path
+from typing import Iterable, Optional, Sequence
+
+from .models import AddressBook, PolicyRule, ServiceBook
+from .overlap import PolicySpace, collapse_networks, format_services, merge_ranges, policy_space
+
+
+CONSOLIDATION_FIELDS = [
+    "policy_ids",
+    "action",
+    "dimension",
+    "reason",
+    "proposed_srcaddr",
+    "proposed_dstaddr",
+    "proposed_service",
+    "merged_source",
+    "merged_destination",
+    "merged_services",
+]
+
+SIBLING_CIDRS = "sibling-cidrs"
+CONTIGUOUS_PORTS = "contiguous-ports"
+SAME_OTHER_DIMENSIONS = "same-other-dimensions"
+
+
+@dataclass(frozen=True)
+class Consolidation:
+    """Adjacent policies differing in one dimension only, and the single policy that could replace them.
+
+    ``dimension`` is `src`, `dst` or `service`. ``reason`` tells whether the
+    merged dimension also collapses into fewer blocks or ranges (sibling CIDRs,
+    contiguous ports) or only the object lists are combined.
+    """
+
+    policies: tuple[PolicyRule, ...]
+    dimension: str
+    reason: str
+    merged: PolicySpace
+
+    def _names(self, attributes: Sequence[str]) -> tuple[str, ...]:
+        names: list[str] = []
+        for policy in self.policies:
+            for attribute in attributes:
+                names.extend(name for name in getattr(policy, attribute) if name not in names)
+        return tuple(names)
+
+    @property
+    def source_names(self) -> tuple[str, ...]:
+        return self._names(("source", "source6"))
+
+    @property
+    def destination_names(self) -> tuple[str, ...]:
+        return self._names(("destination", "destination6"))
+
+    @property
+    def service_names(self) -> tuple[str, ...]:
+        return self._names(("services",))
+
+
+def _template(policy: PolicyRule) -> PolicyRule:
+    # Everything but identity, position, addresses, services and free text must match for a merge.
+    return replace(
+        policy,
+        policy_id="",
+        name="",
+        priority=0,
+        source=(),
+        destination=(),
+        services=(),
+        source6=(),
+        destination6=(),
+        comment=None,
+        uuid=None,
+    )
+
+
+def _differing_dimension(first: PolicySpace, second: PolicySpace) -> Optional[str]:
+    differing = [
+        dimension
+        for dimension, same in (
+            ("src", first.source == second.source),
+            ("dst", first.destination == second.destination),
+            ("service", first.services == second.services),
+        )
+        if not same
+    ]
+    return differing[0] if len(differing) == 1 else None
+
+
+def _merge(first: PolicySpace, second: PolicySpace, dimension: str) -> PolicySpace:
+    if dimension == "src":
+        return replace(first, source=collapse_networks([*first.source, *second.source]))
+    if dimension == "dst":
+        return replace(first, destination=collapse_networks([*first.destination, *second.destination]))
+    if None in first.services or None in second.services:
+        return replace(first, services={None: ((0, 65535),)})
+    protocols = [*first.services, *(protocol for protocol in second.services if protocol not in first.services)]
+    return replace(
+        first,
+        services={
+            protocol: merge_ranges([*first.services.get(protocol, ()), *second.services.get(protocol, ())])
+            for protocol in protocols
+        },
+    )
+
+
+def _reason(spaces: Sequence[PolicySpace], merged: PolicySpace, dimension: str) -> str:
+    if dimension == "service":
+        before = sum(len(ranges) for space in spaces for ranges in space.services.values())
+        after = sum(len(ranges) for ranges in merged.services.values())
+        return CONTIGUOUS_PORTS if after < before else SAME_OTHER_DIMENSIONS
+    attribute = "source" if dimension == "src" else "destination"
+    before = sum(len(getattr(space, attribute)) for space in spaces)
+    return SIBLING_CIDRS if len(getattr(merged, attribute)) < before else SAME_OTHER_DIMENSIONS
+
+
+def find_consolidations(
+    policies: Iterable[PolicyRule], address_book: AddressBook, service_book: ServiceBook
+) -> list[Consolidation]:
+    """Return runs of adjacent enabled policies that one policy could replace.
+
+    Policies in a run share action, interfaces, schedule, NAT, profiles and
+    every other setting, and differ from each other in exactly one of
+    source, destination or service. Only adjacent policies are merged, since
+    merging across another policy could change which one decides a flow.
+    Disabled policies never match and do not break a run; policies that
+    depend on more than plain addresses and ports do.
+    """
+    consolidations: list[Consolidation] = []
+    run: list[tuple[PolicyRule, PolicySpace]] = []
+    merged: Optional[PolicySpace] = None
+    dimension: Optional[str] = None
+
+    def close() -> None:
+        if len(run) > 1 and merged is not None and dimension is not None:
+            spaces = [space for _, space in run]
+            consolidations.append(
+                Consolidation(
+                    policies=tuple(policy for policy, _ in run),
+                    dimension=dimension,
+                    reason=_reason(spaces, merged, dimension),
+                    merged=merged,
+                )
+            )
+
+    for policy in policies:
+        if not policy.enabled:
+            continue
+        space = policy_space(policy, address_book, service_book)
+        if run and space is not None and _template(run[0][0]) == _template(policy):
+            # Every policy in a run shares the two dimensions that are not being merged.
+            differing = _differing_dimension(run[0][1], space)
+            if differing is not None and dimension in (None, differing):
+                dimension = differing
+                merged = _merge(merged or run[0][1], space, differing)
+                run.append((policy, space))
+                continue
+        close()
+        run = [(policy, space)] if space is not None else []
+        merged, dimension = None, None
+    close()
+    return consolidations
+
+
+def write_consolidations(output_path: Path, consolidations: Iterable[Consolidation]) -> None:
+    """Write consolidation suggestions and the proposed merged policies as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=CONSOLIDATION_FIELDS)
+        writer.writeheader()
+        for consolidation in consolidations:
+            writer.writerow(
+                {
+                    "policy_ids": ";".join(policy.policy_id for policy in consolidation.policies),
+                    "action": consolidation.policies[0].action,
+                    "dimension": consolidation.dimension,
+                    "reason": consolidation.reason,
+                    "proposed_srcaddr": ",".join(consolidation.source_names),
+                    "proposed_dstaddr": ",".join(consolidation.destination_names),
+                    "proposed_service": ",".join(consolidation.service_names),
+                    "merged_source": ";".join(str(block) for block in consolidation.merged.source),
+                    "merged_destination": ";".join(str(block) for block in consolidation.merged.destination),
+                    "merged_services": format_services(consolidation.merged.services),
+                }
+            )
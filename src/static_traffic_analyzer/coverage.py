@@ -0,0 +1,202 @@
+"""Address-space coverage: how much of the requested source and destination space each policy decides."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Iterable, Optional, Sequence
+
+from .models import AddressBook, PolicyRule, ServiceBook
+from .overlap import Network, PolicySpace, intersect_networks, policy_space, subtract_networks
+from .utils import PortSpec
+
+
+POLICY_COVERAGE_FIELDS = [
+    "policy_id",
+    "policy_name",
+    "side",
+    "network_segment",
+    "covered_addresses",
+    "fraction",
+]
+
+SPACE_COVERAGE_FIELDS = [
+    "src_network_segment",
+    "dst_network_segment",
+    "service_label",
+    "protocol",
+    "port",
+    "outcome",
+    "matched_policy_id",
+    "src_blocks",
+    "dst_blocks",
+    "fraction",
+]
+
+ALLOW = "ALLOW"
+DENY = "DENY"
+IMPLICIT_DENY = "IMPLICIT_DENY"
+
+
+@dataclass(frozen=True)
+class PolicyCoverage:
+    """The share of one input CIDR a policy's source or destination addresses cover."""
+
+    policy: PolicyRule
+    side: str
+    network: Network
+    covered: int
+
+    @property
+    def fraction(self) -> float:
+        return self.covered / self.network.num_addresses
+
+
+@dataclass(frozen=True)
+class SpaceRegion:
+    """Part of a source × destination pair decided the same way, for one service."""
+
+    src_network: Network
+    dst_network: Network
+    port_spec: PortSpec
+    outcome: str
+    policy: Optional[PolicyRule]
+    src_blocks: tuple[Network, ...]
+    dst_blocks: tuple[Network, ...]
+
+    @property
+    def fraction(self) -> float:
+        size = sum(block.num_addresses for block in self.src_blocks) * sum(
+            block.num_addresses for block in self.dst_blocks
+        )
+        return size / (self.src_network.num_addresses * self.dst_network.num_addresses)
+
+
+def _active_spaces(
+    policies: Iterable[PolicyRule], address_book: AddressBook, service_book: ServiceBook
+) -> list[tuple[PolicyRule, PolicySpace]]:
+    # Without an evaluation time only always-on policies are active, as in the evaluator.
+    active: list[tuple[PolicyRule, PolicySpace]] = []
+    for policy in policies:
+        if not policy.enabled or policy.schedule not in (None, "always"):
+            continue
+        space = policy_space(policy, address_book, service_book)
+        if space is not None:
+            active.append((policy, space))
+    return active
+
+
+def _matches_service(space: PolicySpace, port_spec: PortSpec) -> bool:
+    if None in space.services:
+        return True
+    return any(start <= port_spec.port <= end for start, end in space.services.get(port_spec.protocol, ()))
+
+
+def policy_coverage(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    src_networks: Sequence[Network],
+    dst_networks: Sequence[Network],
+) -> list[PolicyCoverage]:
+    """Return, per policy, how many addresses of each input CIDR its source and destination cover."""
+    results: list[PolicyCoverage] = []
+    for policy, space in _active_spaces(policies, address_book, service_book):
+        for side, networks, blocks in (("src", src_networks, space.source), ("dst", dst_networks, space.destination)):
+            for network in networks:
+                covered = sum(shared.num_addresses for shared in intersect_networks((network,), blocks))
+                if covered:
+                    results.append(PolicyCoverage(policy=policy, side=side, network=network, covered=covered))
+    return results
+
+
+def space_coverage(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    src_networks: Sequence[Network],
+    dst_networks: Sequence[Network],
+    ports: Sequence[PortSpec],
+) -> list[SpaceRegion]:
+    """Split each source × destination pair, per service, into the regions each policy decides first.
+
+    Whatever no policy matches falls to implicit deny. Policies that depend on
+    more than plain addresses and ports, or on a schedule, are left out.
+    """
+    active = _active_spaces(policies, address_book, service_book)
+    regions: list[SpaceRegion] = []
+    for src_network in src_networks:
+        for dst_network in dst_networks:
+            if src_network.version != dst_network.version:
+                continue
+            for port_spec in ports:
+                # Undecided parts of the pair, as source blocks × destination blocks.
+                remaining: list[tuple[tuple[Network, ...], tuple[Network, ...]]] = [((src_network,), (dst_network,))]
+                for policy, space in active:
+                    if not remaining:
+                        break
+                    if not _matches_service(space, port_spec):
+                        continue
+                    undecided: list[tuple[tuple[Network, ...], tuple[Network, ...]]] = []
+                    for sources, destinations in remaining:
+                        src_in = intersect_networks(sources, space.source)
+                        dst_in = intersect_networks(destinations, space.destination)
+                        if not src_in or not dst_in:
+                            undecided.append((sources, destinations))
+                            continue
+                        outcome = ALLOW if policy.action.lower() == "accept" else DENY
+                        regions.append(
+                            SpaceRegion(src_network, dst_network, port_spec, outcome, policy, src_in, dst_in)
+                        )
+                        src_out = subtract_networks(sources, src_in)
+                        dst_out = subtract_networks(destinations, dst_in)
+                        if src_out:
+                            undecided.append((src_out, destinations))
+                        if dst_out:
+                            undecided.append((src_in, dst_out))
+                    remaining = undecided
+                for sources, destinations in remaining:
+                    regions.append(
+                        SpaceRegion(src_network, dst_network, port_spec, IMPLICIT_DENY, None, sources, destinations)
+                    )
+    return regions
+
+
+def write_policy_coverage(output_path: Path, coverage: Iterable[PolicyCoverage]) -> None:
+    """Write per-policy coverage of the input CIDRs as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=POLICY_COVERAGE_FIELDS)
+        writer.writeheader()
+        for entry in coverage:
+            writer.writerow(
+                {
+                    "policy_id": entry.policy.policy_id,
+                    "policy_name": entry.policy.name,
+                    "side": entry.side,
+                    "network_segment": str(entry.network),
+                    "covered_addresses": entry.covered,
+                    "fraction": f"{entry.fraction:.4f}",
+                }
+            )
+
+
+def write_space_coverage(output_path: Path, regions: Iterable[SpaceRegion]) -> None:
+    """Write the allowed, explicitly denied and implicitly denied regions as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=SPACE_COVERAGE_FIELDS)
+        writer.writeheader()
+        for region in regions:
+            writer.writerow(
+                {
+                    "src_network_segment": str(region.src_network),
+                    "dst_network_segment": str(region.dst_network),
+                    "service_label": region.port_spec.label,
+                    "protocol": region.port_spec.protocol.value,
+                    "port": region.port_spec.port,
+                    "outcome": region.outcome,
+                    "matched_policy_id": region.policy.policy_id if region.policy else "",
+                    "src_blocks": ";".join(str(block) for block in region.src_blocks),
+                    "dst_blocks": ";".join(str(block) for block in region.dst_blocks),
+                    "fraction": f"{region.fraction:.4f}",
+                }
+            )
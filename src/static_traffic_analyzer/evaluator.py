@@ -1,49 +1,178 @@
 """Policy evaluation logic for the static traffic analyzer."""
 from __future__ import annotations
 
-from dataclasses import dataclass
-from ipaddress import IPv4Address, IPv4Network
-from typing import Iterable, Optional
+import threading
+from dataclasses import dataclass, replace
+from datetime import datetime
+from ipaddress import IPv4Address, IPv4Network, IPv6Address, ip_network
+from typing import Iterable, Mapping, Optional, Sequence
 
+from .addrindex import PolicyIndex
+from .catalog import ADMIN_ACCESS_SERVICES
 from .models import (
     AddressBook,
     AddressObject,
     AddressType,
     Decision,
+    Interface,
+    InternetService,
     MatchDetail,
     MatchOutcome,
     PolicyRule,
     Protocol,
+    Schedule,
     ServiceBook,
     ServiceEntry,
     ServiceObject,
+    SNATRule,
+    VirtualIP,
+    Zone,
+    expand_zones,
 )
+from .geoip import GeoIPDatabase, geography_outcome
+from .identity import UserIdentity, identity_outcome, mac_outcome
+from .portindex import PortIndex
+from .resolver import FQDNResolver
+from .routing import PROTOCOL_NUMBERS
+from .utils import PortSpec
 
 
 @dataclass(frozen=True)
 class MatchMode:
-    """Matching behavior for address containment."""
+    """Matching behavior for address containment.
+
+    ``mode`` applies to sources, and to destinations unless ``dst_mode``
+    overrides it, so each dimension can be expanded or sampled independently.
+    ``exact`` matches like ``segment``; callers split segments at policy
+    boundaries first (see :mod:`.exact`).
+    """
 
     mode: str
     max_hosts: int
+    dst_mode: Optional[str] = None
+
+    def for_destination(self) -> MatchMode:
+        """Return the mode used for destination matching."""
+        if self.dst_mode is None:
+            return self
+        return MatchMode(mode=self.dst_mode, max_hosts=self.max_hosts)
+
+
+@dataclass(frozen=True)
+class PolicyCheck:
+    """Per-dimension outcome of one policy considered while evaluating a flow.
+
+    Dimensions are None when the policy was skipped before matching, and
+    ``interface`` is None when the flow's interfaces were not given.
+    """
+
+    policy_id: str
+    policy_name: str
+    source: Optional[MatchOutcome] = None
+    destination: Optional[MatchOutcome] = None
+    service: Optional[MatchOutcome] = None
+    skipped: Optional[str] = None
+    interface: Optional[MatchOutcome] = None
+
+    def _dimensions(self) -> dict[str, Optional[MatchOutcome]]:
+        return {
+            "src": self.source,
+            "dst": self.destination,
+            "service": self.service,
+            "interface": self.interface or MatchOutcome.MATCH,
+        }
+
+    @property
+    def matched(self) -> bool:
+        """Return True if every dimension matched."""
+        return self.skipped is None and all(outcome == MatchOutcome.MATCH for outcome in self._dimensions().values())
+
+    @property
+    def failed(self) -> tuple[str, ...]:
+        """Return the criteria that ruled the policy out: src, dst, service, interface, schedule or disabled."""
+        if self.skipped == "disabled":
+            return ("disabled",)
+        if self.skipped is not None:
+            return ("schedule",)
+        return tuple(name for name, outcome in self._dimensions().items() if outcome == MatchOutcome.NO_MATCH)
+
+
+@dataclass(frozen=True)
+class Explanation:
+    """Ordered policy checks behind a verdict and the verdict's reason."""
+
+    checks: tuple[PolicyCheck, ...]
+    reason: str
+
+
+def _resolved_fqdn_objects(obj: AddressObject, resolver: FQDNResolver) -> list[AddressObject]:
+    """Return host objects for an FQDN's resolved addresses (empty if unresolved)."""
+    fqdn = obj.fqdn or ""
+    addresses = resolver.resolve_wildcard(fqdn) if "*" in fqdn else resolver.resolve(fqdn)
+    if not addresses:
+        return []
+    return [
+        AddressObject(name=obj.name, address_type=AddressType.IPMASK, subnet=IPv4Network(address))
+        for address in sorted(addresses)
+    ]
 
 
 def _evaluate_address_objects(
     objects: Iterable[AddressObject],
     network: IPv4Network,
     mode: MatchMode,
+    resolver: Optional[FQDNResolver] = None,
+    geoip: Optional[GeoIPDatabase] = None,
+    mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]] = None,
 ) -> MatchOutcome:
-    """Evaluate address objects against a target network."""
+    """Evaluate address objects against a target network.
+
+    FQDN objects are UNKNOWN unless a resolver is supplied, in which case they
+    match on their resolved addresses and are non-matching if resolution fails.
+    Wildcard FQDNs resolve only through hosts file entries and stay UNKNOWN
+    otherwise, as does any FQDN checked against an IPv6 network. Geography
+    objects are UNKNOWN without a GeoIP database, MAC objects without an
+    IP-to-MAC mapping and dynamic objects always.
+    """
     has_unknown = False
     for obj in objects:
-        if obj.address_type == AddressType.FQDN:
+        if obj.address_type == AddressType.GEOGRAPHY:
+            target = ip_network(network.network_address) if mode.mode == "sample-ip" else network
+            outcome = geography_outcome(geoip, target, obj.country)
+            if outcome == MatchOutcome.MATCH:
+                return MatchOutcome.MATCH
+            has_unknown = has_unknown or outcome == MatchOutcome.UNKNOWN
+            continue
+        if obj.address_type == AddressType.DYNAMIC:
+            # Dynamic objects only match once a mapping has expanded them into addresses.
             has_unknown = True
             continue
+        if obj.address_type == AddressType.MAC:
+            target = ip_network(network.network_address) if mode.mode == "sample-ip" else network
+            outcome = mac_outcome(mac_map, target, obj.macaddrs)
+            if outcome == MatchOutcome.MATCH:
+                return MatchOutcome.MATCH
+            has_unknown = has_unknown or outcome == MatchOutcome.UNKNOWN
+            continue
+        if obj.address_type == AddressType.FQDN:
+            if network.version == 6:
+                # The resolver only returns A records, so it cannot decide an IPv6 flow.
+                has_unknown = True
+                continue
+            resolved = _resolved_fqdn_objects(obj, resolver) if resolver is not None and obj.fqdn else []
+            if _evaluate_address_objects(resolved, network, mode) == MatchOutcome.MATCH:
+                return MatchOutcome.MATCH
+            if resolver is None or not obj.fqdn or ("*" in obj.fqdn and not resolved):
+                has_unknown = True
+            continue
         if mode.mode == "sample-ip":
             if obj.contains_ip(network.network_address):
                 return MatchOutcome.MATCH
         elif mode.mode == "expand":
-            if network.num_addresses <= mode.max_hosts:
+            # Only a partial overlap needs the host-by-host walk; containment and disjointness are uniform.
+            if obj.contains_network(network):
+                return MatchOutcome.MATCH
+            if network.num_addresses <= mode.max_hosts and obj.overlaps_network(network):
                 all_match = True
                 for ip in network.hosts() if network.num_addresses > 2 else [network.network_address]:
                     if not obj.contains_ip(ip):
@@ -51,9 +180,6 @@ def _evaluate_address_objects(
                         break
                 if all_match:
                     return MatchOutcome.MATCH
-            else:
-                if obj.contains_network(network):
-                    return MatchOutcome.MATCH
         else:
             if obj.contains_network(network):
                 return MatchOutcome.MATCH
@@ -67,20 +193,21 @@ def _evaluate_address_group(
     names: Iterable[str],
     network: IPv4Network,
     mode: MatchMode,
+    resolver: Optional[FQDNResolver] = None,
+    geoip: Optional[GeoIPDatabase] = None,
+    mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]] = None,
 ) -> MatchOutcome:
-    """Evaluate address group references against a target network."""
+    """Evaluate address group references against a target network, in the network's IP version namespace."""
     aggregated_objects: list[AddressObject] = []
     has_unknown = False
-    print("_evaluate_address_group")
     for name in names:
-        objects = list(address_book.resolve_group_members(name))
-        print(objects)
+        objects = list(address_book.resolve_group_members(name, version=network.version))
         if not objects:
             has_unknown = True
         aggregated_objects.extend(objects)
     if not aggregated_objects and has_unknown:
         return MatchOutcome.UNKNOWN
-    result = _evaluate_address_objects(aggregated_objects, network, mode)
+    result = _evaluate_address_objects(aggregated_objects, network, mode, resolver, geoip, mac_map)
     if result == MatchOutcome.NO_MATCH and has_unknown:
         return MatchOutcome.UNKNOWN
     return result
@@ -90,15 +217,16 @@ def _evaluate_services(
     services: Iterable[ServiceObject],
     protocol: Protocol,
     port: int,
+    src_port: Optional[int] = None,
 ) -> MatchOutcome:
-    """Evaluate services against a protocol/port."""
+    """Evaluate services against a protocol/port and optional source port."""
     has_unknown = False
     for service in services:
         if not service.entries:
             has_unknown = True
             continue
         for entry in service.entries:
-            if entry.matches(protocol, port):
+            if entry.matches(protocol, port, src_port):
                 return MatchOutcome.MATCH
     if has_unknown:
         return MatchOutcome.UNKNOWN
@@ -110,8 +238,9 @@ def _evaluate_service_group(
     names: Iterable[str],
     protocol: Protocol,
     port: int,
+    src_port: Optional[int] = None,
 ) -> MatchOutcome:
-    """Evaluate service group references against a protocol/port."""
+    """Evaluate service group references against a protocol/port and optional source port."""
     aggregated_services: list[ServiceObject] = []
     has_unknown = False
     for name in names:
@@ -121,17 +250,164 @@ def _evaluate_service_group(
         aggregated_services.extend(services)
     if not aggregated_services and has_unknown:
         return MatchOutcome.UNKNOWN
-    result = _evaluate_services(aggregated_services, protocol, port)
+    result = _evaluate_services(aggregated_services, protocol, port, src_port)
     if result == MatchOutcome.NO_MATCH and has_unknown:
         return MatchOutcome.UNKNOWN
     return result
 
 
-def _schedule_active(schedule: Optional[str]) -> bool:
-    """Return True if the schedule should be treated as active."""
-    if schedule is None:
+def _evaluate_internet_services(
+    isdb: Optional[Mapping[str, InternetService]],
+    names: Iterable[str],
+    network: IPv4Network,
+    mode: MatchMode,
+    protocol: Optional[Protocol] = None,
+    port: Optional[int] = None,
+) -> MatchOutcome:
+    """Evaluate ISDB references against a network and, for destinations, the flow's port.
+
+    A range only matches together with the ports it serves. Entries missing
+    from the mapping (or no mapping at all) make the outcome UNKNOWN.
+    """
+    has_unknown = False
+    for name in names:
+        service = isdb.get(name) if isdb else None
+        if service is None:
+            has_unknown = True
+            continue
+        for entry in service.entries:
+            if protocol is not None and port is not None and not entry.service.matches(protocol, port):
+                continue
+            outcome = _evaluate_address_objects((entry.address,), network, mode)
+            if outcome == MatchOutcome.MATCH:
+                return MatchOutcome.MATCH
+            has_unknown = has_unknown or outcome == MatchOutcome.UNKNOWN
+    return MatchOutcome.UNKNOWN if has_unknown else MatchOutcome.NO_MATCH
+
+
+def _port_forward_filter(
+    address_book: AddressBook,
+    names: Iterable[str],
+    protocol: Protocol,
+    port: int,
+) -> tuple[str, ...]:
+    """Expand VIP groups and drop port-forwarding VIPs that do not translate the flow's protocol/port."""
+    if not address_book.vips:
+        return tuple(names)
+    vips, vip_groups = address_book.vips, address_book.vip_groups
+    members = (member for name in names for member in (vip_groups[name].members if name in vip_groups else (name,)))
+    return tuple(name for name in members if name not in vips or vips[name].applies_to_port(protocol, port))
+
+
+def _negate(outcome: MatchOutcome) -> MatchOutcome:
+    """Invert a definitive match outcome, leaving UNKNOWN untouched."""
+    if outcome == MatchOutcome.MATCH:
+        return MatchOutcome.NO_MATCH
+    if outcome == MatchOutcome.NO_MATCH:
+        return MatchOutcome.MATCH
+    return outcome
+
+
+def _schedule_active(
+    schedule: Optional[str],
+    ignore_schedule: bool = False,
+    schedules: Optional[Mapping[str, Schedule]] = None,
+    at: Optional[datetime] = None,
+    _visited: Optional[set[str]] = None,
+) -> bool:
+    """Return True if the schedule should be treated as active.
+
+    Without ``at`` only ``always`` is active. With ``at`` named schedules are
+    checked against that time; unknown schedule names are inactive.
+    """
+    if schedule is None or ignore_schedule or schedule.lower() == "always":
         return True
-    return schedule.lower() == "always"
+    if at is None or not schedules or schedule not in schedules:
+        return False
+    definition = schedules[schedule]
+    if definition.kind != "group":
+        return definition.is_active(at)
+    visited = _visited or set()
+    if schedule in visited:
+        return False
+    visited.add(schedule)
+    return any(_schedule_active(member, False, schedules, at, visited) for member in definition.members)
+
+
+def _with_identity(
+    src_result: MatchOutcome,
+    policy: PolicyRule,
+    src_network: IPv4Network,
+    identities: Optional[Mapping[IPv4Address | IPv6Address, UserIdentity]],
+) -> MatchOutcome:
+    """Narrow a source outcome by the policy's users and groups, if it names any."""
+    if src_result == MatchOutcome.NO_MATCH or not (policy.users or policy.groups):
+        return src_result
+    identity_result = identity_outcome(identities, src_network, policy.users, policy.groups)
+    if identity_result == MatchOutcome.NO_MATCH:
+        return MatchOutcome.NO_MATCH
+    return MatchOutcome.UNKNOWN if MatchOutcome.UNKNOWN in (src_result, identity_result) else MatchOutcome.MATCH
+
+
+def _interface_outcome(names: Sequence[str], interface: Optional[str], zones: Mapping[str, Zone]) -> MatchOutcome:
+    """Match a flow's interface (or zone) against a policy's srcintf/dstintf; unknown interfaces always match."""
+    if interface is None or not names or "any" in names or interface in names:
+        return MatchOutcome.MATCH
+    flow = set(expand_zones((interface,), zones))
+    allowed = set(expand_zones(names, zones))
+    if flow <= allowed:
+        return MatchOutcome.MATCH
+    # A zone only partly covered by the policy may or may not carry the flow.
+    return MatchOutcome.UNKNOWN if flow & allowed else MatchOutcome.NO_MATCH
+
+
+def _with_interfaces(
+    src_result: MatchOutcome,
+    policy: PolicyRule,
+    ingress: Optional[str],
+    egress: Optional[str],
+    zones: Optional[Mapping[str, Zone]],
+) -> MatchOutcome:
+    """Narrow a source outcome by the policy's srcintf and dstintf, when the flow's interfaces are known."""
+    if src_result == MatchOutcome.NO_MATCH or (ingress is None and egress is None):
+        return src_result
+    outcomes = (
+        _interface_outcome(policy.src_interfaces, ingress, zones or {}),
+        _interface_outcome(policy.dst_interfaces, egress, zones or {}),
+    )
+    if MatchOutcome.NO_MATCH in outcomes:
+        return MatchOutcome.NO_MATCH
+    return MatchOutcome.UNKNOWN if MatchOutcome.UNKNOWN in (src_result, *outcomes) else MatchOutcome.MATCH
+
+
+def _references_mac(address_book: AddressBook, names: Iterable[str], version: int) -> bool:
+    return any(
+        obj.address_type == AddressType.MAC
+        for name in names
+        for obj in address_book.resolve_group_members(name, version=version)
+    )
+
+
+def _only_mac_unknown(
+    address_book: AddressBook,
+    policy: PolicyRule,
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    outcomes: tuple[MatchOutcome, MatchOutcome, MatchOutcome],
+) -> bool:
+    """Return True if every UNKNOWN outcome is an address dimension referencing MAC objects."""
+    src_result, dst_result, service_result = outcomes
+    if service_result == MatchOutcome.UNKNOWN:
+        return False
+    if src_result == MatchOutcome.UNKNOWN and not _references_mac(
+        address_book, policy.source_for(src_network.version), src_network.version
+    ):
+        return False
+    if dst_result == MatchOutcome.UNKNOWN and not _references_mac(
+        address_book, policy.destination_for(dst_network.version), dst_network.version
+    ):
+        return False
+    return True
 
 
 def evaluate_policy(
@@ -144,37 +420,81 @@ def evaluate_policy(
     port: int,
     match_mode: MatchMode,
     ignore_schedule: bool,
+    resolver: Optional[FQDNResolver] = None,
+    schedules: Optional[Mapping[str, Schedule]] = None,
+    at: Optional[datetime] = None,
+    geoip: Optional[GeoIPDatabase] = None,
+    isdb: Optional[Mapping[str, InternetService]] = None,
+    mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]] = None,
+    identities: Optional[Mapping[IPv4Address | IPv6Address, UserIdentity]] = None,
+    src_port: Optional[int] = None,
+    ingress: Optional[str] = None,
+    egress: Optional[str] = None,
+    zones: Optional[Mapping[str, Zone]] = None,
 ) -> MatchDetail:
-    """Evaluate policies and return the first definitive decision."""
-    print(service_book)
+    """Evaluate policies and return the first definitive decision.
+
+    A policy left undecided only by MAC address objects, for want of a
+    mapping, is reported with reason UNSUPPORTED_OBJECT. Policies naming
+    users or groups also require the source to be mapped to a match.
+    Service source port ranges only apply when ``src_port`` is given, and
+    srcintf/dstintf only when the ``ingress``/``egress`` interface or zone is.
+    """
     for policy in policies:
-        print(policy.name, policy.schedule, policy.enabled, src_network,policy.source, dst_network,policy.destination)
         if not policy.enabled:
-            print("policy continue")
             continue
-        if not _schedule_active(policy.schedule):
-            print("schedule continue")
+        if not _schedule_active(policy.schedule, ignore_schedule, schedules, at):
             continue
-        src_result = _evaluate_address_group(address_book, policy.source, src_network, match_mode)
-        print(src_result)
+        if policy.internet_services_src:
+            src_result = _evaluate_internet_services(isdb, policy.internet_services_src, src_network, match_mode)
+        else:
+            src_result = _evaluate_address_group(
+                address_book,
+                policy.source_for(src_network.version),
+                src_network,
+                match_mode,
+                resolver,
+                geoip,
+                mac_map,
+            )
+        src_result = _with_identity(src_result, policy, src_network, identities)
+        src_result = _with_interfaces(src_result, policy, ingress, egress, zones)
         if src_result == MatchOutcome.NO_MATCH:
             continue
-        dst_result = _evaluate_address_group(address_book, policy.destination, dst_network, match_mode)
-        print(dst_result)
-        if dst_result == MatchOutcome.NO_MATCH:
-            continue
-        service_result = _evaluate_service_group(service_book, policy.services, protocol, port)
-        print(service_result)
-        if service_result == MatchOutcome.NO_MATCH:
+        if policy.internet_services:
+            # ISDB entries replace both the destination addresses and the services.
+            dst_result = service_result = _evaluate_internet_services(
+                isdb, policy.internet_services, dst_network, match_mode.for_destination(), protocol, port
+            )
+        else:
+            dst_result = _evaluate_address_group(
+                address_book,
+                _port_forward_filter(address_book, policy.destination_for(dst_network.version), protocol, port),
+                dst_network,
+                match_mode.for_destination(),
+                resolver,
+                geoip,
+                mac_map,
+            )
+            if dst_result == MatchOutcome.NO_MATCH:
+                continue
+            service_result = _evaluate_service_group(service_book, policy.services, protocol, port, src_port)
+            if policy.service_negate:
+                service_result = _negate(service_result)
+        if MatchOutcome.NO_MATCH in (dst_result, service_result):
             continue
 
         if MatchOutcome.UNKNOWN in (src_result, dst_result, service_result):
+            unsupported = mac_map is None and _only_mac_unknown(
+                address_book, policy, src_network, dst_network, (src_result, dst_result, service_result)
+            )
             return MatchDetail(
                 decision=Decision.UNKNOWN,
                 matched_policy_id=policy.policy_id,
                 matched_policy_name=policy.name,
                 matched_policy_action=policy.action,
-                reason="UNKNOWN_MATCH_CONDITION",
+                reason="UNSUPPORTED_OBJECT" if unsupported else "UNKNOWN_MATCH_CONDITION",
+                policy=policy,
             )
 
         decision = Decision.ALLOW if policy.action.lower() == "accept" else Decision.DENY
@@ -184,6 +504,7 @@ def evaluate_policy(
             matched_policy_name=policy.name,
             matched_policy_action=policy.action,
             reason="MATCHED_POLICY",
+            policy=policy,
         )
 
     return MatchDetail(
@@ -195,6 +516,448 @@ def evaluate_policy(
     )
 
 
+class Evaluator:
+    """Evaluate flows against a fixed policy set.
+
+    Policies whose services can never match a given protocol/port are skipped
+    through a protocol/port lookup table, so repeated segment pairs over the
+    same ports only walk the policies that could possibly apply. A prefix index
+    over policy addresses likewise skips policies whose source or destination
+    cannot overlap the flow; both keep priority order.
+    """
+
+    def __init__(
+        self,
+        policies: Iterable[PolicyRule],
+        address_book: AddressBook,
+        service_book: ServiceBook,
+        match_mode: MatchMode,
+        ignore_schedule: bool = False,
+        resolver: Optional[FQDNResolver] = None,
+        schedules: Optional[Mapping[str, Schedule]] = None,
+        at: Optional[datetime] = None,
+        geoip: Optional[GeoIPDatabase] = None,
+        isdb: Optional[Mapping[str, InternetService]] = None,
+        mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]] = None,
+        identities: Optional[Mapping[IPv4Address | IPv6Address, UserIdentity]] = None,
+        zones: Optional[Mapping[str, Zone]] = None,
+    ) -> None:
+        self.policies = tuple(policies)
+        self.address_book = address_book
+        self.service_book = service_book
+        self.match_mode = match_mode
+        self.ignore_schedule = ignore_schedule
+        self.resolver = resolver
+        self.schedules = schedules
+        self.at = at
+        self.geoip = geoip
+        self.isdb = isdb
+        self.mac_map = mac_map
+        self.identities = identities
+        self.zones = zones
+        self._candidates: dict[tuple[Protocol, int], frozenset[int]] = {}
+        self._index: Optional[PolicyIndex] = None
+        self._port_index: Optional[PortIndex] = None
+        self._lock = threading.Lock()
+
+    def port_index(self) -> PortIndex:
+        """Return the protocol/port lookup table over policy services, building it on first use."""
+        if self._port_index is None:
+            with self._lock:
+                if self._port_index is None:
+                    self._port_index = PortIndex(self.policies, self.service_book)
+        return self._port_index
+
+    def _service_candidates(self, protocol: Protocol, port: int) -> frozenset[int]:
+        """Return the positions of enabled policies whose services may match, caching them per port."""
+        key = (protocol, port)
+        candidates = self._candidates.get(key)
+        if candidates is None:
+            candidates = self.port_index().candidates(protocol, port)
+            with self._lock:
+                candidates = self._candidates.setdefault(key, candidates)
+        return candidates
+
+    def warm_ports(self, ports: Iterable[PortSpec]) -> None:
+        """Precompute candidate policies for each distinct protocol/port."""
+        for spec in ports:
+            self._service_candidates(spec.protocol, spec.port)
+
+    def address_index(self) -> PolicyIndex:
+        """Return the prefix index over policy addresses, building it on first use."""
+        if self._index is None:
+            with self._lock:
+                if self._index is None:
+                    self._index = PolicyIndex(self.policies, self.address_book)
+        return self._index
+
+    def flow_candidates(
+        self, src_network: IPv4Network, dst_network: IPv4Network, protocol: Protocol, port: int
+    ) -> list[PolicyRule]:
+        """Return, in priority order, the enabled policies whose addresses and services may match the flow."""
+        positions = self.address_index().candidates(src_network, dst_network)
+        positions &= self._service_candidates(protocol, port)
+        return [self.policies[position] for position in sorted(positions)]
+
+    def evaluate(
+        self,
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+        src_port: Optional[int] = None,
+        ingress: Optional[str] = None,
+        egress: Optional[str] = None,
+    ) -> MatchDetail:
+        """Evaluate a single flow and return the first definitive decision."""
+        return self._evaluate(
+            self.flow_candidates(src_network, dst_network, protocol, port),
+            src_network,
+            dst_network,
+            protocol,
+            port,
+            src_port,
+            ingress,
+            egress,
+        )
+
+    def all_matches(
+        self,
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+        src_port: Optional[int] = None,
+        ingress: Optional[str] = None,
+        egress: Optional[str] = None,
+    ) -> list[MatchDetail]:
+        """Return, in priority order, every policy that definitively matches the flow, not just the first.
+
+        Policies only possibly matching (reason UNKNOWN_MATCH_CONDITION or
+        UNSUPPORTED_OBJECT) are left out, as are disabled and inactive ones.
+        """
+        matches: list[MatchDetail] = []
+        for policy in self.flow_candidates(src_network, dst_network, protocol, port):
+            detail = self._evaluate((policy,), src_network, dst_network, protocol, port, src_port, ingress, egress)
+            if detail.reason == "MATCHED_POLICY":
+                matches.append(detail)
+        return matches
+
+    def _evaluate(
+        self,
+        policies: Iterable[PolicyRule],
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+        src_port: Optional[int],
+        ingress: Optional[str],
+        egress: Optional[str],
+    ) -> MatchDetail:
+        return evaluate_policy(
+            policies=policies,
+            address_book=self.address_book,
+            service_book=self.service_book,
+            src_network=src_network,
+            dst_network=dst_network,
+            protocol=protocol,
+            port=port,
+            match_mode=self.match_mode,
+            ignore_schedule=self.ignore_schedule,
+            resolver=self.resolver,
+            schedules=self.schedules,
+            at=self.at,
+            geoip=self.geoip,
+            isdb=self.isdb,
+            mac_map=self.mac_map,
+            identities=self.identities,
+            src_port=src_port,
+            ingress=ingress,
+            egress=egress,
+            zones=self.zones,
+        )
+
+    def _dimension_outcomes(
+        self,
+        policy: PolicyRule,
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+        src_port: Optional[int] = None,
+    ) -> dict[str, MatchOutcome]:
+        """Return the source, destination and service outcome of one policy for a flow."""
+        if policy.internet_services_src:
+            source_result = _evaluate_internet_services(
+                self.isdb, policy.internet_services_src, src_network, self.match_mode
+            )
+        else:
+            source_result = _evaluate_address_group(
+                self.address_book,
+                policy.source_for(src_network.version),
+                src_network,
+                self.match_mode,
+                self.resolver,
+                self.geoip,
+                self.mac_map,
+            )
+        source_result = _with_identity(source_result, policy, src_network, self.identities)
+        dst_mode = self.match_mode.for_destination()
+        if policy.internet_services:
+            # The destination is judged on the ISDB ranges alone; the service on ranges serving the port.
+            return {
+                "source": source_result,
+                "destination": _evaluate_internet_services(self.isdb, policy.internet_services, dst_network, dst_mode),
+                "service": _evaluate_internet_services(
+                    self.isdb, policy.internet_services, dst_network, dst_mode, protocol, port
+                ),
+            }
+        service_result = _evaluate_service_group(self.service_book, policy.services, protocol, port, src_port)
+        if policy.service_negate:
+            service_result = _negate(service_result)
+        return {
+            "source": source_result,
+            "destination": _evaluate_address_group(
+                self.address_book,
+                _port_forward_filter(self.address_book, policy.destination_for(dst_network.version), protocol, port),
+                dst_network,
+                dst_mode,
+                self.resolver,
+                self.geoip,
+                self.mac_map,
+            ),
+            "service": service_result,
+        }
+
+    def near_misses(
+        self,
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+    ) -> list[tuple[PolicyRule, str]]:
+        """Return accept policies matching exactly two of src/dst/service, with the failing dimension."""
+        misses: list[tuple[PolicyRule, str]] = []
+        for policy in self.policies:
+            if not policy.enabled or policy.action.lower() != "accept":
+                continue
+            results = self._dimension_outcomes(policy, src_network, dst_network, protocol, port)
+            failed = [dimension for dimension, outcome in results.items() if outcome != MatchOutcome.MATCH]
+            if len(failed) == 1:
+                misses.append((policy, failed[0]))
+        return misses
+
+    def evaluate_explain(
+        self,
+        src_network: IPv4Network,
+        dst_network: IPv4Network,
+        protocol: Protocol,
+        port: int,
+        src_port: Optional[int] = None,
+        ingress: Optional[str] = None,
+        egress: Optional[str] = None,
+    ) -> tuple[MatchDetail, Explanation]:
+        """Evaluate a flow and explain every policy considered up to the deciding one.
+
+        The interface dimension is only checked when ``ingress`` or ``egress`` is given.
+        """
+        detail = self.evaluate(src_network, dst_network, protocol, port, src_port, ingress=ingress, egress=egress)
+        checks: list[PolicyCheck] = []
+        for policy in self.policies:
+            if not policy.enabled:
+                checks.append(PolicyCheck(policy.policy_id, policy.name, skipped="disabled"))
+                continue
+            if not _schedule_active(policy.schedule, self.ignore_schedule, self.schedules, self.at):
+                checks.append(PolicyCheck(policy.policy_id, policy.name, skipped="schedule inactive"))
+                continue
+            results = self._dimension_outcomes(policy, src_network, dst_network, protocol, port, src_port)
+            interface = None
+            if ingress is not None or egress is not None:
+                interface = _with_interfaces(MatchOutcome.MATCH, policy, ingress, egress, self.zones)
+            checks.append(
+                PolicyCheck(
+                    policy.policy_id,
+                    policy.name,
+                    source=results["source"],
+                    destination=results["destination"],
+                    service=results["service"],
+                    interface=interface,
+                )
+            )
+            if detail.policy is policy:
+                break
+        return detail, Explanation(checks=tuple(checks), reason=detail.reason)
+
+
+def evaluate_multicast_policy(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+    match_mode: MatchMode,
+    ignore_schedule: bool,
+    schedules: Optional[Mapping[str, Schedule]] = None,
+    at: Optional[datetime] = None,
+) -> MatchDetail:
+    """Evaluate multicast policies, tagging the reason with the policy table used."""
+    detail = evaluate_policy(
+        policies=policies,
+        address_book=address_book,
+        service_book=service_book,
+        src_network=src_network,
+        dst_network=dst_network,
+        protocol=protocol,
+        port=port,
+        match_mode=match_mode,
+        ignore_schedule=ignore_schedule,
+        schedules=schedules,
+        at=at,
+    )
+    return replace(detail, reason=f"MULTICAST_{detail.reason}")
+
+
+def find_local_interface(interfaces: Mapping[str, Interface], dst_network: IPv4Network) -> Optional[Interface]:
+    """Return the interface whose own address is the (single-address) destination, if any."""
+    if dst_network.num_addresses != 1:
+        return None
+    for interface in interfaces.values():
+        if interface.ip is not None and interface.ip.ip == dst_network.network_address:
+            return interface
+    return None
+
+
+def evaluate_local_in_policy(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    interface: Interface,
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+    match_mode: MatchMode,
+    ignore_schedule: bool,
+    schedules: Optional[Mapping[str, Schedule]] = None,
+    at: Optional[datetime] = None,
+) -> MatchDetail:
+    """Evaluate traffic addressed to the firewall itself.
+
+    A matching local-in deny decides the flow. Otherwise the flow is allowed
+    only if the interface's ``allowaccess`` opens the service: an accept policy
+    cannot expose a management service the interface does not offer.
+    """
+    detail = evaluate_policy(
+        policies=policies,
+        address_book=address_book,
+        service_book=service_book,
+        src_network=src_network,
+        dst_network=dst_network,
+        protocol=protocol,
+        port=port,
+        match_mode=match_mode,
+        ignore_schedule=ignore_schedule,
+        schedules=schedules,
+        at=at,
+    )
+    if detail.policy is not None and detail.decision != Decision.ALLOW:
+        return replace(detail, reason=f"LOCAL_IN_{detail.reason}")
+    allowed = any(
+        entry.matches(protocol, port)
+        for access in interface.allowaccess
+        if access in ADMIN_ACCESS_SERVICES
+        for entry in ADMIN_ACCESS_SERVICES[access].entries
+    )
+    if not allowed:
+        return replace(detail, decision=Decision.DENY, reason="LOCAL_IN_ACCESS_DISABLED")
+    if detail.policy is not None:
+        return replace(detail, reason=f"LOCAL_IN_{detail.reason}")
+    return replace(detail, decision=Decision.ALLOW, reason="LOCAL_IN_ALLOWACCESS")
+
+
+def find_snat_rule(
+    rules: Iterable[SNATRule],
+    address_book: AddressBook,
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    match_mode: MatchMode,
+    protocol: Protocol,
+    src_port: Optional[int] = None,
+) -> Optional[SNATRule]:
+    """Return the first enabled central SNAT rule matching the flow, if any.
+
+    Entries scoped to an original source port are skipped when the flow has no
+    source port, as such flows are assumed to use an ephemeral one.
+    """
+    for rule in rules:
+        if not rule.enabled:
+            continue
+        if rule.protocol and rule.protocol != PROTOCOL_NUMBERS.get(protocol):
+            continue
+        if rule.orig_port is not None and not (
+            src_port is not None and rule.orig_port[0] <= src_port <= rule.orig_port[1]
+        ):
+            continue
+        if _evaluate_address_group(address_book, rule.orig_addr, src_network, match_mode) != MatchOutcome.MATCH:
+            continue
+        dst_mode = match_mode.for_destination()
+        if _evaluate_address_group(address_book, rule.dst_addr, dst_network, dst_mode) != MatchOutcome.MATCH:
+            continue
+        return rule
+    return None
+
+
+def find_dos_policy(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+    match_mode: MatchMode,
+) -> Optional[PolicyRule]:
+    """Return the first enabled DoS policy whose addresses and services match the flow, if any.
+
+    DoS policies are checked on ingress before firewall policies, whatever the
+    firewall policy decides. Their ingress interface is not checked.
+    """
+    for policy in policies:
+        if not policy.enabled:
+            continue
+        outcomes = (
+            _evaluate_address_group(address_book, policy.source_for(src_network.version), src_network, match_mode),
+            _evaluate_address_group(
+                address_book, policy.destination_for(dst_network.version), dst_network, match_mode.for_destination()
+            ),
+            _evaluate_service_group(service_book, policy.services, protocol, port),
+        )
+        if all(outcome == MatchOutcome.MATCH for outcome in outcomes):
+            return policy
+    return None
+
+
+def find_vip(
+    address_book: AddressBook,
+    policy: PolicyRule,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+    match_mode: MatchMode,
+) -> Optional[VirtualIP]:
+    """Return the VIP of the policy's destinations that translates the flow, if any."""
+    for name in _port_forward_filter(address_book, policy.destination_for(dst_network.version), protocol, port):
+        vip = address_book.vips.get(name)
+        if vip is None:
+            continue
+        outcome = _evaluate_address_group(address_book, (name,), dst_network, match_mode.for_destination())
+        if outcome == MatchOutcome.MATCH:
+            return vip
+    return None
+
+
 def normalize_service_entries(entries: Iterable[ServiceEntry]) -> tuple[ServiceEntry, ...]:
     """Return a normalized tuple of service entries."""
     normalized: list[ServiceEntry] = []
@@ -0,0 +1,61 @@
+"""Exact evaluation: split input CIDRs at policy address boundaries instead of sampling or enumerating hosts."""
+from __future__ import annotations
+
+from typing import Iterable, Optional
+
+from .evaluator import Evaluator
+from .models import MatchDetail, Protocol
+from .overlap import Network, collapse_networks
+
+
+def split_network(network: Network, boundaries: Iterable[Network]) -> list[Network]:
+    """Split a network into the fewest CIDR halves that no boundary block cuts through."""
+    inside = [block for block in boundaries if block.prefixlen > network.prefixlen and block.subnet_of(network)]
+    if not inside:
+        return [network]
+    pieces: list[Network] = []
+    for half in network.subnets(prefixlen_diff=1):
+        pieces.extend(split_network(half, inside))
+    return pieces
+
+
+def _outcome(match: MatchDetail) -> tuple[str, Optional[str], str]:
+    return match.decision.value, match.matched_policy_id, match.reason
+
+
+def exact_subranges(
+    evaluator: Evaluator,
+    src_network: Network,
+    dst_network: Network,
+    protocol: Protocol,
+    port: int,
+    src_port: Optional[int] = None,
+    ingress: Optional[str] = None,
+    egress: Optional[str] = None,
+) -> list[tuple[Network, Network]]:
+    """Return source × destination sub-ranges that are each decided uniformly.
+
+    Each side is split wherever a policy's address block starts or ends, so
+    every piece is either inside or outside each fixed address object and
+    segment containment decides it exactly. Pieces with the same outcome are
+    merged back into the largest CIDRs possible. FQDN, geography and other
+    objects without fixed blocks do not split the ranges.
+    """
+    index = evaluator.address_index()
+    src_pieces = split_network(src_network, index.boundaries("src", src_network))
+    dst_pieces = split_network(dst_network, index.boundaries("dst", dst_network))
+    cells: dict[tuple[Network, tuple[str, Optional[str], str]], list[Network]] = {}
+    for src_piece in src_pieces:
+        by_outcome: dict[tuple[str, Optional[str], str], list[Network]] = {}
+        for dst_piece in dst_pieces:
+            match = evaluator.evaluate(src_piece, dst_piece, protocol, port, src_port, ingress=ingress, egress=egress)
+            by_outcome.setdefault(_outcome(match), []).append(dst_piece)
+        for outcome, pieces in by_outcome.items():
+            for dst_block in collapse_networks(pieces):
+                cells.setdefault((dst_block, outcome), []).append(src_piece)
+    subranges = [
+        (src_block, dst_block)
+        for (dst_block, _), pieces in cells.items()
+        for src_block in collapse_networks(pieces)
+    ]
+    return sorted(subranges, key=lambda pair: (pair[0].network_address, pair[1].network_address))
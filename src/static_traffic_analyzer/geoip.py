@@ -0,0 +1,90 @@
+"""Country lookups for geography address objects."""
+from __future__ import annotations
+
+import csv
+from ipaddress import IPv4Network
+from pathlib import Path
+from typing import Any, Optional, Protocol
+
+from .models import MatchOutcome
+from .utils import ParseError, parse_ipv4_network
+
+
+class GeoIPDatabase(Protocol):
+    """Decides whether a network lies in a country."""
+
+    def match_country(self, network: IPv4Network, country: str) -> MatchOutcome:
+        """Return MATCH if the whole network is in the country, UNKNOWN if only part may be."""
+
+
+class CSVGeoIP:
+    """GeoIP ranges loaded from a `network,country` CSV (e.g. flattened GeoLite2 blocks)."""
+
+    def __init__(self, ranges: list[tuple[IPv4Network, str]]) -> None:
+        self.ranges = ranges
+
+    @classmethod
+    def from_path(cls, path: Path) -> CSVGeoIP:
+        ranges: list[tuple[IPv4Network, str]] = []
+        with path.open(newline="", encoding="utf-8") as handle:
+            reader = csv.DictReader(handle)
+            if not {"network", "country"} <= set(reader.fieldnames or []):
+                raise ParseError(f"GeoIP CSV must have network and country columns: {path}")
+            for row in reader:
+                if row["network"] and row["country"]:
+                    ranges.append((parse_ipv4_network(row["network"]), row["country"].strip().upper()))
+        return cls(ranges)
+
+    def match_country(self, network: IPv4Network, country: str) -> MatchOutcome:
+        country = country.upper()
+        partial = False
+        for block, block_country in self.ranges:
+            if block_country != country or not block.overlaps(network):
+                continue
+            if network.subnet_of(block):
+                return MatchOutcome.MATCH
+            partial = True
+        return MatchOutcome.UNKNOWN if partial else MatchOutcome.NO_MATCH
+
+
+class MaxMindGeoIP:
+    """GeoIP lookups against a MaxMind .mmdb database (GeoLite2/GeoIP2 Country or City)."""
+
+    def __init__(self, reader: Any) -> None:
+        self.reader = reader
+
+    @classmethod
+    def from_path(cls, path: Path) -> MaxMindGeoIP:
+        try:
+            import maxminddb  # type: ignore
+        except ModuleNotFoundError as exc:
+            raise ParseError(
+                "maxminddb is required for .mmdb GeoIP databases. "
+                "Install with: pip install 'static-traffic-analyzer[geoip]' or use a network,country CSV"
+            ) from exc
+        return cls(maxminddb.open_database(str(path)))
+
+    def match_country(self, network: IPv4Network, country: str) -> MatchOutcome:
+        record, prefix_len = self.reader.get_with_prefix_len(str(network.network_address))
+        if prefix_len > network.prefixlen:
+            # The database splits this network into smaller blocks that may differ.
+            return MatchOutcome.UNKNOWN
+        code = ((record or {}).get("country") or {}).get("iso_code")
+        return MatchOutcome.MATCH if code and code.upper() == country.upper() else MatchOutcome.NO_MATCH
+
+
+def load_geoip(path: str) -> GeoIPDatabase:
+    """Open a GeoIP database, choosing the format from the file extension."""
+    location = Path(path)
+    if not location.is_file():
+        raise ParseError(f"GeoIP database not found: {path}")
+    if location.suffix.lower() == ".mmdb":
+        return MaxMindGeoIP.from_path(location)
+    return CSVGeoIP.from_path(location)
+
+
+def geography_outcome(geoip: Optional[GeoIPDatabase], network: IPv4Network, country: Optional[str]) -> MatchOutcome:
+    """Match a network against a geography object, UNKNOWN without a database."""
+    if geoip is None or not country:
+        return MatchOutcome.UNKNOWN
+    return geoip.match_country(network, country)
@@ -0,0 +1,115 @@
+"""Source host attributes that firewall objects match on besides the IP address."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from ipaddress import IPv4Address, IPv4Network, IPv6Address
+from pathlib import Path
+from typing import Mapping, Optional, Sequence
+
+from .models import MatchOutcome
+from .utils import ParseError, normalize_mac, parse_ip_address
+
+
+@dataclass(frozen=True)
+class UserIdentity:
+    """The authenticated user behind a source address and the groups they belong to."""
+
+    user: Optional[str]
+    groups: frozenset[str]
+
+
+def load_mac_map(path: Path) -> dict[IPv4Address | IPv6Address, str]:
+    """Read an `ip,mac` CSV mapping source addresses to MAC addresses."""
+    if not path.is_file():
+        raise ParseError(f"MAC mapping file not found: {path}")
+    mapping: dict[IPv4Address | IPv6Address, str] = {}
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        if not {"ip", "mac"} <= set(reader.fieldnames or []):
+            raise ParseError(f"MAC mapping file must have ip and mac columns: {path}")
+        for line_number, row in enumerate(reader, start=2):
+            if not row.get("ip") or not row.get("mac"):
+                continue
+            try:
+                mapping[parse_ip_address(row["ip"].strip())] = normalize_mac(row["mac"])
+            except ParseError as exc:
+                raise ParseError(f"{path.name} line {line_number}: {exc}") from exc
+    return mapping
+
+
+def _mac_in(mac: str, entries: Sequence[str]) -> bool:
+    value = int(mac.replace(":", ""), 16)
+    for entry in entries:
+        start, _, end = entry.partition("-")
+        if int(start.replace(":", ""), 16) <= value <= int((end or start).replace(":", ""), 16):
+            return True
+    return False
+
+
+def mac_outcome(
+    mac_map: Optional[Mapping[IPv4Address | IPv6Address, str]],
+    network: IPv4Network,
+    entries: Sequence[str],
+) -> MatchOutcome:
+    """Match a network against MAC object entries (addresses or `a-b` ranges) via the mapping.
+
+    Every address of the network must be mapped for a definitive answer;
+    without a mapping the outcome is UNKNOWN.
+    """
+    if mac_map is None:
+        return MatchOutcome.UNKNOWN
+    mapped = [mac for address, mac in mac_map.items() if address in network]
+    matches = sum(1 for mac in mapped if _mac_in(mac, entries))
+    if len(mapped) < network.num_addresses:
+        return MatchOutcome.UNKNOWN
+    if matches == len(mapped):
+        return MatchOutcome.MATCH
+    return MatchOutcome.NO_MATCH if matches == 0 else MatchOutcome.UNKNOWN
+
+
+def load_identity_map(path: Path) -> dict[IPv4Address | IPv6Address, UserIdentity]:
+    """Read an `ip,user,groups` CSV mapping source addresses to users; groups are `;`-separated."""
+    if not path.is_file():
+        raise ParseError(f"Identity mapping file not found: {path}")
+    mapping: dict[IPv4Address | IPv6Address, UserIdentity] = {}
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        columns = set(reader.fieldnames or [])
+        if "ip" not in columns or not columns & {"user", "groups"}:
+            raise ParseError(f"Identity mapping file must have an ip column and user or groups columns: {path}")
+        for line_number, row in enumerate(reader, start=2):
+            if not row.get("ip"):
+                continue
+            try:
+                address = parse_ip_address(row["ip"].strip())
+            except ParseError as exc:
+                raise ParseError(f"{path.name} line {line_number}: {exc}") from exc
+            user = (row.get("user") or "").strip().lower() or None
+            groups = frozenset(group.strip().lower() for group in (row.get("groups") or "").split(";") if group.strip())
+            mapping[address] = UserIdentity(user=user, groups=groups)
+    return mapping
+
+
+def identity_outcome(
+    identity_map: Optional[Mapping[IPv4Address | IPv6Address, UserIdentity]],
+    network: IPv4Network,
+    users: Sequence[str],
+    groups: Sequence[str],
+) -> MatchOutcome:
+    """Match a source network against a policy's `users` and `groups` via the mapping.
+
+    As with MAC objects, every address must be mapped for a definitive answer;
+    unmapped addresses are unauthenticated and could still log in.
+    """
+    if identity_map is None:
+        return MatchOutcome.UNKNOWN
+    wanted_users = {user.lower() for user in users}
+    wanted_groups = {group.lower() for group in groups}
+    mapped = [identity for address, identity in identity_map.items() if address in network]
+    matches = sum(1 for identity in mapped if identity.user in wanted_users or identity.groups & wanted_groups)
+    if len(mapped) < network.num_addresses:
+        return MatchOutcome.UNKNOWN
+    if matches == len(mapped):
+        return MatchOutcome.MATCH
+    return MatchOutcome.NO_MATCH if matches == 0 else MatchOutcome.UNKNOWN
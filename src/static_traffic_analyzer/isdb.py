@@ -0,0 +1,50 @@
+"""Internet Service Database (ISDB) mapping files."""
+from __future__ import annotations
+
+import csv
+from pathlib import Path
+
+from .models import InternetService, InternetServiceEntry, ServiceEntry
+from .utils import ParseError, parse_address_object, parse_service_entry
+
+
+ANY_SERVICE_ENTRY = ServiceEntry(protocol=None, start_port=None, end_port=None)
+
+
+def _service_entry(protocol: str, ports: str) -> ServiceEntry:
+    protocol = protocol.strip().lower()
+    if not protocol:
+        return ANY_SERVICE_ENTRY
+    return parse_service_entry(f"{protocol}_{ports.strip() or '1-65535'}")
+
+
+def load_isdb(path: Path) -> dict[str, InternetService]:
+    """Read a `name,network,protocol,ports` CSV into ISDB entries keyed by service name.
+
+    ``network`` is a CIDR or an `a-b` address range. A blank protocol means any
+    traffic to the range; a blank port list means every port of the protocol.
+    Repeated names add ranges to the same service.
+    """
+    if not path.is_file():
+        raise ParseError(f"ISDB mapping file not found: {path}")
+    entries: dict[str, list[InternetServiceEntry]] = {}
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        if not {"name", "network"} <= set(reader.fieldnames or []):
+            raise ParseError(f"ISDB mapping file must have name and network columns: {path}")
+        for line_number, row in enumerate(reader, start=2):
+            name = (row.get("name") or "").strip()
+            network = (row.get("network") or "").strip()
+            if not name or not network:
+                continue
+            try:
+                if "-" in network:
+                    start, end = network.split("-", 1)
+                    address = parse_address_object(name, "iprange", start_ip=start.strip(), end_ip=end.strip())
+                else:
+                    address = parse_address_object(name, "ipmask", subnet=network)
+                service = _service_entry(row.get("protocol") or "", row.get("ports") or "")
+            except ParseError as exc:
+                raise ParseError(f"{path.name} line {line_number}: {exc}") from exc
+            entries.setdefault(name, []).append(InternetServiceEntry(address=address, service=service))
+    return {name: InternetService(name=name, entries=tuple(items)) for name, items in entries.items()}
@@ -0,0 +1,160 @@
+"""Least-privilege suggestions: narrow broad accept policies to the requested traffic they allowed."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Iterable, Mapping, Optional
+
+from .models import AddressBook, Decision, PolicyRule, Protocol, ServiceBook
+from .overlap import (
+    Network,
+    PolicySpace,
+    collapse_networks,
+    format_services,
+    intersect_networks,
+    merge_ranges,
+    policy_space,
+)
+from .utils import parse_network
+
+
+# Address blocks this large or larger, per IP version, make a policy side broad.
+BROAD_PREFIXLEN = {4: 16, 6: 48}
+
+LEAST_PRIVILEGE_FIELDS = [
+    "policy_id",
+    "policy_name",
+    "broad",
+    "allowed_flows",
+    "current_source",
+    "current_destination",
+    "current_services",
+    "suggested_source",
+    "suggested_destination",
+    "suggested_services",
+]
+
+
+@dataclass(frozen=True)
+class LeastPrivilege:
+    """A broad accept policy and the narrower space covering only the flows it allowed.
+
+    ``broad`` names the dimensions (src, dst, service) that are narrowed;
+    the others keep the policy's current space in ``suggested``.
+    """
+
+    policy: PolicyRule
+    broad: tuple[str, ...]
+    allowed_flows: int
+    current: PolicySpace
+    suggested: PolicySpace
+
+
+@dataclass
+class _Allowed:
+    """Flows one policy allowed during the run."""
+
+    sources: list[Network] = field(default_factory=list)
+    destinations: list[Network] = field(default_factory=list)
+    ports: dict[Protocol, list[tuple[int, int]]] = field(default_factory=dict)
+    flows: int = 0
+
+
+def _broad_networks(blocks: Iterable[Network]) -> bool:
+    return any(block.prefixlen <= BROAD_PREFIXLEN[block.version] for block in blocks)
+
+
+def broad_dimensions(space: PolicySpace) -> tuple[str, ...]:
+    """Return which of a policy's source, destination and service are broad."""
+    broad = []
+    if _broad_networks(space.source):
+        broad.append("src")
+    if _broad_networks(space.destination):
+        broad.append("dst")
+    if None in space.services:
+        broad.append("service")
+    return tuple(broad)
+
+
+def _row_network(row: Mapping[str, str | int | None], side: str) -> Network:
+    # Exact mode rows carry the sub-range the policy decided; otherwise the whole segment matched.
+    return parse_network(str(row.get(f"{side}_subrange") or row[f"{side}_network_segment"]))
+
+
+def recommend_least_privilege(
+    rows: Iterable[Mapping[str, str | int | None]],
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+) -> list[LeastPrivilege]:
+    """Suggest narrower replacements for broad accept policies that allowed flows in the run.
+
+    A policy is broad when its source or destination holds a block at least
+    as large as ``BROAD_PREFIXLEN`` or its service matches every protocol.
+    Policies whose space depends on FQDN, ISDB or identity objects are skipped.
+    """
+    allowed: dict[str, _Allowed] = {}
+    for row in rows:
+        policy_id = str(row.get("matched_policy_id") or "")
+        if row["decision"] != Decision.ALLOW.value or not policy_id:
+            continue
+        entry = allowed.setdefault(policy_id, _Allowed())
+        entry.sources.append(_row_network(row, "src"))
+        entry.destinations.append(_row_network(row, "dst"))
+        port = int(row["port"] or 0)
+        entry.ports.setdefault(Protocol(str(row["protocol"])), []).append((port, port))
+        entry.flows += 1
+    suggestions: list[LeastPrivilege] = []
+    for policy in policies:
+        if policy.policy_id not in allowed or policy.action.lower() != "accept":
+            continue
+        space: Optional[PolicySpace] = policy_space(policy, address_book, service_book)
+        if space is None:
+            continue
+        broad = broad_dimensions(space)
+        if not broad:
+            continue
+        entry = allowed[policy.policy_id]
+        # Sampled segments may reach past the policy, so the suggestion never grows beyond it.
+        suggested = PolicySpace(
+            source=(
+                intersect_networks(collapse_networks(entry.sources), space.source) if "src" in broad else space.source
+            ),
+            destination=(
+                intersect_networks(collapse_networks(entry.destinations), space.destination)
+                if "dst" in broad
+                else space.destination
+            ),
+            services=(
+                {protocol: merge_ranges(ranges) for protocol, ranges in entry.ports.items()}
+                if "service" in broad
+                else space.services
+            ),
+        )
+        suggestions.append(
+            LeastPrivilege(policy=policy, broad=broad, allowed_flows=entry.flows, current=space, suggested=suggested)
+        )
+    return suggestions
+
+
+def write_least_privilege(output_path: Path, suggestions: Iterable[LeastPrivilege]) -> None:
+    """Write broad policies with their current and suggested narrower spaces as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=LEAST_PRIVILEGE_FIELDS)
+        writer.writeheader()
+        for suggestion in suggestions:
+            writer.writerow(
+                {
+                    "policy_id": suggestion.policy.policy_id,
+                    "policy_name": suggestion.policy.name,
+                    "broad": ";".join(suggestion.broad),
+                    "allowed_flows": suggestion.allowed_flows,
+                    "current_source": ";".join(str(block) for block in suggestion.current.source),
+                    "current_destination": ";".join(str(block) for block in suggestion.current.destination),
+                    "current_services": format_services(suggestion.current.services),
+                    "suggested_source": ";".join(str(block) for block in suggestion.suggested.source),
+                    "suggested_destination": ";".join(str(block) for block in suggestion.suggested.destination),
+                    "suggested_services": format_services(suggestion.suggested.services),
+                }
+            )
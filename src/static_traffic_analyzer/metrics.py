@@ -0,0 +1,62 @@
+"""OpenMetrics exposition of run statistics."""
+from __future__ import annotations
+
+import os
+from collections import Counter
+from pathlib import Path
+from typing import Mapping
+
+from .models import Decision
+
+
+METRIC_PREFIX = "static_traffic_analyzer"
+
+
+def _escape_label(value: str) -> str:
+    """Escape a label value per the OpenMetrics text format."""
+    return value.replace("\\", "\\\\").replace("\n", "\\n").replace('"', '\\"')
+
+
+class RunMetrics:
+    """Accumulate decision and policy hit counters while a run progresses."""
+
+    def __init__(self) -> None:
+        self.flows_processed = 0
+        self.decisions: Counter[str] = Counter({decision.value: 0 for decision in Decision})
+        self.policy_hits: Counter[str] = Counter()
+
+    def record(self, row: Mapping[str, str | int | None]) -> None:
+        """Count a single output row."""
+        self.flows_processed += 1
+        self.decisions[str(row["decision"])] += 1
+        policy_id = row.get("matched_policy_id")
+        if policy_id:
+            self.policy_hits[str(policy_id)] += 1
+
+    def render(self) -> str:
+        """Render the counters in OpenMetrics text format."""
+        lines = [
+            f"# TYPE {METRIC_PREFIX}_flows counter",
+            f"# HELP {METRIC_PREFIX}_flows Flows evaluated.",
+            f"{METRIC_PREFIX}_flows_total {self.flows_processed}",
+            f"# TYPE {METRIC_PREFIX}_decisions counter",
+            f"# HELP {METRIC_PREFIX}_decisions Flows evaluated by final decision.",
+        ]
+        for decision, count in sorted(self.decisions.items()):
+            lines.append(f'{METRIC_PREFIX}_decisions_total{{decision="{_escape_label(decision)}"}} {count}')
+        lines.extend(
+            [
+                f"# TYPE {METRIC_PREFIX}_policy_hits counter",
+                f"# HELP {METRIC_PREFIX}_policy_hits Flows decided by each policy.",
+            ]
+        )
+        for policy_id, count in sorted(self.policy_hits.items()):
+            lines.append(f'{METRIC_PREFIX}_policy_hits_total{{policy_id="{_escape_label(policy_id)}"}} {count}')
+        lines.append("# EOF")
+        return "\n".join(lines) + "\n"
+
+    def write(self, path: Path) -> None:
+        """Atomically replace the metrics file so scrapers never see partial output."""
+        temp_path = path.with_name(f".{path.name}.tmp")
+        temp_path.write_text(self.render(), encoding="utf-8")
+        os.replace(temp_path, path)
@@ -2,9 +2,12 @@
 from __future__ import annotations
 
 from dataclasses import dataclass, field
+from datetime import datetime, time, timedelta
 from enum import Enum
-from ipaddress import IPv4Address, IPv4Network
-from typing import Iterable, Optional
+from ipaddress import IPv4Address, IPv4Interface, IPv4Network, IPv6Address, IPv6Network
+from typing import Iterable, Mapping, Optional
+
+WEEKDAYS = ("monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday")
 
 
 class AddressType(str, Enum):
@@ -13,6 +16,9 @@ class AddressType(str, Enum):
     IPMASK = "ipmask"
     IPRANGE = "iprange"
     FQDN = "fqdn"
+    GEOGRAPHY = "geography"
+    MAC = "mac"
+    DYNAMIC = "dynamic"
 
 
 @dataclass(frozen=True)
@@ -21,26 +27,42 @@ class AddressObject:
 
     name: str
     address_type: AddressType
-    subnet: Optional[IPv4Network] = None
-    start_ip: Optional[IPv4Address] = None
-    end_ip: Optional[IPv4Address] = None
-
-    def contains_ip(self, ip: IPv4Address) -> bool:
+    subnet: Optional[IPv4Network | IPv6Network] = None
+    start_ip: Optional[IPv4Address | IPv6Address] = None
+    end_ip: Optional[IPv4Address | IPv6Address] = None
+    interface: Optional[str] = None
+    fqdn: Optional[str] = None
+    country: Optional[str] = None
+    macaddrs: tuple[str, ...] = ()
+
+    def contains_ip(self, ip: IPv4Address | IPv6Address) -> bool:
         """Return True if the IP address is contained by this object."""
         if self.address_type == AddressType.IPMASK and self.subnet is not None:
             return ip in self.subnet
         if self.address_type == AddressType.IPRANGE and self.start_ip and self.end_ip:
-            return self.start_ip <= ip <= self.end_ip
+            return self.start_ip.version == ip.version and self.start_ip <= ip <= self.end_ip
         return False
 
-    def contains_network(self, network: IPv4Network) -> bool:
+    def contains_network(self, network: IPv4Network | IPv6Network) -> bool:
         """Return True if the network is fully contained by this object."""
         if self.address_type == AddressType.IPMASK and self.subnet is not None:
-            return network.subnet_of(self.subnet)
+            return self.subnet.version == network.version and network.subnet_of(self.subnet)
         if self.address_type == AddressType.IPRANGE and self.start_ip and self.end_ip:
+            if self.start_ip.version != network.version:
+                return False
             return self.start_ip <= network.network_address and self.end_ip >= network.broadcast_address
         return False
 
+    def overlaps_network(self, network: IPv4Network | IPv6Network) -> bool:
+        """Return True if the object holds at least one address of the network."""
+        if self.address_type == AddressType.IPMASK and self.subnet is not None:
+            return self.subnet.version == network.version and self.subnet.overlaps(network)
+        if self.address_type == AddressType.IPRANGE and self.start_ip and self.end_ip:
+            if self.start_ip.version != network.version:
+                return False
+            return self.start_ip <= network.broadcast_address and self.end_ip >= network.network_address
+        return False
+
 
 @dataclass(frozen=True)
 class AddressGroup:
@@ -51,29 +73,44 @@ class AddressGroup:
 
 
 class Protocol(str, Enum):
-    """Supported L4 protocols."""
+    """Supported flow protocols."""
 
     TCP = "tcp"
     UDP = "udp"
+    SCTP = "sctp"
+    ICMP = "icmp"
+
+
+# ICMP flows and service entries carry the ICMP type where TCP/UDP use ports.
+ICMP_TYPE_RANGE = (0, 255)
 
 
 @dataclass(frozen=True)
 class ServiceEntry:
-    """Represents a single service entry (protocol + port range)."""
+    """Represents a single service entry (protocol + port range, or ICMP type range for ICMP).
+
+    An optional source port range narrows the entry for flows that carry a source port.
+    """
 
     protocol: Optional[Protocol]
     start_port: Optional[int]
     end_port: Optional[int]
+    src_start_port: Optional[int] = None
+    src_end_port: Optional[int] = None
 
-    def matches(self, protocol: Protocol, port: int) -> bool:
-        """Return True if this service entry matches the protocol and port."""
+    def matches(self, protocol: Protocol, port: int, src_port: Optional[int] = None) -> bool:
+        """Return True if this service entry matches the protocol, port and (if given) source port."""
         if self.protocol is None:
             return True
         if self.protocol != protocol:
             return False
         if self.start_port is None or self.end_port is None:
             return False
-        return self.start_port <= port <= self.end_port
+        if not self.start_port <= port <= self.end_port:
+            return False
+        if src_port is None or self.src_start_port is None or self.src_end_port is None:
+            return True
+        return self.src_start_port <= src_port <= self.src_end_port
 
 
 @dataclass(frozen=True)
@@ -82,6 +119,7 @@ class ServiceObject:
 
     name: str
     entries: tuple[ServiceEntry, ...]
+    category: Optional[str] = None
 
 
 @dataclass(frozen=True)
@@ -106,6 +144,182 @@ class PolicyRule:
     enabled: bool
     schedule: Optional[str] = None
     comment: Optional[str] = None
+    tcp_session_without_syn: Optional[str] = None
+    anti_replay: Optional[str] = None
+    session_ttl: Optional[str] = None
+    service_negate: bool = False
+    src_interfaces: tuple[str, ...] = ()
+    dst_interfaces: tuple[str, ...] = ()
+    nat: bool = False
+    ip_pools: tuple[str, ...] = ()
+    source6: tuple[str, ...] = ()
+    destination6: tuple[str, ...] = ()
+    internet_services: tuple[str, ...] = ()
+    internet_services_src: tuple[str, ...] = ()
+    uuid: Optional[str] = None
+    logtraffic: Optional[str] = None
+    av_profile: Optional[str] = None
+    ips_sensor: Optional[str] = None
+    webfilter_profile: Optional[str] = None
+    ssl_ssh_profile: Optional[str] = None
+    users: tuple[str, ...] = ()
+    groups: tuple[str, ...] = ()
+    section: Optional[str] = None
+
+    def source_for(self, version: int) -> tuple[str, ...]:
+        """Return the source addresses that apply to traffic of this IP version."""
+        return self.source6 if version == 6 else self.source
+
+    def destination_for(self, version: int) -> tuple[str, ...]:
+        """Return the destination addresses that apply to traffic of this IP version."""
+        return self.destination6 if version == 6 else self.destination
+
+
+@dataclass(frozen=True)
+class SNATRule:
+    """Represents a central source NAT map entry.
+
+    `protocol` is an IP protocol number (0 matches any) and `orig_port` the
+    original source port range, or None when the entry matches any port.
+    """
+
+    rule_id: str
+    orig_addr: tuple[str, ...]
+    dst_addr: tuple[str, ...]
+    nat_ippool: tuple[str, ...]
+    enabled: bool = True
+    nat: bool = True
+    protocol: int = 0
+    orig_port: Optional[tuple[int, int]] = None
+
+
+@dataclass(frozen=True)
+class Schedule:
+    """Represents a FortiGate recurring, one-time or group schedule.
+
+    Times are the firewall's local wall-clock time. A recurring schedule whose
+    end is not after its start runs past midnight into the following day;
+    equal start and end times cover the whole day.
+    """
+
+    name: str
+    kind: str
+    days: tuple[str, ...] = ()
+    start_time: time = time(0, 0)
+    end_time: time = time(0, 0)
+    start: Optional[datetime] = None
+    end: Optional[datetime] = None
+    members: tuple[str, ...] = ()
+
+    def is_active(self, at: datetime) -> bool:
+        """Return True if a recurring or one-time schedule is active at ``at`` (groups are resolved by the caller)."""
+        if self.kind == "onetime":
+            return self.start is not None and self.end is not None and self.start <= at <= self.end
+        if self.kind != "recurring":
+            return False
+        today = WEEKDAYS[at.weekday()]
+        yesterday = WEEKDAYS[(at - timedelta(days=1)).weekday()]
+        now = at.time()
+        if self.start_time == self.end_time:
+            return today in self.days
+        if self.start_time < self.end_time:
+            return today in self.days and self.start_time <= now < self.end_time
+        return (today in self.days and now >= self.start_time) or (yesterday in self.days and now < self.end_time)
+
+
+@dataclass(frozen=True)
+class Interface:
+    """Represents a FortiGate `system interface` and the management access it allows."""
+
+    name: str
+    ip: Optional[IPv4Interface] = None
+    allowaccess: tuple[str, ...] = ()
+    vdom: Optional[str] = None
+
+
+@dataclass(frozen=True)
+class Zone:
+    """Represents a FortiGate `system zone` grouping interfaces under one name."""
+
+    name: str
+    interfaces: tuple[str, ...] = ()
+    intrazone: str = "deny"
+
+
+def expand_zones(names: Iterable[str], zones: Mapping[str, Zone]) -> tuple[str, ...]:
+    """Replace zone names with their member interfaces, keeping other names as given."""
+    expanded: list[str] = []
+    for name in names:
+        zone = zones.get(name)
+        for interface in zone.interfaces if zone is not None else (name,):
+            if interface not in expanded:
+                expanded.append(interface)
+    return tuple(expanded)
+
+
+@dataclass(frozen=True)
+class StaticRoute:
+    """Represents a `router static` entry, or a connected route derived from an interface address."""
+
+    seq_num: str
+    dst: IPv4Network
+    gateway: Optional[IPv4Address] = None
+    device: Optional[str] = None
+    distance: int = 10
+    priority: int = 0
+    blackhole: bool = False
+    enabled: bool = True
+    connected: bool = False
+
+
+@dataclass(frozen=True)
+class PolicyRoute:
+    """Represents a `router policy` (policy-based routing) entry; empty src/dst match any address."""
+
+    seq_num: str
+    input_devices: tuple[str, ...] = ()
+    src: tuple[IPv4Network, ...] = ()
+    dst: tuple[IPv4Network, ...] = ()
+    protocol: int = 0
+    start_port: int = 1
+    end_port: int = 65535
+    gateway: Optional[IPv4Address] = None
+    output_device: Optional[str] = None
+    action: str = "permit"
+    enabled: bool = True
+
+
+@dataclass(frozen=True)
+class IPPool:
+    """Represents a source NAT IP pool (FortiGate `firewall ippool`)."""
+
+    name: str
+    pool_type: str
+    start_ip: IPv4Address
+    end_ip: IPv4Address
+
+    @property
+    def label(self) -> str:
+        """Return the pool name with its external address range."""
+        if self.start_ip == self.end_ip:
+            return f"{self.name} ({self.start_ip})"
+        return f"{self.name} ({self.start_ip}-{self.end_ip})"
+
+
+@dataclass(frozen=True)
+class InternetServiceEntry:
+    """One address range of an Internet Service Database entry and the ports served there."""
+
+    address: AddressObject
+    service: ServiceEntry
+
+
+@dataclass(frozen=True)
+class InternetService:
+    """Represents an Internet Service Database (ISDB) entry referenced by policies."""
+
+    name: str
+    entries: tuple[InternetServiceEntry, ...]
 
 
 class MatchOutcome(str, Enum):
@@ -122,6 +336,7 @@ class Decision(str, Enum):
     ALLOW = "ALLOW"
     DENY = "DENY"
     UNKNOWN = "UNKNOWN"
+    UNROUTABLE = "UNROUTABLE"
 
 
 @dataclass(frozen=True)
@@ -133,44 +348,105 @@ class MatchDetail:
     matched_policy_name: Optional[str]
     matched_policy_action: Optional[str]
     reason: str
+    policy: Optional[PolicyRule] = None
+
+
+@dataclass(frozen=True)
+class VirtualIP:
+    """Represents a destination NAT virtual IP (FortiGate `firewall vip`).
+
+    With port forwarding the VIP only applies to ``protocol`` traffic whose
+    destination port is inside ``ext_ports``.
+    """
+
+    name: str
+    ext_start: IPv4Address
+    ext_end: IPv4Address
+    mapped_start: IPv4Address
+    mapped_end: IPv4Address
+    port_forward: bool = False
+    protocol: Protocol = Protocol.TCP
+    ext_ports: Optional[tuple[int, int]] = None
+    mapped_ports: Optional[tuple[int, int]] = None
+
+    def applies_to_port(self, protocol: Protocol, port: int) -> bool:
+        """Return True if the VIP translates traffic to this protocol/port."""
+        if not self.port_forward:
+            return True
+        if protocol != self.protocol or self.ext_ports is None:
+            return False
+        return self.ext_ports[0] <= port <= self.ext_ports[1]
+
+    def translate_ip(self, ip: IPv4Address) -> IPv4Address:
+        """Map an external address to its internal address, offset-for-offset within the ranges."""
+        if self.mapped_start == self.mapped_end:
+            return self.mapped_start
+        return min(self.mapped_start + (int(ip) - int(self.ext_start)), self.mapped_end)
+
+    def translate_port(self, port: int) -> int:
+        """Map an external port to the mapped port, offset-for-offset within the ranges."""
+        if not self.port_forward or self.ext_ports is None or self.mapped_ports is None:
+            return port
+        return min(self.mapped_ports[0] + (port - self.ext_ports[0]), self.mapped_ports[1])
 
 
 @dataclass
 class AddressBook:
-    """Holds address objects and groups with resolution helpers."""
+    """Holds address objects and groups with resolution helpers.
+
+    IPv6 objects and groups live in their own namespace, as on FortiGate where
+    `address6` and `address` entries may share names such as "all".
+    """
 
     objects: dict[str, AddressObject] = field(default_factory=dict)
     groups: dict[str, AddressGroup] = field(default_factory=dict)
-
-    def resolve_group_members(self, name: str, _visited: Optional[set[str]] = None) -> Iterable[AddressObject]:
+    vips: dict[str, VirtualIP] = field(default_factory=dict)
+    # `firewall vipgrp` entries, also listed in `groups` so address resolution flattens them.
+    vip_groups: dict[str, AddressGroup] = field(default_factory=dict)
+    objects6: dict[str, AddressObject] = field(default_factory=dict)
+    groups6: dict[str, AddressGroup] = field(default_factory=dict)
+
+    def resolve_group_members(
+        self, name: str, _visited: Optional[set[str]] = None, version: int = 4
+    ) -> Iterable[AddressObject]:
         """Resolve all address objects inside a group, recursively."""
-        if name in self.objects:
-            return [self.objects[name]]
-        if name not in self.groups:
+        objects, groups = (self.objects6, self.groups6) if version == 6 else (self.objects, self.groups)
+        if name in objects:
+            return [objects[name]]
+        if name not in groups:
             return []
         visited = _visited or set()
         if name in visited:
             return []
         visited.add(name)
         resolved: list[AddressObject] = []
-        for member in self.groups[name].members:
-            resolved.extend(self.resolve_group_members(member, visited))
+        for member in groups[name].members:
+            resolved.extend(self.resolve_group_members(member, visited, version))
         return resolved
 
 
 @dataclass
 class ServiceBook:
-    """Holds service objects and groups with resolution helpers."""
+    """Holds service objects and groups with resolution helpers.
+
+    A service category name resolves to every service in that category unless
+    a service or group has the same name.
+    """
 
     services: dict[str, ServiceObject] = field(default_factory=dict)
     groups: dict[str, ServiceGroup] = field(default_factory=dict)
+    categories: set[str] = field(default_factory=set)
+
+    def category_members(self, category: str) -> list[ServiceObject]:
+        """Return the services assigned to a category."""
+        return [service for service in self.services.values() if service.category == category]
 
     def resolve_group_members(self, name: str, _visited: Optional[set[str]] = None) -> Iterable[ServiceObject]:
-        """Resolve all service objects inside a group, recursively."""
+        """Resolve all service objects inside a group or category, recursively."""
         if name in self.services:
             return [self.services[name]]
         if name not in self.groups:
-            return []
+            return self.category_members(name) if name in self.categories else []
         visited = _visited or set()
         if name in visited:
             return []
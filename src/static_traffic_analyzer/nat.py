@@ -0,0 +1,66 @@
+"""Address translation applied around the policy lookup: DNAT before, SNAT after."""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from ipaddress import IPv4Address, IPv4Network, IPv6Network, summarize_address_range
+from typing import Mapping, Optional, Sequence
+
+from .models import IPPool, Protocol, VirtualIP
+
+
+@dataclass(frozen=True)
+class NATPath:
+    """The flow as it arrives and as it leaves the firewall, with the translations applied."""
+
+    original: str
+    translated: str
+    steps: tuple[str, ...] = ()
+
+
+def find_dnat_vip(
+    vips: Mapping[str, VirtualIP], dst_network: IPv4Network | IPv6Network, protocol: Protocol, port: int
+) -> Optional[VirtualIP]:
+    """Return the VIP whose external range holds the whole destination and translates the port."""
+    if dst_network.version != 4:
+        return None
+    for vip in vips.values():
+        if not vip.applies_to_port(protocol, port):
+            continue
+        if vip.ext_start <= dst_network.network_address and dst_network.broadcast_address <= vip.ext_end:
+            return vip
+    return None
+
+
+def vip_destination(vip: VirtualIP, dst_network: IPv4Network) -> Optional[IPv4Network]:
+    """Return the mapped destination, or None if it is not a single CIDR block."""
+    first = vip.translate_ip(dst_network.network_address)
+    last = vip.translate_ip(dst_network.broadcast_address)
+    blocks = list(summarize_address_range(first, last))
+    return blocks[0] if len(blocks) == 1 else None
+
+
+def snat_source(
+    nat: bool,
+    pool_names: Sequence[str],
+    ippools: Mapping[str, IPPool],
+    egress_ip: Optional[IPv4Address] = None,
+) -> Optional[str]:
+    """Return the translated source address (range), or None when the source is not translated.
+
+    Without an IP pool the egress interface address is used; it reads
+    ``egress-interface`` when that address is not known.
+    """
+    if not nat:
+        return None
+    for name in pool_names:
+        pool = ippools.get(name)
+        if pool is not None:
+            return str(pool.start_ip) if pool.start_ip == pool.end_ip else f"{pool.start_ip}-{pool.end_ip}"
+    if pool_names:
+        return pool_names[0]
+    return str(egress_ip) if egress_ip is not None else "egress-interface"
+
+
+def flow_label(src: str, dst: str, protocol: Protocol, port: int) -> str:
+    """Format a flow tuple as `src -> dst proto/port`."""
+    return f"{src} -> {dst} {protocol.value}/{port}"
@@ -0,0 +1,620 @@
+"""Output row layout and writers."""
+from __future__ import annotations
+
+import csv
+import gzip
+import json
+import sqlite3
+from ipaddress import IPv4Network, IPv6Network
+from pathlib import Path
+from typing import IO, TYPE_CHECKING, Iterable, Mapping, Optional, Sequence
+
+from .models import Decision, IPPool, MatchDetail, PolicyRule, SNATRule, VirtualIP
+from .utils import ParseError
+
+if TYPE_CHECKING:
+    from .chain import ChainResult
+    from .nat import NATPath
+    from .routing import RouteDecision
+    from .topology import Device
+    from .vdom import VdomHop
+
+
+OUTPUT_FIELDS = [
+    "src_network_segment",
+    "dst_network_segment",
+    "dst_gn",
+    "dst_site",
+    "dst_location",
+    "service_label",
+    "protocol",
+    "port",
+    "decision",
+    "matched_policy_id",
+    "matched_policy_name",
+    "matched_policy_action",
+    "reason",
+]
+
+SESSION_FIELDS = [
+    "matched_policy_tcp_session_without_syn",
+    "matched_policy_anti_replay",
+    "matched_policy_session_ttl",
+]
+
+
+def session_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return informational session handling columns for the matched policy."""
+    if policy is None:
+        return {field: "" for field in SESSION_FIELDS}
+    return {
+        "matched_policy_tcp_session_without_syn": policy.tcp_session_without_syn or "",
+        "matched_policy_anti_replay": policy.anti_replay or "",
+        "matched_policy_session_ttl": policy.session_ttl or "",
+    }
+
+
+NAT_FIELDS = [
+    "snat_rule_id",
+    "translated_source",
+]
+
+
+def _pool_labels(names: Sequence[str], ippools: Optional[Mapping[str, IPPool]]) -> str:
+    pools = ippools or {}
+    return ",".join(pools[name].label if name in pools else name for name in names)
+
+
+def nat_columns(snat_rule: Optional[SNATRule], ippools: Optional[Mapping[str, IPPool]] = None) -> dict[str, str]:
+    """Return source NAT annotation columns for an allowed flow."""
+    if snat_rule is None:
+        return {field: "" for field in NAT_FIELDS}
+    if not snat_rule.nat:
+        translated = "no-nat"
+    elif snat_rule.nat_ippool:
+        translated = _pool_labels(snat_rule.nat_ippool, ippools)
+    else:
+        translated = "egress-interface"
+    return {"snat_rule_id": snat_rule.rule_id, "translated_source": translated}
+
+
+def policy_nat_columns(policy: Optional[PolicyRule], ippools: Optional[Mapping[str, IPPool]] = None) -> dict[str, str]:
+    """Return source NAT annotation columns from the matched policy's own `set nat`/`set poolname`."""
+    if policy is None:
+        return {field: "" for field in NAT_FIELDS}
+    if not policy.nat:
+        translated = "no-nat"
+    elif policy.ip_pools:
+        translated = _pool_labels(policy.ip_pools, ippools)
+    else:
+        translated = "egress-interface"
+    return {"snat_rule_id": "", "translated_source": translated}
+
+
+DNAT_FIELDS = [
+    "dnat_vip",
+    "translated_destination",
+    "translated_port",
+]
+
+
+def dnat_columns(vip: Optional[VirtualIP], dst_network: IPv4Network, port: int) -> dict[str, str | int]:
+    """Return destination NAT columns for a flow sent to a VIP's external address."""
+    if vip is None:
+        return {field: "" for field in DNAT_FIELDS}
+    first = vip.translate_ip(dst_network.network_address)
+    last = vip.translate_ip(dst_network.broadcast_address)
+    return {
+        "dnat_vip": vip.name,
+        "translated_destination": str(first) if first == last else f"{first}-{last}",
+        "translated_port": vip.translate_port(port),
+    }
+
+
+NAT_PATH_FIELDS = [
+    "original_flow",
+    "translated_flow",
+    "nat_steps",
+]
+
+
+def nat_path_columns(path: Optional[NATPath]) -> dict[str, str]:
+    """Return the flow before and after translation, and the DNAT/SNAT steps taken."""
+    if path is None:
+        return {field: "" for field in NAT_PATH_FIELDS}
+    return {"original_flow": path.original, "translated_flow": path.translated, "nat_steps": ";".join(path.steps)}
+
+
+NEAR_MISS_FIELDS = [
+    "near_miss_count",
+    "near_miss_policies",
+]
+
+
+def near_miss_columns(misses: Sequence[tuple[PolicyRule, str]]) -> dict[str, str | int]:
+    """Return remediation hint columns listing accept policies that missed by one dimension."""
+    return {
+        "near_miss_count": len(misses),
+        "near_miss_policies": ";".join(f"{policy.policy_id}:{dimension}" for policy, dimension in misses),
+    }
+
+
+MATCH_ALL_FIELDS = [
+    "all_matched_policy_count",
+    "all_matched_policies",
+]
+
+
+def match_all_columns(matches: Sequence[MatchDetail]) -> dict[str, str | int]:
+    """Return every policy matching the flow, in priority order, as `id:action` pairs."""
+    return {
+        "all_matched_policy_count": len(matches),
+        "all_matched_policies": ";".join(
+            f"{match.matched_policy_id}:{match.matched_policy_action}" for match in matches
+        ),
+    }
+
+
+REVERSE_FIELDS = [
+    "reverse_port",
+    "reverse_decision",
+    "reverse_policy_id",
+    "asymmetric",
+]
+
+
+def reverse_columns(forward: MatchDetail, reverse: Optional[MatchDetail], port: Optional[int]) -> dict[str, str | int]:
+    """Return the decision for the flow's return direction and whether exactly one direction is allowed.
+
+    ``asymmetric`` is `yes` only when one direction is allowed and the other
+    denied; undecided directions never count.
+    """
+    if reverse is None or port is None:
+        return {field: "" for field in REVERSE_FIELDS}
+    decisions = {forward.decision, reverse.decision}
+    return {
+        "reverse_port": port,
+        "reverse_decision": reverse.decision.value,
+        "reverse_policy_id": reverse.matched_policy_id or "",
+        "asymmetric": "yes" if decisions == {Decision.ALLOW, Decision.DENY} else "no",
+    }
+
+
+RAW_REFERENCE_FIELDS = [
+    "matched_policy_srcaddr",
+    "matched_policy_dstaddr",
+    "matched_policy_service",
+]
+
+
+def raw_reference_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the matched policy's object names as written, before group flattening."""
+    if policy is None:
+        return {field: "" for field in RAW_REFERENCE_FIELDS}
+    return {
+        "matched_policy_srcaddr": ",".join((*policy.source, *policy.source6)),
+        "matched_policy_dstaddr": ",".join((*policy.destination, *policy.destination6)),
+        "matched_policy_service": ",".join(policy.services),
+    }
+
+
+COMMENT_FIELDS = [
+    "matched_policy_comments",
+    "matched_policy_uuid",
+]
+
+
+def comment_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the matched policy's comments and UUID for tracing results back to change tickets."""
+    if policy is None:
+        return {field: "" for field in COMMENT_FIELDS}
+    return {"matched_policy_comments": policy.comment or "", "matched_policy_uuid": policy.uuid or ""}
+
+
+SECTION_FIELDS = [
+    "matched_policy_section",
+]
+
+
+def section_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the GUI section (global-label) the matched policy sits in."""
+    if policy is None:
+        return {field: "" for field in SECTION_FIELDS}
+    return {"matched_policy_section": policy.section or ""}
+
+
+EXACT_FIELDS = [
+    "src_subrange",
+    "dst_subrange",
+]
+
+
+def exact_columns(src_piece: IPv4Network | IPv6Network, dst_piece: IPv4Network | IPv6Network) -> dict[str, str]:
+    """Return the part of the segment pair a row decides in exact mode."""
+    return {"src_subrange": str(src_piece), "dst_subrange": str(dst_piece)}
+
+
+HIT_COUNT_FIELDS = [
+    "matched_policy_observed_hits",
+]
+
+
+def hit_count_columns(policy_id: Optional[str], hits: Mapping[str, int]) -> dict[str, str | int]:
+    """Return how often the matched policy appears in the imported traffic log."""
+    if not policy_id:
+        return {field: "" for field in HIT_COUNT_FIELDS}
+    return {"matched_policy_observed_hits": hits.get(policy_id, 0)}
+
+
+UTM_FIELDS = [
+    "matched_policy_av_profile",
+    "matched_policy_ips_sensor",
+    "matched_policy_webfilter_profile",
+    "matched_policy_ssl_ssh_profile",
+]
+
+
+def utm_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the security profiles the matched policy inspects traffic with."""
+    if policy is None:
+        return {field: "" for field in UTM_FIELDS}
+    return {
+        "matched_policy_av_profile": policy.av_profile or "",
+        "matched_policy_ips_sensor": policy.ips_sensor or "",
+        "matched_policy_webfilter_profile": policy.webfilter_profile or "",
+        "matched_policy_ssl_ssh_profile": policy.ssl_ssh_profile or "",
+    }
+
+
+INTERFACE_FIELDS = [
+    "matched_policy_srcintf",
+    "matched_policy_dstintf",
+]
+
+
+def interface_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the interfaces the matched policy applies to."""
+    if policy is None:
+        return {field: "" for field in INTERFACE_FIELDS}
+    return {
+        "matched_policy_srcintf": ",".join(policy.src_interfaces),
+        "matched_policy_dstintf": ",".join(policy.dst_interfaces),
+    }
+
+
+DOS_FIELDS = [
+    "dos_policy_id",
+    "dos_policy_name",
+]
+
+
+def dos_columns(policy: Optional[PolicyRule]) -> dict[str, str]:
+    """Return the DoS policy the flow also traverses, if any."""
+    if policy is None:
+        return {field: "" for field in DOS_FIELDS}
+    return {"dos_policy_id": policy.policy_id, "dos_policy_name": policy.name}
+
+
+ROUTE_FIELDS = [
+    "egress_interface",
+    "egress_route",
+]
+
+
+def route_columns(decision: Optional[RouteDecision]) -> dict[str, str]:
+    """Return the interface and route a flow leaves through in routing-aware mode."""
+    if decision is None:
+        return {field: "" for field in ROUTE_FIELDS}
+    return {"egress_interface": decision.egress_interface or "", "egress_route": decision.route or ""}
+
+
+CHAIN_FIELDS = [
+    "chain_decision",
+    "chain_blocking_hop",
+    "chain_blocking_policy_id",
+]
+
+
+def chain_columns(result: Optional[ChainResult]) -> dict[str, str]:
+    """Return end-to-end reachability columns for a flow evaluated across chained firewalls."""
+    if result is None:
+        return {field: "" for field in CHAIN_FIELDS}
+    blocking = result.blocking_detail
+    return {
+        "chain_decision": result.decision.value,
+        "chain_blocking_hop": result.blocking_hop or "",
+        "chain_blocking_policy_id": (blocking.matched_policy_id or "") if blocking else "",
+    }
+
+
+TOPOLOGY_FIELDS = [
+    "topology_path",
+    "topology_decision",
+    "topology_blocking_device",
+    "topology_blocking_policy_id",
+]
+
+
+VDOM_FIELDS = [
+    "vdom_path",
+    "vdom_decision",
+    "vdom_blocking_vdom",
+    "vdom_blocking_policy_id",
+]
+
+
+def vdom_columns(path: Optional[Sequence[VdomHop]], result: Optional[ChainResult]) -> dict[str, str]:
+    """Return the VDOMs a flow crosses over inter-VDOM links and the first one that blocks it."""
+    if path is None:
+        return {**{field: "" for field in VDOM_FIELDS}, "vdom_decision": "NO_PATH"}
+    blocking = result.blocking_detail if result is not None else None
+    return {
+        "vdom_path": ">".join(hop.vdom for hop in path),
+        "vdom_decision": result.decision.value if result is not None else "",
+        "vdom_blocking_vdom": (result.blocking_hop or "") if result is not None else "",
+        "vdom_blocking_policy_id": (blocking.matched_policy_id or "") if blocking else "",
+    }
+
+
+def topology_columns(path: Optional[Sequence[Device]], result: Optional[ChainResult]) -> dict[str, str]:
+    """Return the devices between a flow's segments and the first one that blocks it."""
+    if path is None:
+        return {**{field: "" for field in TOPOLOGY_FIELDS}, "topology_decision": "NO_PATH"}
+    blocking = result.blocking_detail if result is not None else None
+    return {
+        "topology_path": ">".join(device.name for device in path),
+        "topology_decision": result.decision.value if result is not None else "",
+        "topology_blocking_device": (result.blocking_hop or "") if result is not None else "",
+        "topology_blocking_policy_id": (blocking.matched_policy_id or "") if blocking else "",
+    }
+
+
+SOURCE_SET_FIELDS = [
+    "source_set",
+]
+
+SOURCE_SET_CSV = "src-csv"
+SOURCE_SET_THREAT_FEED = "threat-feed"
+
+
+METADATA_COLLISION_POLICIES = ("reject", "prefix")
+
+
+def metadata_field(prefix: str, header: str) -> str:
+    """Return the output column name for an input metadata header."""
+    return f"{prefix}{header.strip().lower().replace(' ', '_')}"
+
+
+def metadata_fields(
+    headers: Iterable[str],
+    prefix: str,
+    reserved: Iterable[str] = (),
+    collision: str = "reject",
+    exclude: Iterable[str] = ("Network Segment",),
+) -> dict[str, str]:
+    """Map extra input CSV headers to prefixed output columns.
+
+    A column that would collide with a ``reserved`` output column or an earlier
+    header is rejected, or with ``collision="prefix"`` renamed to
+    ``<prefix>meta_<header>``.
+    """
+    skipped = set(exclude)
+    taken = set(reserved)
+    columns: dict[str, str] = {}
+    for header in headers:
+        if not header or header in skipped:
+            continue
+        column = metadata_field(prefix, header)
+        if column in taken and collision == "prefix":
+            column = metadata_field(f"{prefix}meta_", header)
+        if column in taken:
+            hint = "rename the header" if collision == "prefix" else "rename it or use --metadata-collision prefix"
+            raise ParseError(f"Metadata column {header!r} collides with output column {column!r}; {hint}")
+        taken.add(column)
+        columns[header] = column
+    return columns
+
+
+def metadata_columns(record: dict[str, str], columns: Mapping[str, str]) -> dict[str, str]:
+    """Return the metadata columns for one input record, named as mapped by ``metadata_fields``."""
+    return {column: record.get(header, "") for header, column in columns.items()}
+
+
+AGGREGATE_FIELDS = [
+    "flow_count",
+]
+
+
+def aggregate_rows(
+    rows: Iterable[dict[str, str | int | None]],
+    fields: Sequence[str],
+) -> list[dict[str, str | int | None]]:
+    """Collapse rows identical on every written column into one, summing their flow counts.
+
+    A row without a flow count stands for one flow. Rows keep the order in
+    which each distinct result first appears.
+    """
+    key_fields = [field for field in fields if field not in AGGREGATE_FIELDS]
+    aggregated: dict[tuple[str | int | None, ...], dict[str, str | int | None]] = {}
+    for row in rows:
+        key = tuple(row.get(field) for field in key_fields)
+        count = int(row.get("flow_count") or 1)
+        existing = aggregated.get(key)
+        if existing is None:
+            aggregated[key] = {**row, "flow_count": count}
+        else:
+            existing["flow_count"] = int(existing["flow_count"] or 0) + count
+    return list(aggregated.values())
+
+
+# Result file formats: positional CSV with a header, or one JSON object per line.
+OUTPUT_FORMATS = ("csv", "jsonl")
+
+
+def json_line(row: Mapping[str, str | int | None], fieldnames: Sequence[str]) -> str:
+    """Return a row as one line of JSON with keys in column order; missing columns are null."""
+    return json.dumps({field: row.get(field) for field in fieldnames}, ensure_ascii=False) + "\n"
+
+
+def write_output(
+    output_path: Path,
+    rows: Iterable[dict[str, str | int | None]],
+    extra_fields: Sequence[str] = (),
+    output_format: str = "csv",
+) -> None:
+    """Write output rows to a CSV or JSON Lines file."""
+    fieldnames = [*OUTPUT_FIELDS, *extra_fields]
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        if output_format == "jsonl":
+            for row in rows:
+                handle.write(json_line(row, fieldnames))
+            return
+        writer = csv.DictWriter(handle, fieldnames=fieldnames)
+        writer.writeheader()
+        for row in rows:
+            writer.writerow(row)
+
+
+# An --out path with one of these suffixes is written as a SQLite database.
+SQLITE_SUFFIXES = (".db", ".sqlite", ".sqlite3")
+
+
+def _quote_identifier(name: str) -> str:
+    # Metadata columns are named after input CSV headers, which may hold spaces or quotes.
+    return '"' + name.replace('"', '""') + '"'
+
+
+def write_sqlite_output(
+    output_path: Path,
+    rows: Iterable[dict[str, str | int | None]],
+    extra_fields: Sequence[str] = (),
+    metadata: Optional[Mapping[str, str]] = None,
+) -> None:
+    """Write output rows to a `results` table of a new SQLite database, with run details in `run_metadata`.
+
+    Result columns have no declared type, so ports and counts stay integers.
+    `decision` and `matched_policy_id` are indexed for ad-hoc queries. An
+    existing file at ``output_path`` is replaced.
+    """
+    fieldnames = [*OUTPUT_FIELDS, *extra_fields]
+    columns = ", ".join(_quote_identifier(field) for field in fieldnames)
+    output_path.unlink(missing_ok=True)
+    connection = sqlite3.connect(output_path)
+    try:
+        connection.execute(f"CREATE TABLE results ({columns})")
+        connection.executemany(
+            f"INSERT INTO results ({columns}) VALUES ({', '.join('?' for _ in fieldnames)})",
+            (tuple(row.get(field) for field in fieldnames) for row in rows),
+        )
+        # Indexing after the bulk insert is cheaper than maintaining the indexes row by row.
+        connection.execute("CREATE INDEX results_decision ON results (decision)")
+        connection.execute("CREATE INDEX results_policy ON results (matched_policy_id, decision)")
+        (count,) = connection.execute("SELECT COUNT(*) FROM results").fetchone()
+        connection.execute("CREATE TABLE run_metadata (key TEXT PRIMARY KEY, value TEXT)")
+        connection.executemany(
+            "INSERT INTO run_metadata (key, value) VALUES (?, ?)",
+            [*(metadata or {}).items(), ("rows", str(count)), ("columns", ",".join(fieldnames))],
+        )
+        connection.commit()
+    finally:
+        connection.close()
+
+
+DEFAULT_SHARD_SIZE = 100_000
+
+
+PARTITIONS = ("allow", "deny", "unmatched", "unknown", "unroutable")
+
+
+def decision_partition(row: Mapping[str, str | int | None]) -> str:
+    """Return the output subdirectory for a row."""
+    if row["decision"] == Decision.ALLOW.value:
+        return "allow"
+    if row["decision"] == Decision.UNKNOWN.value:
+        return "unknown"
+    if row["decision"] == Decision.UNROUTABLE.value:
+        return "unroutable"
+    if not row.get("matched_policy_id"):
+        return "unmatched"
+    return "deny"
+
+
+class _ShardWriter:
+    """Write CSV or JSON Lines rows into numbered shard files, rolling over every shard_size rows."""
+
+    def __init__(
+        self, directory: Path, fieldnames: Sequence[str], shard_size: int, compress: bool, output_format: str = "csv"
+    ) -> None:
+        self.directory = directory
+        self.fieldnames = list(fieldnames)
+        self.shard_size = shard_size
+        self.compress = compress
+        self.output_format = output_format
+        self.shard_index = 0
+        self.rows_in_shard = 0
+        self._handle: Optional[IO[str]] = None
+        self._writer: Optional[csv.DictWriter] = None
+
+    def _open_next(self) -> None:
+        self.close()
+        suffix = f".{self.output_format}.gz" if self.compress else f".{self.output_format}"
+        path = self.directory / f"part-{self.shard_index:05d}{suffix}"
+        if self.compress:
+            self._handle = gzip.open(path, "wt", newline="", encoding="utf-8")
+        else:
+            self._handle = path.open("w", newline="", encoding="utf-8")
+        if self.output_format == "csv":
+            self._writer = csv.DictWriter(self._handle, fieldnames=self.fieldnames)
+            self._writer.writeheader()
+        self.shard_index += 1
+        self.rows_in_shard = 0
+
+    def write(self, row: Mapping[str, str | int | None]) -> None:
+        if self._handle is None or self.rows_in_shard >= self.shard_size:
+            self._open_next()
+        assert self._handle is not None
+        if self._writer is not None:
+            self._writer.writerow(row)
+        else:
+            self._handle.write(json_line(row, self.fieldnames))
+        self.rows_in_shard += 1
+
+    def close(self) -> None:
+        if self._handle is not None:
+            self._handle.close()
+        self._handle = None
+        self._writer = None
+
+
+def write_partitioned_output(
+    output_dir: Path,
+    rows: Iterable[dict[str, str | int | None]],
+    extra_fields: Sequence[str] = (),
+    shard_size: int = DEFAULT_SHARD_SIZE,
+    compress: bool = False,
+    output_format: str = "csv",
+) -> None:
+    """Write rows under allow/, deny/, unmatched/ and unknown/ subdirectories in sharded CSV or JSON Lines files.
+
+    Shards left by an earlier run are removed first, so a partition that gets
+    fewer rows (or none) this time does not keep stale results.
+    """
+    fieldnames = [*OUTPUT_FIELDS, *extra_fields]
+    for partition in PARTITIONS:
+        for stale in (output_dir / partition).glob("part-*"):
+            stale.unlink()
+    writers: dict[str, _ShardWriter] = {}
+    try:
+        for row in rows:
+            partition = decision_partition(row)
+            writer = writers.get(partition)
+            if writer is None:
+                directory = output_dir / partition
+                directory.mkdir(parents=True, exist_ok=True)
+                writer = writers[partition] = _ShardWriter(
+                    directory, fieldnames, shard_size, compress, output_format
+                )
+            writer.write(row)
+    finally:
+        for writer in writers.values():
+            writer.close()
@@ -0,0 +1,274 @@
+"""Policy match spaces as address blocks and port ranges, for comparing policies with each other."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from ipaddress import IPv4Network, IPv6Network, collapse_addresses, summarize_address_range
+from pathlib import Path
+from typing import Iterable, Mapping, Optional, Sequence
+
+from .models import AddressBook, AddressType, PolicyRule, Protocol, ServiceBook
+
+
+Network = IPv4Network | IPv6Network
+PortRange = tuple[int, int]
+
+
+@dataclass(frozen=True)
+class PolicySpace:
+    """The sources, destinations and services a policy matches.
+
+    Service ranges are keyed by protocol; the ``None`` key stands for every
+    protocol and port.
+    """
+
+    source: tuple[Network, ...]
+    destination: tuple[Network, ...]
+    services: Mapping[Optional[Protocol], tuple[PortRange, ...]]
+
+
+def _address_blocks(address_book: AddressBook, names: Iterable[str], version: int) -> Optional[list[Network]]:
+    blocks: list[Network] = []
+    for name in names:
+        members = list(address_book.resolve_group_members(name, version=version))
+        if not members:
+            return None
+        for obj in members:
+            if obj.address_type == AddressType.IPMASK and obj.subnet is not None:
+                blocks.append(obj.subnet)
+            elif obj.address_type == AddressType.IPRANGE and obj.start_ip is not None and obj.end_ip is not None:
+                blocks.extend(summarize_address_range(obj.start_ip, obj.end_ip))
+            else:
+                # FQDN, geography, MAC and dynamic objects have no fixed address space.
+                return None
+    return blocks
+
+
+def collapse_networks(blocks: Iterable[Network]) -> tuple[Network, ...]:
+    """Merge adjacent and nested blocks, IPv4 first."""
+    v4 = [block for block in blocks if block.version == 4]
+    v6 = [block for block in blocks if block.version == 6]
+    return (*collapse_addresses(v4), *collapse_addresses(v6))
+
+
+def subtract_networks(networks: Sequence[Network], remove: Sequence[Network]) -> tuple[Network, ...]:
+    """Return the parts of ``networks`` outside every block of ``remove``."""
+    remaining = list(networks)
+    for hole in remove:
+        kept: list[Network] = []
+        for block in remaining:
+            if block.version != hole.version or not block.overlaps(hole):
+                kept.append(block)
+            elif not block.subnet_of(hole):
+                kept.extend(block.address_exclude(hole))
+        remaining = kept
+    return collapse_networks(remaining)
+
+
+def merge_ranges(ranges: Iterable[PortRange]) -> tuple[PortRange, ...]:
+    """Merge overlapping and adjacent port ranges, in port order."""
+    merged: list[PortRange] = []
+    for start, end in sorted(ranges):
+        if merged and start <= merged[-1][1] + 1:
+            merged[-1] = (merged[-1][0], max(merged[-1][1], end))
+        else:
+            merged.append((start, end))
+    return tuple(merged)
+
+
+def policy_space(policy: PolicyRule, address_book: AddressBook, service_book: ServiceBook) -> Optional[PolicySpace]:
+    """Return the space a policy matches, or None if it depends on more than plain addresses and ports."""
+    if policy.service_negate or policy.internet_services or policy.internet_services_src:
+        return None
+    if policy.users or policy.groups:
+        return None
+    source4 = _address_blocks(address_book, policy.source, 4)
+    source6 = _address_blocks(address_book, policy.source6, 6)
+    destination4 = _address_blocks(address_book, policy.destination, 4)
+    destination6 = _address_blocks(address_book, policy.destination6, 6)
+    if None in (source4, source6, destination4, destination6) or not policy.services:
+        return None
+    services: dict[Optional[Protocol], list[PortRange]] = {}
+    for name in policy.services:
+        members = list(service_book.resolve_group_members(name))
+        if not members:
+            return None
+        for entry in (entry for service in members for entry in service.entries):
+            if entry.protocol is None:
+                services.setdefault(None, []).append((0, 65535))
+            elif entry.start_port is None or entry.end_port is None:
+                return None
+            else:
+                services.setdefault(entry.protocol, []).append((entry.start_port, entry.end_port))
+    return PolicySpace(
+        source=collapse_networks([*source4, *source6]),
+        destination=collapse_networks([*destination4, *destination6]),
+        services={protocol: merge_ranges(ranges) for protocol, ranges in services.items()},
+    )
+
+
+def _networks_cover(cover: Sequence[Network], blocks: Sequence[Network]) -> bool:
+    return all(any(block.version == outer.version and block.subnet_of(outer) for outer in cover) for block in blocks)
+
+
+def _ranges_cover(cover: Sequence[PortRange], ranges: Sequence[PortRange]) -> bool:
+    return all(any(outer[0] <= start and end <= outer[1] for outer in cover) for start, end in ranges)
+
+
+def covers(outer: PolicySpace, inner: PolicySpace) -> bool:
+    """Return True if every flow in ``inner`` is also in ``outer``."""
+    if not (_networks_cover(outer.source, inner.source) and _networks_cover(outer.destination, inner.destination)):
+        return False
+    if None in outer.services:
+        return True
+    if None in inner.services:
+        return False
+    return all(
+        _ranges_cover(outer.services.get(protocol, ()), ranges) for protocol, ranges in inner.services.items()
+    )
+
+
+def interfaces_cover(outer: Sequence[str], inner: Sequence[str]) -> bool:
+    """Return True if the ``outer`` srcintf/dstintf list admits every interface of ``inner``."""
+    if not outer or "any" in outer:
+        return True
+    return bool(inner) and "any" not in inner and set(inner) <= set(outer)
+
+
+def intersect_networks(first: Sequence[Network], second: Sequence[Network]) -> tuple[Network, ...]:
+    """Return the blocks both lists contain."""
+    # Two CIDR blocks are either disjoint or one holds the other.
+    shared: list[Network] = []
+    for a in first:
+        for b in second:
+            if a.version != b.version:
+                continue
+            if a.subnet_of(b):
+                shared.append(a)
+            elif b.subnet_of(a):
+                shared.append(b)
+    return collapse_networks(shared)
+
+
+def _intersect_ranges(first: Sequence[PortRange], second: Sequence[PortRange]) -> tuple[PortRange, ...]:
+    shared = [(max(a[0], b[0]), min(a[1], b[1])) for a in first for b in second if max(a[0], b[0]) <= min(a[1], b[1])]
+    return merge_ranges(shared)
+
+
+def intersect(first: PolicySpace, second: PolicySpace) -> Optional[PolicySpace]:
+    """Return the flows both spaces match, or None if they are disjoint."""
+    source = intersect_networks(first.source, second.source)
+    destination = intersect_networks(first.destination, second.destination)
+    # A flow needs a source and destination of the same IP version.
+    versions = {block.version for block in source} & {block.version for block in destination}
+    if not versions:
+        return None
+    if None in first.services:
+        services = dict(second.services)
+    elif None in second.services:
+        services = dict(first.services)
+    else:
+        services = {
+            protocol: shared
+            for protocol, ranges in first.services.items()
+            if (shared := _intersect_ranges(ranges, second.services.get(protocol, ())))
+        }
+    if not services:
+        return None
+    return PolicySpace(
+        source=tuple(block for block in source if block.version in versions),
+        destination=tuple(block for block in destination if block.version in versions),
+        services=services,
+    )
+
+
+def interfaces_overlap(first: Sequence[str], second: Sequence[str]) -> bool:
+    """Return True if two srcintf/dstintf lists can admit the same interface."""
+    if not first or not second or "any" in first or "any" in second:
+        return True
+    return bool(set(first) & set(second))
+
+
+@dataclass(frozen=True)
+class PolicyOverlap:
+    """Two policies that match some of the same flows; the earlier one decides them."""
+
+    earlier: PolicyRule
+    later: PolicyRule
+    shared: PolicySpace
+
+    @property
+    def conflict(self) -> bool:
+        """Return True if the policies disagree on whether the shared flows are allowed."""
+        return (self.earlier.action.lower() == "accept") != (self.later.action.lower() == "accept")
+
+
+def find_overlaps(
+    policies: Iterable[PolicyRule], address_book: AddressBook, service_book: ServiceBook
+) -> list[PolicyOverlap]:
+    """Compare every pair of enabled policies and return those whose match spaces intersect.
+
+    Schedules are not considered; policies that depend on more than plain
+    addresses and ports are skipped.
+    """
+    rules = [policy for policy in policies if policy.enabled]
+    spaces = [policy_space(policy, address_book, service_book) for policy in rules]
+    overlaps: list[PolicyOverlap] = []
+    for index, (later, later_space) in enumerate(zip(rules, spaces)):
+        if later_space is None:
+            continue
+        for earlier, earlier_space in zip(rules[:index], spaces[:index]):
+            if earlier_space is None:
+                continue
+            if not interfaces_overlap(earlier.src_interfaces, later.src_interfaces):
+                continue
+            if not interfaces_overlap(earlier.dst_interfaces, later.dst_interfaces):
+                continue
+            shared = intersect(earlier_space, later_space)
+            if shared is not None:
+                overlaps.append(PolicyOverlap(earlier=earlier, later=later, shared=shared))
+    return overlaps
+
+
+def format_services(services: Mapping[Optional[Protocol], Sequence[PortRange]]) -> str:
+    """Format service ranges as `tcp/80;udp/1000-2000`, or `all` for every protocol."""
+    if None in services:
+        return "all"
+    return ";".join(
+        f"{protocol.value}/{start}" if start == end else f"{protocol.value}/{start}-{end}"
+        for protocol, ranges in services.items()
+        if protocol is not None
+        for start, end in ranges
+    )
+
+
+OVERLAP_FIELDS = [
+    "earlier_policy_id",
+    "earlier_action",
+    "later_policy_id",
+    "later_action",
+    "conflict",
+    "source",
+    "destination",
+    "services",
+]
+
+
+def write_overlaps(output_path: Path, overlaps: Iterable[PolicyOverlap]) -> None:
+    """Write overlapping policy pairs and the flows they share as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=OVERLAP_FIELDS)
+        writer.writeheader()
+        for overlap in overlaps:
+            writer.writerow(
+                {
+                    "earlier_policy_id": overlap.earlier.policy_id,
+                    "earlier_action": overlap.earlier.action,
+                    "later_policy_id": overlap.later.policy_id,
+                    "later_action": overlap.later.action,
+                    "conflict": "yes" if overlap.conflict else "no",
+                    "source": ";".join(str(block) for block in overlap.shared.source),
+                    "destination": ";".join(str(block) for block in overlap.shared.destination),
+                    "services": format_services(overlap.shared.services),
+                }
+            )
@@ -0,0 +1,426 @@
+"""Parser for Cisco ASA running configurations."""
+from __future__ import annotations
+
+from dataclasses import replace
+from typing import Iterable, Optional
+
+from ..models import (
+    AddressBook,
+    AddressGroup,
+    PolicyRule,
+    Protocol,
+    ServiceBook,
+    ServiceEntry,
+    ServiceGroup,
+    ServiceObject,
+)
+from ..utils import ParseError, parse_address_object
+from .common import NON_L4_ENTRY, RuleSet, add_builtin_objects
+
+
+# Port names the ASA prints instead of numbers.
+ASA_PORT_NAMES = {
+    "echo": 7,
+    "ftp-data": 20,
+    "ftp": 21,
+    "ssh": 22,
+    "telnet": 23,
+    "smtp": 25,
+    "domain": 53,
+    "bootps": 67,
+    "bootpc": 68,
+    "tftp": 69,
+    "www": 80,
+    "http": 80,
+    "kerberos": 88,
+    "pop3": 110,
+    "ntp": 123,
+    "netbios-ns": 137,
+    "netbios-dgm": 138,
+    "netbios-ssn": 139,
+    "imap4": 143,
+    "snmp": 161,
+    "snmptrap": 162,
+    "ldap": 389,
+    "https": 443,
+    "syslog": 514,
+    "ldaps": 636,
+    "sqlnet": 1521,
+    "radius": 1645,
+    "radius-acct": 1646,
+}
+
+PORT_OPERATORS = ("eq", "neq", "lt", "gt", "range")
+
+# Address keywords and the IP versions they cover; plain `any` covers both on ASA 9.x.
+ANY_ADDRESSES = {"any": (4, 6), "any4": (4,), "any6": (6,)}
+
+
+def _host(address: str) -> str:
+    return f"{address}/128" if ":" in address else f"{address}/32"
+
+
+def _port(value: str) -> int:
+    if value.isdigit():
+        return int(value)
+    if value in ASA_PORT_NAMES:
+        return ASA_PORT_NAMES[value]
+    raise ParseError(f"Unknown ASA port name: {value}")
+
+
+PortMatch = tuple[str, list[str]]
+
+
+def _port_ranges(port: Optional[PortMatch]) -> list[tuple[int, int]]:
+    """Translate an ASA port operator into port ranges; no operator means every port."""
+    if port is None:
+        return [(1, 65535)]
+    operator, values = port
+    if operator == "eq":
+        ranges = [(_port(values[0]), _port(values[0]))]
+    elif operator == "range":
+        ranges = [(_port(values[0]), _port(values[1]))]
+    elif operator == "lt":
+        ranges = [(1, _port(values[0]) - 1)]
+    elif operator == "gt":
+        ranges = [(_port(values[0]) + 1, 65535)]
+    elif operator == "neq":
+        port = _port(values[0])
+        ranges = [(1, port - 1), (port + 1, 65535)]
+    else:
+        raise ParseError(f"Unsupported ASA port operator: {operator}")
+    return [(start, end) for start, end in ranges if start <= end]
+
+
+def _port_entries(
+    protocols: Iterable[Protocol], port: Optional[PortMatch], source_port: Optional[PortMatch] = None
+) -> list[ServiceEntry]:
+    """Return service entries for destination and source port operators."""
+    source_ranges: list[tuple[Optional[int], Optional[int]]] = [(None, None)]
+    if source_port is not None:
+        source_ranges = [*_port_ranges(source_port)]
+    return [
+        ServiceEntry(protocol=protocol, start_port=start, end_port=end, src_start_port=src_start, src_end_port=src_end)
+        for protocol in protocols
+        for start, end in _port_ranges(port)
+        for src_start, src_end in source_ranges
+    ]
+
+
+def _protocols(value: str) -> Optional[tuple[Protocol, ...]]:
+    """Return the L4 protocols for an ASA protocol keyword, or None for `ip` (any)."""
+    if value == "ip":
+        return None
+    if value == "tcp-udp":
+        return (Protocol.TCP, Protocol.UDP)
+    if value not in (Protocol.TCP.value, Protocol.UDP.value):
+        # ICMP type keywords are not parsed, so icmp lines are skipped like other protocols.
+        return ()
+    return (Protocol(value),)
+
+
+def _consume_port(tokens: list[str], index: int) -> tuple[Optional[PortMatch], int]:
+    """Read an optional port operator at tokens[index]."""
+    if index >= len(tokens) or tokens[index] not in PORT_OPERATORS:
+        return None, index
+    operator = tokens[index]
+    width = 2 if operator == "range" else 1
+    return (operator, tokens[index + 1 : index + 1 + width]), index + 1 + width
+
+
+def _service_ports(tokens: list[str], index: int) -> tuple[Optional[PortMatch], Optional[PortMatch]]:
+    """Read `[source OP PORT] [destination OP PORT]`, or a bare destination operator, as (destination, source)."""
+    source_port = port = None
+    if index < len(tokens) and tokens[index] == "source":
+        source_port, index = _consume_port(tokens, index + 1)
+    if index < len(tokens) and tokens[index] == "destination":
+        index += 1
+    port, _ = _consume_port(tokens, index)
+    return port, source_port
+
+
+def _port_label(protocols: Iterable[Protocol], port: Optional[PortMatch], source_port: Optional[PortMatch]) -> str:
+    label = "/".join(protocol.value for protocol in protocols)
+    if source_port is not None:
+        label += f" source {source_port[0]} {' '.join(source_port[1])}"
+    if port is not None:
+        label += f" {port[0]} {' '.join(port[1])}"
+    return label
+
+
+def parse_asa_config(lines: Iterable[str]) -> RuleSet:
+    """Parse ASA objects, object-groups and extended access-lists into internal models.
+
+    Policy IDs are ``<acl>:<line>`` with lines numbered as ``show access-list``
+    does, remarks included. Entries for protocols other than TCP/UDP/IP are
+    skipped since they can never match a simulated flow. Source port
+    operators become the service's source port range.
+
+    When the config has ``access-group`` lines, only bound access-lists are
+    kept: interface ACLs first, with their rules limited to the bound
+    interface (srcintf for ``in``, dstintf for ``out``), then the global ACL.
+    They still form one first-match list, so a flow is only held to its own
+    interfaces' ACLs when its interfaces are known. Without
+    ``access-group`` lines every access-list is evaluated in file order.
+    ``interface <nameif>`` addresses stay unresolved and evaluate as UNKNOWN.
+    """
+    address_book = AddressBook()
+    service_book = ServiceBook()
+    policies: list[PolicyRule] = []
+
+    current_kind: Optional[str] = None
+    current_name: Optional[str] = None
+    current_protocols: Optional[tuple[Protocol, ...]] = None
+    members: list[str] = []
+    entries: list[ServiceEntry] = []
+    acl_lines: dict[str, int] = {}
+    acl_policies: dict[str, list[PolicyRule]] = {}
+    # ACL name -> (direction, interface) of each access-group binding; interface is None for `global`.
+    bindings: dict[str, list[tuple[str, Optional[str]]]] = {}
+
+    def flush() -> None:
+        nonlocal current_kind, current_name, members, entries
+        if current_kind == "network-group" and current_name:
+            # Filed under both IP versions until resolve_versions() knows which members it has.
+            address_book.groups[current_name] = AddressGroup(name=current_name, members=tuple(members))
+            address_book.groups6[current_name] = address_book.groups[current_name]
+        elif current_kind == "service" and current_name:
+            if members:
+                service_book.groups[current_name] = ServiceGroup(name=current_name, members=tuple(members))
+            else:
+                service_book.services[current_name] = ServiceObject(
+                    name=current_name, entries=tuple(entries) or (NON_L4_ENTRY,)
+                )
+        elif current_kind == "service-group" and current_name:
+            if entries:
+                # port-object lines have no name of their own; keep them in a member object.
+                port_objects = f"{current_name} port-objects"
+                service_book.services[port_objects] = ServiceObject(name=port_objects, entries=tuple(entries))
+                members.append(port_objects)
+            service_book.groups[current_name] = ServiceGroup(name=current_name, members=tuple(members))
+        current_kind = None
+        current_name = None
+        members = []
+        entries = []
+
+    def inline_address(value: str) -> str:
+        """Register an inline host or subnet and return its object name."""
+        objects = address_book.objects6 if ":" in value else address_book.objects
+        if value not in objects:
+            objects[value] = parse_address_object(value, "ipmask", subnet=value)
+        return value
+
+    def address_ref(tokens: list[str], index: int) -> tuple[str, int]:
+        keyword = tokens[index]
+        if keyword in ANY_ADDRESSES:
+            return keyword, index + 1
+        if keyword == "host":
+            return inline_address(_host(tokens[index + 1])), index + 2
+        if keyword in ("object", "object-group"):
+            return tokens[index + 1], index + 2
+        if keyword == "interface":
+            # The interface's own address is not in the ACL config; an unresolved name evaluates as UNKNOWN.
+            return f"interface {tokens[index + 1]}", index + 2
+        if ":" in keyword:
+            # IPv6 prefixes are written as a single `address/length` token.
+            return inline_address(keyword), index + 1
+        return inline_address(f"{keyword}/{tokens[index + 1]}"), index + 2
+
+    def group_versions(name: str, visited: set[str]) -> set[int]:
+        """Return the IP versions of the objects a name resolves to."""
+        if name in visited:
+            return set()
+        visited.add(name)
+        namespaces = ((4, address_book.objects), (6, address_book.objects6))
+        versions = {version for version, objects in namespaces if name in objects}
+        if name in address_book.groups:
+            for member in address_book.groups[name].members:
+                versions |= group_versions(member, visited)
+        return versions
+
+    def by_version(name: str) -> tuple[tuple[str, ...], tuple[str, ...]]:
+        """Split an address reference into its IPv4 and IPv6 names."""
+        if name in ANY_ADDRESSES:
+            versions = set(ANY_ADDRESSES[name])
+            name = "all"
+        else:
+            # Undefined names (and `interface` references) stay unresolved for both versions.
+            versions = known_versions.get(name) or {4, 6}
+        return (name,) if 4 in versions else (), (name,) if 6 in versions else ()
+
+    def inline_service(
+        protocols: Optional[tuple[Protocol, ...]],
+        port: Optional[PortMatch],
+        source_port: Optional[PortMatch] = None,
+    ) -> str:
+        if protocols is None:
+            return "ALL"
+        name = _port_label(protocols, port, source_port)
+        service_entries = _port_entries(protocols, port, source_port)
+        service_book.services.setdefault(name, ServiceObject(name=name, entries=tuple(service_entries)))
+        return name
+
+    def parse_access_list(tokens: list[str]) -> None:
+        # access-list NAME [line N] extended permit|deny PROTO SRC [port] DST [port] [log ...] [inactive]
+        acl_name = tokens[1]
+        sequence = acl_lines.get(acl_name, 0) + 1
+        acl_lines[acl_name] = sequence
+        index = 2
+        if tokens[index] == "line":
+            index += 2
+        if tokens[index] != "extended":
+            return
+        action = tokens[index + 1]
+        index += 2
+        if tokens[index] in ("object", "object-group"):
+            service_ref: Optional[str] = tokens[index + 1]
+            protocols: Optional[tuple[Protocol, ...]] = None
+            index += 2
+        else:
+            service_ref = None
+            protocols = _protocols(tokens[index])
+            index += 1
+            if protocols == ():
+                return
+        source, index = address_ref(tokens, index)
+        source_port, index = _consume_port(tokens, index)
+        destination, index = address_ref(tokens, index)
+        if service_ref is None and index < len(tokens) and tokens[index] == "object-group":
+            service_ref = tokens[index + 1]
+            index += 2
+            if source_port is not None:
+                # A port group's entries carry no source port; an unresolved name keeps the rule UNKNOWN.
+                service_ref = f"{service_ref} source {source_port[0]} {' '.join(source_port[1])}"
+        port, index = _consume_port(tokens, index)
+        if service_ref is None:
+            service_ref = inline_service(protocols, port, source_port)
+        acl_policies.setdefault(acl_name, []).append(
+            PolicyRule(
+                policy_id=f"{acl_name}:{sequence}",
+                name=acl_name,
+                priority=0,
+                source=(source,),
+                destination=(destination,),
+                services=(service_ref,),
+                action="accept" if action == "permit" else "deny",
+                enabled="inactive" not in tokens[index:],
+            )
+        )
+
+    for raw_line in lines:
+        if not raw_line.strip() or raw_line.lstrip().startswith("!"):
+            continue
+        tokens = raw_line.split()
+        indented = raw_line[:1].isspace()
+        if not indented:
+            flush()
+            if tokens[:2] == ["object", "network"]:
+                current_kind, current_name = "network", tokens[2]
+            elif tokens[:2] == ["object", "service"]:
+                current_kind, current_name = "service", tokens[2]
+            elif tokens[:2] == ["object-group", "network"]:
+                current_kind, current_name = "network-group", tokens[2]
+            elif tokens[:2] == ["object-group", "service"]:
+                current_kind, current_name = "service-group", tokens[2]
+                current_protocols = _protocols(tokens[3]) if len(tokens) > 3 else None
+            elif tokens[0] == "access-list" and len(tokens) > 3:
+                parse_access_list(tokens)
+            elif tokens[0] == "access-group" and len(tokens) >= 3:
+                # access-group ACL in|out interface NAMEIF, or access-group ACL global
+                interface = tokens[4] if tokens[2] in ("in", "out") and len(tokens) > 4 else None
+                bindings.setdefault(tokens[1], []).append((tokens[2], interface))
+            continue
+
+        keyword = tokens[0]
+        if current_kind == "network" and current_name:
+            value = tokens[1] if len(tokens) > 1 else ""
+            objects = address_book.objects6 if ":" in value or value == "v6" else address_book.objects
+            if keyword == "host":
+                objects[current_name] = parse_address_object(current_name, "ipmask", subnet=_host(tokens[1]))
+            elif keyword == "subnet":
+                subnet = tokens[1] if ":" in tokens[1] else f"{tokens[1]}/{tokens[2]}"
+                objects[current_name] = parse_address_object(current_name, "ipmask", subnet=subnet)
+            elif keyword == "range":
+                objects[current_name] = parse_address_object(
+                    current_name, "iprange", start_ip=tokens[1], end_ip=tokens[2]
+                )
+            elif keyword == "fqdn":
+                objects[current_name] = parse_address_object(current_name, "fqdn", fqdn=tokens[-1])
+        elif current_kind == "network-group":
+            if keyword == "network-object" and tokens[1] == "host":
+                members.append(inline_address(_host(tokens[2])))
+            elif keyword == "network-object" and tokens[1] == "object":
+                members.append(tokens[2])
+            elif keyword == "network-object" and ":" in tokens[1]:
+                members.append(inline_address(tokens[1]))
+            elif keyword == "network-object":
+                members.append(inline_address(f"{tokens[1]}/{tokens[2]}"))
+            elif keyword == "group-object":
+                members.append(tokens[1])
+        elif current_kind == "service" and keyword == "service":
+            protocols = _protocols(tokens[1])
+            if protocols is None:
+                members.append("ALL")
+            elif protocols:
+                # service tcp [source OP PORT] [destination OP PORT]
+                port, source_port = _service_ports(tokens, 2)
+                entries.extend(_port_entries(protocols, port, source_port))
+        elif current_kind == "service-group":
+            if keyword == "port-object" and current_protocols:
+                port, _ = _consume_port(tokens, 1)
+                if port is not None:
+                    entries.extend(_port_entries(current_protocols, port))
+            elif keyword == "service-object" and tokens[1] == "object":
+                members.append(tokens[2])
+            elif keyword == "service-object":
+                protocols = _protocols(tokens[1])
+                if protocols is None:
+                    members.append("ALL")
+                elif protocols:
+                    port, source_port = _service_ports(tokens, 2)
+                    members.append(inline_service(protocols, port, source_port))
+            elif keyword == "group-object":
+                members.append(tokens[1])
+    flush()
+
+    known_versions = {
+        name: group_versions(name, set())
+        for name in (*address_book.objects, *address_book.objects6, *address_book.groups)
+    }
+    for name in list(address_book.groups):
+        versions = known_versions[name]
+        if versions and 4 not in versions:
+            del address_book.groups[name]
+        if versions and 6 not in versions:
+            del address_book.groups6[name]
+
+    if bindings:
+        scoped: list[tuple[str, tuple[str, ...], tuple[str, ...]]] = []
+        for direction in ("in", "out"):
+            for acl_name, bound in bindings.items():
+                interfaces = tuple(interface for way, interface in bound if way == direction and interface)
+                if interfaces:
+                    scoped.append((acl_name, interfaces, ()) if direction == "in" else (acl_name, (), interfaces))
+        scoped.extend((acl_name, (), ()) for acl_name, bound in bindings.items() if ("global", None) in bound)
+    else:
+        scoped = [(acl_name, (), ()) for acl_name in acl_policies]
+    for acl_name, src_interfaces, dst_interfaces in scoped:
+        for policy in acl_policies.get(acl_name, []):
+            (source,), (destination,) = policy.source, policy.destination
+            source4, source6 = by_version(source)
+            destination4, destination6 = by_version(destination)
+            policies.append(
+                replace(
+                    policy,
+                    priority=len(policies) + 1,
+                    source=source4,
+                    destination=destination4,
+                    source6=source6,
+                    destination6=destination6,
+                    src_interfaces=src_interfaces,
+                    dst_interfaces=dst_interfaces,
+                )
+            )
+
+    add_builtin_objects(address_book, service_book)
+    return RuleSet(address_book=address_book, service_book=service_book, policies=policies)
@@ -0,0 +1,101 @@
+"""Parser for Azure network security group (NSG) JSON exports."""
+from __future__ import annotations
+
+import json
+from typing import Any, Iterable
+
+from ..models import AddressBook, PolicyRule, Protocol, ServiceBook, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_ipv4_network, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+NSG_PROTOCOLS = {"tcp": (Protocol.TCP,), "udp": (Protocol.UDP,), "*": (Protocol.TCP, Protocol.UDP)}
+
+
+def _properties(rule: dict[str, Any]) -> dict[str, Any]:
+    """Return rule fields from either the flattened CLI or the nested ARM layout."""
+    return {**rule, **rule.get("properties", {})}
+
+
+def _values(fields: dict[str, Any], single: str, plural: str) -> list[str]:
+    values = [str(value) for value in fields.get(plural) or []]
+    if fields.get(single):
+        values.append(str(fields[single]))
+    return values or ["*"]
+
+
+def parse_azure_nsg(lines: Iterable[str]) -> RuleSet:
+    """Parse inbound NSG security rules, evaluated in ascending priority order.
+
+    Accepts `az network nsg show` output (custom and default rules) or a bare
+    rule list. Service tags such as ``VirtualNetwork`` are kept as unresolved
+    names, so flows they would decide evaluate as UNKNOWN. Rules for other
+    protocols (e.g. ICMP) are skipped.
+    """
+    try:
+        document = json.loads("".join(lines))
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid NSG JSON: {exc}") from exc
+    if isinstance(document, list):
+        rules = document
+    else:
+        nsg = _properties(document)
+        rules = [*nsg.get("securityRules", []), *nsg.get("defaultSecurityRules", [])]
+
+    address_book = AddressBook()
+    service_book = ServiceBook()
+
+    def address(prefix: str) -> str:
+        if prefix == "*":
+            return "all"
+        try:
+            parse_ipv4_network(prefix)
+        except ParseError:
+            return prefix
+        address_book.objects.setdefault(prefix, parse_address_object(prefix, "ipmask", subnet=prefix))
+        return prefix
+
+    def service(protocols: tuple[Protocol, ...], port: str) -> str:
+        if port == "*" and len(protocols) == 2:
+            return "ALL"
+        name = f"{'/'.join(protocol.value for protocol in protocols)} {port}"
+        if name not in service_book.services:
+            port_range = "1-65535" if port == "*" else port
+            entries = tuple(parse_service_entry(f"{protocol.value}_{port_range}") for protocol in protocols)
+            service_book.services[name] = ServiceObject(name=name, entries=entries)
+        return name
+
+    policies: list[PolicyRule] = []
+    for rule in rules:
+        fields = _properties(rule)
+        if str(fields.get("direction", "Inbound")).lower() != "inbound":
+            continue
+        protocols = NSG_PROTOCOLS.get(str(fields.get("protocol", "*")).lower())
+        if protocols is None:
+            continue
+        priority = int(fields.get("priority", 0))
+        policies.append(
+            PolicyRule(
+                policy_id=str(fields.get("name", priority)),
+                name=str(fields.get("name", priority)),
+                priority=priority,
+                source=tuple(
+                    address(prefix) for prefix in _values(fields, "sourceAddressPrefix", "sourceAddressPrefixes")
+                ),
+                destination=tuple(
+                    address(prefix)
+                    for prefix in _values(fields, "destinationAddressPrefix", "destinationAddressPrefixes")
+                ),
+                services=tuple(
+                    service(protocols, port)
+                    for port in _values(fields, "destinationPortRange", "destinationPortRanges")
+                ),
+                action="accept" if str(fields.get("access", "")).lower() == "allow" else "deny",
+                enabled=True,
+                comment=fields.get("description") or None,
+            )
+        )
+    policies.sort(key=lambda rule: rule.priority)
+
+    add_builtin_objects(address_book, service_book)
+    return RuleSet(address_book=address_book, service_book=service_book, policies=policies)
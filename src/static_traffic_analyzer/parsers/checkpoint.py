@@ -0,0 +1,163 @@
+"""Parser for Check Point R8x policy package JSON exports."""
+from __future__ import annotations
+
+import json
+from typing import Any, Iterable
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+SERVICE_PROTOCOLS = {"service-tcp": "tcp", "service-udp": "udp"}
+ALLOW_ACTIONS = {"accept", "allow"}
+# The IPv4 field each address object type needs; objects without it are IPv6-only.
+IPV4_FIELDS = {"host": "ipv4-address", "network": "subnet4", "address-range": "ipv4-address-first"}
+
+
+def _port_entry(protocol: str, port: str):
+    """Parse Check Point port syntax: `80`, `1000-2000`, `>1023` or `<1024`."""
+    port = port.strip()
+    if port.startswith(">"):
+        port = f"{int(port[1:]) + 1}-65535"
+    elif port.startswith("<"):
+        port = f"1-{int(port[1:]) - 1}"
+    return parse_service_entry(f"{protocol}_{port}")
+
+
+def _reference(value: Any) -> str:
+    """Return the uid of a reference given inline or as a uid string."""
+    if isinstance(value, dict):
+        return str(value.get("uid", value.get("name", "")))
+    return str(value)
+
+
+def _iter_rules(rulebase: Iterable[dict[str, Any]]) -> Iterable[dict[str, Any]]:
+    """Yield access rules in order, descending into access sections."""
+    for item in rulebase:
+        if item.get("type") == "access-section":
+            yield from _iter_rules(item.get("rulebase", []))
+        elif item.get("type", "access-rule") == "access-rule":
+            yield item
+
+
+def parse_checkpoint_layers(lines: Iterable[str]) -> list[tuple[str, RuleSet]]:
+    """Parse objects and each access layer from a Check Point `show package` JSON export.
+
+    The export is either a single layer (``objects-dictionary`` plus ``rulebase``)
+    or a package with ``objects`` and ``access-layers``. Each layer gets its own
+    rule set over the shared objects; a flow is accepted only if every layer
+    accepts it, so the layers are meant to be evaluated as a chain in order.
+    Rules negating source or destination are kept with an unresolved reference
+    so they evaluate as UNKNOWN instead of being silently inverted. Only IPv4
+    addresses are read: IPv6-only hosts, networks and ranges are left
+    undefined with a warning, so rules referencing them evaluate as UNKNOWN.
+    """
+    try:
+        document = json.loads("".join(lines))
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid Check Point JSON export: {exc}") from exc
+    if not isinstance(document, dict):
+        raise ParseError("Check Point export must be a JSON object")
+
+    objects = [*document.get("objects-dictionary", []), *document.get("objects", [])]
+    layers = document.get("access-layers") or [document]
+
+    names: dict[str, str] = {}
+    for obj in objects:
+        names[str(obj.get("uid", obj.get("name")))] = str(obj.get("name"))
+
+    address_book = AddressBook()
+    service_book = ServiceBook()
+    actions: dict[str, str] = {}
+    warnings: list[str] = []
+    for obj in objects:
+        name = str(obj.get("name"))
+        kind = obj.get("type")
+        if kind in IPV4_FIELDS and IPV4_FIELDS[kind] not in obj:
+            warnings.append(f"{kind} {name}: no IPv4 address; IPv6 objects are not supported")
+            continue
+        if kind == "host":
+            address_book.objects[name] = parse_address_object(name, "ipmask", subnet=f"{obj['ipv4-address']}/32")
+        elif kind == "network":
+            subnet = f"{obj['subnet4']}/{obj.get('mask-length4', obj.get('subnet-mask'))}"
+            address_book.objects[name] = parse_address_object(name, "ipmask", subnet=subnet)
+        elif kind == "address-range":
+            address_book.objects[name] = parse_address_object(
+                name, "iprange", start_ip=obj["ipv4-address-first"], end_ip=obj["ipv4-address-last"]
+            )
+        elif kind == "dns-domain":
+            address_book.objects[name] = parse_address_object(name, "fqdn", fqdn=name.lstrip("."))
+        elif kind == "group":
+            members = tuple(names.get(_reference(member), _reference(member)) for member in obj.get("members", []))
+            address_book.groups[name] = AddressGroup(name=name, members=members)
+        elif kind in SERVICE_PROTOCOLS:
+            entries = tuple(
+                _port_entry(SERVICE_PROTOCOLS[kind], port) for port in str(obj.get("port", "")).split(",") if port
+            )
+            service_book.services[name] = ServiceObject(name=name, entries=entries)
+        elif kind == "service-group":
+            members = tuple(names.get(_reference(member), _reference(member)) for member in obj.get("members", []))
+            service_book.groups[name] = ServiceGroup(name=name, members=members)
+        elif kind == "RulebaseAction":
+            actions[str(obj.get("uid"))] = name.lower()
+    add_builtin_objects(address_book, service_book)
+
+    def resolve(values: Iterable[Any], any_name: str) -> tuple[str, ...]:
+        resolved = []
+        for value in values:
+            name = names.get(_reference(value), _reference(value))
+            resolved.append(any_name if name == "Any" else name)
+        return tuple(resolved) or (any_name,)
+
+    rule_sets: list[tuple[str, RuleSet]] = []
+    for layer_index, layer in enumerate(layers, start=1):
+        layer_name = str(layer.get("name", f"layer{layer_index}"))
+        policies: list[PolicyRule] = []
+        for rule in _iter_rules(layer.get("rulebase", [])):
+            number = rule.get("rule-number", len(policies) + 1)
+            source = resolve(rule.get("source", []), "all")
+            destination = resolve(rule.get("destination", []), "all")
+            if rule.get("source-negate"):
+                source = tuple(f"not {name}" for name in source)
+            if rule.get("destination-negate"):
+                destination = tuple(f"not {name}" for name in destination)
+            action = actions.get(_reference(rule.get("action", "")), _reference(rule.get("action", "")).lower())
+            policies.append(
+                PolicyRule(
+                    policy_id=f"{layer_name}:{number}" if len(layers) > 1 else str(number),
+                    name=str(rule.get("name") or f"rule {number}"),
+                    priority=len(policies) + 1,
+                    source=source,
+                    destination=destination,
+                    services=resolve(rule.get("service", []), "ALL"),
+                    action="accept" if action in ALLOW_ACTIONS else "deny",
+                    enabled=bool(rule.get("enabled", True)),
+                    comment=rule.get("comments") or None,
+                    service_negate=bool(rule.get("service-negate", False)),
+                )
+            )
+        rule_sets.append(
+            (
+                layer_name,
+                RuleSet(address_book=address_book, service_book=service_book, policies=policies, warnings=warnings),
+            )
+        )
+    return rule_sets
+
+
+def parse_checkpoint_package(lines: Iterable[str]) -> RuleSet:
+    """Parse a Check Point `show package` JSON export with a single access layer.
+
+    Every ordered layer of a package must accept a flow, which one first-match
+    rule list cannot express, so packages with several layers are rejected;
+    evaluate those layer by layer with :func:`parse_checkpoint_layers`.
+    """
+    layers = parse_checkpoint_layers(lines)
+    if len(layers) > 1:
+        names = ", ".join(name for name, _ in layers)
+        raise ParseError(
+            f"Check Point package has {len(layers)} ordered access layers ({names}); every layer must accept a "
+            "flow, so export and analyze one layer at a time"
+        )
+    return layers[0][1]
@@ -0,0 +1,34 @@
+"""Helpers shared by the vendor configuration providers."""
+from __future__ import annotations
+
+from dataclasses import dataclass, field
+
+from ..catalog import DEFAULT_SERVICES
+from ..models import AddressBook, PolicyRule, Protocol, ServiceBook, ServiceEntry
+from ..utils import make_any_service, parse_address_object
+
+
+# Stand-in for protocols such as icmp or esp: a port-less entry never matches a TCP/UDP flow.
+NON_L4_ENTRY = ServiceEntry(protocol=Protocol.TCP, start_port=None, end_port=None)
+
+
+@dataclass
+class RuleSet:
+    """Rule base parsed from a non-FortiGate vendor configuration."""
+
+    address_book: AddressBook
+    service_book: ServiceBook
+    policies: list[PolicyRule]
+    warnings: list[str] = field(default_factory=list)
+
+
+def add_builtin_objects(address_book: AddressBook, service_book: ServiceBook) -> None:
+    """Add the catch-all address/service and default services unless already defined."""
+    if "all" not in address_book.objects:
+        address_book.objects["all"] = parse_address_object("all", "ipmask", subnet="0.0.0.0/0")
+    if "all" not in address_book.objects6:
+        address_book.objects6["all"] = parse_address_object("all", "ipmask", subnet="::/0")
+    for name, service in DEFAULT_SERVICES.items():
+        service_book.services.setdefault(name, service)
+    if "ALL" not in service_book.services:
+        service_book.services["ALL"] = make_any_service("ALL")
@@ -0,0 +1,107 @@
+"""Parser for a vendor-neutral rule base stored as a directory of CSV files.
+
+The directory holds:
+
+* ``policies.csv`` (required): ``id,name,source,destination,service,action,enabled,comment``
+  with action accept or deny
+* ``addresses.csv``: ``name,type,subnet,start_ip,end_ip`` with type ipmask, iprange or fqdn
+* ``services.csv``: ``name,protocol,ports``; repeat a name to add more tcp/udp port ranges
+* ``groups.csv``: ``name,kind,members`` with kind address or service
+
+Multi-valued cells (policy endpoints, services and group members) are separated
+by ``;``. Policies are evaluated in file order; ``enabled`` defaults to true.
+"""
+from __future__ import annotations
+
+import csv
+from pathlib import Path
+from typing import Iterator
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+POLICY_COLUMNS = ("id", "source", "destination", "service", "action")
+ACTIONS = ("accept", "deny")
+FALSE_VALUES = {"0", "false", "no", "disable", "disabled"}
+
+
+def _read_rows(path: Path, required: tuple[str, ...]) -> Iterator[tuple[int, dict[str, str]]]:
+    """Yield (line number, stripped row) pairs, checking the required headers first."""
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        missing = [column for column in required if column not in (reader.fieldnames or [])]
+        if missing:
+            raise ParseError(f"{path.name} missing required header(s): {', '.join(missing)}")
+        for row in reader:
+            yield reader.line_num, {key: (value or "").strip() for key, value in row.items() if key}
+
+
+def _split(value: str) -> tuple[str, ...]:
+    return tuple(item.strip() for item in value.split(";") if item.strip())
+
+
+def parse_csv_rules(directory: Path) -> RuleSet:
+    """Load policies and objects from the CSV files in ``directory``."""
+    if not (directory / "policies.csv").is_file():
+        raise ParseError(f"CSV rules directory has no policies.csv: {directory}")
+    address_book = AddressBook()
+    service_book = ServiceBook()
+
+    addresses = directory / "addresses.csv"
+    if addresses.is_file():
+        for line, row in _read_rows(addresses, ("name", "type")):
+            try:
+                address_book.objects[row["name"]] = parse_address_object(
+                    name=row["name"],
+                    address_type=row["type"],
+                    subnet=row.get("subnet") or None,
+                    start_ip=row.get("start_ip") or None,
+                    end_ip=row.get("end_ip") or None,
+                )
+            except ParseError as exc:
+                raise ParseError(f"addresses.csv line {line}: {exc}") from exc
+
+    services = directory / "services.csv"
+    if services.is_file():
+        for line, row in _read_rows(services, ("name", "protocol", "ports")):
+            try:
+                entry = parse_service_entry(f"{row['protocol']}_{row['ports']}")
+            except ParseError as exc:
+                raise ParseError(f"services.csv line {line}: {exc}") from exc
+            existing = service_book.services.get(row["name"])
+            entries = (existing.entries if existing else ()) + (entry,)
+            service_book.services[row["name"]] = ServiceObject(name=row["name"], entries=entries)
+
+    groups = directory / "groups.csv"
+    if groups.is_file():
+        for line, row in _read_rows(groups, ("name", "kind", "members")):
+            kind = row["kind"].lower()
+            if kind == "address":
+                address_book.groups[row["name"]] = AddressGroup(name=row["name"], members=_split(row["members"]))
+            elif kind == "service":
+                service_book.groups[row["name"]] = ServiceGroup(name=row["name"], members=_split(row["members"]))
+            else:
+                raise ParseError(f"groups.csv line {line}: kind must be address or service, got {row['kind']!r}")
+
+    policies: list[PolicyRule] = []
+    for priority, (line, row) in enumerate(_read_rows(directory / "policies.csv", POLICY_COLUMNS), start=1):
+        if row["action"].lower() not in ACTIONS:
+            raise ParseError(f"policies.csv line {line}: action must be accept or deny, got {row['action']!r}")
+        policies.append(
+            PolicyRule(
+                policy_id=row["id"],
+                name=row.get("name") or row["id"],
+                priority=priority,
+                source=_split(row["source"]),
+                destination=_split(row["destination"]),
+                services=_split(row["service"]),
+                action=row["action"].lower(),
+                enabled=row.get("enabled", "").lower() not in FALSE_VALUES,
+                comment=row.get("comment") or None,
+            )
+        )
+
+    add_builtin_objects(address_book, service_book)
+    return RuleSet(address_book=address_book, service_book=service_book, policies=policies)
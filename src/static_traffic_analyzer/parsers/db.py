@@ -1,7 +1,9 @@
-"""Parser for MariaDB firewall tables."""
+"""Parser for MariaDB and SQLite firewall tables."""
 from __future__ import annotations
 
+import sqlite3
 from dataclasses import dataclass
+from pathlib import Path
 from typing import Any
 
 from ..catalog import DEFAULT_SERVICES
@@ -18,6 +20,23 @@ class DatabaseData:
     policies: list[PolicyRule]
 
 
+EXPECTED_SCHEMA: dict[str, tuple[str, ...]] = {
+    "cfg_address": ("object_name", "address_type", "subnet", "start_ip", "end_ip"),
+    "cfg_address_group": ("group_name", "members"),
+    "cfg_service_group": ("group_name", "members"),
+    "cfg_policy": (
+        "priority",
+        "src_objects",
+        "dst_objects",
+        "service_object",
+        "action",
+        "is_enabled",
+        "log_traffic",
+        "comments",
+    ),
+}
+
+
 def _require_connector() -> Any:
     """Import the MariaDB connector, raising a clear error if missing."""
     try:
@@ -30,12 +49,8 @@ def _require_connector() -> Any:
     return mysql.connector
 
 
-def parse_database(dsn: str) -> DatabaseData:
-    """Load MariaDB firewall tables into internal models."""
-    connector = _require_connector()
-    connection = connector.connect(dsn=dsn)
-    cursor = connection.cursor(dictionary=True)
-
+def _load_tables(cursor: Any) -> DatabaseData:
+    """Read the cfg_* tables through a cursor that returns rows as dicts."""
     address_book = AddressBook()
     service_book = ServiceBook()
     policies: list[PolicyRule] = []
@@ -109,8 +124,66 @@ def parse_database(dsn: str) -> DatabaseData:
                     continue
 
     policies.sort(key=lambda rule: rule.priority)
+    return DatabaseData(address_book=address_book, service_book=service_book, policies=policies)
+
 
+def parse_database(dsn: str) -> DatabaseData:
+    """Load MariaDB firewall tables into internal models."""
+    connector = _require_connector()
+    connection = connector.connect(dsn=dsn)
+    cursor = connection.cursor(dictionary=True)
+    try:
+        return _load_tables(cursor)
+    finally:
+        cursor.close()
+        connection.close()
+
+
+def _dict_row(cursor: sqlite3.Cursor, row: tuple[Any, ...]) -> dict[str, Any]:
+    return {column[0]: value for column, value in zip(cursor.description, row)}
+
+
+def parse_sqlite(path: str) -> DatabaseData:
+    """Load the same cfg_* tables from a SQLite database file."""
+    if not Path(path).is_file():
+        raise ParseError(f"SQLite database not found: {path}")
+    connection = sqlite3.connect(f"{Path(path).resolve().as_uri()}?mode=ro", uri=True)
+    connection.row_factory = _dict_row
+    cursor = connection.cursor()
+    try:
+        return _load_tables(cursor)
+    except sqlite3.Error as exc:
+        raise ParseError(f"Cannot read rules from {path}: {exc}") from exc
+    finally:
+        cursor.close()
+        connection.close()
+
+
+def diff_schema(actual: dict[str, set[str]]) -> list[str]:
+    """Compare table -> columns found in the database with what the parser reads."""
+    problems: list[str] = []
+    for table, columns in EXPECTED_SCHEMA.items():
+        if table not in actual:
+            problems.append(f"missing table: {table}")
+            continue
+        for column in columns:
+            if column not in actual[table]:
+                problems.append(f"missing column: {table}.{column}")
+    return problems
+
+
+def check_schema(dsn: str) -> list[str]:
+    """Inspect information_schema and report tables or columns the parser needs but cannot find."""
+    connector = _require_connector()
+    connection = connector.connect(dsn=dsn)
+    cursor = connection.cursor(dictionary=True)
+    cursor.execute(
+        "SELECT table_name AS table_name, column_name AS column_name "
+        "FROM information_schema.columns WHERE table_schema = DATABASE()"
+    )
+    actual: dict[str, set[str]] = {}
+    for row in cursor.fetchall():
+        actual.setdefault(str(row["table_name"]).lower(), set()).add(str(row["column_name"]).lower())
     cursor.close()
     connection.close()
-
-    return DatabaseData(address_book=address_book, service_book=service_book, policies=policies)
+    return diff_schema(actual)
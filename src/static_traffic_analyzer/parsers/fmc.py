@@ -0,0 +1,273 @@
+"""Parser for Cisco Firepower Management Center (FMC) access control policy exports."""
+from __future__ import annotations
+
+import csv
+import io
+import json
+import re
+from typing import Any, Iterable
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceEntry, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_ipv4_network, parse_service_entry
+from .common import NON_L4_ENTRY, RuleSet, add_builtin_objects
+
+
+ALLOW_ACTIONS = {"ALLOW", "TRUST"}
+# MONITOR logs the flow and continues with the next rule, so it never decides a flow.
+PASS_THROUGH_ACTIONS = {"MONITOR"}
+PROTOCOL_NUMBERS = {"6": "tcp", "17": "udp", "tcp": "tcp", "udp": "udp"}
+# UI CSV exports write port literals as `TCP (6):443` or `TCP (6)/8000-8100`.
+CSV_PORT_PATTERN = re.compile(r"^(?P<proto>[A-Za-z]+)(?:\s*\(\d+\))?(?:\s*[:/]\s*(?P<port>\d+(?:-\d+)?))?$")
+ANY_VALUES = {"", "any", "any-ipv4"}
+
+
+def _items(value: Any) -> list[dict[str, Any]]:
+    """Accept both bare lists and the REST API ``{"items": [...]}`` paging envelope."""
+    if isinstance(value, dict):
+        value = value.get("items", [])
+    return [item for item in value or [] if isinstance(item, dict)]
+
+
+class _Builder:
+    """Collects objects referenced by rules into the address and service books."""
+
+    def __init__(self) -> None:
+        self.address_book = AddressBook()
+        self.service_book = ServiceBook()
+
+    def network_literal(self, value: str) -> str:
+        value = value.strip()
+        if value not in self.address_book.objects:
+            if "-" in value:
+                start, end = value.split("-", 1)
+                self.address_book.objects[value] = parse_address_object(
+                    value, "iprange", start_ip=start.strip(), end_ip=end.strip()
+                )
+            else:
+                self.address_book.objects[value] = parse_address_object(
+                    value, "ipmask", subnet=str(parse_ipv4_network(value))
+                )
+        return value
+
+    def network_object(self, obj: dict[str, Any]) -> None:
+        name = str(obj.get("name"))
+        kind = str(obj.get("type", ""))
+        value = str(obj.get("value", "")).strip()
+        if kind == "FQDN":
+            self.address_book.objects[name] = parse_address_object(name, "fqdn", fqdn=value)
+        elif kind == "Range":
+            start, end = value.split("-", 1)
+            self.address_book.objects[name] = parse_address_object(
+                name, "iprange", start_ip=start.strip(), end_ip=end.strip()
+            )
+        elif kind in ("Host", "Network"):
+            self.address_book.objects[name] = parse_address_object(
+                name, "ipmask", subnet=str(parse_ipv4_network(value))
+            )
+
+    def port_entry(self, protocol: str, port: str | None) -> ServiceEntry:
+        name = PROTOCOL_NUMBERS.get(protocol.strip().lower())
+        if name is None:
+            return NON_L4_ENTRY
+        return parse_service_entry(f"{name}_{port or '1-65535'}")
+
+    def port_literal(self, protocol: str, port: str | None) -> str:
+        protocol = protocol.strip().lower()
+        name = f"{PROTOCOL_NUMBERS.get(protocol, protocol)} {port or 'any'}"
+        if name not in self.service_book.services:
+            self.service_book.services[name] = ServiceObject(name=name, entries=(self.port_entry(protocol, port),))
+        return name
+
+    def network_refs(self, field: Any) -> tuple[str, ...]:
+        if not field:
+            return ("all",)
+        names = [str(obj["name"]) for obj in field.get("objects", [])]
+        names.extend(self.network_literal(str(literal["value"])) for literal in field.get("literals", []))
+        return tuple(names) or ("all",)
+
+    def port_refs(self, field: Any) -> tuple[str, ...]:
+        if not field:
+            return ("ALL",)
+        names = [str(obj["name"]) for obj in field.get("objects", [])]
+        names.extend(
+            self.port_literal(str(literal.get("protocol", "")), literal.get("port"))
+            for literal in field.get("literals", [])
+        )
+        return tuple(names) or ("ALL",)
+
+    def zone_refs(self, field: Any) -> tuple[str, ...]:
+        return tuple(str(obj["name"]) for obj in (field or {}).get("objects", []))
+
+
+def _rule(
+    index: int,
+    name: str,
+    action: str,
+    enabled: bool,
+    source: tuple[str, ...],
+    destination: tuple[str, ...],
+    services: tuple[str, ...],
+    comment: str | None = None,
+    policy_id: str | None = None,
+    src_interfaces: tuple[str, ...] = (),
+    dst_interfaces: tuple[str, ...] = (),
+) -> PolicyRule:
+    return PolicyRule(
+        policy_id=policy_id or str(index),
+        name=name or f"rule {index}",
+        priority=index,
+        source=source,
+        destination=destination,
+        services=services,
+        action="accept" if action in ALLOW_ACTIONS else "deny",
+        enabled=enabled,
+        comment=comment,
+        src_interfaces=src_interfaces,
+        dst_interfaces=dst_interfaces,
+    )
+
+
+def _parse_json(document: dict[str, Any]) -> RuleSet:
+    builder = _Builder()
+    for obj in [*_items(document.get("networks")), *_items(document.get("hosts")), *_items(document.get("ranges"))]:
+        builder.network_object(obj)
+    for obj in _items(document.get("fqdns")):
+        builder.network_object({**obj, "type": "FQDN"})
+    for group in _items(document.get("networkgroups")):
+        name = str(group.get("name"))
+        members = [str(obj["name"]) for obj in group.get("objects", [])]
+        members.extend(builder.network_literal(str(literal["value"])) for literal in group.get("literals", []))
+        builder.address_book.groups[name] = AddressGroup(name=name, members=tuple(members))
+    for obj in _items(document.get("protocolportobjects")):
+        name = str(obj.get("name"))
+        builder.service_book.services[name] = ServiceObject(
+            name=name, entries=(builder.port_entry(str(obj.get("protocol", "")), obj.get("port")),)
+        )
+    for group in _items(document.get("portobjectgroups")):
+        name = str(group.get("name"))
+        members = tuple(str(obj["name"]) for obj in group.get("objects", []))
+        builder.service_book.groups[name] = ServiceGroup(name=name, members=members)
+
+    policies: list[PolicyRule] = []
+    index = 0
+    for index, rule in enumerate(_items(document.get("accessrules")), start=1):
+        action = str(rule.get("action", "BLOCK")).upper()
+        if action in PASS_THROUGH_ACTIONS:
+            continue
+        services = builder.port_refs(rule.get("destinationPorts"))
+        if rule.get("applications") or rule.get("urls"):
+            # Application and URL conditions are decided by inspection, not by address and port.
+            services = (f"{rule.get('name', index)} application filter",)
+        elif rule.get("sourcePorts"):
+            services = (f"{rule.get('name', index)} source port filter",)
+        policies.append(
+            _rule(
+                index,
+                str(rule.get("name", "")),
+                action,
+                bool(rule.get("enabled", True)),
+                builder.network_refs(rule.get("sourceNetworks")),
+                builder.network_refs(rule.get("destinationNetworks")),
+                services,
+                comment=str(rule["description"]) if rule.get("description") else None,
+                src_interfaces=builder.zone_refs(rule.get("sourceZones")),
+                dst_interfaces=builder.zone_refs(rule.get("destinationZones")),
+            )
+        )
+    default_action = document.get("defaultAction")
+    if isinstance(default_action, dict) and default_action.get("action"):
+        action = str(default_action["action"]).upper()
+        policies.append(
+            _rule(index + 1, "default action", action, True, ("all",), ("all",), ("ALL",), policy_id="default")
+        )
+
+    add_builtin_objects(builder.address_book, builder.service_book)
+    return RuleSet(address_book=builder.address_book, service_book=builder.service_book, policies=policies)
+
+
+def _cell_values(value: str) -> list[str]:
+    return [item.strip() for item in re.split(r"[;\n]", value or "") if item.strip()]
+
+
+def _parse_csv(text: str) -> RuleSet:
+    builder = _Builder()
+    reader = csv.DictReader(io.StringIO(text))
+    required = ("Name", "Action", "Source Networks", "Destination Networks", "Destination Ports")
+    missing = [column for column in required if column not in (reader.fieldnames or [])]
+    if missing:
+        raise ParseError(f"FMC CSV export missing column(s): {', '.join(missing)}")
+
+    def networks(value: str) -> tuple[str, ...]:
+        names: list[str] = []
+        for item in _cell_values(value):
+            if item.lower() in ANY_VALUES:
+                return ("all",)
+            try:
+                names.append(builder.network_literal(item))
+            except ParseError:
+                names.append(item)
+        return tuple(names) or ("all",)
+
+    def ports(value: str) -> tuple[str, ...]:
+        names: list[str] = []
+        for item in _cell_values(value):
+            if item.lower() in ANY_VALUES:
+                return ("ALL",)
+            match = CSV_PORT_PATTERN.match(item)
+            if match and match.group("proto").lower() in PROTOCOL_NUMBERS:
+                names.append(builder.port_literal(match.group("proto"), match.group("port")))
+            else:
+                names.append(item)
+        return tuple(names) or ("ALL",)
+
+    def conditions(value: str | None) -> list[str]:
+        return [item for item in _cell_values(value or "") if item.lower() not in ANY_VALUES]
+
+    policies: list[PolicyRule] = []
+    for index, row in enumerate(reader, start=1):
+        action = (row.get("Action") or "BLOCK").strip().upper().replace(" ", "_")
+        if action in PASS_THROUGH_ACTIONS:
+            continue
+        name = (row.get("Name") or "").strip()
+        services = ports(row.get("Destination Ports", ""))
+        if conditions(row.get("Applications")) or conditions(row.get("URLs")):
+            services = (f"{name or index} application filter",)
+        elif conditions(row.get("Source Ports")):
+            services = (f"{name or index} source port filter",)
+        policies.append(
+            _rule(
+                index,
+                name,
+                action,
+                (row.get("Enabled") or "true").strip().lower() not in ("false", "no", "disabled"),
+                networks(row.get("Source Networks", "")),
+                networks(row.get("Destination Networks", "")),
+                services,
+                src_interfaces=tuple(conditions(row.get("Source Zones"))),
+                dst_interfaces=tuple(conditions(row.get("Destination Zones"))),
+            )
+        )
+
+    add_builtin_objects(builder.address_book, builder.service_book)
+    return RuleSet(address_book=builder.address_book, service_book=builder.service_book, policies=policies)
+
+
+def parse_fmc_export(lines: Iterable[str]) -> RuleSet:
+    """Parse an FMC access control policy export in JSON or CSV form.
+
+    JSON exports combine the REST API collections (``networks``, ``hosts``,
+    ``ranges``, ``fqdns``, ``networkgroups``, ``protocolportobjects``,
+    ``portobjectgroups``, ``accessrules`` and ``defaultAction``). CSV exports
+    carry rules only, so object names in cells stay unresolved unless they are
+    literal addresses or ports. Source and destination zones scope rules to
+    the flow's interfaces. Rules with application, URL or source port
+    conditions evaluate as UNKNOWN; MONITOR rules are skipped.
+    """
+    text = "".join(lines)
+    if not text.lstrip().startswith("{"):
+        return _parse_csv(text)
+    try:
+        document = json.loads(text)
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid FMC JSON export: {exc}") from exc
+    return _parse_json(document)
@@ -1,19 +1,106 @@
 """Parser for FortiGate CLI configuration files."""
 from __future__ import annotations
 
-from dataclasses import dataclass
-from typing import Iterable
+import shlex
+from dataclasses import dataclass, field
+from datetime import datetime, time
+from ipaddress import IPv4Interface, IPv4Network
+from typing import Iterable, Optional
 
 from ..catalog import DEFAULT_SERVICES
 from ..models import (
     AddressBook,
     AddressGroup,
+    AddressObject,
+    Interface,
+    IPPool,
+    PolicyRoute,
     PolicyRule,
+    Protocol,
+    Schedule,
+    SNATRule,
     ServiceBook,
     ServiceGroup,
     ServiceObject,
+    StaticRoute,
+    VirtualIP,
+    Zone,
 )
-from ..utils import ParseError, make_any_service, parse_address_object, parse_service_entry
+from ..utils import (
+    ParseError,
+    make_any_service,
+    parse_address_object,
+    parse_icmp_entry,
+    parse_ipv4_address,
+    parse_service_entry,
+    parse_subnet,
+)
+
+
+# Address types that match like a built-in type once parsed.
+ADDRESS_TYPE_ALIASES = {
+    "multicastrange": "iprange",
+    "broadcastmask": "ipmask",
+    "interface-subnet": "ipmask",
+    "wildcard-fqdn": "fqdn",
+    "ipprefix": "ipmask",
+}
+
+# Suffixes of the policy fields naming ISDB entries, after the `internet-service` or `internet-service-src` prefix.
+ISDB_REFERENCE_FIELDS = ("name", "id", "group", "custom", "custom-group")
+
+
+def _field_values(fields: dict[str, list[str] | str], key: str) -> tuple[str, ...]:
+    """Split a possibly multi-valued `set` field into unquoted names."""
+    raw = fields.get(key, [])
+    values = [raw] if isinstance(raw, str) else raw
+    names: list[str] = []
+    for value in values:
+        try:
+            names.extend(shlex.split(value))
+        except ValueError:
+            names.extend(value.strip('"').split())
+    return tuple(name for name in names if name)
+
+
+def _internet_services(fields: dict[str, list[str] | str], prefix: str) -> tuple[str, ...]:
+    """Return the ISDB entries a policy matches on instead of addresses, if `<prefix>` is enabled."""
+    if str(fields.get(prefix, "disable")).lower() != "enable":
+        return ()
+    names: list[str] = []
+    for suffix in ISDB_REFERENCE_FIELDS:
+        names.extend(_field_values(fields, f"{prefix}-{suffix}"))
+    return tuple(names)
+
+
+def _ingress_interfaces(fields: dict[str, list[str] | str]) -> tuple[str, ...]:
+    """Return a policy's ingress interfaces: `srcintf`, or `intf` (local-in) or `interface` (DoS)."""
+    for key in ("srcintf", "intf", "interface"):
+        names = _field_values(fields, key)
+        if names:
+            return names
+    return ()
+
+
+def _ip_range(value: str) -> tuple[str, str]:
+    """Split `a.b.c.d` or `a.b.c.d-e.f.g.h` into start and end."""
+    start, _, end = value.partition("-")
+    return start.strip(), (end or start).strip()
+
+
+def _port_range(value: str) -> tuple[int, int]:
+    start, _, end = value.partition("-")
+    return int(start), int(end or start)
+
+
+def _schedule_time(value: str) -> time:
+    hour, _, minute = value.strip('"').partition(":")
+    return time(int(hour) % 24, int(minute or 0))
+
+
+def _schedule_datetime(value: str) -> datetime:
+    """Parse a one-time schedule bound such as `09:00 2024/01/31`."""
+    return datetime.strptime(value.strip('"'), "%H:%M %Y/%m/%d")
 
 
 @dataclass
@@ -23,24 +110,86 @@ class FortiGateData:
     address_book: AddressBook
     service_book: ServiceBook
     policies: list[PolicyRule]
+    multicast_policies: list[PolicyRule] = field(default_factory=list)
+    snat_rules: list[SNATRule] = field(default_factory=list)
+    ippools: dict[str, IPPool] = field(default_factory=dict)
+    central_nat: bool = False
+    schedules: dict[str, Schedule] = field(default_factory=dict)
+    interfaces: dict[str, Interface] = field(default_factory=dict)
+    zones: dict[str, Zone] = field(default_factory=dict)
+    static_routes: list[StaticRoute] = field(default_factory=list)
+    policy_routes: list[PolicyRoute] = field(default_factory=list)
+    local_in_policies: list[PolicyRule] = field(default_factory=list)
+    dos_policies: list[PolicyRule] = field(default_factory=list)
+    warnings: list[str] = field(default_factory=list)
+
 
+def parse_fortigate_config(lines: Iterable[str], strict: bool = False, source: str = "config") -> FortiGateData:
+    """Parse a FortiGate CLI configuration file into internal models.
 
-def parse_fortigate_config(lines: Iterable[str]) -> FortiGateData:
-    """Parse a FortiGate CLI configuration file into internal models."""
+    Within an entry `set` replaces a field, `append` adds members to it and
+    `unset` or `clear` drop it back to its default.
+
+    Unrecognized lines and entries that cannot be parsed are recorded in
+    ``warnings`` with their line number; with ``strict`` they raise ParseError
+    naming ``source`` instead.
+    """
     address_book = AddressBook()
     service_book = ServiceBook()
     policies: list[PolicyRule] = []
+    multicast_policies: list[PolicyRule] = []
+    snat_rules: list[SNATRule] = []
+    ippools: dict[str, IPPool] = {}
+    central_nat = False
+    schedules: dict[str, Schedule] = {}
+    interfaces: dict[str, Interface] = {}
+    zones: dict[str, Zone] = {}
+    static_routes: list[StaticRoute] = []
+    policy_routes: list[PolicyRoute] = []
+    local_in_policies: list[PolicyRule] = []
+    dos_policies: list[PolicyRule] = []
+    warnings: list[str] = []
+    # `interface-subnet` address name -> interface whose subnet it follows.
+    interface_subnets: dict[str, str] = {}
 
     current_section = None
     current_name = None
     current_fields: dict[str, list[str] | str] = {}
+    # Line of the `edit` (or `config`) whose fields are being collected.
+    entry_line = 0
+    # Sub-tables nested in an edit (VIP `config realservers`, DHCP `config ip-range`) are not modelled;
+    # they are skipped by depth so their `end` does not close the outer section.
+    in_edit = False
+    nested_depth = 0
+    nested_line = 0
+
+    def report(message: str, line_number: Optional[int] = None) -> None:
+        warnings.append(f"line {line_number or entry_line}: {message}")
 
-    def flush_address() -> None:
+    def flush_address(target: dict[str, AddressObject]) -> None:
         nonlocal current_name, current_fields
         if not current_name:
             return
-        address_type = str(current_fields.get("type", "ipmask"))
-        subnet = current_fields.get("subnet")
+        # `firewall wildcard-fqdn custom` entries carry no type.
+        default_type = "wildcard-fqdn" if "wildcard-fqdn" in current_fields else "ipmask"
+        address_type = str(current_fields.get("type", default_type))
+        interface = current_fields.get("interface") or current_fields.get("associated-interface")
+        if isinstance(interface, list):
+            interface = interface[0]
+        if address_type == "interface-subnet" and interface and target is address_book.objects:
+            interface_subnets[current_name] = interface.strip('"')
+        address_type = ADDRESS_TYPE_ALIASES.get(address_type, address_type)
+        fqdn = current_fields.get("fqdn") or current_fields.get("wildcard-fqdn")
+        if isinstance(fqdn, list):
+            fqdn = fqdn[0]
+        country = _field_values(current_fields, "country")
+        macaddrs = _field_values(current_fields, "macaddr")
+        if "start-mac" in current_fields:
+            start_mac = _field_values(current_fields, "start-mac")[0]
+            end_mac = (_field_values(current_fields, "end-mac") or (start_mac,))[0]
+            macaddrs += (f"{start_mac}-{end_mac}",)
+        # `firewall address6` prefixes are set with `ip6`.
+        subnet = current_fields.get("subnet") or current_fields.get("ip6")
         if isinstance(subnet, list):
             subnet_value = " ".join(subnet)
         else:
@@ -51,35 +200,37 @@ def parse_fortigate_config(lines: Iterable[str]) -> FortiGateData:
             start_ip = start_ip[0]
         if isinstance(end_ip, list):
             end_ip = end_ip[0]
-        if subnet_value and address_type == "ipmask":
-            parts = subnet_value.split()
-            if len(parts) == 2:
-                subnet_value = f"{parts[0]}/{parts[1]}"
         try:
-            address_book.objects[current_name] = parse_address_object(
+            if subnet_value and address_type == "ipmask":
+                subnet_value = str(parse_subnet(subnet_value))
+            target[current_name] = parse_address_object(
                 name=current_name,
                 address_type=address_type,
                 subnet=subnet_value,
                 start_ip=start_ip,
                 end_ip=end_ip,
+                interface=interface.strip('"') if interface else None,
+                fqdn=fqdn.strip('"') if fqdn else None,
+                country=country[0] if country else None,
+                macaddrs=macaddrs,
             )
-        except ParseError:
-            address_book.objects[current_name] = parse_address_object(
+        except ParseError as exc:
+            # Interface-subnet objects may take their subnet from `system interface` once parsing ends.
+            if current_name not in interface_subnets or subnet_value:
+                report(f"address {current_name}: {exc}")
+            target[current_name] = parse_address_object(
                 name=current_name,
                 address_type="fqdn",
             )
         current_name = None
         current_fields = {}
 
-    def flush_addr_group() -> None:
+    def flush_addr_group(target: dict[str, AddressGroup]) -> None:
         nonlocal current_name, current_fields
         if not current_name:
             return
-        members = current_fields.get("member", [])
-        if isinstance(members, str):
-            members = [members]
-        cleaned = tuple(member.strip('"') for member in members if member)
-        address_book.groups[current_name] = AddressGroup(name=current_name, members=cleaned)
+        cleaned = _field_values(current_fields, "member")
+        target[current_name] = AddressGroup(name=current_name, members=cleaned)
         current_name = None
         current_fields = {}
 
@@ -88,7 +239,14 @@ def parse_fortigate_config(lines: Iterable[str]) -> FortiGateData:
         if not current_name:
             return
         entries = []
-        for key in ("tcp-portrange", "udp-portrange"):
+        if str(current_fields.get("protocol", "")).upper() == "ICMP":
+            # ICMP codes are not simulated, so `icmpcode` does not narrow the entry.
+            icmp_type = current_fields.get("icmptype")
+            try:
+                entries.append(parse_icmp_entry(str(icmp_type).strip('"') if icmp_type else None))
+            except ParseError as exc:
+                report(f"service {current_name}: {exc}")
+        for key in ("tcp-portrange", "udp-portrange", "sctp-portrange"):
             raw = current_fields.get(key)
             if not raw:
                 continue
@@ -98,16 +256,27 @@ def parse_fortigate_config(lines: Iterable[str]) -> FortiGateData:
                 raw_values = [raw]
             for value in raw_values:
                 for part in str(value).split():
-                    proto = "tcp" if key.startswith("tcp") else "udp"
+                    proto = key.split("-", 1)[0]
                     entry_value = f"{proto}_{part}"
                     try:
                         entries.append(parse_service_entry(entry_value))
-                    except ParseError:
-                        continue
+                    except ParseError as exc:
+                        report(f"service {current_name}: {exc}")
+        category = _field_values(current_fields, "category")
         if not entries:
-            service_book.services[current_name] = make_any_service(current_name)
-        else:
-            service_book.services[current_name] = ServiceObject(name=current_name, entries=tuple(entries))
+            entries = list(make_any_service(current_name).entries)
+        service_book.services[current_name] = ServiceObject(
+            name=current_name, entries=tuple(entries), category=category[0] if category else None
+        )
+        if category:
+            service_book.categories.add(category[0])
+        current_name = None
+        current_fields = {}
+
+    def flush_service_category() -> None:
+        nonlocal current_name, current_fields
+        if current_name:
+            service_book.categories.add(current_name)
         current_name = None
         current_fields = {}
 
@@ -115,109 +284,497 @@ def parse_fortigate_config(lines: Iterable[str]) -> FortiGateData:
         nonlocal current_name, current_fields
         if not current_name:
             return
-        members = current_fields.get("member", [])
-        if isinstance(members, str):
-            members = [members]
-        cleaned = tuple(member.strip('"') for member in members if member)
+        cleaned = _field_values(current_fields, "member")
         service_book.groups[current_name] = ServiceGroup(name=current_name, members=cleaned)
         current_name = None
         current_fields = {}
 
-    def flush_policy() -> None:
+    def build_policy(
+        target: list[PolicyRule], ipv6_only: bool = False, default_services: tuple[str, ...] = ()
+    ) -> None:
         nonlocal current_name, current_fields
         if not current_name:
             return
         policy_id = current_name
         name = str(current_fields.get("name", "no-name"))
-        srcaddr = current_fields.get("srcaddr", [])
-        dstaddr = current_fields.get("dstaddr", [])
-        service = current_fields.get("service", [])
         action = str(current_fields.get("action", "deny"))
         schedule = current_fields.get("schedule")
         status = str(current_fields.get("status", "enable"))
-        if isinstance(srcaddr, str):
-            srcaddr = [srcaddr]
-        if isinstance(dstaddr, str):
-            dstaddr = [dstaddr]
-        if isinstance(service, str):
-            service = [service]
-        policies.append(
+        tcp_session_without_syn = current_fields.get("tcp-session-without-syn")
+        anti_replay = current_fields.get("anti-replay")
+        session_ttl = current_fields.get("session-ttl")
+        uses_ippool = str(current_fields.get("ippool", "disable")).lower() == "enable"
+        comments = current_fields.get("comments")
+        uuid = current_fields.get("uuid")
+        logtraffic = current_fields.get("logtraffic")
+        # Profiles left behind on a policy with `utm-status disable` do not inspect anything.
+        inspects = str(current_fields.get("utm-status", "enable")).lower() == "enable"
+        profiles = {
+            key: (_field_values(current_fields, key) or (None,))[0] if inspects else None
+            for key in ("av-profile", "ips-sensor", "webfilter-profile", "ssl-ssh-profile")
+        }
+        # Consolidated and NGFW security policies name their IPv4 addresses srcaddr4/dstaddr4.
+        source = _field_values(current_fields, "srcaddr") or _field_values(current_fields, "srcaddr4")
+        destination = _field_values(current_fields, "dstaddr") or _field_values(current_fields, "dstaddr4")
+        services = _field_values(current_fields, "service") or default_services
+        # A GUI section starts at the policy carrying its label and runs until the next labelled policy.
+        label = _field_values(current_fields, "global-label") or _field_values(current_fields, "label")
+        section = label[0] if label else (target[-1].section if target else None)
+        if _field_values(current_fields, "application") or _field_values(current_fields, "app-category"):
+            # Application signatures are decided by inspection, not by address and port.
+            services = (f"{policy_id} application filter",)
+        # Unified policies carry IPv6 addresses in srcaddr6/dstaddr6; legacy policy6 uses srcaddr/dstaddr.
+        source6, destination6 = _field_values(current_fields, "srcaddr6"), _field_values(current_fields, "dstaddr6")
+        if ipv6_only:
+            source, destination, source6, destination6 = (), (), source, destination
+        target.append(
             PolicyRule(
                 policy_id=policy_id,
                 name=name.strip('"'),
-                priority=int(policy_id) if policy_id.isdigit() else len(policies) + 1,
-                source=tuple(item.strip('"') for item in srcaddr if item),
-                destination=tuple(item.strip('"') for item in dstaddr if item),
-                services=tuple(item.strip('"') for item in service if item),
+                priority=int(policy_id) if policy_id.isdigit() else len(target) + 1,
+                source=source,
+                destination=destination,
+                services=services,
                 action=action,
                 enabled=status.lower() == "enable",
                 schedule=schedule.strip('"') if isinstance(schedule, str) else None,
+                comment=comments.strip('"').replace('\\"', '"') if isinstance(comments, str) else None,
+                tcp_session_without_syn=tcp_session_without_syn if isinstance(tcp_session_without_syn, str) else None,
+                anti_replay=anti_replay if isinstance(anti_replay, str) else None,
+                session_ttl=session_ttl if isinstance(session_ttl, str) else None,
+                service_negate=str(current_fields.get("service-negate", "disable")).lower() == "enable",
+                src_interfaces=_ingress_interfaces(current_fields),
+                dst_interfaces=_field_values(current_fields, "dstintf"),
+                nat=str(current_fields.get("nat", "disable")).lower() == "enable",
+                ip_pools=_field_values(current_fields, "poolname") if uses_ippool else (),
+                source6=source6,
+                destination6=destination6,
+                internet_services=_internet_services(current_fields, "internet-service"),
+                internet_services_src=_internet_services(current_fields, "internet-service-src"),
+                uuid=uuid.strip('"') if isinstance(uuid, str) else None,
+                logtraffic=logtraffic.lower() if isinstance(logtraffic, str) else None,
+                av_profile=profiles["av-profile"],
+                ips_sensor=profiles["ips-sensor"],
+                webfilter_profile=profiles["webfilter-profile"],
+                ssl_ssh_profile=profiles["ssl-ssh-profile"],
+                users=_field_values(current_fields, "users"),
+                groups=_field_values(current_fields, "groups"),
+                section=section,
             )
         )
         current_name = None
         current_fields = {}
 
+    def flush_policy() -> None:
+        build_policy(policies)
+
+    def flush_policy6() -> None:
+        build_policy(policies, ipv6_only=True)
+
+    def flush_security_policy() -> None:
+        # NGFW policy-based mode leaves `service` unset to allow the applications' default ports.
+        build_policy(policies, default_services=("ALL",))
+
+    def flush_multicast_policy() -> None:
+        build_policy(multicast_policies)
+
+    def flush_local_in_policy() -> None:
+        build_policy(local_in_policies)
+
+    def flush_dos_policy() -> None:
+        build_policy(dos_policies)
+
+    def flush_vip() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        extip = _field_values(current_fields, "extip")
+        mappedip = _field_values(current_fields, "mappedip")
+        if not extip or not mappedip:
+            # Load balancer and FQDN-mapped VIPs cannot be simulated; keep the name unresolved.
+            current_name = None
+            current_fields = {}
+            return
+        ext_start, ext_end = _ip_range(extip[0])
+        mapped_start, mapped_end = _ip_range(mappedip[0])
+        port_forward = str(current_fields.get("portforward", "disable")).lower() == "enable"
+        extport = _field_values(current_fields, "extport")
+        mappedport = _field_values(current_fields, "mappedport") or extport
+        try:
+            vip = VirtualIP(
+                name=current_name,
+                ext_start=parse_ipv4_address(ext_start),
+                ext_end=parse_ipv4_address(ext_end),
+                mapped_start=parse_ipv4_address(mapped_start),
+                mapped_end=parse_ipv4_address(mapped_end),
+                port_forward=port_forward,
+                protocol=Protocol(str(current_fields.get("protocol", "tcp")).lower()),
+                ext_ports=_port_range(extport[0]) if extport else None,
+                mapped_ports=_port_range(mappedport[0]) if mappedport else None,
+            )
+        except (ParseError, ValueError) as exc:
+            report(f"vip {current_name}: {exc}")
+            current_name = None
+            current_fields = {}
+            return
+        address_book.vips[current_name] = vip
+        # Policies reference the VIP as a destination; it matches traffic sent to the external address.
+        address_book.objects[current_name] = parse_address_object(
+            current_name, "iprange", start_ip=ext_start, end_ip=ext_end
+        )
+        current_name = None
+        current_fields = {}
+
+    def flush_vip_group() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        group = AddressGroup(name=current_name, members=_field_values(current_fields, "member"))
+        address_book.vip_groups[current_name] = group
+        address_book.groups[current_name] = group
+        current_name = None
+        current_fields = {}
+
+    def flush_ippool() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        start_ip = _field_values(current_fields, "startip")
+        end_ip = _field_values(current_fields, "endip")
+        try:
+            if not start_ip:
+                raise ParseError("Missing startip")
+            ippools[current_name] = IPPool(
+                name=current_name,
+                pool_type=str(current_fields.get("type", "overload")),
+                start_ip=parse_ipv4_address(start_ip[0]),
+                end_ip=parse_ipv4_address((end_ip or start_ip)[0]),
+            )
+        except ParseError as exc:
+            report(f"ippool {current_name}: {exc}")
+        current_name = None
+        current_fields = {}
+
+    def flush_central_snat() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        orig_port = None
+        try:
+            protocol = int(current_fields.get("protocol", 0))
+            if str(current_fields.get("orig-port", "0")) != "0":
+                orig_port = _port_range(str(current_fields["orig-port"]))
+        except ValueError:
+            report(f"central-snat-map {current_name}: invalid protocol or orig-port")
+            current_name = None
+            current_fields = {}
+            return
+        snat_rules.append(
+            SNATRule(
+                rule_id=current_name,
+                orig_addr=_field_values(current_fields, "orig-addr") or ("all",),
+                dst_addr=_field_values(current_fields, "dst-addr") or ("all",),
+                nat_ippool=_field_values(current_fields, "nat-ippool"),
+                enabled=str(current_fields.get("status", "enable")).lower() == "enable",
+                nat=str(current_fields.get("nat", "enable")).lower() == "enable",
+                protocol=protocol,
+                orig_port=orig_port,
+            )
+        )
+        current_name = None
+        current_fields = {}
+
+    def flush_schedule(kind: str) -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        try:
+            if kind == "recurring":
+                schedule = Schedule(
+                    name=current_name,
+                    kind=kind,
+                    days=tuple(day.lower() for day in _field_values(current_fields, "day")),
+                    start_time=_schedule_time(str(current_fields.get("start", "00:00"))),
+                    end_time=_schedule_time(str(current_fields.get("end", "00:00"))),
+                )
+            elif kind == "onetime":
+                schedule = Schedule(
+                    name=current_name,
+                    kind=kind,
+                    start=_schedule_datetime(str(current_fields.get("start", ""))),
+                    end=_schedule_datetime(str(current_fields.get("end", ""))),
+                )
+            else:
+                schedule = Schedule(name=current_name, kind=kind, members=_field_values(current_fields, "member"))
+            schedules[current_name] = schedule
+        except ValueError as exc:
+            # Unparseable schedules stay unresolved and are treated as inactive.
+            report(f"schedule {current_name}: {exc}")
+        current_name = None
+        current_fields = {}
+
+    def flush_interface() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        address = "/".join(_field_values(current_fields, "ip")[:2])
+        try:
+            ip = IPv4Interface(address) if address and not address.startswith("0.0.0.0") else None
+        except ValueError as exc:
+            report(f"interface {current_name}: {exc}")
+            ip = None
+        interfaces[current_name] = Interface(
+            name=current_name,
+            ip=ip,
+            allowaccess=_field_values(current_fields, "allowaccess"),
+            vdom=next(iter(_field_values(current_fields, "vdom")), None),
+        )
+        current_name = None
+        current_fields = {}
+
+    def flush_static_route() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        dstaddr = _field_values(current_fields, "dstaddr")
+        try:
+            if dstaddr:
+                # Named destinations must be subnet addresses with `allow-routing enable`.
+                address = address_book.objects.get(dstaddr[0])
+                if address is None or address.subnet is None:
+                    raise ParseError(f"Route destination is not a subnet address: {dstaddr[0]}")
+                dst = address.subnet
+            else:
+                dst = parse_subnet(" ".join(_field_values(current_fields, "dst")) or "0.0.0.0/0")
+            gateway = _field_values(current_fields, "gateway")
+            static_routes.append(
+                StaticRoute(
+                    seq_num=current_name,
+                    dst=dst,
+                    gateway=parse_ipv4_address(gateway[0]) if gateway else None,
+                    device=(_field_values(current_fields, "device") or (None,))[0],
+                    distance=int(current_fields.get("distance", 10)),
+                    priority=int(current_fields.get("priority", 0)),
+                    blackhole=str(current_fields.get("blackhole", "disable")).lower() == "enable",
+                    enabled=str(current_fields.get("status", "enable")).lower() == "enable",
+                )
+            )
+        except (ParseError, ValueError) as exc:
+            report(f"static route {current_name}: {exc}")
+        current_name = None
+        current_fields = {}
+
+    def route_prefixes(key: str, address_key: str) -> tuple[IPv4Network, ...]:
+        prefixes = [parse_subnet(value) for value in _field_values(current_fields, key)]
+        for name in _field_values(current_fields, address_key):
+            address = address_book.objects.get(name)
+            if address is None or address.subnet is None:
+                raise ParseError(f"Policy route {address_key} is not a subnet address: {name}")
+            prefixes.append(address.subnet)
+        # `0.0.0.0/0.0.0.0` is the CLI default and means any address.
+        return tuple(prefix for prefix in prefixes if prefix.prefixlen)
+
+    def flush_policy_route() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        try:
+            gateway = _field_values(current_fields, "gateway")
+            policy_routes.append(
+                PolicyRoute(
+                    seq_num=current_name,
+                    input_devices=_field_values(current_fields, "input-device"),
+                    src=route_prefixes("src", "srcaddr"),
+                    dst=route_prefixes("dst", "dstaddr"),
+                    protocol=int(current_fields.get("protocol", 0)),
+                    start_port=int(current_fields.get("start-port", 1)),
+                    end_port=int(current_fields.get("end-port", 65535)),
+                    gateway=parse_ipv4_address(gateway[0]) if gateway else None,
+                    output_device=(_field_values(current_fields, "output-device") or (None,))[0],
+                    action=str(current_fields.get("action", "permit")).lower(),
+                    enabled=str(current_fields.get("status", "enable")).lower() == "enable",
+                )
+            )
+        except (ParseError, ValueError) as exc:
+            report(f"policy route {current_name}: {exc}")
+        current_name = None
+        current_fields = {}
+
+    def flush_zone() -> None:
+        nonlocal current_name, current_fields
+        if not current_name:
+            return
+        zones[current_name] = Zone(
+            name=current_name,
+            interfaces=_field_values(current_fields, "interface"),
+            intrazone=str(current_fields.get("intrazone", "deny")).lower(),
+        )
+        current_name = None
+        current_fields = {}
+
+    def flush_settings() -> None:
+        nonlocal central_nat, current_fields
+        if "central-nat" in current_fields:
+            central_nat = str(current_fields["central-nat"]).lower() == "enable"
+        current_fields = {}
+
     section_flush = {
-        "config firewall address": flush_address,
-        "config firewall addrgrp": flush_addr_group,
+        "config firewall address": lambda: flush_address(address_book.objects),
+        "config firewall addrgrp": lambda: flush_addr_group(address_book.groups),
+        "config firewall address6": lambda: flush_address(address_book.objects6),
+        "config firewall addrgrp6": lambda: flush_addr_group(address_book.groups6),
         "config firewall service custom": flush_service,
         "config firewall service group": flush_service_group,
+        "config firewall service category": flush_service_category,
+        "config firewall multicast-address": lambda: flush_address(address_book.objects),
         "config firewall policy": flush_policy,
+        "config firewall policy6": flush_policy6,
+        "config firewall consolidated policy": flush_policy,
+        "config firewall security-policy": flush_security_policy,
+        "config firewall multicast-policy": flush_multicast_policy,
+        "config firewall local-in-policy": flush_local_in_policy,
+        "config firewall DoS-policy": flush_dos_policy,
+        "config system interface": flush_interface,
+        "config system zone": flush_zone,
+        "config router static": flush_static_route,
+        "config router policy": flush_policy_route,
+        "config firewall central-snat-map": flush_central_snat,
+        "config firewall vip": flush_vip,
+        "config firewall vipgrp": flush_vip_group,
+        "config firewall wildcard-fqdn custom": lambda: flush_address(address_book.objects),
+        "config firewall ippool": flush_ippool,
+        "config system settings": flush_settings,
+        "config firewall schedule recurring": lambda: flush_schedule("recurring"),
+        "config firewall schedule onetime": lambda: flush_schedule("onetime"),
+        "config firewall schedule group": lambda: flush_schedule("group"),
     }
 
-    for raw_line in lines:
+    for line_number, raw_line in enumerate(lines, start=1):
         line = raw_line.strip()
         if not line or line.startswith("#"):
             continue
+        if nested_depth:
+            if line.startswith("config "):
+                nested_depth += 1
+            elif line == "end":
+                nested_depth -= 1
+            continue
         if line.startswith("config "):
+            # `config vdom` wraps whole sections in its edits rather than sub-tables,
+            # and between a VDOM's sections no table is open at all.
+            if in_edit and current_section not in (None, "config vdom"):
+                nested_depth = 1
+                nested_line = line_number
+                continue
             if current_section in section_flush:
                 section_flush[current_section]()
+            # A `config vdom` edit name is not an entry of the section it opens.
+            current_name = None
+            current_fields = {}
             current_section = line
+            entry_line = line_number
             continue
         if line == "end":
             if current_section in section_flush:
                 section_flush[current_section]()
             current_section = None
+            in_edit = False
             continue
         if line.startswith("edit "):
             if current_section in section_flush:
                 section_flush[current_section]()
             current_name = line.split(" ", 1)[1].strip().strip('"')
             current_fields = {}
+            entry_line = line_number
+            in_edit = True
             continue
         if line == "next":
+            in_edit = False
             if current_section in section_flush:
                 section_flush[current_section]()
             continue
         if line.startswith("set "):
             parts = line.split(" ", 2)
             if len(parts) < 3:
+                report(f"`set` without a value: {line}", line_number)
+                continue
+            key = parts[1]
+            value = parts[2].strip()
+            try:
+                shlex.split(value)
+            except ValueError:
+                report(f"Unbalanced quotes: {line}", line_number)
+            current_fields[key] = value
+            continue
+        if line.startswith("append "):
+            parts = line.split(" ", 2)
+            if len(parts) < 3:
+                report(f"`append` without a value: {line}", line_number)
                 continue
             key = parts[1]
             value = parts[2].strip()
-            if key in current_fields:
-                existing = current_fields[key]
-                if isinstance(existing, list):
-                    existing.append(value)
-                else:
-                    current_fields[key] = [existing, value]
+            # Appended members extend the list; `_field_values` splits each value.
+            existing = current_fields.get(key)
+            if isinstance(existing, list):
+                existing.append(value)
+            elif existing:
+                current_fields[key] = [existing, value]
             else:
                 current_fields[key] = value
             continue
-        if line.startswith("unset "):
+        if line.startswith(("unset ", "clear ")):
+            # `clear` empties a member list, which leaves the same default as `unset`.
             key = line.split(" ", 1)[1].strip()
             current_fields.pop(key, None)
+            continue
+        report(f"Unrecognized line: {line}", line_number)
+
+    if nested_depth:
+        report("Nested config block is missing its `end`", nested_line)
 
     if current_section in section_flush:
         section_flush[current_section]()
 
+    # The interface's own address decides the subnet, wherever `system interface` appears in the file.
+    for name, interface_name in interface_subnets.items():
+        interface = interfaces.get(interface_name)
+        if interface is not None and interface.ip is not None:
+            address_book.objects[name] = parse_address_object(
+                name, "ipmask", subnet=str(interface.ip.network), interface=interface_name
+            )
+
     if "all" not in address_book.objects:
         address_book.objects["all"] = parse_address_object("all", "ipmask", subnet="0.0.0.0/0")
+    if "all" not in address_book.objects6:
+        address_book.objects6["all"] = parse_address_object("all", "ipmask", subnet="::/0")
     for name, service in DEFAULT_SERVICES.items():
         service_book.services.setdefault(name, service)
     if "ALL" not in service_book.services:
         service_book.services["ALL"] = make_any_service("ALL")
 
+    if strict and warnings:
+        details = "\n".join(f"{source} {warning}" for warning in warnings)
+        raise ParseError(f"{source}: {len(warnings)} problem(s) in strict parse mode:\n{details}")
+
     policies.sort(key=lambda rule: rule.priority)
+    multicast_policies.sort(key=lambda rule: rule.priority)
+    local_in_policies.sort(key=lambda rule: rule.priority)
+    dos_policies.sort(key=lambda rule: rule.priority)
 
-    return FortiGateData(address_book=address_book, service_book=service_book, policies=policies)
+    return FortiGateData(
+        address_book=address_book,
+        service_book=service_book,
+        policies=policies,
+        multicast_policies=multicast_policies,
+        snat_rules=snat_rules,
+        ippools=ippools,
+        central_nat=central_nat,
+        schedules=schedules,
+        interfaces=interfaces,
+        zones=zones,
+        static_routes=static_routes,
+        policy_routes=policy_routes,
+        local_in_policies=local_in_policies,
+        dos_policies=dos_policies,
+        warnings=warnings,
+    )
@@ -0,0 +1,202 @@
+"""Load firewall objects from the FortiGate REST API."""
+from __future__ import annotations
+
+import json
+import ssl
+from dataclasses import dataclass
+from typing import Any, Iterable, Optional
+from urllib.error import HTTPError, URLError
+from urllib.parse import urlencode
+from urllib.request import Request, urlopen
+
+from ..utils import ParseError
+from .fortigate import FortiGateData, parse_fortigate_config
+
+
+# CLI section name -> CMDB endpoint. Objects come back as JSON and are rendered
+# as CLI `edit` blocks so the config parser's resolution rules apply unchanged.
+CMDB_SECTIONS: dict[str, str] = {
+    "config system settings": "system/settings",
+    "config firewall address": "firewall/address",
+    "config firewall addrgrp": "firewall/addrgrp",
+    "config firewall address6": "firewall/address6",
+    "config firewall addrgrp6": "firewall/addrgrp6",
+    "config firewall service custom": "firewall.service/custom",
+    "config firewall service group": "firewall.service/group",
+    "config firewall service category": "firewall.service/category",
+    "config firewall policy": "firewall/policy",
+    "config firewall policy6": "firewall/policy6",
+    "config firewall consolidated policy": "firewall.consolidated/policy",
+    "config firewall security-policy": "firewall/security-policy",
+    "config firewall multicast-address": "firewall/multicast-address",
+    "config firewall multicast-policy": "firewall/multicast-policy",
+    "config firewall local-in-policy": "firewall/local-in-policy",
+    "config firewall DoS-policy": "firewall/DoS-policy",
+    "config system interface": "system/interface",
+    "config system zone": "system/zone",
+    "config router static": "router/static",
+    "config router policy": "router/policy",
+    "config firewall central-snat-map": "firewall/central-snat-map",
+    "config firewall vip": "firewall/vip",
+    "config firewall vipgrp": "firewall/vipgrp",
+    "config firewall ippool": "firewall/ippool",
+    "config firewall schedule recurring": "firewall.schedule/recurring",
+    "config firewall schedule onetime": "firewall.schedule/onetime",
+    "config firewall schedule group": "firewall.schedule/group",
+}
+
+# Tables that may be absent (older firmware, feature disabled); a 404 reads as empty.
+OPTIONAL_SECTIONS = {
+    "config firewall multicast-address",
+    "config firewall multicast-policy",
+    "config firewall central-snat-map",
+    "config firewall policy6",
+    "config firewall consolidated policy",
+    "config firewall security-policy",
+    "config firewall vipgrp",
+}
+
+# Keys used as the `edit` name for each section; everything else becomes `set`.
+EDIT_KEYS: dict[str, Optional[str]] = {
+    "config system settings": None,
+    "config firewall policy": "policyid",
+    "config firewall policy6": "policyid",
+    "config firewall consolidated policy": "policyid",
+    "config firewall security-policy": "policyid",
+    "config firewall multicast-policy": "id",
+    "config firewall local-in-policy": "policyid",
+    "config firewall DoS-policy": "policyid",
+    "config firewall central-snat-map": "policyid",
+    "config router static": "seq-num",
+    "config router policy": "seq-num",
+}
+
+# Keys naming a member table entry: VIP mappedip entries use "range", zone members
+# "interface-name" and policy route src/dst entries "subnet".
+MEMBER_KEYS = ("name", "range", "interface-name", "subnet")
+
+DEFAULT_PAGE_SIZE = 500
+
+
+@dataclass(frozen=True)
+class APIOptions:
+    """Connection settings for the FortiGate REST API."""
+
+    base_url: str
+    token: str
+    verify_tls: bool = True
+    ca_file: Optional[str] = None
+    page_size: int = DEFAULT_PAGE_SIZE
+    timeout: float = 30.0
+    vdom: Optional[str] = None
+
+
+def _ssl_context(options: APIOptions) -> ssl.SSLContext:
+    context = ssl.create_default_context(cafile=options.ca_file)
+    if not options.verify_tls:
+        context.check_hostname = False
+        context.verify_mode = ssl.CERT_NONE
+    return context
+
+
+def _get_json(url: str, options: APIOptions) -> dict[str, Any]:
+    request = Request(url, headers={"Authorization": f"Bearer {options.token}", "Accept": "application/json"})
+    context = _ssl_context(options) if url.lower().startswith("https://") else None
+    try:
+        with urlopen(request, timeout=options.timeout, context=context) as response:
+            payload = json.load(response)
+    except HTTPError as exc:
+        raise ParseError(f"FortiGate API request failed ({exc.code}): {url}") from exc
+    except (URLError, OSError) as exc:
+        raise ParseError(f"FortiGate API unreachable: {url}: {exc}") from exc
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"FortiGate API returned invalid JSON: {url}") from exc
+    if not isinstance(payload, dict):
+        raise ParseError(f"Unexpected FortiGate API response: {url}")
+    return payload
+
+
+def fetch_cmdb_table(path: str, options: APIOptions) -> list[dict[str, Any]]:
+    """Fetch every entry of a CMDB table, following start/count pagination."""
+    results: list[dict[str, Any]] = []
+    start = 0
+    while True:
+        params: dict[str, str | int] = {"start": start, "count": options.page_size}
+        if options.vdom:
+            params["vdom"] = options.vdom
+        query = urlencode(params)
+        url = f"{options.base_url.rstrip('/')}/api/v2/cmdb/{path}?{query}"
+        payload = _get_json(url, options)
+        page = payload.get("results", [])
+        if isinstance(page, dict):
+            # Singleton objects such as system/settings are returned unpaginated.
+            return [page]
+        if not isinstance(page, list):
+            raise ParseError(f"Unexpected FortiGate API results for {path}")
+        results.extend(page)
+        if len(page) < options.page_size:
+            return results
+        start += len(page)
+
+
+def _quote(value: str) -> str:
+    return '"' + value.replace('"', '\\"') + '"'
+
+
+def _render_values(value: Any) -> list[str]:
+    """Render a JSON attribute as the value of a `set <key>` line, or nothing.
+
+    Member tables become quoted names on a single line, as in `show` output.
+    """
+    if isinstance(value, list):
+        names = [
+            str(next((item[key] for key in MEMBER_KEYS if key in item), ""))
+            for item in value
+            if isinstance(item, dict)
+        ]
+        rendered = " ".join(_quote(name) for name in names if name)
+        return [rendered] if rendered else []
+    if isinstance(value, dict) or value is None:
+        return []
+    text = str(value).strip()
+    return [text] if text else []
+
+
+def render_config(tables: dict[str, Iterable[dict[str, Any]]]) -> list[str]:
+    """Render fetched CMDB tables as FortiGate CLI configuration lines."""
+    lines: list[str] = []
+    for section, entries in tables.items():
+        edit_key = EDIT_KEYS.get(section, "name")
+        lines.append(section)
+        for entry in entries:
+            if edit_key is None:
+                for key, value in entry.items():
+                    for rendered in _render_values(value):
+                        lines.append(f"    set {key} {rendered}")
+                continue
+            edit_name = entry.get(edit_key)
+            if edit_name in (None, ""):
+                continue
+            lines.append(f"    edit {_quote(str(edit_name)) if edit_key == 'name' else edit_name}")
+            for key, value in entry.items():
+                if key in (edit_key, "q_origin_key"):
+                    continue
+                for rendered in _render_values(value):
+                    lines.append(f"        set {key} {rendered}")
+            lines.append("    next")
+        lines.append("end")
+    return lines
+
+
+def parse_fortigate_api(options: APIOptions) -> FortiGateData:
+    """Fetch policy objects from a live FortiGate and parse them into internal models."""
+    tables: dict[str, Iterable[dict[str, Any]]] = {}
+    for section, path in CMDB_SECTIONS.items():
+        try:
+            tables[section] = fetch_cmdb_table(path, options)
+        except ParseError as exc:
+            if section in OPTIONAL_SECTIONS and isinstance(exc.__cause__, HTTPError) and exc.__cause__.code == 404:
+                tables[section] = []
+                continue
+            raise
+    return parse_fortigate_config(render_config(tables))
@@ -0,0 +1,84 @@
+"""Fetch a FortiGate configuration over SSH."""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from typing import Any, Callable, Optional
+
+from ..utils import ParseError
+from .fortigate import FortiGateData, parse_fortigate_config
+
+
+DEFAULT_COMMAND = "show full-configuration"
+
+ClientFactory = Callable[["SSHOptions"], Any]
+
+
+@dataclass(frozen=True)
+class SSHOptions:
+    """Connection settings for fetching the configuration over SSH."""
+
+    host: str
+    username: str
+    port: int = 22
+    key_file: Optional[str] = None
+    password: Optional[str] = None
+    accept_unknown_host: bool = False
+    timeout: float = 30.0
+    command: str = DEFAULT_COMMAND
+
+
+def _require_paramiko() -> Any:
+    """Import paramiko, raising a clear error if missing."""
+    try:
+        import paramiko  # type: ignore
+    except ImportError as exc:  # pragma: no cover - depends on environment
+        raise ParseError(
+            "paramiko is required for SSH config fetch. "
+            "Install with: pip install static-traffic-analyzer[ssh]"
+        ) from exc
+    return paramiko
+
+
+def _default_client(options: SSHOptions) -> Any:
+    paramiko = _require_paramiko()
+    client = paramiko.SSHClient()
+    client.load_system_host_keys()
+    policy = paramiko.AutoAddPolicy() if options.accept_unknown_host else paramiko.RejectPolicy()
+    client.set_missing_host_key_policy(policy)
+    return client
+
+
+def fetch_config_over_ssh(options: SSHOptions, client_factory: Optional[ClientFactory] = None) -> list[str]:
+    """Run the show command on the device and return its output lines.
+
+    The console should use ``set output standard`` so the output is not paged.
+    """
+    client = (client_factory or _default_client)(options)
+    try:
+        client.connect(
+            options.host,
+            port=options.port,
+            username=options.username,
+            password=options.password,
+            key_filename=options.key_file,
+            timeout=options.timeout,
+            look_for_keys=options.key_file is None and options.password is None,
+        )
+        _, stdout, stderr = client.exec_command(options.command, timeout=options.timeout)
+        output = stdout.read().decode("utf-8", errors="replace")
+        status = stdout.channel.recv_exit_status()
+        if status != 0:
+            error = stderr.read().decode("utf-8", errors="replace").strip()
+            raise ParseError(f"SSH command failed on {options.host} ({status}): {error}")
+    except ParseError:
+        raise
+    except Exception as exc:  # paramiko raises many unrelated exception types
+        raise ParseError(f"SSH config fetch from {options.host} failed: {exc}") from exc
+    finally:
+        client.close()
+    return [line.rstrip("\r") for line in output.split("\n")]
+
+
+def parse_fortigate_ssh(options: SSHOptions, client_factory: Optional[ClientFactory] = None) -> FortiGateData:
+    """Fetch the running configuration over SSH and parse it into internal models."""
+    return parse_fortigate_config(fetch_config_over_ssh(options, client_factory))
@@ -0,0 +1,375 @@
+"""Parser for nftables rulesets exported with `nft -j list ruleset`."""
+from __future__ import annotations
+
+import json
+from dataclasses import dataclass, replace
+from typing import Any, Iterable, Optional
+
+from ..models import AddressBook, AddressGroup, PolicyRule, Protocol, ServiceBook, ServiceEntry, ServiceObject
+from ..utils import ParseError, parse_address_object
+from .common import RuleSet, add_builtin_objects
+
+
+VERDICTS = {"accept": "accept", "drop": "deny", "reject": "deny"}
+RETURN = "return"
+# Base chain hooks whose rules decide forwarded traffic.
+EVALUATED_HOOKS = ("forward",)
+
+
+def _prefix(value: Any) -> str:
+    """Render an address expression (plain, prefix or range) as CIDR or range text."""
+    if isinstance(value, dict) and "prefix" in value:
+        return f"{value['prefix']['addr']}/{value['prefix']['len']}"
+    if isinstance(value, dict) and "range" in value:
+        start, end = value["range"]
+        return f"{start}-{end}"
+    return str(value)
+
+
+def _port_ranges(value: Any) -> list[tuple[int, int]]:
+    if isinstance(value, dict) and "range" in value:
+        start, end = value["range"]
+        return [(int(start), int(end))]
+    if isinstance(value, dict) and "set" in value:
+        return [port_range for item in value["set"] for port_range in _port_ranges(item)]
+    return [(int(value), int(value))]
+
+
+def _set_element(element: Any) -> Any:
+    """Unwrap `{"elem": {"val": ...}}` set elements carrying timeouts or comments."""
+    if isinstance(element, dict) and "elem" in element:
+        return element["elem"].get("val")
+    return element
+
+
+def _elements(value: Any) -> list[Any]:
+    if isinstance(value, dict) and "set" in value:
+        return list(value["set"])
+    if isinstance(value, list):
+        return value
+    return [value]
+
+
+class _Builder:
+    """Translate nftables objects into address and service book entries."""
+
+    def __init__(self) -> None:
+        self.address_book = AddressBook()
+        self.service_book = ServiceBook()
+        self.port_sets: dict[str, list[tuple[int, int]]] = {}
+
+    def address(self, value: Any) -> str:
+        text = _prefix(value)
+        if text not in self.address_book.objects:
+            if "-" in text:
+                start, end = text.split("-", 1)
+                self.address_book.objects[text] = parse_address_object(text, "iprange", start_ip=start, end_ip=end)
+            else:
+                self.address_book.objects[text] = parse_address_object(text, "ipmask", subnet=text)
+        return text
+
+    def add_set(self, definition: dict[str, Any]) -> None:
+        name = f"@{definition['name']}"
+        elements = [_set_element(element) for element in definition.get("elem", [])]
+        if definition.get("type") == "ipv4_addr":
+            members = tuple(self.address(element) for element in elements)
+            self.address_book.groups[name] = AddressGroup(name=name, members=members)
+        elif definition.get("type") == "inet_service":
+            self.port_sets[name] = [port_range for element in elements for port_range in _port_ranges(element)]
+
+    def addresses(self, right: Any) -> tuple[str, ...]:
+        if isinstance(right, str) and right.startswith("@"):
+            return (right,)
+        return tuple(self.address(element) for element in _elements(right))
+
+    def _ranges(self, value: Any) -> tuple[list[tuple[int, int]], str]:
+        if isinstance(value, str) and value.startswith("@"):
+            return self.port_sets.get(value, []), value
+        ranges = _port_ranges(value)
+        return ranges, ",".join(f"{start}-{end}" if start != end else str(start) for start, end in ranges)
+
+    def service(self, protocols: tuple[Protocol, ...], ports: Any = None, src_ports: Any = None) -> str:
+        name = "/".join(protocol.value for protocol in protocols)
+        ranges = [(1, 65535)]
+        if ports is not None:
+            ranges, label = self._ranges(ports)
+            name = f"{name} dport {label}"
+        src_ranges: list[tuple[Optional[int], Optional[int]]] = [(None, None)]
+        if src_ports is not None:
+            src_ranges, label = self._ranges(src_ports)  # type: ignore[assignment]
+            name = f"{name} sport {label}"
+        entries = tuple(
+            ServiceEntry(protocol=protocol, start_port=start, end_port=end, src_start_port=low, src_end_port=high)
+            for protocol in protocols
+            for start, end in ranges
+            for low, high in src_ranges
+        )
+        self.service_book.services.setdefault(name, ServiceObject(name=name, entries=entries))
+        return name
+
+
+def _protocols(value: Any) -> Optional[tuple[Protocol, ...]]:
+    """Return the L4 protocols named by a match, or None if none are TCP/UDP."""
+    protocols = []
+    for element in _elements(value):
+        if str(element) in (Protocol.TCP.value, Protocol.UDP.value):
+            protocols.append(Protocol(str(element)))
+    return tuple(protocols) or None
+
+
+def _describe(left: Any) -> str:
+    """Name the left-hand side of a match, e.g. ``meta mark`` or ``tcp flags``."""
+    if isinstance(left, dict) and "payload" in left:
+        payload = left["payload"]
+        return f"{payload.get('protocol', 'payload')} {payload.get('field', payload.get('base', ''))}".strip()
+    if isinstance(left, dict) and left:
+        key, value = next(iter(left.items()))
+        return f"{key} {value['key']}" if isinstance(value, dict) and "key" in value else str(key)
+    return str(left)
+
+
+@dataclass(frozen=True)
+class _Match:
+    """The conditions a rule places on a flow; None leaves a dimension unconstrained.
+
+    Conditions that cannot be modelled are listed in ``unmodelled`` and make
+    the rule evaluate as UNKNOWN rather than match more than it does.
+    """
+
+    source: Optional[tuple[str, ...]] = None
+    destination: Optional[tuple[str, ...]] = None
+    protocols: Optional[tuple[Protocol, ...]] = None
+    ports: Any = None
+    src_ports: Any = None
+    src_interfaces: Optional[tuple[str, ...]] = None
+    dst_interfaces: Optional[tuple[str, ...]] = None
+    unmodelled: tuple[str, ...] = ()
+
+    def combine(self, other: _Match) -> Optional[_Match]:
+        """Return the conditions of a jump rule and a rule of its target chain together, or None if disjoint."""
+        protocols = self.protocols or other.protocols
+        if self.protocols and other.protocols:
+            protocols = tuple(protocol for protocol in self.protocols if protocol in other.protocols)
+            if not protocols:
+                return None
+        unmodelled = [*self.unmodelled, *other.unmodelled]
+        values: dict[str, Any] = {}
+        for name in ("source", "destination", "ports", "src_ports", "src_interfaces", "dst_interfaces"):
+            outer, inner = getattr(self, name), getattr(other, name)
+            if outer is not None and inner is not None and outer != inner:
+                # Intersecting two named conditions is not modelled.
+                unmodelled.append(f"{name} narrowed twice")
+            values[name] = inner if inner is not None else outer
+        return _Match(protocols=protocols, unmodelled=tuple(unmodelled), **values)
+
+
+@dataclass(frozen=True)
+class _Rule:
+    """A parsed rule: its conditions and either a verdict or a jump/goto target."""
+
+    match: _Match
+    verdict: Optional[str] = None
+    target: Optional[tuple[str, str]] = None
+
+
+def _interfaces(right: Any) -> Optional[tuple[str, ...]]:
+    """Return interface names, or None if a wildcard name cannot be matched exactly."""
+    names = tuple(str(element) for element in _elements(right))
+    return None if any("*" in name for name in names) else names
+
+
+def _parse_rule(builder: _Builder, rule: dict[str, Any]) -> Optional[_Rule]:
+    """Parse one rule, or return None if it cannot apply to a new IPv4 TCP/UDP flow."""
+    fields: dict[str, Any] = {}
+    unmodelled: list[str] = []
+    verdict: Optional[str] = None
+    target: Optional[tuple[str, str]] = None
+    for expression in rule.get("expr", []):
+        for key in VERDICTS:
+            if key in expression:
+                verdict = VERDICTS[key]
+        if RETURN in expression:
+            verdict = RETURN
+        for kind in ("jump", "goto"):
+            if kind in expression:
+                target = (kind, expression[kind]["target"])
+        match = expression.get("match")
+        if not match:
+            continue
+        left, right, negated = match.get("left", {}), match.get("right"), match.get("op") == "!="
+        payload = left.get("payload", {}) if isinstance(left, dict) else {}
+        meta = left.get("meta", {}).get("key") if isinstance(left, dict) and "meta" in left else None
+        field = payload.get("field")
+        if isinstance(left, dict) and "ct" in left and left["ct"].get("key") == "state":
+            if ("new" in _elements(right)) == negated:
+                return None
+        elif payload.get("protocol") == "ip6" or (meta == "nfproto" and "ipv6" in _elements(right) and not negated):
+            # IPv6-only rules never see the IPv4 flows evaluated here.
+            return None
+        elif meta == "nfproto" and _elements(right) == ["ipv4"] and not negated:
+            continue
+        elif payload.get("protocol") == "ip" and field in ("saddr", "daddr"):
+            names = builder.addresses(right)
+            if negated:
+                # Negated matches are not modelled; an unresolved name evaluates as UNKNOWN.
+                names = tuple(f"not {name}" for name in names)
+            fields["source" if field == "saddr" else "destination"] = names
+        elif (meta == "l4proto" or (payload.get("protocol") == "ip" and field == "protocol")) and not negated:
+            matched = _protocols(right)
+            if matched is None:
+                return None
+            fields["protocols"] = matched
+        elif payload.get("protocol") in ("tcp", "udp", "th") and field in ("dport", "sport") and not negated:
+            if payload["protocol"] != "th":
+                fields["protocols"] = (Protocol(payload["protocol"]),)
+            fields["ports" if field == "dport" else "src_ports"] = right
+        elif meta in ("iifname", "oifname", "iif", "oif") and not negated and _interfaces(right) is not None:
+            fields["src_interfaces" if meta.startswith("i") else "dst_interfaces"] = _interfaces(right)
+        else:
+            unmodelled.append(f"{'!= ' if negated else ''}{_describe(left)}")
+    if verdict is None and target is None:
+        return None
+    return _Rule(match=_Match(unmodelled=tuple(unmodelled), **fields), verdict=verdict, target=target)
+
+
+def _policy(builder: _Builder, match: _Match, policy_id: str, name: str, priority: int, action: str) -> PolicyRule:
+    protocols = match.protocols or (Protocol.TCP, Protocol.UDP)
+    if match.ports is not None or match.src_ports is not None or protocols != (Protocol.TCP, Protocol.UDP):
+        service = builder.service(protocols, match.ports, match.src_ports)
+    else:
+        service = "ALL"
+    source = match.source or ("all",)
+    if match.unmodelled:
+        # Unresolved names evaluate as UNKNOWN, so the rule never decides a flow it may not match.
+        source = tuple(f"unmodelled {text}" for text in match.unmodelled)
+    return PolicyRule(
+        policy_id=policy_id,
+        name=name,
+        priority=priority,
+        source=source,
+        destination=match.destination or ("all",),
+        services=(service,),
+        action=action,
+        enabled=True,
+        src_interfaces=match.src_interfaces or (),
+        dst_interfaces=match.dst_interfaces or (),
+    )
+
+
+def _flatten(
+    builder: _Builder,
+    rules_by_chain: dict[tuple[str, str, str], list[dict[str, Any]]],
+    key: tuple[str, str, str],
+    inherited: _Match,
+    prefix: str,
+    chain_policy: str,
+    policies: list[PolicyRule],
+    stack: tuple[tuple[str, str, str], ...] = (),
+) -> None:
+    """Append a chain's rules in order, inlining jumped-to chains under the jump rule's conditions."""
+    family, table, chain = key
+    for rule in rules_by_chain.get(key, []):
+        parsed = _parse_rule(builder, rule)
+        match = inherited.combine(parsed.match) if parsed is not None else None
+        if parsed is None or match is None:
+            continue
+        policy_id = f"{prefix}{chain}:{rule.get('handle', len(policies) + 1)}"
+        name = str(rule.get("comment") or chain)
+        if parsed.target is not None:
+            kind, target = parsed.target
+            nested = (family, table, target)
+            if nested in stack or nested == key:
+                continue
+            _flatten(builder, rules_by_chain, nested, match, f"{policy_id}>", chain_policy, policies, (*stack, key))
+            if kind == "goto":
+                # Flows falling off the end of a goto target skip the rest of this chain.
+                end = _policy(builder, match, f"{policy_id}>{target}:end", name, len(policies) + 1, chain_policy)
+                policies.append(end)
+        elif parsed.verdict == RETURN:
+            if stack:
+                # Resuming the calling chain is not modelled.
+                match = replace(match, unmodelled=(*match.unmodelled, RETURN))
+            policies.append(_policy(builder, match, policy_id, name, len(policies) + 1, chain_policy))
+        elif parsed.verdict is not None:
+            policies.append(_policy(builder, match, policy_id, name, len(policies) + 1, parsed.verdict))
+
+
+def parse_nftables_chains(lines: Iterable[str]) -> list[tuple[str, RuleSet]]:
+    """Parse an nftables JSON ruleset into one rule set per forward base chain, in ``prio`` order.
+
+    Rules of each base chain hooked at ``forward`` are evaluated in order,
+    followed by the chain's policy. Rules that ``jump`` or ``goto`` another
+    chain are replaced by that chain's rules, narrowed by the jump's matches.
+    Named ``ipv4_addr`` sets become address groups and ``inet_service`` sets
+    become port lists for the rules using them. Rules that match only
+    established traffic, IPv6 or non-TCP/UDP protocols are skipped; rules with
+    other matches that cannot be modelled evaluate as UNKNOWN.
+    """
+    try:
+        document = json.loads("".join(lines))
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid nftables JSON: {exc}") from exc
+    items = document.get("nftables", []) if isinstance(document, dict) else []
+
+    builder = _Builder()
+    base_chains: dict[tuple[str, str, str], dict[str, Any]] = {}
+    for item in items:
+        if "set" in item:
+            builder.add_set(item["set"])
+        elif "chain" in item and item["chain"].get("hook") in EVALUATED_HOOKS:
+            chain = item["chain"]
+            base_chains[(chain["family"], chain["table"], chain["name"])] = chain
+    add_builtin_objects(builder.address_book, builder.service_book)
+
+    rules_by_chain: dict[tuple[str, str, str], list[dict[str, Any]]] = {}
+    for item in items:
+        rule = item.get("rule")
+        if rule:
+            rules_by_chain.setdefault((rule["family"], rule["table"], rule["chain"]), []).append(rule)
+    rule_sets: list[tuple[str, RuleSet]] = []
+    # A packet traverses every base chain on the hook, lowest priority first.
+    for key, chain in sorted(base_chains.items(), key=lambda item: item[1].get("prio", 0)):
+        chain_policy = VERDICTS.get(chain.get("policy", "accept"), "deny")
+        policies: list[PolicyRule] = []
+        _flatten(builder, rules_by_chain, key, _Match(), f"{chain['table']}/", chain_policy, policies)
+        policies.append(
+            PolicyRule(
+                policy_id=f"{chain['table']}/{chain['name']}:policy",
+                name=f"{chain['name']} policy",
+                priority=len(policies) + 1,
+                source=("all",),
+                destination=("all",),
+                services=("ALL",),
+                action=chain_policy,
+                enabled=True,
+            )
+        )
+        rule_sets.append(
+            (
+                f"{chain['table']}/{chain['name']}",
+                RuleSet(address_book=builder.address_book, service_book=builder.service_book, policies=policies),
+            )
+        )
+    if not rule_sets:
+        rule_sets.append(
+            ("forward", RuleSet(address_book=builder.address_book, service_book=builder.service_book, policies=[]))
+        )
+    return rule_sets
+
+
+def parse_nftables_ruleset(lines: Iterable[str]) -> RuleSet:
+    """Parse an nftables JSON ruleset with a single forward base chain into internal models.
+
+    A packet must be accepted by every base chain hooked at ``forward``, which
+    one first-match rule list cannot express, so rulesets with several such
+    chains are rejected; evaluate those chain by chain with
+    :func:`parse_nftables_chains`.
+    """
+    chains = parse_nftables_chains(lines)
+    if len(chains) > 1:
+        names = ", ".join(name for name, _ in chains)
+        raise ParseError(
+            f"nftables ruleset has {len(chains)} forward base chains ({names}); every chain must accept a "
+            "flow, so analyze one chain at a time"
+        )
+    return chains[0][1]
@@ -0,0 +1,150 @@
+"""Parser for the vendor-neutral JSON/YAML rules schema.
+
+Other tools can emit rules for the analyzer in this schema instead of a
+vendor configuration. Every top-level key except ``policies`` is optional::
+
+    version: 1
+    addresses:
+      - {name: LAN, type: ipmask, subnet: 10.0.0.0/24}
+      - {name: WEB, type: iprange, start_ip: 10.1.0.20, end_ip: 10.1.0.29}
+      - {name: UPDATES, type: fqdn, fqdn: updates.example.com}
+    address_groups:
+      - {name: SERVERS, members: [WEB]}
+    services:
+      - {name: WEB_PORTS, entries: [{protocol: tcp, ports: "443"}, {protocol: tcp, ports: 8000-8100}]}
+    service_groups:
+      - {name: WEB_SVC, members: [WEB_PORTS, DNS]}
+    policies:
+      - {id: "10", name: lan-to-web, source: [LAN], destination: [SERVERS], services: [WEB_SVC], action: accept}
+
+Policies are evaluated in list order. ``action`` is ``accept`` or ``deny``;
+``enabled`` is a boolean defaulting to true and ``schedule``/``comment`` are
+optional. The built-in ``all`` address and
+``ALL``/default services are always available.
+"""
+from __future__ import annotations
+
+import json
+from typing import Any, Iterable
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+SCHEMA_VERSION = 1
+ACTIONS = ("accept", "deny")
+
+
+def _require_yaml() -> Any:
+    """Import PyYAML, raising a clear error if missing."""
+    try:
+        import yaml  # type: ignore
+    except ModuleNotFoundError as exc:
+        raise ParseError(
+            "PyYAML is required for YAML rule files. "
+            "Install with: pip install 'static-traffic-analyzer[yaml]' or use JSON"
+        ) from exc
+    return yaml
+
+
+def _load_document(text: str) -> Any:
+    if text.lstrip().startswith(("{", "[")):
+        try:
+            return json.loads(text)
+        except json.JSONDecodeError as exc:
+            raise ParseError(f"Invalid rules JSON: {exc}") from exc
+    yaml = _require_yaml()
+    try:
+        return yaml.safe_load(text)
+    except yaml.YAMLError as exc:
+        raise ParseError(f"Invalid rules YAML: {exc}") from exc
+
+
+def _entries(document: dict[str, Any], key: str) -> list[dict[str, Any]]:
+    entries = document.get(key) or []
+    if not isinstance(entries, list) or not all(isinstance(entry, dict) for entry in entries):
+        raise ParseError(f"'{key}' must be a list of mappings")
+    return entries
+
+
+def _names(entry: dict[str, Any], key: str) -> tuple[str, ...]:
+    value = entry.get(key) or []
+    if isinstance(value, str):
+        return (value,)
+    return tuple(str(item) for item in value)
+
+
+def _required(entry: dict[str, Any], key: str, section: str) -> str:
+    if entry.get(key) in (None, ""):
+        raise ParseError(f"{section} entry missing '{key}': {entry}")
+    return str(entry[key])
+
+
+def parse_normalized_rules(lines: Iterable[str]) -> RuleSet:
+    """Parse a JSON or YAML document in the normalized rules schema."""
+    document = _load_document("".join(lines))
+    if not isinstance(document, dict):
+        raise ParseError("Rules document must be a mapping with a 'policies' list")
+    version = document.get("version", SCHEMA_VERSION)
+    if version != SCHEMA_VERSION:
+        raise ParseError(f"Unsupported rules schema version: {version}")
+    if "policies" not in document:
+        raise ParseError("Rules document has no 'policies' list")
+
+    address_book = AddressBook()
+    service_book = ServiceBook()
+
+    for entry in _entries(document, "addresses"):
+        name = _required(entry, "name", "addresses")
+        address_book.objects[name] = parse_address_object(
+            name=name,
+            address_type=str(entry.get("type", "ipmask")),
+            subnet=entry.get("subnet"),
+            start_ip=entry.get("start_ip"),
+            end_ip=entry.get("end_ip"),
+            fqdn=entry.get("fqdn"),
+        )
+    for entry in _entries(document, "address_groups"):
+        name = _required(entry, "name", "address_groups")
+        address_book.groups[name] = AddressGroup(name=name, members=_names(entry, "members"))
+
+    for entry in _entries(document, "services"):
+        name = _required(entry, "name", "services")
+        service_entries = tuple(
+            parse_service_entry(f"{_required(item, 'protocol', name)}_{_required(item, 'ports', name)}")
+            for item in _entries(entry, "entries")
+        )
+        if not service_entries:
+            raise ParseError(f"Service {name} has no entries")
+        service_book.services[name] = ServiceObject(name=name, entries=service_entries)
+    for entry in _entries(document, "service_groups"):
+        name = _required(entry, "name", "service_groups")
+        service_book.groups[name] = ServiceGroup(name=name, members=_names(entry, "members"))
+
+    policies: list[PolicyRule] = []
+    for priority, entry in enumerate(_entries(document, "policies"), start=1):
+        policy_id = _required(entry, "id", "policies")
+        action = _required(entry, "action", "policies").lower()
+        if action not in ACTIONS:
+            raise ParseError(f"Policy {policy_id}: action must be accept or deny, got {entry['action']!r}")
+        enabled = entry.get("enabled", True)
+        if not isinstance(enabled, bool):
+            raise ParseError(f"Policy {policy_id}: enabled must be true or false, got {enabled!r}")
+        policies.append(
+            PolicyRule(
+                policy_id=policy_id,
+                name=str(entry.get("name") or policy_id),
+                priority=priority,
+                source=_names(entry, "source"),
+                destination=_names(entry, "destination"),
+                services=_names(entry, "services"),
+                action=action,
+                enabled=enabled,
+                schedule=entry.get("schedule"),
+                comment=entry.get("comment"),
+            )
+        )
+
+    add_builtin_objects(address_book, service_book)
+    return RuleSet(address_book=address_book, service_book=service_book, policies=policies)
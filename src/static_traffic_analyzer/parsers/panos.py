@@ -0,0 +1,224 @@
+"""Parser for Palo Alto PAN-OS configurations in set or XML format."""
+from __future__ import annotations
+
+import shlex
+import xml.etree.ElementTree as ET
+from typing import Iterable, Optional
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+# Services PAN-OS predefines; referenced by name without a definition.
+PREDEFINED_SERVICES = {
+    "service-http": ("tcp", "80,8080"),
+    "service-https": ("tcp", "443"),
+}
+
+# Scopes that may prefix an object path in set output.
+SCOPE_KEYWORDS = {"shared": 0, "vsys": 1, "device-group": 1}
+
+
+def _address(name: str, kind: str, value: str):
+    if kind == "ip-netmask":
+        return parse_address_object(name, "ipmask", subnet=value)
+    if kind == "ip-range":
+        start, _, end = value.partition("-")
+        return parse_address_object(name, "iprange", start_ip=start, end_ip=end)
+    if kind == "fqdn":
+        return parse_address_object(name, "fqdn", fqdn=value)
+    raise ParseError(f"Unsupported PAN-OS address type: {kind}")
+
+
+def _ports(value: str) -> list[str]:
+    return [port.strip() for port in value.split(",") if port.strip()]
+
+
+def _service(name: str, protocol: str, ports: str, source_ports: str = "") -> ServiceObject:
+    entries = tuple(
+        parse_service_entry(f"{protocol}_{port}{f':{source_port}' if source_port else ''}")
+        for port in _ports(ports)
+        for source_port in _ports(source_ports) or [""]
+    )
+    if not entries:
+        raise ParseError("Missing port")
+    return ServiceObject(name=name, entries=entries)
+
+
+class _Collector:
+    """Accumulate PAN-OS objects and rules before building internal models."""
+
+    def __init__(self) -> None:
+        self.addresses: dict[str, tuple[str, str]] = {}
+        self.address_groups: dict[str, list[str]] = {}
+        # Service name -> protocol, port and source-port settings.
+        self.services: dict[str, dict[str, str]] = {}
+        self.service_groups: dict[str, list[str]] = {}
+        self.rules: dict[str, dict[str, list[str]]] = {}
+
+    def rule(self, name: str) -> dict[str, list[str]]:
+        return self.rules.setdefault(name, {})
+
+    def build(self) -> RuleSet:
+        address_book = AddressBook()
+        service_book = ServiceBook()
+        warnings: list[str] = []
+        for name, (kind, value) in self.addresses.items():
+            try:
+                address_book.objects[name] = _address(name, kind, value)
+            except ParseError as exc:
+                # Left undefined, so rules referencing it evaluate as UNKNOWN.
+                warnings.append(f"address {name}: {exc}")
+        for name, members in self.address_groups.items():
+            address_book.groups[name] = AddressGroup(name=name, members=tuple(members))
+        for name, (protocol, ports) in PREDEFINED_SERVICES.items():
+            service_book.services[name] = _service(name, protocol, ports)
+        for name, settings in self.services.items():
+            try:
+                service_book.services[name] = _service(
+                    name, settings.get("protocol", ""), settings.get("port", ""), settings.get("source-port", "")
+                )
+            except ParseError as exc:
+                warnings.append(f"service {name}: {exc}")
+        for name, members in self.service_groups.items():
+            service_book.groups[name] = ServiceGroup(name=name, members=tuple(members))
+        add_builtin_objects(address_book, service_book)
+
+        policies: list[PolicyRule] = []
+        for index, (name, fields) in enumerate(self.rules.items(), start=1):
+            services = fields.get("service", ["any"])
+            applications = fields.get("application", ["any"])
+            if applications != ["any"]:
+                # App-ID decides these rules, not address and port; an unresolved service keeps them UNKNOWN.
+                services = [f"{name} application filter"]
+            elif services == ["application-default"]:
+                services = ["any"]
+            action = (fields.get("action") or ["deny"])[0]
+            source = _any(fields.get("source", ["any"]))
+            destination = _any(fields.get("destination", ["any"]))
+            # Negation is not modelled; an unresolved name evaluates as UNKNOWN.
+            if (fields.get("negate-source") or ["no"])[0] == "yes":
+                source = [f"not {member}" for member in source]
+            if (fields.get("negate-destination") or ["no"])[0] == "yes":
+                destination = [f"not {member}" for member in destination]
+            policies.append(
+                PolicyRule(
+                    policy_id=name,
+                    name=name,
+                    priority=index,
+                    source=tuple(source),
+                    destination=tuple(destination),
+                    services=tuple("ALL" if service == "any" else service for service in services),
+                    action="accept" if action == "allow" else "deny",
+                    enabled=(fields.get("disabled") or ["no"])[0] != "yes",
+                    comment=(fields.get("description") or [None])[0],
+                )
+            )
+        return RuleSet(address_book=address_book, service_book=service_book, policies=policies, warnings=warnings)
+
+
+def _any(names: Iterable[str]) -> list[str]:
+    return ["all" if name == "any" else name for name in names]
+
+
+def _values(tokens: list[str]) -> list[str]:
+    """Return the values of a set command, unwrapping `[ a b ]` lists."""
+    return [token for token in tokens if token not in ("[", "]")]
+
+
+def _strip_scope(tokens: list[str]) -> list[str]:
+    while tokens and tokens[0] in SCOPE_KEYWORDS:
+        tokens = tokens[1 + SCOPE_KEYWORDS[tokens[0]] :]
+    return tokens
+
+
+def _parse_set(lines: Iterable[str], collector: _Collector) -> None:
+    for raw_line in lines:
+        line = raw_line.strip()
+        if not line.startswith("set "):
+            continue
+        try:
+            tokens = _strip_scope(shlex.split(line)[1:])
+        except ValueError as exc:
+            raise ParseError(f"Invalid PAN-OS set line: {line}") from exc
+        if len(tokens) < 3:
+            continue
+        kind, name, rest = tokens[0], tokens[1], tokens[2:]
+        if kind == "address" and len(rest) >= 2:
+            collector.addresses[name] = (rest[0], rest[1])
+        elif kind == "address-group" and rest[0] == "static":
+            collector.address_groups.setdefault(name, []).extend(_values(rest[1:]))
+        elif kind == "service" and rest[:1] == ["protocol"] and len(rest) >= 4:
+            settings = collector.services.setdefault(name, {})
+            settings["protocol"] = rest[1]
+            for key, value in zip(rest[2::2], rest[3::2]):
+                if key in ("port", "source-port"):
+                    settings[key] = value
+        elif kind == "service-group" and rest[0] == "members":
+            collector.service_groups.setdefault(name, []).extend(_values(rest[1:]))
+        elif kind in ("rulebase", "pre-rulebase", "post-rulebase") and tokens[1:3] == ["security", "rules"]:
+            if len(tokens) < 5:
+                continue
+            rule = collector.rule(tokens[3])
+            field, values = tokens[4], _values(tokens[5:])
+            rule.setdefault(field, []).extend(values)
+
+
+def _members(element: Optional[ET.Element]) -> list[str]:
+    if element is None:
+        return []
+    return [member.text or "" for member in element.findall("member")]
+
+
+def _parse_xml(text: str, collector: _Collector) -> None:
+    try:
+        root = ET.fromstring(text)
+    except ET.ParseError as exc:
+        raise ParseError(f"Invalid PAN-OS XML: {exc}") from exc
+    for entry in root.findall(".//address/entry"):
+        for kind in ("ip-netmask", "ip-range", "fqdn", "ip-wildcard"):
+            value = entry.findtext(kind)
+            if value:
+                collector.addresses[entry.get("name", "")] = (kind, value.strip())
+    for entry in root.findall(".//address-group/entry"):
+        collector.address_groups[entry.get("name", "")] = _members(entry.find("static"))
+    for entry in root.findall(".//service/entry"):
+        for protocol in ("tcp", "udp"):
+            ports = entry.findtext(f"protocol/{protocol}/port")
+            if ports:
+                settings = {"protocol": protocol, "port": ports.strip()}
+                source_ports = entry.findtext(f"protocol/{protocol}/source-port")
+                if source_ports:
+                    settings["source-port"] = source_ports.strip()
+                collector.services[entry.get("name", "")] = settings
+    for entry in root.findall(".//service-group/entry"):
+        collector.service_groups[entry.get("name", "")] = _members(entry.find("members"))
+    for entry in root.findall(".//security/rules/entry"):
+        rule = collector.rule(entry.get("name", ""))
+        for field in ("from", "to", "source", "destination", "service", "application"):
+            element = entry.find(field)
+            if element is not None:
+                rule[field] = _members(element)
+        for field in ("action", "disabled", "description", "negate-source", "negate-destination"):
+            value = entry.findtext(field)
+            if value is not None:
+                rule[field] = [value.strip()]
+
+
+def parse_panos_config(lines: Iterable[str]) -> RuleSet:
+    """Parse PAN-OS addresses, groups, services and security rules into internal models.
+
+    Accepts either `set` command output or an XML configuration export. Rules
+    are evaluated in the order they first appear. Addresses and services that
+    cannot be parsed are left undefined with a warning, so rules referencing
+    them evaluate as UNKNOWN.
+    """
+    lines = list(lines)
+    collector = _Collector()
+    text = "".join(line if line.endswith("\n") else f"{line}\n" for line in lines)
+    if text.lstrip().startswith("<"):
+        _parse_xml(text, collector)
+    else:
+        _parse_set(lines, collector)
+    return collector.build()
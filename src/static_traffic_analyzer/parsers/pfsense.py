@@ -0,0 +1,130 @@
+"""Parser for pfSense/OPNsense config.xml files."""
+from __future__ import annotations
+
+import xml.etree.ElementTree as ET
+from typing import Iterable, Optional
+
+from ..models import AddressBook, AddressGroup, PolicyRule, Protocol, ServiceBook, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_ipv4_network, parse_service_entry
+from .common import RuleSet, add_builtin_objects
+
+
+PROTOCOLS = {"tcp": (Protocol.TCP,), "udp": (Protocol.UDP,), "tcp/udp": (Protocol.TCP, Protocol.UDP)}
+RULE_ACTIONS = {"pass": "accept", "block": "deny", "reject": "deny"}
+
+
+def _is_network(value: str) -> bool:
+    try:
+        parse_ipv4_network(value)
+    except ParseError:
+        return False
+    return True
+
+
+def _port_range(value: str) -> str:
+    """Normalize pfSense port syntax (`80`, `1000:2000`, `1000-2000`) to `start-end`."""
+    return value.replace(":", "-")
+
+
+def parse_pfsense_config(lines: Iterable[str]) -> RuleSet:
+    """Parse aliases and filter rules from a pfSense or OPNsense config.xml.
+
+    Rules are evaluated in file order and scoped to the interfaces they are
+    assigned to (floating rules list several), which are matched against the
+    flow's ingress interface. Source ports
+    narrow the rule's service for flows that carry a source port. Interface
+    networks such as ``lan`` and negated endpoints cannot be resolved from the
+    file alone, so they are kept as unresolved names and evaluate as UNKNOWN.
+    """
+    try:
+        root = ET.fromstring("".join(lines))
+    except ET.ParseError as exc:
+        raise ParseError(f"Invalid pfSense config.xml: {exc}") from exc
+
+    address_book = AddressBook()
+    service_book = ServiceBook()
+    port_aliases: dict[str, list[str]] = {}
+
+    def address(value: str) -> str:
+        if value not in address_book.objects and value not in address_book.groups and _is_network(value):
+            address_book.objects[value] = parse_address_object(value, "ipmask", subnet=value)
+        return value
+
+    for alias in root.findall("./aliases/alias"):
+        name = alias.findtext("name", "").strip()
+        kind = alias.findtext("type", "").strip()
+        values = alias.findtext("address", "").split()
+        if kind in ("host", "network"):
+            members: list[str] = []
+            for value in values:
+                if "-" in value and not _is_network(value):
+                    start, end = value.split("-", 1)
+                    address_book.objects[value] = parse_address_object(value, "iprange", start_ip=start, end_ip=end)
+                    members.append(value)
+                else:
+                    members.append(address(value))
+            address_book.groups[name] = AddressGroup(name=name, members=tuple(members))
+        elif kind == "port":
+            port_aliases[name] = [_port_range(value) for value in values]
+
+    def endpoint(element: Optional[ET.Element]) -> tuple[str, ...]:
+        if element is None or element.find("any") is not None:
+            names: tuple[str, ...] = ("all",)
+        elif element.findtext("address"):
+            names = (address(element.findtext("address", "").strip()),)
+        else:
+            # Interface networks (lan, opt1, ...) depend on runtime interface addressing.
+            names = (f"{element.findtext('network', '').strip()} net",)
+        if element is not None and element.find("not") is not None:
+            names = tuple(f"not {name}" for name in names)
+        return names
+
+    def service(protocols: tuple[Protocol, ...], port: str, source_port: str = "") -> str:
+        values = port_aliases.get(port, [_port_range(port)])
+        source_values = port_aliases.get(source_port, [_port_range(source_port)]) if source_port else [""]
+        name = f"{'/'.join(protocol.value for protocol in protocols)} {port}"
+        if source_port:
+            name = f"{name} from {source_port}"
+        if name not in service_book.services:
+            entries = tuple(
+                parse_service_entry(f"{protocol.value}_{value}{f':{source}' if source else ''}")
+                for protocol in protocols
+                for value in values
+                for source in source_values
+            )
+            service_book.services[name] = ServiceObject(name=name, entries=entries)
+        return name
+
+    policies: list[PolicyRule] = []
+    for index, rule in enumerate(root.findall("./filter/rule"), start=1):
+        action = rule.findtext("type", "pass").strip()
+        protocol = rule.findtext("protocol", "").strip()
+        if rule.findtext("ipprotocol", "inet").strip() == "inet6":
+            continue
+        if protocol and protocol not in PROTOCOLS:
+            continue
+        protocols = PROTOCOLS.get(protocol, (Protocol.TCP, Protocol.UDP))
+        source, destination = rule.find("source"), rule.find("destination")
+        port = destination.findtext("port", "").strip() if destination is not None else ""
+        source_port = source.findtext("port", "").strip() if source is not None else ""
+        if protocol:
+            services: tuple[str, ...] = (service(protocols, port or "1-65535", source_port),)
+        else:
+            services = ("ALL",)
+        policies.append(
+            PolicyRule(
+                policy_id=rule.findtext("tracker", "").strip() or str(index),
+                name=rule.findtext("descr", "").strip() or f"rule {index}",
+                priority=index,
+                source=endpoint(source),
+                destination=endpoint(destination),
+                services=services,
+                action=RULE_ACTIONS.get(action, "deny"),
+                src_interfaces=tuple(name for name in rule.findtext("interface", "").split(",") if name),
+                enabled=rule.find("disabled") is None,
+                comment=rule.findtext("descr") or None,
+            )
+        )
+
+    add_builtin_objects(address_book, service_book)
+    return RuleSet(address_book=address_book, service_book=service_book, policies=policies)
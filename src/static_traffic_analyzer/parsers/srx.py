@@ -0,0 +1,179 @@
+"""Parser for Juniper SRX configurations in `display set` format."""
+from __future__ import annotations
+
+import shlex
+from typing import Iterable
+
+from ..models import AddressBook, AddressGroup, PolicyRule, ServiceBook, ServiceEntry, ServiceGroup, ServiceObject
+from ..utils import ParseError, parse_address_object, parse_service_entry
+from .common import NON_L4_ENTRY, RuleSet, add_builtin_objects
+
+
+# Predefined junos-* applications commonly referenced by policies.
+PREDEFINED_APPLICATIONS = {
+    "junos-ftp": "tcp_21",
+    "junos-ssh": "tcp_22",
+    "junos-telnet": "tcp_23",
+    "junos-smtp": "tcp_25",
+    "junos-dns-tcp": "tcp_53",
+    "junos-dns-udp": "udp_53",
+    "junos-http": "tcp_80",
+    "junos-ntp": "udp_123",
+    "junos-snmp-agentx": "tcp_705",
+    "junos-https": "tcp_443",
+    "junos-ldap": "tcp_389",
+    "junos-ms-sql": "tcp_1433",
+    "junos-mysql": "tcp_3306",
+    "junos-rdp": "tcp_3389",
+}
+
+SRX_ACTIONS = {"permit": "accept", "deny": "deny", "reject": "deny"}
+# Application settings that decide which flows match; others (timeouts, ALGs) do not narrow the match.
+APPLICATION_MATCH_FIELDS = ("protocol", "destination-port", "source-port")
+PROTOCOL_NUMBERS = {"6": "tcp", "17": "udp"}
+
+
+def _application_entries(terms: dict[str, dict[str, str]]) -> tuple[ServiceEntry, ...]:
+    """Build service entries from an application's terms; the unnamed term holds settings given without one."""
+    entries: list[ServiceEntry] = []
+    for settings in terms.values():
+        if not any(field in settings for field in APPLICATION_MATCH_FIELDS):
+            continue
+        protocol = PROTOCOL_NUMBERS.get(settings.get("protocol", ""), settings.get("protocol", ""))
+        if not protocol:
+            raise ParseError("term without a protocol")
+        if protocol not in ("tcp", "udp"):
+            entries.append(NON_L4_ENTRY)
+            continue
+        source_port = settings.get("source-port")
+        suffix = f":{source_port}" if source_port and source_port != "0-65535" else ""
+        entries.append(parse_service_entry(f"{protocol}_{settings.get('destination-port', '1-65535')}{suffix}"))
+    if not entries:
+        raise ParseError("no protocol")
+    return tuple(entries)
+
+
+def parse_srx_config(lines: Iterable[str]) -> RuleSet:
+    """Parse SRX address books, applications and security policies into internal models.
+
+    Zone-pair policies are evaluated in the order they first appear, followed
+    by global policies. The from-zone and to-zone become the policy's source
+    and destination interfaces, so they are matched when a flow's zones are
+    known. Policy IDs are ``<from-zone>-><to-zone>:<name>`` or ``global:<name>``.
+    Applications are built from their terms; one whose terms cannot be parsed
+    is left undefined with a warning, so policies using it evaluate as UNKNOWN.
+    """
+    address_book = AddressBook()
+    service_book = ServiceBook()
+    address_sets: dict[str, list[str]] = {}
+    # Application name -> term name ("" for settings outside a term) -> settings.
+    applications: dict[str, dict[str, dict[str, str]]] = {}
+    application_sets: dict[str, list[str]] = {}
+    policies: dict[str, dict[str, list[str]]] = {}
+    global_policies: dict[str, dict[str, list[str]]] = {}
+    zones: dict[str, tuple[str, str]] = {}
+    inactive: set[str] = set()
+    warnings: list[str] = []
+
+    for raw_line in lines:
+        line = raw_line.strip()
+        if not (line.startswith("set ") or line.startswith("deactivate ")):
+            continue
+        try:
+            tokens = shlex.split(line)
+        except ValueError as exc:
+            raise ParseError(f"Invalid SRX set line: {line}") from exc
+        verb, tokens = tokens[0], tokens[1:]
+
+        if tokens[:2] == ["security", "policies"]:
+            rest = tokens[2:]
+            if rest[:1] == ["global"] and len(rest) >= 3 and rest[1] == "policy":
+                key, table, body = f"global:{rest[2]}", global_policies, rest[3:]
+            elif rest[:1] == ["from-zone"] and len(rest) >= 6 and rest[4] == "policy":
+                key, table, body = f"{rest[1]}->{rest[3]}:{rest[5]}", policies, rest[6:]
+                zones[key] = (rest[1], rest[3])
+            else:
+                continue
+            if verb == "deactivate":
+                if not body:
+                    inactive.add(key)
+                continue
+            fields = table.setdefault(key, {})
+            if body[:1] == ["match"] and len(body) >= 3:
+                fields.setdefault(body[1], []).append(body[2])
+            elif body[:1] == ["then"] and len(body) >= 2 and body[1] in SRX_ACTIONS:
+                fields["action"] = [body[1]]
+            elif body[:1] == ["description"] and len(body) >= 2:
+                fields["description"] = [body[1]]
+            continue
+        if verb != "set":
+            continue
+
+        # Global and legacy per-zone address books share the same syntax after the prefix.
+        if "address-book" in tokens:
+            book = tokens[tokens.index("address-book") + 1 :]
+            if book[:1] == ["global"] or (book and book[0] not in ("address", "address-set")):
+                book = book[1:]
+            if book[:1] == ["address"] and len(book) >= 3:
+                name, value = book[1], book[2:]
+                try:
+                    if value[0] == "range-address" and len(value) >= 4:
+                        address_book.objects[name] = parse_address_object(
+                            name, "iprange", start_ip=value[1], end_ip=value[3]
+                        )
+                    elif value[0] == "dns-name":
+                        address_book.objects[name] = parse_address_object(name, "fqdn", fqdn=value[1])
+                    elif value[0] not in ("description", "wildcard-address"):
+                        address_book.objects[name] = parse_address_object(name, "ipmask", subnet=value[0])
+                except ParseError:
+                    address_book.objects[name] = parse_address_object(name, "fqdn")
+            elif book[:1] == ["address-set"] and len(book) >= 4:
+                address_sets.setdefault(book[1], []).append(book[3])
+            continue
+
+        if tokens[:2] == ["applications", "application"] and len(tokens) >= 5:
+            settings = tokens[3:]
+            term = ""
+            if settings[0] == "term":
+                term, settings = settings[1], settings[2:]
+            fields = applications.setdefault(tokens[2], {}).setdefault(term, {})
+            # Several settings may share a line, e.g. `term t1 protocol tcp destination-port 8080`.
+            for field, value in zip(settings[::2], settings[1::2]):
+                fields[field] = value
+        elif tokens[:2] == ["applications", "application-set"] and len(tokens) >= 5:
+            application_sets.setdefault(tokens[2], []).append(tokens[4])
+
+    for name, members in address_sets.items():
+        address_book.groups[name] = AddressGroup(name=name, members=tuple(members))
+    for name, value in PREDEFINED_APPLICATIONS.items():
+        service_book.services[name] = ServiceObject(name=name, entries=(parse_service_entry(value),))
+    for name, terms in applications.items():
+        try:
+            service_book.services[name] = ServiceObject(name=name, entries=_application_entries(terms))
+        except ParseError as exc:
+            warnings.append(f"application {name}: {exc}")
+    for name, members in application_sets.items():
+        service_book.groups[name] = ServiceGroup(name=name, members=tuple(members))
+    add_builtin_objects(address_book, service_book)
+
+    rules: list[PolicyRule] = []
+    for key, fields in [*policies.items(), *global_policies.items()]:
+        action = (fields.get("action") or ["deny"])[0]
+        rules.append(
+            PolicyRule(
+                policy_id=key,
+                name=key.split(":", 1)[1],
+                priority=len(rules) + 1,
+                source=tuple("all" if name == "any" else name for name in fields.get("source-address", ["any"])),
+                destination=tuple(
+                    "all" if name == "any" else name for name in fields.get("destination-address", ["any"])
+                ),
+                services=tuple("ALL" if name == "any" else name for name in fields.get("application", ["any"])),
+                action=SRX_ACTIONS[action],
+                enabled=key not in inactive,
+                comment=(fields.get("description") or [None])[0],
+                src_interfaces=(zones[key][0],) if key in zones else tuple(fields.get("from-zone", [])),
+                dst_interfaces=(zones[key][1],) if key in zones else tuple(fields.get("to-zone", [])),
+            )
+        )
+    return RuleSet(address_book=address_book, service_book=service_book, policies=rules, warnings=warnings)
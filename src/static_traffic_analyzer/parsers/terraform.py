@@ -0,0 +1,56 @@
+"""Load fortios resources from `terraform show -json` plan or state output."""
+from __future__ import annotations
+
+import json
+from typing import Any, Iterable, Iterator
+
+from ..utils import ParseError
+from .fortigate import FortiGateData, parse_fortigate_config
+from .fortigate_api import render_config
+
+
+# fortios resource type -> FortiGate CLI section.
+RESOURCE_SECTIONS = {
+    "fortios_firewall_address": "config firewall address",
+    "fortios_firewall_addrgrp": "config firewall addrgrp",
+    "fortios_firewallservice_custom": "config firewall service custom",
+    "fortios_firewallservice_group": "config firewall service group",
+    "fortios_firewall_policy": "config firewall policy",
+    "fortios_firewall_vip": "config firewall vip",
+    "fortios_firewall_ippool": "config firewall ippool",
+}
+
+
+def _iter_resources(module: dict[str, Any]) -> Iterator[dict[str, Any]]:
+    yield from module.get("resources", [])
+    for child in module.get("child_modules", []):
+        yield from _iter_resources(child)
+
+
+def _cli_attributes(values: dict[str, Any]) -> dict[str, Any]:
+    """Translate provider attribute names (tcp_portrange) to CLI names (tcp-portrange)."""
+    return {key.replace("_", "-"): value for key, value in values.items() if value not in (None, "", [])}
+
+
+def parse_terraform_fortios(lines: Iterable[str]) -> FortiGateData:
+    """Parse fortios address, service and policy resources from Terraform JSON.
+
+    Plans are read from ``planned_values`` so proposed changes are simulated
+    before apply; state files are read from ``values``. Resources are rendered
+    as CLI configuration and parsed by the FortiGate config parser.
+    """
+    try:
+        document = json.loads("".join(lines))
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid Terraform JSON: {exc}") from exc
+    values = document.get("planned_values") or document.get("values")
+    if not isinstance(values, dict):
+        raise ParseError("Terraform JSON has neither planned_values nor values")
+
+    tables: dict[str, list[dict[str, Any]]] = {section: [] for section in RESOURCE_SECTIONS.values()}
+    for resource in _iter_resources(values.get("root_module", {})):
+        section = RESOURCE_SECTIONS.get(resource.get("type", ""))
+        if section is None or resource.get("mode", "managed") != "managed":
+            continue
+        tables[section].append(_cli_attributes(resource.get("values") or {}))
+    return parse_fortigate_config(render_config(tables))
@@ -0,0 +1,57 @@
+"""Protocol/port lookup table over policy services."""
+from __future__ import annotations
+
+from typing import Optional, Sequence
+
+from .models import PolicyRule, Protocol, ServiceBook, ServiceEntry
+
+
+# Ranges up to this many ports are bucketed port by port; wider ones are scanned per protocol.
+BUCKET_SPAN = 1024
+
+
+def _service_entries(service_book: ServiceBook, names: Sequence[str]) -> Optional[list[ServiceEntry]]:
+    # None when a name does not resolve or a service has no entries: the evaluator reports those as UNKNOWN.
+    entries: list[ServiceEntry] = []
+    for name in names:
+        services = list(service_book.resolve_group_members(name))
+        if not services or any(not service.entries for service in services):
+            return None
+        entries.extend(entry for service in services for entry in service.entries)
+    return entries
+
+
+class PortIndex:
+    """Enabled policies bucketed by the (protocol, port) pairs their services match.
+
+    Policies that match on every protocol, negate their services, use ISDB
+    entries or reference unresolvable services go to a broad list that every
+    lookup returns.
+    """
+
+    def __init__(self, policies: Sequence[PolicyRule], service_book: ServiceBook) -> None:
+        self._buckets: dict[tuple[Protocol, int], set[int]] = {}
+        self._wide: dict[Protocol, list[tuple[int, int, int]]] = {}
+        self._broad: set[int] = set()
+        for position, policy in enumerate(policies):
+            if not policy.enabled:
+                continue
+            entries = None
+            if not (policy.internet_services or policy.service_negate):
+                entries = _service_entries(service_book, policy.services)
+            if entries is None or any(entry.protocol is None for entry in entries):
+                self._broad.add(position)
+                continue
+            for entry in entries:
+                if entry.start_port is None or entry.end_port is None:
+                    continue
+                if entry.end_port - entry.start_port >= BUCKET_SPAN:
+                    self._wide.setdefault(entry.protocol, []).append((entry.start_port, entry.end_port, position))
+                    continue
+                for port in range(entry.start_port, entry.end_port + 1):
+                    self._buckets.setdefault((entry.protocol, port), set()).add(position)
+
+    def candidates(self, protocol: Protocol, port: int) -> frozenset[int]:
+        """Return the positions of enabled policies whose services may match the protocol/port."""
+        wide = {position for start, end, position in self._wide.get(protocol, ()) if start <= port <= end}
+        return frozenset(self._buckets.get((protocol, port), set()) | wide | self._broad)
@@ -0,0 +1,139 @@
+"""Reverse rule search: every policy that could match a partially specified flow, and per-flow explanations."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass
+from ipaddress import IPv4Network, IPv6Network
+from pathlib import Path
+from typing import Iterable, Optional
+
+from .evaluator import PolicyCheck
+from .models import AddressBook, MatchOutcome, PolicyRule, Protocol, ServiceBook
+from .overlap import PolicySpace, format_services, intersect, policy_space
+from .utils import ParseError, parse_network
+
+
+ANY_NETWORKS = (IPv4Network("0.0.0.0/0"), IPv6Network("::/0"))
+PORT_PROTOCOLS = (Protocol.TCP, Protocol.UDP, Protocol.SCTP)
+
+QUERY_FIELDS = ["policy_id", "policy_name", "action", "enabled", "source", "destination", "services"]
+
+
+@dataclass(frozen=True)
+class RuleCandidate:
+    """A policy that could match the queried flow.
+
+    ``shared`` is the part of the query the policy matches, or None when the
+    policy depends on FQDN, identity or ISDB objects and was not narrowed down.
+    """
+
+    policy: PolicyRule
+    shared: Optional[PolicySpace]
+
+
+def parse_query_port(value: str) -> dict[Optional[Protocol], tuple[tuple[int, int], ...]]:
+    """Parse a query service: `443/tcp`, `1000-2000/udp`, `tcp` (any port) or `443` (any port protocol)."""
+    text = value.strip().lower()
+    if "/" in text:
+        port_text, _, proto_text = text.partition("/")
+    elif text.replace("-", "").isdigit():
+        port_text, proto_text = text, ""
+    else:
+        port_text, proto_text = "", text
+    try:
+        protocols = (Protocol(proto_text),) if proto_text else PORT_PROTOCOLS
+    except ValueError as exc:
+        raise ParseError(f"Invalid query port (expected e.g. 443/tcp): {value}") from exc
+    if not port_text:
+        return {protocol: ((0, 65535),) for protocol in protocols}
+    start_text, _, end_text = port_text.partition("-")
+    if not start_text.isdigit() or (end_text and not end_text.isdigit()):
+        raise ParseError(f"Invalid query port (expected e.g. 443/tcp): {value}")
+    start, end = int(start_text), int(end_text or start_text)
+    if start > end or end > 65535:
+        raise ParseError(f"Invalid query port range: {value}")
+    return {protocol: ((start, end),) for protocol in protocols}
+
+
+def parse_flow_port(value: str) -> tuple[Protocol, int]:
+    """Parse the single destination port and protocol of a concrete flow, e.g. `443/tcp`."""
+    services = parse_query_port(value)
+    if len(services) != 1:
+        raise ParseError(f"Explain needs a single port and protocol (e.g. 443/tcp): {value}")
+    ((protocol, ((start, end),)),) = services.items()
+    if start != end and protocol in PORT_PROTOCOLS:
+        raise ParseError(f"Explain needs a single port and protocol (e.g. 443/tcp): {value}")
+    return protocol, start
+
+
+def query_space(src: Optional[str] = None, dst: Optional[str] = None, port: Optional[str] = None) -> PolicySpace:
+    """Build the space of flows a partial query describes; unspecified dimensions match anything."""
+    return PolicySpace(
+        source=(parse_network(src),) if src else ANY_NETWORKS,
+        destination=(parse_network(dst),) if dst else ANY_NETWORKS,
+        services=parse_query_port(port) if port else {None: ((0, 65535),)},
+    )
+
+
+def find_candidate_rules(
+    policies: Iterable[PolicyRule],
+    address_book: AddressBook,
+    service_book: ServiceBook,
+    query: PolicySpace,
+    include_disabled: bool = False,
+) -> list[RuleCandidate]:
+    """Return every policy, in evaluation order, whose match space intersects the query."""
+    candidates: list[RuleCandidate] = []
+    for policy in policies:
+        if not policy.enabled and not include_disabled:
+            continue
+        space = policy_space(policy, address_book, service_book)
+        if space is None:
+            candidates.append(RuleCandidate(policy=policy, shared=None))
+            continue
+        shared = intersect(space, query)
+        if shared is not None:
+            candidates.append(RuleCandidate(policy=policy, shared=shared))
+    return candidates
+
+
+def write_candidate_rules(output_path: Path, candidates: Iterable[RuleCandidate]) -> None:
+    """Write candidate policies and the part of the query each one matches as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=QUERY_FIELDS)
+        writer.writeheader()
+        for candidate in candidates:
+            shared = candidate.shared
+            writer.writerow(
+                {
+                    "policy_id": candidate.policy.policy_id,
+                    "policy_name": candidate.policy.name,
+                    "action": candidate.policy.action,
+                    "enabled": "yes" if candidate.policy.enabled else "no",
+                    "source": ";".join(str(block) for block in shared.source) if shared else "unresolved",
+                    "destination": ";".join(str(block) for block in shared.destination) if shared else "unresolved",
+                    "services": format_services(shared.services) if shared else "unresolved",
+                }
+            )
+
+
+def format_check(check: PolicyCheck) -> str:
+    """Describe one policy considered for a flow: skipped, matched, or which criteria failed."""
+    prefix = f"policy {check.policy_id} ({check.policy_name}):"
+    if check.skipped is not None:
+        return f"{prefix} skipped, {check.skipped}"
+    if check.matched:
+        return f"{prefix} matched"
+    if check.failed:
+        return f"{prefix} no match on {', '.join(check.failed)}"
+    undecided = [
+        name
+        for name, outcome in (
+            ("src", check.source),
+            ("dst", check.destination),
+            ("service", check.service),
+            ("interface", check.interface),
+        )
+        if outcome == MatchOutcome.UNKNOWN
+    ]
+    return f"{prefix} may match, undecided on {', '.join(undecided)}"
@@ -0,0 +1,255 @@
+"""Reports derived from evaluated output rows."""
+from __future__ import annotations
+
+import csv
+from pathlib import Path
+from typing import Iterable, Mapping
+
+from .models import Decision, PolicyRule
+
+
+Row = Mapping[str, str | int | None]
+
+
+def build_service_matrix(rows: Iterable[Row]) -> dict[str, list[tuple[str, str]]]:
+    """Group allowed (src segment, dst segment) pairs by service label."""
+    matrix: dict[str, list[tuple[str, str]]] = {}
+    seen: set[tuple[str, str, str]] = set()
+    for row in rows:
+        label = str(row["service_label"])
+        matrix.setdefault(label, [])
+        if row["decision"] != Decision.ALLOW.value:
+            continue
+        pair = (str(row["src_network_segment"]), str(row["dst_network_segment"]))
+        if (label, *pair) in seen:
+            continue
+        seen.add((label, *pair))
+        matrix[label].append(pair)
+    return matrix
+
+
+def write_service_matrix(output_path: Path, matrix: Mapping[str, list[tuple[str, str]]]) -> None:
+    """Write the per-service matrix as a CSV grouped by service label."""
+    fieldnames = ["service_label", "src_network_segment", "dst_network_segment"]
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=fieldnames)
+        writer.writeheader()
+        for label in sorted(matrix):
+            for src, dst in matrix[label]:
+                writer.writerow({"service_label": label, "src_network_segment": src, "dst_network_segment": dst})
+
+
+LOGGING_REPORT_FIELDS = [
+    "src_network_segment",
+    "dst_network_segment",
+    "protocol",
+    "port",
+    "matched_policy_id",
+    "matched_policy_name",
+    "logtraffic",
+]
+
+
+def build_logging_report(rows: Iterable[Row], policies: Iterable[PolicyRule]) -> list[dict[str, str | int | None]]:
+    """List allowed flows whose matching policy has `set logtraffic disable`."""
+    unlogged = {policy.policy_id: policy for policy in policies if policy.logtraffic == "disable"}
+    report: list[dict[str, str | int | None]] = []
+    for row in rows:
+        policy = unlogged.get(str(row["matched_policy_id"]))
+        if row["decision"] != Decision.ALLOW.value or policy is None:
+            continue
+        report.append(
+            {
+                "src_network_segment": row["src_network_segment"],
+                "dst_network_segment": row["dst_network_segment"],
+                "protocol": row["protocol"],
+                "port": row["port"],
+                "matched_policy_id": policy.policy_id,
+                "matched_policy_name": policy.name,
+                "logtraffic": policy.logtraffic,
+            }
+        )
+    return report
+
+
+def write_logging_report(output_path: Path, report: Iterable[Mapping[str, str | int | None]]) -> None:
+    """Write allowed-but-unlogged flows as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=LOGGING_REPORT_FIELDS)
+        writer.writeheader()
+        writer.writerows(report)
+
+
+SECTION_REPORT_FIELDS = [
+    "section",
+    "policies",
+    "allowed_flows",
+    "denied_flows",
+]
+
+
+def build_section_report(rows: Iterable[Row], policies: Iterable[PolicyRule]) -> list[dict[str, str | int]]:
+    """Count policies and matched flows per GUI section, in policy order."""
+    sections: dict[str, dict[str, str | int]] = {}
+    policy_sections: dict[str, str] = {}
+    for policy in policies:
+        name = policy.section or ""
+        policy_sections[policy.policy_id] = name
+        entry = sections.setdefault(name, {"section": name, "policies": 0, "allowed_flows": 0, "denied_flows": 0})
+        entry["policies"] = int(entry["policies"]) + 1
+    for row in rows:
+        name = policy_sections.get(str(row["matched_policy_id"]))
+        if name is None:
+            continue
+        key = {Decision.ALLOW.value: "allowed_flows", Decision.DENY.value: "denied_flows"}.get(str(row["decision"]))
+        if key:
+            sections[name][key] = int(sections[name][key]) + 1
+    return list(sections.values())
+
+
+def write_section_report(output_path: Path, report: Iterable[Mapping[str, str | int]]) -> None:
+    """Write per-section policy and flow counts as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=SECTION_REPORT_FIELDS)
+        writer.writeheader()
+        writer.writerows(report)
+
+
+HIT_COUNT_REPORT_FIELDS = [
+    "policy_id",
+    "policy_name",
+    "enabled",
+    "observed_hits",
+    "simulated_flows",
+    "unused",
+]
+
+
+def build_hit_count_report(
+    rows: Iterable[Row], policies: Iterable[PolicyRule], hits: Mapping[str, int]
+) -> list[dict[str, str | int]]:
+    """Pair each policy's observed log hits with the flows it matched in the simulation.
+
+    Enabled policies with no observed hits are flagged as unused.
+    """
+    simulated: dict[str, int] = {}
+    for row in rows:
+        policy_id = str(row["matched_policy_id"] or "")
+        if policy_id:
+            simulated[policy_id] = simulated.get(policy_id, 0) + 1
+    return [
+        {
+            "policy_id": policy.policy_id,
+            "policy_name": policy.name,
+            "enabled": "yes" if policy.enabled else "no",
+            "observed_hits": hits.get(policy.policy_id, 0),
+            "simulated_flows": simulated.get(policy.policy_id, 0),
+            "unused": "yes" if policy.enabled and not hits.get(policy.policy_id) else "no",
+        }
+        for policy in policies
+    ]
+
+
+def write_hit_count_report(output_path: Path, report: Iterable[Mapping[str, str | int]]) -> None:
+    """Write per-policy observed and simulated hit counts as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=HIT_COUNT_REPORT_FIELDS)
+        writer.writeheader()
+        writer.writerows(report)
+
+
+POLICY_STATS_COUNTS = ["matches", "allows", "denies", "unknown"]
+
+POLICY_STATS_FIELDS = [
+    "policy_id",
+    "policy_name",
+    "action",
+    "enabled",
+    *POLICY_STATS_COUNTS,
+]
+
+# Flows no policy matched are counted on a final row without a policy ID.
+IMPLICIT_DENY_NAME = "(implicit deny)"
+
+_DECISION_COUNTS = {Decision.ALLOW.value: "allows", Decision.DENY.value: "denies", Decision.UNKNOWN.value: "unknown"}
+
+
+def build_policy_stats(rows: Iterable[Row], policies: Iterable[PolicyRule]) -> list[dict[str, str | int]]:
+    """Count the simulated flows each policy decided, split by decision, in policy order.
+
+    Unroutable flows never reach the policy table and are not counted.
+    """
+    stats: dict[str, dict[str, int]] = {}
+    for row in rows:
+        key = _DECISION_COUNTS.get(str(row["decision"]))
+        if key is None:
+            continue
+        counts = stats.setdefault(str(row["matched_policy_id"] or ""), dict.fromkeys(POLICY_STATS_COUNTS, 0))
+        counts["matches"] += 1
+        counts[key] += 1
+    empty = dict.fromkeys(POLICY_STATS_COUNTS, 0)
+    report: list[dict[str, str | int]] = [
+        {
+            "policy_id": policy.policy_id,
+            "policy_name": policy.name,
+            "action": policy.action,
+            "enabled": "yes" if policy.enabled else "no",
+            **stats.get(policy.policy_id, empty),
+        }
+        for policy in policies
+    ]
+    report.append(
+        {"policy_id": "", "policy_name": IMPLICIT_DENY_NAME, "action": "deny", "enabled": "yes", **stats.get("", empty)}
+    )
+    return report
+
+
+def write_policy_stats(output_path: Path, report: Iterable[Mapping[str, str | int]]) -> None:
+    """Write per-policy match, allow, deny and unknown counts as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=POLICY_STATS_FIELDS)
+        writer.writeheader()
+        writer.writerows(report)
+
+
+DEAD_RULE_FIELDS = [
+    "policy_id",
+    "policy_name",
+    "action",
+    "reason",
+    "detail",
+]
+
+SHADOWED = "shadowed"
+NO_MATCHING_FLOWS = "no-matching-flows"
+
+
+def build_dead_rule_report(
+    rows: Iterable[Row], policies: Iterable[PolicyRule], shadowed: Mapping[str, str]
+) -> list[dict[str, str]]:
+    """List enabled policies that matched none of the simulated flows, in policy order.
+
+    ``shadowed`` maps policy IDs the static audit found unreachable to its
+    finding. Those are reported as shadowed; the rest simply saw no flow
+    from the analyzed inputs and may still match other traffic.
+    """
+    matched = {str(row["matched_policy_id"] or "") for row in rows}
+    return [
+        {
+            "policy_id": policy.policy_id,
+            "policy_name": policy.name,
+            "action": policy.action,
+            "reason": SHADOWED if policy.policy_id in shadowed else NO_MATCHING_FLOWS,
+            "detail": shadowed.get(policy.policy_id, ""),
+        }
+        for policy in policies
+        if policy.enabled and policy.policy_id not in matched
+    ]
+
+
+def write_dead_rule_report(output_path: Path, report: Iterable[Mapping[str, str]]) -> None:
+    """Write enabled policies without simulated matches as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=DEAD_RULE_FIELDS)
+        writer.writeheader()
+        writer.writerows(report)
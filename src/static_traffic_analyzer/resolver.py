@@ -0,0 +1,147 @@
+"""Bounded, cached DNS resolution for FQDN address objects."""
+from __future__ import annotations
+
+import fnmatch
+import logging
+import socket
+import threading
+import time
+from ipaddress import IPv4Address, ip_address
+from pathlib import Path
+from typing import Callable, Mapping, Optional
+
+
+LOGGER = logging.getLogger(__name__)
+
+Lookup = Callable[[str], frozenset[IPv4Address]]
+
+
+def system_lookup(fqdn: str) -> frozenset[IPv4Address]:
+    """Resolve IPv4 addresses for a name using the system resolver."""
+    infos = socket.getaddrinfo(fqdn, None, family=socket.AF_INET, type=socket.SOCK_STREAM)
+    return frozenset(IPv4Address(info[4][0]) for info in infos)
+
+
+def no_lookup(fqdn: str) -> frozenset[IPv4Address]:
+    """Lookup used when only static hosts entries may resolve names."""
+    raise OSError(f"{fqdn} is not in the hosts file")
+
+
+def load_hosts_file(path: Path) -> dict[str, frozenset[IPv4Address]]:
+    """Read IPv4 entries from an /etc/hosts style file into name -> addresses."""
+    hosts: dict[str, set[IPv4Address]] = {}
+    with path.open(encoding="utf-8") as handle:
+        for raw_line in handle:
+            fields = raw_line.split("#", 1)[0].split()
+            if len(fields) < 2:
+                continue
+            try:
+                address = ip_address(fields[0])
+            except ValueError:
+                continue
+            if not isinstance(address, IPv4Address):
+                continue
+            for name in fields[1:]:
+                hosts.setdefault(name.lower().rstrip("."), set()).add(address)
+    return {name: frozenset(addresses) for name, addresses in hosts.items()}
+
+
+class FQDNResolver:
+    """Resolve FQDNs with a per-lookup timeout and an overall time budget.
+
+    A lookup that fails, times out, or starts after the budget is spent yields
+    None, which callers treat as non-matching. Failures are remembered for
+    ``negative_ttl`` seconds so a slow name is not retried for every flow.
+    """
+
+    def __init__(
+        self,
+        timeout: float = 2.0,
+        budget: Optional[float] = None,
+        negative_ttl: float = 60.0,
+        lookup: Lookup = system_lookup,
+        clock: Callable[[], float] = time.monotonic,
+        static_hosts: Optional[Mapping[str, frozenset[IPv4Address]]] = None,
+    ) -> None:
+        self.static_hosts = dict(static_hosts or {})
+        self.timeout = timeout
+        self.budget = budget
+        self.negative_ttl = negative_ttl
+        self._lookup = lookup
+        self._clock = clock
+        self._spent = 0.0
+        self._positive: dict[str, frozenset[IPv4Address]] = {}
+        self._negative: dict[str, float] = {}
+        self._lock = threading.Lock()
+
+    def _budget_left(self) -> Optional[float]:
+        if self.budget is None:
+            return None
+        return max(self.budget - self._spent, 0.0)
+
+    def resolve(self, fqdn: str) -> Optional[frozenset[IPv4Address]]:
+        """Return the addresses for fqdn, or None if it could not be resolved in time.
+
+        Static hosts entries take precedence over DNS.
+        """
+        key = fqdn.lower().rstrip(".")
+        if key in self.static_hosts:
+            return self.static_hosts[key]
+        with self._lock:
+            if key in self._positive:
+                return self._positive[key]
+            expires = self._negative.get(key)
+            if expires is not None and self._clock() < expires:
+                return None
+            remaining = self._budget_left()
+        if remaining is not None and remaining <= 0:
+            LOGGER.warning("DNS budget exhausted; treating %s as non-matching", fqdn)
+            self._remember_failure(key)
+            return None
+        timeout = self.timeout if remaining is None else min(self.timeout, remaining)
+
+        outcome: dict[str, frozenset[IPv4Address]] = {}
+
+        def run() -> None:
+            try:
+                outcome["addresses"] = self._lookup(key)
+            except (OSError, UnicodeError, ValueError):
+                pass
+
+        started = self._clock()
+        worker = threading.Thread(target=run, name=f"resolve-{key}", daemon=True)
+        worker.start()
+        worker.join(timeout)
+        with self._lock:
+            self._spent += self._clock() - started
+
+        if worker.is_alive():
+            LOGGER.warning("DNS lookup for %s timed out after %.2fs; treating as non-matching", fqdn, timeout)
+            self._remember_failure(key)
+            return None
+        addresses = outcome.get("addresses")
+        if not addresses:
+            LOGGER.warning("DNS lookup for %s failed; treating as non-matching", fqdn)
+            self._remember_failure(key)
+            return None
+        with self._lock:
+            self._positive[key] = addresses
+        return addresses
+
+    def resolve_wildcard(self, pattern: str) -> Optional[frozenset[IPv4Address]]:
+        """Return addresses of static hosts entries matching a wildcard such as ``*.example.com``.
+
+        DNS cannot enumerate the names under a wildcard, so only the hosts file is consulted.
+        """
+        key = pattern.lower().rstrip(".")
+        addresses = frozenset(
+            address
+            for name, entries in self.static_hosts.items()
+            if fnmatch.fnmatchcase(name, key)
+            for address in entries
+        )
+        return addresses or None
+
+    def _remember_failure(self, key: str) -> None:
+        with self._lock:
+            self._negative[key] = self._clock() + self.negative_ttl
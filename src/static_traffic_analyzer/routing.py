@@ -0,0 +1,156 @@
+"""Route lookups that decide whether and where a destination is forwarded."""
+from __future__ import annotations
+
+from dataclasses import dataclass
+from ipaddress import IPv4Network
+from typing import Iterable, Mapping, Optional, Sequence
+
+from .models import Interface, PolicyRoute, Protocol, StaticRoute
+
+
+# IP protocol numbers used by `router policy` entries; 0 matches any protocol.
+PROTOCOL_NUMBERS = {Protocol.ICMP: 1, Protocol.TCP: 6, Protocol.UDP: 17, Protocol.SCTP: 132}
+
+
+@dataclass(frozen=True)
+class RouteDecision:
+    """Where a flow leaves the firewall, or why it cannot."""
+
+    egress_interface: Optional[str]
+    route: Optional[str]
+    unroutable: Optional[str] = None
+    ingress_interface: Optional[str] = None
+
+
+def connected_routes(interfaces: Mapping[str, Interface]) -> list[StaticRoute]:
+    """Return a distance-0 route for the subnet of every interface with an address."""
+    return [
+        StaticRoute(seq_num=name, dst=interface.ip.network, device=name, distance=0, connected=True)
+        for name, interface in interfaces.items()
+        if interface.ip is not None
+    ]
+
+
+def route_label(route: StaticRoute) -> str:
+    """Name a route for output, e.g. `static:3` or `connected:port1`."""
+    return f"{'connected' if route.connected else 'static'}:{route.seq_num}"
+
+
+def find_route(routes: Iterable[StaticRoute], dst_network: IPv4Network) -> Optional[StaticRoute]:
+    """Return the longest-prefix route covering the whole destination, preferring lower distance then priority."""
+    covering = [
+        route
+        for route in routes
+        if route.enabled and route.dst.version == dst_network.version and dst_network.subnet_of(route.dst)
+    ]
+    if not covering:
+        return None
+    return min(covering, key=lambda route: (-route.dst.prefixlen, route.distance, route.priority))
+
+
+def unroutable_reason(routes: Iterable[StaticRoute], dst_network: IPv4Network) -> Optional[str]:
+    """Return NO_ROUTE or BLACKHOLE_ROUTE if no part of the destination can be forwarded, else None.
+
+    A destination only partly covered by more specific routes still has a
+    forwardable part, so it is left to the policies.
+    """
+    routes = list(routes)
+    best = find_route(routes, dst_network)
+    if best is not None and not best.blackhole:
+        return None
+    if any(
+        route.enabled
+        and not route.blackhole
+        and route.dst.version == dst_network.version
+        and route.dst.subnet_of(dst_network)
+        for route in routes
+    ):
+        return None
+    return "NO_ROUTE" if best is None else "BLACKHOLE_ROUTE"
+
+
+def rpf_reason(routes: Iterable[StaticRoute], src_network: IPv4Network, ingress: Optional[str]) -> Optional[str]:
+    """Return why strict reverse-path forwarding drops the source arriving on ``ingress``, else None.
+
+    RPF_NO_ROUTE when no part of the source routes back anywhere,
+    RPF_WRONG_INTERFACE when the best route back leaves by another interface.
+    With an unknown ingress, or a source only partly covered by more specific
+    routes, only the first is checked. Equal-cost routes all count as best.
+    """
+    routes = list(routes)
+    if unroutable_reason(routes, src_network) is not None:
+        return "RPF_NO_ROUTE"
+    best = find_route(routes, src_network)
+    if ingress is None or best is None:
+        return None
+    equal_cost = [
+        route.device
+        for route in routes
+        if route.enabled
+        and route.dst == best.dst
+        and (route.distance, route.priority) == (best.distance, best.priority)
+    ]
+    return None if ingress in equal_cost else "RPF_WRONG_INTERFACE"
+
+
+def _covered(network: IPv4Network, prefixes: Sequence[IPv4Network]) -> bool:
+    return not prefixes or any(network.version == prefix.version and network.subnet_of(prefix) for prefix in prefixes)
+
+
+def find_policy_route(
+    policy_routes: Iterable[PolicyRoute],
+    ingress: Optional[str],
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+) -> Optional[PolicyRoute]:
+    """Return the first enabled policy route whose conditions cover the whole flow.
+
+    Entries that only partly overlap the flow's networks, or that name input
+    devices when the ingress interface is unknown, are passed over.
+    """
+    for entry in policy_routes:
+        if not entry.enabled:
+            continue
+        if entry.input_devices and ingress not in entry.input_devices:
+            continue
+        if not (_covered(src_network, entry.src) and _covered(dst_network, entry.dst)):
+            continue
+        if entry.protocol and entry.protocol != PROTOCOL_NUMBERS.get(protocol):
+            continue
+        if protocol != Protocol.ICMP and not entry.start_port <= port <= entry.end_port:
+            continue
+        return entry
+    return None
+
+
+def route_flow(
+    routes: Sequence[StaticRoute],
+    policy_routes: Sequence[PolicyRoute],
+    src_network: IPv4Network,
+    dst_network: IPv4Network,
+    protocol: Protocol,
+    port: int,
+) -> RouteDecision:
+    """Pick the egress for a flow: a matching `permit` policy route first, then the routing table.
+
+    The ingress interface that policy routes may require is taken from the
+    route back to the source. A `deny` policy route sends the flow to the
+    routing table, as on the firewall.
+    """
+    source_route = find_route(routes, src_network)
+    ingress = source_route.device if source_route is not None else None
+    entry = find_policy_route(policy_routes, ingress, src_network, dst_network, protocol, port)
+    if entry is not None and entry.action == "permit":
+        return RouteDecision(
+            egress_interface=entry.output_device, route=f"policy-route:{entry.seq_num}", ingress_interface=ingress
+        )
+    reason = unroutable_reason(routes, dst_network)
+    if reason is not None:
+        return RouteDecision(egress_interface=None, route=None, unroutable=reason, ingress_interface=ingress)
+    best = find_route(routes, dst_network)
+    if best is None:
+        # Only more specific routes cover parts of the destination.
+        return RouteDecision(egress_interface=None, route=None, ingress_interface=ingress)
+    return RouteDecision(egress_interface=best.device, route=route_label(best), ingress_interface=ingress)
@@ -0,0 +1,51 @@
+"""Offline resolution of dynamic (SDN connector) address objects."""
+from __future__ import annotations
+
+import json
+from pathlib import Path
+
+from .models import AddressBook, AddressGroup, AddressObject, AddressType
+from .utils import ParseError, parse_address_object
+
+
+def load_dynamic_map(path: Path) -> dict[str, list[str]]:
+    """Read a JSON object mapping dynamic object names to lists of addresses, CIDRs or `a-b` ranges."""
+    if not path.is_file():
+        raise ParseError(f"Dynamic object mapping file not found: {path}")
+    try:
+        document = json.loads(path.read_text(encoding="utf-8"))
+    except json.JSONDecodeError as exc:
+        raise ParseError(f"Invalid dynamic object mapping JSON: {exc}") from exc
+    if not isinstance(document, dict) or not all(isinstance(value, list) for value in document.values()):
+        raise ParseError("Dynamic object mapping must be a JSON object of name -> list of addresses")
+    return {str(name): [str(value) for value in values] for name, values in document.items()}
+
+
+def _address(name: str, value: str) -> AddressObject:
+    value = value.strip()
+    if "-" in value:
+        start, end = value.split("-", 1)
+        return parse_address_object(name, "iprange", start_ip=start.strip(), end_ip=end.strip())
+    return parse_address_object(name, "ipmask", subnet=value)
+
+
+def apply_dynamic_map(address_book: AddressBook, mapping: dict[str, list[str]]) -> None:
+    """Replace mapped dynamic objects with groups of their current addresses.
+
+    Each address becomes a member object named `<object>[<address>]`; dynamic
+    objects missing from the mapping are left unresolved and match as UNKNOWN.
+    """
+    for objects, groups in ((address_book.objects, address_book.groups), (address_book.objects6, address_book.groups6)):
+        for name, obj in list(objects.items()):
+            if obj.address_type != AddressType.DYNAMIC or name not in mapping:
+                continue
+            members: list[str] = []
+            for value in mapping[name]:
+                member = f"{name}[{value.strip()}]"
+                try:
+                    objects[member] = _address(member, value)
+                except ParseError as exc:
+                    raise ParseError(f"Dynamic object {name}: {exc}") from exc
+                members.append(member)
+            del objects[name]
+            groups[name] = AddressGroup(name=name, members=tuple(members))
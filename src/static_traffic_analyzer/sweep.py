@@ -0,0 +1,105 @@
+"""Evaluate flows across a time window to find decisions that change with schedules."""
+from __future__ import annotations
+
+import csv
+import re
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from ipaddress import IPv4Network, IPv6Network
+from pathlib import Path
+from typing import Iterable, Sequence
+
+from .evaluator import Evaluator
+from .models import MatchDetail
+from .utils import ParseError, PortSpec
+
+
+STEP_PATTERN = re.compile(r"^(?P<count>\d+)(?P<unit>[mhd])$")
+STEP_UNITS = {"m": "minutes", "h": "hours", "d": "days"}
+
+SCHEDULE_CHANGE_FIELDS = [
+    "src_network_segment",
+    "dst_network_segment",
+    "service_label",
+    "protocol",
+    "port",
+    "at",
+    "previous_decision",
+    "previous_policy_id",
+    "decision",
+    "matched_policy_id",
+]
+
+Flow = tuple[IPv4Network | IPv6Network, IPv4Network | IPv6Network, PortSpec]
+
+
+@dataclass(frozen=True)
+class ScheduleChange:
+    """A flow whose decision or matched policy differs from the previous point of the sweep."""
+
+    src_network: IPv4Network | IPv6Network
+    dst_network: IPv4Network | IPv6Network
+    port_spec: PortSpec
+    at: datetime
+    previous: MatchDetail
+    current: MatchDetail
+
+
+def parse_step(value: str) -> timedelta:
+    """Parse a sweep step such as 15m, 1h or 1d."""
+    match = STEP_PATTERN.match(value.strip().lower())
+    if not match or int(match.group("count")) == 0:
+        raise ParseError(f"Invalid sweep step (expected e.g. 15m, 1h or 1d): {value}")
+    return timedelta(**{STEP_UNITS[match.group("unit")]: int(match.group("count"))})
+
+
+def sweep_times(start: datetime, end: datetime, step: timedelta) -> list[datetime]:
+    """Return the evaluation times from start to end inclusive, step apart."""
+    if end < start:
+        raise ParseError(f"Sweep ends before it starts: {start.isoformat()} > {end.isoformat()}")
+    times: list[datetime] = []
+    at = start
+    while at <= end:
+        times.append(at)
+        at += step
+    return times
+
+
+def find_schedule_changes(evaluators: Sequence[Evaluator], flows: Iterable[Flow]) -> list[ScheduleChange]:
+    """Evaluate each flow with every evaluator (one per sweep time) and report where the outcome changes."""
+    changes: list[ScheduleChange] = []
+    for src_network, dst_network, port_spec in flows:
+        previous = None
+        for evaluator in evaluators:
+            current = evaluator.evaluate(
+                src_network, dst_network, port_spec.protocol, port_spec.port, port_spec.src_port
+            )
+            if previous is not None and (previous.decision, previous.matched_policy_id) != (
+                current.decision,
+                current.matched_policy_id,
+            ):
+                changes.append(ScheduleChange(src_network, dst_network, port_spec, evaluator.at, previous, current))
+            previous = current
+    return changes
+
+
+def write_schedule_changes(output_path: Path, changes: Iterable[ScheduleChange]) -> None:
+    """Write schedule-driven decision changes as CSV, one row per transition."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=SCHEDULE_CHANGE_FIELDS)
+        writer.writeheader()
+        for change in changes:
+            writer.writerow(
+                {
+                    "src_network_segment": str(change.src_network),
+                    "dst_network_segment": str(change.dst_network),
+                    "service_label": change.port_spec.label,
+                    "protocol": change.port_spec.protocol.value,
+                    "port": change.port_spec.port,
+                    "at": change.at.isoformat() if change.at else "",
+                    "previous_decision": change.previous.decision.value,
+                    "previous_policy_id": change.previous.matched_policy_id or "",
+                    "decision": change.current.decision.value,
+                    "matched_policy_id": change.current.matched_policy_id or "",
+                }
+            )
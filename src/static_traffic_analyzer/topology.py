@@ -0,0 +1,110 @@
+"""Multi-firewall topologies: which device sits between which networks, and the devices a flow crosses."""
+from __future__ import annotations
+
+import csv
+from collections import deque
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Iterable, Optional
+
+from .overlap import Network
+from .parsers.fortigate import FortiGateData, parse_fortigate_config
+from .utils import ParseError, parse_network
+
+
+@dataclass(frozen=True)
+class Device:
+    """A firewall and the networks its interfaces connect."""
+
+    name: str
+    data: FortiGateData
+    networks: tuple[Network, ...]
+
+
+@dataclass(frozen=True)
+class Topology:
+    """Firewalls joined by the networks they share.
+
+    A flow's endpoints sit in the most specific listed network holding them;
+    its path is the shortest sequence of devices leading from the source
+    network to the destination network.
+    """
+
+    devices: tuple[Device, ...]
+
+    def _network_of(self, segment: Network) -> Optional[Network]:
+        holding = [
+            network
+            for device in self.devices
+            for network in device.networks
+            if network.version == segment.version and segment.subnet_of(network)
+        ]
+        return max(holding, key=lambda network: network.prefixlen, default=None)
+
+    def path(self, src_segment: Network, dst_segment: Network) -> Optional[list[Device]]:
+        """Return the devices between two segments in traversal order, or None if no path connects them."""
+        start, goal = self._network_of(src_segment), self._network_of(dst_segment)
+        if start is None or goal is None:
+            return None
+        previous: dict[Network, Optional[tuple[Network, Device]]] = {start: None}
+        queue = deque([start])
+        while queue:
+            network = queue.popleft()
+            if network == goal:
+                path: list[Device] = []
+                step = previous[network]
+                while step is not None:
+                    network, device = step
+                    path.append(device)
+                    step = previous[network]
+                return path[::-1]
+            for device in self.devices:
+                if network not in device.networks:
+                    continue
+                for neighbour in device.networks:
+                    if neighbour not in previous:
+                        previous[neighbour] = (network, device)
+                        queue.append(neighbour)
+        return None
+
+
+def parse_topology(rows: Iterable[dict[str, str]], base: Path, strict: bool = False) -> Topology:
+    """Build a topology from `device,config,networks` rows; networks are `;`-separated.
+
+    Config paths are FortiGate configs, relative to ``base`` unless absolute.
+    """
+    devices: list[Device] = []
+    seen: set[str] = set()
+    for line_number, row in enumerate(rows, start=2):
+        name = (row.get("device") or "").strip()
+        config = (row.get("config") or "").strip()
+        if not name and not config:
+            continue
+        if not name or not config:
+            raise ParseError(f"Topology line {line_number}: device and config are required")
+        if name in seen:
+            raise ParseError(f"Topology line {line_number}: duplicate device {name}")
+        seen.add(name)
+        networks = tuple(
+            parse_network(value.strip()) for value in (row.get("networks") or "").split(";") if value.strip()
+        )
+        if len(networks) < 2:
+            raise ParseError(f"Topology line {line_number}: device {name} must sit between at least two networks")
+        config_path = base / config
+        if not config_path.is_file():
+            raise ParseError(f"Topology line {line_number}: config not found: {config_path}")
+        with config_path.open(encoding="utf-8") as handle:
+            data = parse_fortigate_config(handle.readlines(), strict=strict, source=str(config_path))
+        devices.append(Device(name=name, data=data, networks=networks))
+    return Topology(devices=tuple(devices))
+
+
+def load_topology(path: Path, strict: bool = False) -> Topology:
+    """Read a topology CSV with device, config and networks columns."""
+    if not path.is_file():
+        raise ParseError(f"Topology file not found: {path}")
+    with path.open(newline="", encoding="utf-8") as handle:
+        reader = csv.DictReader(handle)
+        if not {"device", "config", "networks"} <= set(reader.fieldnames or []):
+            raise ParseError(f"Topology file must have device, config and networks columns: {path}")
+        return parse_topology(reader, path.parent, strict=strict)
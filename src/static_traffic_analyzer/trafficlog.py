@@ -0,0 +1,65 @@
+"""FortiGate and FortiAnalyzer traffic log exports, reduced to per-policy hit counts."""
+from __future__ import annotations
+
+import csv
+import shlex
+from collections import Counter
+from pathlib import Path
+from typing import Iterable
+
+from .utils import ParseError
+
+
+# FortiAnalyzer CSV exports label the column either way.
+POLICY_ID_COLUMNS = ("policyid", "Policy ID")
+
+
+def _key_value_pairs(line: str) -> dict[str, str]:
+    pairs: dict[str, str] = {}
+    for token in shlex.split(line):
+        key, sep, value = token.partition("=")
+        if sep:
+            pairs[key] = value
+    return pairs
+
+
+def count_hits(lines: Iterable[str]) -> Counter[str]:
+    """Count log entries per policy ID.
+
+    Lines are raw FortiGate logs (`key=value` pairs, as sent to syslog or
+    downloaded from the GUI) or a CSV export with a `policyid` column. Only
+    `type=traffic` entries are counted when the log carries a type.
+    """
+    lines = [line for line in lines if line.strip()]
+    hits: Counter[str] = Counter()
+    if not lines:
+        return hits
+    if "=" in lines[0].split(",", 1)[0]:
+        for line_number, line in enumerate(lines, start=1):
+            try:
+                fields = _key_value_pairs(line)
+            except ValueError as exc:
+                raise ParseError(f"Traffic log line {line_number}: {exc}") from exc
+            if fields.get("type", "traffic") != "traffic" or "policyid" not in fields:
+                continue
+            hits[fields["policyid"]] += 1
+        return hits
+    reader = csv.DictReader(lines)
+    column = next((name for name in POLICY_ID_COLUMNS if name in (reader.fieldnames or [])), None)
+    if column is None:
+        raise ParseError("Traffic log CSV must have a policyid column")
+    for row in reader:
+        if (row.get("type") or "traffic") != "traffic":
+            continue
+        policy_id = (row.get(column) or "").strip()
+        if policy_id:
+            hits[policy_id] += 1
+    return hits
+
+
+def load_hit_counts(path: Path) -> Counter[str]:
+    """Read a traffic log export and count entries per policy ID."""
+    if not path.is_file():
+        raise ParseError(f"Traffic log not found: {path}")
+    with path.open(encoding="utf-8", errors="replace") as handle:
+        return count_hits(handle)
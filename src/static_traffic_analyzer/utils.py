@@ -4,13 +4,20 @@ from __future__ import annotations
 import json
 import re
 from dataclasses import dataclass
-from ipaddress import IPv4Address, IPv4Network, ip_address, ip_network
-from typing import Iterable, Optional
+from ipaddress import IPv4Address, IPv4Network, IPv6Address, IPv6Network, ip_address, ip_network
+from typing import Iterable, Iterator, Optional
 
-from .models import AddressObject, AddressType, Protocol, ServiceEntry, ServiceObject
+from .models import ICMP_TYPE_RANGE, AddressObject, AddressType, Protocol, ServiceEntry, ServiceObject
 
 
-PORT_PATTERN = re.compile(r"^(?P<proto>tcp|udp)_(?P<start>\d+)(?:-(?P<end>\d+))?$")
+# FortiGate writes a source port range after the destination range: `80:1024-65535`.
+PORT_PATTERN = re.compile(
+    r"^(?P<proto>tcp|udp|sctp)_(?P<start>\d+)(?:-(?P<end>\d+))?(?::(?P<src_start>\d+)(?:-(?P<src_end>\d+))?)?$"
+)
+ICMP_PATTERN = re.compile(r"^icmp(?:_(?P<start>\d+)(?:-(?P<end>\d+))?)?$")
+MAC_PATTERN = re.compile(r"^[0-9a-f]{2}([:-]?[0-9a-f]{2}){5}$")
+# A bare `icmp` ports file entry simulates ping.
+ICMP_ECHO_REQUEST = 8
 
 
 @dataclass(frozen=True)
@@ -20,6 +27,7 @@ class PortSpec:
     label: str
     protocol: Protocol
     port: int
+    src_port: Optional[int] = None
 
 
 class ParseError(ValueError):
@@ -48,12 +56,75 @@ def parse_ipv4_address(value: str) -> IPv4Address:
     return address
 
 
+def parse_network(value: str) -> IPv4Network | IPv6Network:
+    """Parse an IPv4 or IPv6 CIDR, raising ParseError on failure."""
+    try:
+        return ip_network(value, strict=False)
+    except ValueError as exc:
+        raise ParseError(f"Invalid CIDR: {value}") from exc
+
+
+def parse_subnet(value: str) -> IPv4Network | IPv6Network:
+    """Parse a FortiGate subnet: `ip mask`, `ip/prefix`, `ip/mask` or a bare host address."""
+    parts = value.strip().strip('"').split()
+    if len(parts) == 2:
+        address, mask = parts
+    elif len(parts) == 1 and "/" in parts[0]:
+        address, mask = parts[0].split("/", 1)
+    elif len(parts) == 1:
+        host = parse_ip_address(parts[0])
+        return ip_network(f"{host}/{host.max_prefixlen}")
+    else:
+        raise ParseError(f"Malformed subnet: {value}")
+    parse_ip_address(address)
+    try:
+        return ip_network(f"{address}/{mask}", strict=False)
+    except ValueError as exc:
+        raise ParseError(f"Invalid subnet mask {mask!r} in subnet: {value}") from exc
+
+
+def parse_ip_address(value: str) -> IPv4Address | IPv6Address:
+    """Parse an IPv4 or IPv6 address, raising ParseError on failure."""
+    try:
+        return ip_address(value)
+    except ValueError as exc:
+        raise ParseError(f"Invalid IP address: {value}") from exc
+
+
+def normalize_mac(value: str) -> str:
+    """Return a MAC address as lowercase colon-separated octets, raising ParseError if malformed."""
+    text = value.strip().strip('"').lower()
+    if not MAC_PATTERN.match(text):
+        raise ParseError(f"Invalid MAC address: {value}")
+    digits = re.sub(r"[:-]", "", text)
+    return ":".join(digits[index:index + 2] for index in range(0, 12, 2))
+
+
+def _mac_entry(value: str) -> str:
+    """Normalize a MAC object entry: a single address or a `start-end` range."""
+    if value.count("-") == 1:
+        start, end = value.split("-")
+        return f"{normalize_mac(start)}-{normalize_mac(end)}"
+    return normalize_mac(value)
+
+
+def find_broad_networks(networks: Iterable[IPv4Network], min_prefix: int) -> list[IPv4Network]:
+    """Return networks whose prefix is shorter (broader) than min_prefix."""
+    if not (0 <= min_prefix <= 32):
+        raise ParseError(f"Invalid minimum prefix: {min_prefix}")
+    return [network for network in networks if network.prefixlen < min_prefix]
+
+
 def parse_address_object(
     name: str,
     address_type: str,
     subnet: Optional[str] = None,
     start_ip: Optional[str] = None,
     end_ip: Optional[str] = None,
+    interface: Optional[str] = None,
+    fqdn: Optional[str] = None,
+    country: Optional[str] = None,
+    macaddrs: tuple[str, ...] = (),
 ) -> AddressObject:
     """Build an AddressObject from string inputs."""
     normalized_type = address_type.lower()
@@ -63,39 +134,112 @@ def parse_address_object(
         return AddressObject(
             name=name,
             address_type=AddressType.IPMASK,
-            subnet=parse_ipv4_network(subnet),
+            subnet=parse_network(subnet),
+            interface=interface,
         )
     if normalized_type == AddressType.IPRANGE.value:
         if not start_ip or not end_ip:
             raise ParseError(f"Missing IP range for address object: {name}")
+        start, end = parse_ip_address(start_ip), parse_ip_address(end_ip)
+        if start.version != end.version:
+            raise ParseError(f"Mixed IP versions in range for address object: {name}")
         return AddressObject(
             name=name,
             address_type=AddressType.IPRANGE,
-            start_ip=parse_ipv4_address(start_ip),
-            end_ip=parse_ipv4_address(end_ip),
+            start_ip=start,
+            end_ip=end,
+            interface=interface,
         )
     if normalized_type == AddressType.FQDN.value:
-        return AddressObject(name=name, address_type=AddressType.FQDN)
+        return AddressObject(name=name, address_type=AddressType.FQDN, interface=interface, fqdn=fqdn)
+    if normalized_type == AddressType.GEOGRAPHY.value:
+        if not country:
+            raise ParseError(f"Missing country for geography address object: {name}")
+        return AddressObject(
+            name=name, address_type=AddressType.GEOGRAPHY, interface=interface, country=country.upper()
+        )
+    if normalized_type == AddressType.MAC.value:
+        if not macaddrs:
+            raise ParseError(f"Missing MAC address for address object: {name}")
+        normalized = tuple(_mac_entry(entry) for entry in macaddrs)
+        return AddressObject(name=name, address_type=AddressType.MAC, interface=interface, macaddrs=normalized)
+    if normalized_type == AddressType.DYNAMIC.value:
+        return AddressObject(name=name, address_type=AddressType.DYNAMIC, interface=interface)
     raise ParseError(f"Unsupported address type: {address_type}")
 
 
-def parse_service_entry(value: str) -> ServiceEntry:
-    """Parse a service entry like tcp_80 or udp_1000-2000."""
-    match = PORT_PATTERN.match(value.strip().lower())
-    if not match:
-        raise ParseError(f"Invalid service entry: {value}")
-    proto = Protocol(match.group("proto"))
-    start = int(match.group("start"))
-    end = int(match.group("end") or start)
+def _port_range(start_text: str, end_text: Optional[str], value: str) -> tuple[int, int]:
+    start = int(start_text)
+    end = int(end_text or start)
     if not (1 <= start <= 65535 and 1 <= end <= 65535):
         raise ParseError(f"Port out of range: {value}")
     if start > end:
         raise ParseError(f"Invalid port range: {value}")
-    return ServiceEntry(protocol=proto, start_port=start, end_port=end)
+    return start, end
+
+
+def parse_service_entry(value: str) -> ServiceEntry:
+    """Parse a service entry like tcp_80, udp_1000-2000, tcp_80:1024-65535, sctp_2905, icmp (any type) or icmp_8."""
+    icmp = ICMP_PATTERN.match(value.strip().lower())
+    if icmp:
+        return parse_icmp_entry(icmp.group("start"), icmp.group("end"))
+    match = PORT_PATTERN.match(value.strip().lower())
+    if not match:
+        raise ParseError(f"Invalid service entry: {value}")
+    start, end = _port_range(match.group("start"), match.group("end"), value)
+    src_start = src_end = None
+    if match.group("src_start"):
+        src_start, src_end = _port_range(match.group("src_start"), match.group("src_end"), value)
+    return ServiceEntry(
+        protocol=Protocol(match.group("proto")),
+        start_port=start,
+        end_port=end,
+        src_start_port=src_start,
+        src_end_port=src_end,
+    )
+
+
+def parse_icmp_entry(icmp_type: Optional[str], end_type: Optional[str] = None) -> ServiceEntry:
+    """Build an ICMP service entry for a type (range), or every type when none is given."""
+    if icmp_type is None:
+        return ServiceEntry(protocol=Protocol.ICMP, start_port=ICMP_TYPE_RANGE[0], end_port=ICMP_TYPE_RANGE[1])
+    if not icmp_type.isdigit() or not (end_type is None or end_type.isdigit()):
+        raise ParseError(f"Invalid ICMP type: {icmp_type}")
+    start, end = int(icmp_type), int(end_type or icmp_type)
+    if not (ICMP_TYPE_RANGE[0] <= start <= end <= ICMP_TYPE_RANGE[1]):
+        raise ParseError(f"ICMP type out of range: {icmp_type}")
+    return ServiceEntry(protocol=Protocol.ICMP, start_port=start, end_port=end)
+
+
+def iter_network_lines(lines: Iterable[str]) -> Iterator[IPv4Network | IPv6Network]:
+    """Stream networks from a one-IP-or-CIDR-per-line list such as a threat feed.
+
+    Blank lines, ``#`` comments and any trailing comma-separated annotation are
+    ignored, so common feed formats can be used without preprocessing.
+    """
+    for raw_line in lines:
+        line = raw_line.split("#", 1)[0].split(",", 1)[0].strip()
+        if line:
+            yield parse_network(line)
+
+
+def _single_port(value: str) -> int:
+    value = value.strip()
+    if not value.isdigit():
+        raise ParseError(f"Invalid port: {value}")
+    port = int(value)
+    if not (1 <= port <= 65535):
+        raise ParseError(f"Port out of range: {port}")
+    return port
 
 
 def parse_ports_file(lines: Iterable[str]) -> list[PortSpec]:
-    """Parse the ports input file into PortSpec entries."""
+    """Parse the ports input file into PortSpec entries.
+
+    Lines are `label,port/protocol`, or `label,port:srcport/protocol` to pin
+    the source port. ICMP lines are `label,icmp` (echo request) or
+    `label,icmp/<type>`, and the ICMP type is carried as the port.
+    """
     specs: list[PortSpec] = []
     for raw_line in lines:
         line = raw_line.strip()
@@ -104,19 +248,24 @@ def parse_ports_file(lines: Iterable[str]) -> list[PortSpec]:
         if "," not in line:
             raise ParseError(f"Invalid port line: {line}")
         label, value = [part.strip() for part in line.split(",", 1)]
+        if value.lower() == "icmp" or value.lower().startswith("icmp/"):
+            icmp_type = value.partition("/")[2].strip() or str(ICMP_ECHO_REQUEST)
+            parse_icmp_entry(icmp_type)
+            specs.append(PortSpec(label=label, protocol=Protocol.ICMP, port=int(icmp_type)))
+            continue
         if "/" not in value:
             raise ParseError(f"Invalid port line: {line}")
         port_str, proto_str = [part.strip() for part in value.split("/", 1)]
-        if not port_str.isdigit():
-            raise ParseError(f"Invalid port: {port_str}")
-        port = int(port_str)
-        if not (1 <= port <= 65535):
-            raise ParseError(f"Port out of range: {port}")
+        port_str, _, src_port_str = port_str.partition(":")
+        port = _single_port(port_str)
+        src_port = _single_port(src_port_str) if src_port_str else None
         try:
             protocol = Protocol(proto_str.lower())
         except ValueError as exc:
             raise ParseError(f"Unsupported protocol: {proto_str}") from exc
-        specs.append(PortSpec(label=label, protocol=protocol, port=port))
+        if protocol == Protocol.ICMP:
+            raise ParseError(f"ICMP ports file entries are written icmp or icmp/<type>: {line}")
+        specs.append(PortSpec(label=label, protocol=protocol, port=port, src_port=src_port))
     return specs
 
 
@@ -0,0 +1,158 @@
+"""Multi-VDOM FortiGate configs: each VDOM's own tables, and the inter-VDOM links a flow crosses."""
+from __future__ import annotations
+
+from collections import deque
+from dataclasses import dataclass
+from typing import Iterable, Optional
+
+from .parsers.fortigate import FortiGateData, parse_fortigate_config
+from .utils import ParseError
+
+
+# Top-level blocks wrapping the shared settings and the per-VDOM sections.
+GLOBAL_BLOCK = "config global"
+VDOM_BLOCK = "config vdom"
+
+
+@dataclass(frozen=True)
+class VdomLink:
+    """An inter-VDOM link: `<name>0` and `<name>1` interfaces, each assigned to one VDOM."""
+
+    name: str
+    interfaces: tuple[str, str]
+    vdoms: tuple[str, str]
+
+
+@dataclass(frozen=True)
+class VdomHop:
+    """One VDOM on a flow's path and the link interfaces it enters and leaves by."""
+
+    vdom: str
+    ingress: Optional[str] = None
+    egress: Optional[str] = None
+
+
+@dataclass(frozen=True)
+class VdomConfig:
+    """The VDOMs of one FortiGate, joined by inter-VDOM links.
+
+    A flow between VDOMs takes the path crossing the fewest links and is
+    evaluated by each VDOM's policy table in turn.
+    """
+
+    vdoms: dict[str, FortiGateData]
+    links: tuple[VdomLink, ...] = ()
+    warnings: tuple[str, ...] = ()
+
+    def path(self, src_vdom: str, dst_vdom: str) -> Optional[list[VdomHop]]:
+        """Return the VDOMs from ``src_vdom`` to ``dst_vdom`` in traversal order, or None if no links join them."""
+        if src_vdom not in self.vdoms or dst_vdom not in self.vdoms:
+            return None
+        # VDOM -> (previous VDOM, interface left by, interface entered by)
+        previous: dict[str, Optional[tuple[str, str, str]]] = {src_vdom: None}
+        queue = deque([src_vdom])
+        while queue:
+            vdom = queue.popleft()
+            if vdom == dst_vdom:
+                break
+            for link in self.links:
+                for near, far in ((0, 1), (1, 0)):
+                    neighbour = link.vdoms[far]
+                    if link.vdoms[near] == vdom and neighbour not in previous:
+                        previous[neighbour] = (vdom, link.interfaces[near], link.interfaces[far])
+                        queue.append(neighbour)
+        if dst_vdom not in previous:
+            return None
+        hops: list[VdomHop] = []
+        vdom, egress = dst_vdom, None
+        while True:
+            step = previous[vdom]
+            if step is None:
+                hops.append(VdomHop(vdom, egress=egress))
+                break
+            prior, left_by, entered_by = step
+            hops.append(VdomHop(vdom, ingress=entered_by, egress=egress))
+            vdom, egress = prior, left_by
+        return hops[::-1]
+
+
+def split_vdoms(lines: Iterable[str]) -> tuple[list[str], dict[str, list[str]]]:
+    """Split a config into its global lines and each VDOM's lines.
+
+    Every returned list is as long as the input, with lines belonging
+    elsewhere blanked, so parser warnings keep the original line numbers.
+    Sections outside `config global` and `config vdom` count as global.
+    """
+    # Each line's VDOM, None for global lines, "" for the wrapper lines themselves.
+    owners: list[Optional[str]] = []
+    names: list[str] = []
+    kept = list(lines)
+    depth = 0
+    block: Optional[str] = None
+    current: Optional[str] = None
+    for raw_line in kept:
+        line = raw_line.strip()
+        owner = current if block == VDOM_BLOCK else None
+        if line.startswith("config "):
+            if depth == 0 and line in (GLOBAL_BLOCK, VDOM_BLOCK):
+                block = line
+                owner = ""
+            depth += 1
+        elif line == "end":
+            depth -= 1
+            if depth == 0 and block is not None:
+                block, owner = None, ""
+        elif block == VDOM_BLOCK and depth == 1 and line.startswith("edit "):
+            current = line.split(" ", 1)[1].strip().strip('"')
+            if current not in names:
+                names.append(current)
+            owner = ""
+        elif block == VDOM_BLOCK and depth == 1 and line == "next":
+            current, owner = None, ""
+        owners.append(owner)
+    global_lines = [line if owner is None else "" for line, owner in zip(kept, owners)]
+    vdoms = {name: [line if owner == name else "" for line, owner in zip(kept, owners)] for name in names}
+    return global_lines, vdoms
+
+
+def _vdom_link_names(lines: Iterable[str]) -> list[str]:
+    names: list[str] = []
+    in_links = False
+    for raw_line in lines:
+        line = raw_line.strip()
+        if line == "config system vdom-link":
+            in_links = True
+        elif in_links and line == "end":
+            in_links = False
+        elif in_links and line.startswith("edit "):
+            names.append(line.split(" ", 1)[1].strip().strip('"'))
+    return names
+
+
+def parse_vdom_config(lines: Iterable[str], strict: bool = False, source: str = "config") -> VdomConfig:
+    """Parse a multi-VDOM FortiGate config into one data set per VDOM and the links between them.
+
+    Each VDOM gets the global interfaces assigned to it. A link is kept only
+    when both of its interfaces are assigned to a VDOM.
+    """
+    global_lines, vdom_lines = split_vdoms(lines)
+    if not vdom_lines:
+        raise ParseError(f"{source} has no `config vdom` entries")
+    shared = parse_fortigate_config(global_lines, strict=strict, source=source)
+    vdoms: dict[str, FortiGateData] = {}
+    for name, chunk in vdom_lines.items():
+        data = parse_fortigate_config(chunk, strict=strict, source=source)
+        data.interfaces = {
+            **{key: interface for key, interface in shared.interfaces.items() if interface.vdom == name},
+            **data.interfaces,
+        }
+        data.warnings = [f"vdom {name}: {warning}" for warning in data.warnings]
+        vdoms[name] = data
+    vdoms_by_interface = {key: interface.vdom for key, interface in shared.interfaces.items() if interface.vdom}
+    links = []
+    for name in _vdom_link_names(global_lines):
+        interfaces = (f"{name}0", f"{name}1")
+        ends = tuple(vdoms_by_interface.get(interface) for interface in interfaces)
+        if ends[0] in vdoms and ends[1] in vdoms:
+            links.append(VdomLink(name=name, interfaces=interfaces, vdoms=(str(ends[0]), str(ends[1]))))
+    return VdomConfig(vdoms=vdoms, links=tuple(links), warnings=tuple(shared.warnings))
@@ -0,0 +1,158 @@
+"""What-if analysis: apply a policy patch and report the flows whose decision changes."""
+from __future__ import annotations
+
+import csv
+from dataclasses import dataclass, replace
+from ipaddress import IPv4Network, IPv6Network
+from pathlib import Path
+from typing import Iterable, Mapping, TypeVar
+
+from .evaluator import Evaluator
+from .models import AddressBook, MatchDetail, PolicyRule, ServiceBook
+from .parsers.fortigate import parse_fortigate_config
+from .sweep import Flow
+from .utils import ParseError, PortSpec
+
+
+DECISION_CHANGE_FIELDS = [
+    "src_network_segment",
+    "dst_network_segment",
+    "service_label",
+    "protocol",
+    "port",
+    "previous_decision",
+    "previous_policy_id",
+    "decision",
+    "matched_policy_id",
+]
+
+POLICY_SECTION = "config firewall policy"
+
+T = TypeVar("T")
+
+
+@dataclass(frozen=True)
+class PolicyPatch:
+    """Policies added or replaced, policies deleted, and objects defined by a patch file."""
+
+    policies: tuple[PolicyRule, ...]
+    deleted: frozenset[str]
+    address_book: AddressBook
+    service_book: ServiceBook
+
+
+@dataclass(frozen=True)
+class DecisionChange:
+    """A flow decided differently by the patched policy set."""
+
+    src_network: IPv4Network | IPv6Network
+    dst_network: IPv4Network | IPv6Network
+    port_spec: PortSpec
+    previous: MatchDetail
+    current: MatchDetail
+
+
+def parse_policy_patch(lines: Iterable[str], source: str = "patch") -> PolicyPatch:
+    """Parse a patch written as a FortiGate CLI fragment.
+
+    An `edit` under `config firewall policy` adds the policy or replaces the
+    policy with the same ID as a whole (it is not merged field by field), and
+    `delete <id>` removes one. Address and service objects in the patch are
+    added to, or override, those of the base configuration.
+    """
+    kept: list[str] = []
+    deleted: set[str] = set()
+    section = None
+    for line_number, raw_line in enumerate(lines, start=1):
+        line = raw_line.strip()
+        if line.startswith("config "):
+            section = line
+        elif line == "end":
+            section = None
+        elif line.startswith("delete "):
+            if section != POLICY_SECTION:
+                raise ParseError(f"{source} line {line_number}: `delete` is only supported for firewall policies")
+            deleted.add(line.split(" ", 1)[1].strip().strip('"'))
+            continue
+        kept.append(raw_line)
+    data = parse_fortigate_config(kept, strict=True, source=source)
+    return PolicyPatch(
+        policies=tuple(data.policies),
+        deleted=frozenset(deleted),
+        address_book=data.address_book,
+        service_book=data.service_book,
+    )
+
+
+def _merge(base: Mapping[str, T], patch: Mapping[str, T], builtins: Mapping[str, T]) -> dict[str, T]:
+    # The patch parser adds the built-in objects too; they must not override the base definitions.
+    return {**base, **{name: value for name, value in patch.items() if value != builtins.get(name)}}
+
+
+def apply_policy_patch(
+    policies: Iterable[PolicyRule], address_book: AddressBook, service_book: ServiceBook, patch: PolicyPatch
+) -> tuple[list[PolicyRule], AddressBook, ServiceBook]:
+    """Return the policies and object books with the patch applied, leaving the originals untouched."""
+    base = list(policies)
+    known = {policy.policy_id for policy in base}
+    missing = sorted(patch.deleted - known)
+    if missing:
+        raise ParseError(f"Patch deletes unknown policies: {', '.join(missing)}")
+    replacements = {policy.policy_id: policy for policy in patch.policies}
+    patched = [
+        replace(replacements.pop(policy.policy_id), priority=policy.priority)
+        if policy.policy_id in replacements
+        else policy
+        for policy in base
+        if policy.policy_id not in patch.deleted
+    ]
+    patched.extend(replacements.values())
+    patched.sort(key=lambda rule: rule.priority)
+
+    builtins = parse_fortigate_config([])
+    patched_addresses = AddressBook(
+        objects=_merge(address_book.objects, patch.address_book.objects, builtins.address_book.objects),
+        groups=_merge(address_book.groups, patch.address_book.groups, {}),
+        vips=_merge(address_book.vips, patch.address_book.vips, {}),
+        vip_groups=_merge(address_book.vip_groups, patch.address_book.vip_groups, {}),
+        objects6=_merge(address_book.objects6, patch.address_book.objects6, builtins.address_book.objects6),
+        groups6=_merge(address_book.groups6, patch.address_book.groups6, {}),
+    )
+    patched_services = ServiceBook(
+        services=_merge(service_book.services, patch.service_book.services, builtins.service_book.services),
+        groups=_merge(service_book.groups, patch.service_book.groups, {}),
+        categories=service_book.categories | patch.service_book.categories,
+    )
+    return patched, patched_addresses, patched_services
+
+
+def find_decision_changes(before: Evaluator, after: Evaluator, flows: Iterable[Flow]) -> list[DecisionChange]:
+    """Evaluate each flow against both policy sets and keep those whose decision or matched policy differs."""
+    changes: list[DecisionChange] = []
+    for src_network, dst_network, port_spec in flows:
+        previous = before.evaluate(src_network, dst_network, port_spec.protocol, port_spec.port, port_spec.src_port)
+        current = after.evaluate(src_network, dst_network, port_spec.protocol, port_spec.port, port_spec.src_port)
+        if (previous.decision, previous.matched_policy_id) != (current.decision, current.matched_policy_id):
+            changes.append(DecisionChange(src_network, dst_network, port_spec, previous, current))
+    return changes
+
+
+def write_decision_changes(output_path: Path, changes: Iterable[DecisionChange]) -> None:
+    """Write the flows whose decision changed under the patch as CSV."""
+    with output_path.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=DECISION_CHANGE_FIELDS)
+        writer.writeheader()
+        for change in changes:
+            writer.writerow(
+                {
+                    "src_network_segment": str(change.src_network),
+                    "dst_network_segment": str(change.dst_network),
+                    "service_label": change.port_spec.label,
+                    "protocol": change.port_spec.protocol.value,
+                    "port": change.port_spec.port,
+                    "previous_decision": change.previous.decision.value,
+                    "previous_policy_id": change.previous.matched_policy_id or "",
+                    "decision": change.current.decision.value,
+                    "matched_policy_id": change.current.matched_policy_id or "",
+                }
+            )
@@ -2,9 +2,48 @@
 from __future__ import annotations
 
 import sys
+from ipaddress import ip_network
 from pathlib import Path
+from typing import Optional
+
+import pytest
 
 ROOT = Path(__file__).resolve().parents[1]
 SRC = ROOT / "src"
 if str(SRC) not in sys.path:
     sys.path.insert(0, str(SRC))
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy  # noqa: E402
+
+
+@pytest.fixture
+def evaluate():
+    """Return a helper evaluating one flow against a parsed rule set, sourced from 10.0.0.0/24 by default."""
+
+    def evaluate(
+        data,
+        dst: str,
+        protocol,
+        port: int,
+        *,
+        src: str = "10.0.0.0/24",
+        src_port: Optional[int] = None,
+        ingress: Optional[str] = None,
+        egress: Optional[str] = None,
+    ):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network(src),
+            ip_network(dst),
+            protocol,
+            port,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=False,
+            src_port=src_port,
+            ingress=ingress,
+            egress=egress,
+        )
+
+    return evaluate
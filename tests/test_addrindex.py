@@ -0,0 +1,107 @@
+"""Tests for the policy address prefix index."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.addrindex import PolicyIndex, PrefixIndex
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode, evaluate_policy
+from static_traffic_analyzer.models import Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+    edit "OPS"
+        set type iprange
+        set start-ip 10.0.1.10
+        set end-ip 10.0.1.20
+    next
+    edit "DMZ"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+    edit "PORTAL"
+        set type fqdn
+        set fqdn "portal.example.com"
+    next
+end
+config firewall addrgrp
+    edit "EMPTY"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "OPS"
+        set dstaddr "DMZ"
+        set service "ALL"
+        set action deny
+    next
+    edit 2
+        set srcaddr "LAN"
+        set dstaddr "DMZ"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 3
+        set srcaddr "LAN"
+        set dstaddr "PORTAL"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 4
+        set srcaddr "EMPTY"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 5
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "SSH"
+        set action accept
+    next
+end
+"""
+
+
+def test_prefix_index_finds_supernets_and_subnets_of_the_query():
+    index = PrefixIndex()
+    index.add(0, [ip_network("10.0.0.0/8")])
+    index.add(1, [ip_network("10.1.2.0/24")])
+    index.add(2, [ip_network("10.1.2.128/25"), ip_network("172.16.0.0/12")])
+    index.add(3, [ip_network("2001:db8::/32")])
+    index.freeze()
+
+    assert index.overlapping(ip_network("10.1.2.200/32")) == {0, 1, 2}
+    assert index.overlapping(ip_network("10.1.0.0/16")) == {0, 1, 2}
+    assert index.overlapping(ip_network("10.1.3.0/24")) == {0}
+    assert index.overlapping(ip_network("0.0.0.0/0")) == {0, 1, 2}
+    assert index.overlapping(ip_network("192.168.0.0/16")) == set()
+    assert index.overlapping(ip_network("2001:db8:1::/48")) == {3}
+
+
+def test_policy_index_keeps_unresolvable_addresses_as_candidates():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    index = PolicyIndex(data.policies, data.address_book)
+
+    # FQDN and empty-group policies cannot be ruled out by address.
+    assert index.candidates(ip_network("10.0.1.0/24"), ip_network("192.0.2.10/32")) == {0, 1, 2, 3, 4}
+    assert index.candidates(ip_network("10.0.5.0/24"), ip_network("192.0.2.10/32")) == {1, 2, 3, 4}
+    assert index.candidates(ip_network("172.16.0.0/24"), ip_network("198.51.100.1/32")) == {3, 4}
+
+
+def test_indexed_evaluation_matches_a_full_scan():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+    evaluator = Evaluator(data.policies, data.address_book, data.service_book, mode)
+
+    for src in ("10.0.1.0/24", "10.0.1.12/32", "10.0.9.0/24", "172.16.0.0/24"):
+        for dst in ("192.0.2.0/25", "192.0.2.10/32", "198.51.100.0/24"):
+            for protocol, port in ((Protocol.TCP, 443), (Protocol.TCP, 22), (Protocol.UDP, 53)):
+                flow = (ip_network(src), ip_network(dst), protocol, port)
+                full_scan = evaluate_policy(
+                    data.policies, data.address_book, data.service_book, *flow, match_mode=mode, ignore_schedule=False
+                )
+                assert evaluator.evaluate(*flow) == full_scan
@@ -0,0 +1,60 @@
+"""Tests for output anonymization."""
+from __future__ import annotations
+
+from ipaddress import ip_address, ip_network
+
+from static_traffic_analyzer.anonymize import IPAnonymizer, anonymize_rows
+
+
+def test_anonymize_ip_is_stable_and_distinct():
+    anonymizer = IPAnonymizer("secret")
+    first = anonymizer.anonymize_ip(ip_address("10.0.0.1"))
+
+    assert IPAnonymizer("secret").anonymize_ip(ip_address("10.0.0.1")) == first
+    assert anonymizer.anonymize_ip(ip_address("10.0.0.2")) != first
+    assert IPAnonymizer("other").anonymize_ip(ip_address("10.0.0.1")) != first
+
+
+def test_anonymize_preserves_prefix():
+    anonymizer = IPAnonymizer("secret")
+    network = anonymizer.anonymize_network(ip_network("10.0.1.0/24"))
+    host = anonymizer.anonymize_ip(ip_address("10.0.1.77"))
+
+    assert network.prefixlen == 24
+    assert host in network
+
+
+def test_anonymize_ipv6_preserves_prefix_and_family():
+    anonymizer = IPAnonymizer("secret")
+    network = anonymizer.anonymize_network(ip_network("2001:db8:1::/48"))
+    host = anonymizer.anonymize_ip(ip_address("2001:db8:1::77"))
+
+    assert (network.version, network.prefixlen) == (6, 48)
+    assert host in network
+    assert network != ip_network("2001:db8:1::/48")
+    assert IPAnonymizer("secret").anonymize_ip(ip_address("2001:db8:1::77")) == host
+
+    row = {"src_network_segment": "2001:db8:1::/64", "dst_network_segment": "10.0.0.0/24"}
+    rows = anonymize_rows([row], anonymizer)
+
+    assert ip_network(rows[0]["src_network_segment"]).subnet_of(network)
+    assert ip_network(rows[0]["dst_network_segment"]).version == 4
+
+
+def test_anonymize_rows_redacts_metadata():
+    rows = [
+        {
+            "src_network_segment": "192.168.10.0/24",
+            "dst_network_segment": "10.0.0.0/24",
+            "dst_gn": "GN01",
+            "dst_site": "HSINCHU",
+            "dst_location": "LAB-A",
+            "decision": "ALLOW",
+        }
+    ]
+    result = anonymize_rows(rows, IPAnonymizer("secret"), redact_metadata=True)
+
+    assert result[0]["src_network_segment"] != "192.168.10.0/24"
+    assert result[0]["dst_site"] == ""
+    assert result[0]["decision"] == "ALLOW"
+    assert rows[0]["dst_site"] == "HSINCHU"
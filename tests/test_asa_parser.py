@@ -0,0 +1,126 @@
+"""Tests for the Cisco ASA configuration parser."""
+from __future__ import annotations
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.asa import parse_asa_config
+
+
+ASA_CONFIG = """
+object network WEB01
+ host 10.1.0.10
+object network LAN
+ subnet 10.0.0.0 255.255.255.0
+object network DB_RANGE
+ range 10.2.0.10 10.2.0.20
+object service SQL
+ service tcp destination eq sqlnet
+object-group network WEB_SERVERS
+ network-object object WEB01
+ network-object host 10.1.0.11
+object-group service WEB_PORTS tcp
+ port-object eq www
+ port-object eq https
+!
+access-list OUTSIDE_IN remark allow web
+access-list OUTSIDE_IN extended permit tcp object LAN object-group WEB_SERVERS object-group WEB_PORTS
+access-list OUTSIDE_IN extended permit object SQL object LAN object DB_RANGE
+access-list OUTSIDE_IN extended permit icmp any any
+access-list OUTSIDE_IN extended permit udp host 10.0.0.5 any range 5000 5010 inactive
+access-list OUTSIDE_IN extended deny ip any any log
+"""
+
+
+def test_asa_objects_groups_and_access_lists(evaluate):
+    data = parse_asa_config(ASA_CONFIG.splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "OUTSIDE_IN:2",
+        "OUTSIDE_IN:3",
+        "OUTSIDE_IN:5",
+        "OUTSIDE_IN:6",
+    ]
+    assert data.address_book.groups["WEB_SERVERS"].members == ("WEB01", "10.1.0.11/32")
+    assert not data.policies[2].enabled
+
+    web = evaluate(data, "10.1.0.11/32", Protocol.TCP, 443)
+    assert (web.decision, web.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:2")
+    sql = evaluate(data, "10.2.0.15/32", Protocol.TCP, 1521, src="10.0.0.7/32")
+    assert (sql.decision, sql.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:3")
+    inactive = evaluate(data, "192.0.2.1/32", Protocol.UDP, 5005, src="10.0.0.5/32")
+    assert (inactive.decision, inactive.matched_policy_id) == (Decision.DENY, "OUTSIDE_IN:6")
+    ssh = evaluate(data, "10.1.0.10/32", Protocol.TCP, 22)
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.DENY, "OUTSIDE_IN:6")
+
+
+def test_asa_ipv6_interface_addresses_and_access_groups(evaluate):
+    data = parse_asa_config(
+        """
+object network V6_WEB
+ host 2001:db8::10
+object-group network MIXED
+ network-object host 10.1.0.20
+ network-object 2001:db8:1::/48
+access-list OUTSIDE_IN extended permit tcp any6 object V6_WEB eq 443
+access-list OUTSIDE_IN extended permit tcp any interface outside eq 22
+access-list OUTSIDE_IN extended permit tcp any object-group MIXED eq 80
+access-list OUTSIDE_IN extended permit tcp host 2001:db8::1 any4 eq 25
+access-list CRYPTO extended permit ip any any
+access-group OUTSIDE_IN in interface outside
+""".splitlines()
+    )
+
+    assert [policy.policy_id for policy in data.policies] == [f"OUTSIDE_IN:{line}" for line in range(1, 5)]
+    first, interface, mixed, host6 = data.policies
+    assert (first.source, first.source6, first.destination, first.destination6) == ((), ("all",), (), ("V6_WEB",))
+    assert first.src_interfaces == ("outside",)
+    assert interface.destination == interface.destination6 == ("interface outside",)
+    assert mixed.destination == mixed.destination6 == ("MIXED",)
+    assert (host6.source, host6.source6) == ((), ("2001:db8::1/128",))
+    assert (host6.destination, host6.destination6) == (("all",), ())
+    assert str(data.address_book.objects6["V6_WEB"].subnet) == "2001:db8::10/128"
+
+    ssh = evaluate(data, "10.9.0.1/32", Protocol.TCP, 22, src="192.0.2.0/24")
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.UNKNOWN, "OUTSIDE_IN:2")
+    v6 = evaluate(data, "2001:db8::10/128", Protocol.TCP, 443, src="2001:db8:ff::/64")
+    assert (v6.decision, v6.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:1")
+    web6 = evaluate(data, "2001:db8:1::5/128", Protocol.TCP, 80, src="2001:db8:ff::/64")
+    assert (web6.decision, web6.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:3")
+    unbound = evaluate(data, "10.9.0.1/32", Protocol.TCP, 8080, src="192.0.2.0/24")
+    assert (unbound.decision, unbound.matched_policy_id) == (Decision.DENY, None)
+
+
+def test_asa_source_ports_narrow_services(evaluate):
+    data = parse_asa_config(
+        """
+object service DNS_REPLIES
+ service udp source eq domain
+object-group service MIXED
+ service-object tcp source range 1024 65535 destination eq https
+access-list OUTSIDE_IN extended permit tcp any eq domain any
+access-list OUTSIDE_IN extended permit object DNS_REPLIES any any
+access-list OUTSIDE_IN extended permit object-group MIXED any any
+""".splitlines()
+    )
+
+    entries = data.service_book.services["tcp source eq domain"].entries
+    assert [(entry.start_port, entry.end_port, entry.src_start_port, entry.src_end_port) for entry in entries] == [
+        (1, 65535, 53, 53)
+    ]
+    from_53 = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443, src_port=53)
+    assert (from_53.decision, from_53.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:1")
+    https = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443, src_port=40000)
+    assert (https.decision, https.matched_policy_id) == (Decision.ALLOW, "OUTSIDE_IN:3")
+    snmp = evaluate(data, "192.0.2.1/32", Protocol.UDP, 161, src_port=40000)
+    assert (snmp.decision, snmp.matched_policy_id) == (Decision.DENY, None)
+    low = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443, src_port=80)
+    assert (low.decision, low.matched_policy_id) == (Decision.DENY, None)
+
+    grouped = parse_asa_config(
+        """
+object-group service WEB_PORTS tcp
+ port-object eq www
+access-list OUTSIDE_IN extended permit tcp any range 1 1023 any object-group WEB_PORTS
+""".splitlines()
+    )
+    web = evaluate(grouped, "192.0.2.1/32", Protocol.TCP, 80, src_port=40000)
+    assert (web.decision, web.matched_policy_id) == (Decision.UNKNOWN, "OUTSIDE_IN:1")
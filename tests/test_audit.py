@@ -0,0 +1,286 @@
+"""Tests for static policy audits."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.audit import Severity, audit_policies
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+NEGATE_CONFIG = """
+config firewall policy
+    edit 1
+        set name "negate-all"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set service-negate enable
+        set action accept
+    next
+    edit 2
+        set name "everything-but-http"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "HTTP"
+        set service-negate enable
+        set action accept
+    next
+end
+"""
+
+
+def test_service_negate_all_flagged_as_dead():
+    data = parse_fortigate_config(NEGATE_CONFIG.splitlines())
+
+    findings = [
+        finding
+        for finding in audit_policies(data.policies, data.address_book, data.service_book)
+        if finding.check == "EMPTY_EFFECTIVE_SERVICE"
+    ]
+
+    assert [finding.policy_id for finding in findings] == ["1"]
+    assert findings[0].severity == Severity.MEDIUM
+
+
+def test_service_negate_inverts_service_match():
+    data = parse_fortigate_config(NEGATE_CONFIG.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+
+    def evaluate(port: int):
+        return evaluate_policy(
+            policies=data.policies,
+            address_book=data.address_book,
+            service_book=data.service_book,
+            src_network=ip_network("10.0.0.0/24"),
+            dst_network=ip_network("10.1.0.0/24"),
+            protocol=Protocol.TCP,
+            port=port,
+            match_mode=mode,
+            ignore_schedule=False,
+        )
+
+    assert evaluate(22).matched_policy_id == "2"
+    assert evaluate(22).decision == Decision.ALLOW
+    assert evaluate(80).decision == Decision.DENY
+    assert evaluate(80).reason == "IMPLICIT_DENY"
+
+
+ANY_ANY_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set name "lan-web"
+        set srcaddr "LAN"
+        set dstaddr "all"
+        set service "HTTP"
+        set action accept
+    next
+    edit 2
+        set name "temp-test-allow"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+    edit 3
+        set name "deny-ssh"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "SSH"
+        set action deny
+    next
+end
+"""
+
+
+def test_any_any_any_accept_flagged_critical():
+    data = parse_fortigate_config(ANY_ANY_CONFIG.splitlines())
+
+    findings = audit_policies(data.policies, data.address_book, data.service_book)
+
+    assert findings[0].policy_id == "2"
+    assert findings[0].check == "ANY_ANY_ANY_ACCEPT"
+    assert findings[0].severity == Severity.CRITICAL
+    assert findings[0].detail.endswith("shadows 1 later policy")
+
+
+def test_any_any_any_accept_after_catch_all_deny_is_not_flagged():
+    config = ANY_ANY_CONFIG.replace('set service "SSH"', 'set service "ALL"').replace("edit 3", "edit 0")
+    data = parse_fortigate_config(config.splitlines())
+
+    findings = audit_policies(data.policies, data.address_book, data.service_book)
+
+    assert all(finding.check != "ANY_ANY_ANY_ACCEPT" for finding in findings)
+
+
+
+def test_any_any_any_accept_respects_interface_pairs():
+    def policy(policy_id: int, srcintf: str, dstintf: str, action: str, service: str = "ALL") -> str:
+        return (
+            f"    edit {policy_id}\n"
+            f'        set srcintf "{srcintf}"\n'
+            f'        set dstintf "{dstintf}"\n'
+            '        set srcaddr "all"\n'
+            '        set dstaddr "all"\n'
+            f'        set service "{service}"\n'
+            f"        set action {action}\n"
+            "    next\n"
+        )
+
+    config = (
+        "config firewall policy\n"
+        + policy(1, "port9", "port10", "deny")
+        + policy(2, "port1", "port2", "accept")
+        + policy(3, "port1", "port2", "deny", service="SSH")
+        + policy(4, "port3", "port4", "deny", service="SSH")
+        + "end\n"
+    )
+    data = parse_fortigate_config(config.splitlines())
+
+    findings = [
+        finding
+        for finding in audit_policies(data.policies, data.address_book, data.service_book)
+        if finding.check == "ANY_ANY_ANY_ACCEPT"
+    ]
+
+    assert [(finding.policy_id, finding.severity) for finding in findings] == [("2", Severity.CRITICAL)]
+    assert findings[0].detail.endswith("shadows 1 later policy")
+
+
+SHADOW_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+    edit "LAN_HOSTS"
+        set type iprange
+        set start-ip 10.0.1.10
+        set end-ip 10.0.1.20
+    next
+    edit "WEB"
+        set subnet 192.0.2.10 255.255.255.255
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTP" "HTTPS"
+        set action deny
+    next
+    edit 2
+        set srcaddr "LAN_HOSTS"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 3
+        set srcaddr "LAN_HOSTS"
+        set dstaddr "WEB"
+        set service "SSH"
+        set action accept
+    next
+    edit 4
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTP"
+        set action deny
+    next
+    edit 5
+        set srcaddr "all"
+        set dstaddr "WEB"
+        set service "HTTP"
+        set action accept
+    next
+end
+"""
+
+
+def test_policies_covered_by_an_earlier_policy_are_shadowed():
+    data = parse_fortigate_config(SHADOW_CONFIG.splitlines())
+
+    findings = [
+        finding
+        for finding in audit_policies(data.policies, data.address_book, data.service_book)
+        if finding.check == "SHADOWED_POLICY"
+    ]
+
+    # Policy 3 has a port policy 1 lacks; policy 5 has sources outside LAN.
+    assert [(finding.policy_id, finding.severity) for finding in findings] == [
+        ("2", Severity.HIGH),
+        ("4", Severity.LOW),
+    ]
+    assert "earlier policy 1 (deny)" in findings[0].detail
+
+
+RISKY_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+    edit "DMZ"
+        set subnet 172.16.5.0 255.255.255.0
+    next
+    edit "PARTNER"
+        set subnet 198.51.100.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "LAN"
+        set dstaddr "DMZ"
+        set service "ALL"
+        set action accept
+        set logtraffic disable
+    next
+    edit 3
+        set srcaddr "LAN"
+        set dstaddr "PARTNER"
+        set service "SSH"
+        set action accept
+        set nat enable
+    next
+    edit 4
+        set srcaddr "LAN"
+        set dstaddr "PARTNER"
+        set service "TELNET"
+        set action deny
+        set logtraffic disable
+    next
+end
+"""
+
+
+def test_risky_accept_patterns_flagged_with_severity():
+    data = parse_fortigate_config(RISKY_CONFIG.splitlines())
+
+    findings = audit_policies(data.policies, data.address_book, data.service_book)
+
+    assert [(finding.policy_id, finding.check, finding.severity) for finding in findings] == [
+        ("1", "ANY_ANY_ACCEPT", Severity.HIGH),
+        ("2", "ACCEPT_ALL_SERVICES", Severity.MEDIUM),
+        ("2", "ACCEPT_WITHOUT_LOGGING", Severity.MEDIUM),
+        ("3", "PRIVATE_TO_INTERNET", Severity.MEDIUM),
+    ]
+    assert findings[-1].detail == "RFC1918 sources reach internet addresses with NAT"
+
+
+def test_private_sources_to_private_destinations_are_not_internet_facing():
+    config = RISKY_CONFIG.replace("198.51.100.0", "192.168.7.0")
+    data = parse_fortigate_config(config.splitlines())
+
+    findings = audit_policies(data.policies, data.address_book, data.service_book)
+
+    assert all(finding.check != "PRIVATE_TO_INTERNET" for finding in findings)
@@ -0,0 +1,86 @@
+"""Tests for the Azure NSG parser."""
+from __future__ import annotations
+
+import json
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.azure_nsg import parse_azure_nsg
+
+
+NSG = {
+    "name": "web-nsg",
+    "securityRules": [
+        {
+            "name": "deny-legacy",
+            "priority": 200,
+            "direction": "Inbound",
+            "access": "Deny",
+            "protocol": "Tcp",
+            "sourceAddressPrefix": "*",
+            "destinationAddressPrefix": "10.1.0.0/24",
+            "destinationPortRange": "8000-8100",
+        },
+        {
+            "name": "allow-web",
+            "properties": {
+                "priority": 100,
+                "direction": "Inbound",
+                "access": "Allow",
+                "protocol": "Tcp",
+                "sourceAddressPrefixes": ["10.0.0.0/24", "10.0.1.0/24"],
+                "destinationAddressPrefix": "10.1.0.0/24",
+                "destinationPortRanges": ["443", "8080-8090"],
+            },
+        },
+        {
+            "name": "allow-out",
+            "priority": 100,
+            "direction": "Outbound",
+            "access": "Allow",
+            "protocol": "*",
+            "sourceAddressPrefix": "*",
+            "destinationAddressPrefix": "*",
+            "destinationPortRange": "*",
+        },
+    ],
+    "defaultSecurityRules": [
+        {
+            "name": "AllowVnetInBound",
+            "priority": 65000,
+            "direction": "Inbound",
+            "access": "Allow",
+            "protocol": "*",
+            "sourceAddressPrefix": "VirtualNetwork",
+            "destinationAddressPrefix": "VirtualNetwork",
+            "destinationPortRange": "*",
+        },
+        {
+            "name": "DenyAllInBound",
+            "priority": 65500,
+            "direction": "Inbound",
+            "access": "Deny",
+            "protocol": "*",
+            "sourceAddressPrefix": "*",
+            "destinationAddressPrefix": "*",
+            "destinationPortRange": "*",
+        },
+    ],
+}
+
+
+def test_nsg_rules_evaluate_by_priority(evaluate):
+    data = parse_azure_nsg(json.dumps(NSG).splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "allow-web",
+        "deny-legacy",
+        "AllowVnetInBound",
+        "DenyAllInBound",
+    ]
+
+    allowed = evaluate(data, "10.1.0.5/32", Protocol.TCP, 8085, src="10.0.1.0/24")
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "allow-web")
+    denied = evaluate(data, "10.1.0.5/32", Protocol.TCP, 8050, src="10.0.1.0/24")
+    assert (denied.decision, denied.matched_policy_id) == (Decision.DENY, "deny-legacy")
+    service_tag = evaluate(data, "10.1.0.5/32", Protocol.TCP, 22, src="10.0.1.0/24")
+    assert (service_tag.decision, service_tag.matched_policy_id) == (Decision.UNKNOWN, "AllowVnetInBound")
@@ -0,0 +1,89 @@
+"""Tests for reachability across chained firewalls."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.chain import Hop, evaluate_chain
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+EDGE_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+    edit "DMZ"
+        set subnet 172.16.0.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "DMZ"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+CORE_CONFIG = """
+config firewall address
+    edit "DMZ"
+        set subnet 172.16.0.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 10
+        set srcaddr "all"
+        set dstaddr "DMZ"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 11
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+
+def _hop(name: str, config: str) -> Hop:
+    data = parse_fortigate_config(config.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+    return Hop(name, Evaluator(data.policies, data.address_book, data.service_book, mode))
+
+
+def test_second_hop_blocks_flow_allowed_by_first():
+    hops = [_hop("edge", EDGE_CONFIG), _hop("core", CORE_CONFIG)]
+    src, dst = ip_network("10.0.0.0/24"), ip_network("172.16.0.10/32")
+
+    blocked = evaluate_chain(hops, src, dst, Protocol.TCP, 22)
+    assert blocked.decision == Decision.DENY
+    assert blocked.blocking_hop == "core"
+    assert [(name, detail.decision) for name, detail in blocked.hops] == [
+        ("edge", Decision.ALLOW),
+        ("core", Decision.DENY),
+    ]
+    assert blocked.blocking_detail.matched_policy_id == "11"
+
+    allowed = evaluate_chain(hops, src, dst, Protocol.TCP, 443)
+    assert allowed.decision == Decision.ALLOW
+    assert allowed.blocking_hop is None
+
+
+def test_translate_rewrites_flow_for_next_hop():
+    edge = _hop("edge", EDGE_CONFIG)
+    core = _hop("core", CORE_CONFIG)
+    to_dmz = Hop(
+        edge.name,
+        edge.evaluator,
+        translate=lambda src, dst, protocol, port, detail: (ip_network("172.16.0.1/32"), dst, port),
+    )
+
+    result = evaluate_chain([to_dmz, core], ip_network("10.0.0.5/32"), ip_network("172.16.0.10/32"), Protocol.TCP, 443)
+
+    assert result.decision == Decision.ALLOW
@@ -0,0 +1,147 @@
+"""Tests for the Check Point package parser."""
+from __future__ import annotations
+
+import json
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.chain import Hop, evaluate_chain
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.checkpoint import parse_checkpoint_layers, parse_checkpoint_package
+from static_traffic_analyzer.utils import ParseError
+
+
+EXPORT = {
+    "objects-dictionary": [
+        {"uid": "u-any", "name": "Any", "type": "CpmiAnyObject"},
+        {"uid": "u-accept", "name": "Accept", "type": "RulebaseAction"},
+        {"uid": "u-drop", "name": "Drop", "type": "RulebaseAction"},
+        {"uid": "u-lan", "name": "LAN", "type": "network", "subnet4": "10.0.0.0", "mask-length4": 24},
+        {"uid": "u-web1", "name": "web1", "type": "host", "ipv4-address": "10.1.0.10"},
+        {
+            "uid": "u-pool",
+            "name": "pool",
+            "type": "address-range",
+            "ipv4-address-first": "10.1.0.20",
+            "ipv4-address-last": "10.1.0.29",
+        },
+        {"uid": "u-web", "name": "WEB", "type": "group", "members": ["u-web1", "u-pool"]},
+        {"uid": "u-https", "name": "https", "type": "service-tcp", "port": "443"},
+        {"uid": "u-high", "name": "high-udp", "type": "service-udp", "port": ">1023"},
+        {"uid": "u-websvc", "name": "web-svcs", "type": "service-group", "members": ["u-https"]},
+    ],
+    "rulebase": [
+        {
+            "type": "access-section",
+            "name": "Web",
+            "rulebase": [
+                {
+                    "type": "access-rule",
+                    "rule-number": 1,
+                    "name": "lan to web",
+                    "source": ["u-lan"],
+                    "destination": ["u-web"],
+                    "service": ["u-websvc"],
+                    "action": "u-accept",
+                    "enabled": True,
+                },
+                {
+                    "type": "access-rule",
+                    "rule-number": 2,
+                    "source": ["u-any"],
+                    "destination": ["u-any"],
+                    "service": ["u-high"],
+                    "action": "u-accept",
+                    "enabled": False,
+                },
+            ],
+        },
+        {
+            "type": "access-rule",
+            "rule-number": 3,
+            "name": "cleanup",
+            "source": ["u-any"],
+            "destination": ["u-any"],
+            "service": ["u-any"],
+            "action": "u-drop",
+        },
+    ],
+}
+
+
+def test_checkpoint_package_flattens_groups_and_sections(evaluate):
+    data = parse_checkpoint_package(json.dumps(EXPORT).splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == ["1", "2", "3"]
+    assert data.address_book.groups["WEB"].members == ("web1", "pool")
+    assert data.policies[2].source == ("all",)
+
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 443)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "1")
+    disabled = evaluate(data, "10.1.0.25/32", Protocol.UDP, 5000)
+    assert (disabled.decision, disabled.matched_policy_id) == (Decision.DENY, "3")
+
+
+def test_checkpoint_ordered_layers_must_all_accept():
+    objects = EXPORT["objects-dictionary"]
+    network_layer = {
+        "name": "Network",
+        "rulebase": [{"rule-number": 1, "source": ["u-any"], "destination": ["u-any"], "action": "u-accept"}],
+    }
+    apps_layer = {
+        "name": "Apps",
+        "rulebase": [
+            {
+                "rule-number": 1,
+                "source": ["u-lan"],
+                "destination": ["u-web"],
+                "service": ["u-https"],
+                "action": "u-drop",
+            },
+            {"rule-number": 2, "source": ["u-any"], "destination": ["u-any"], "action": "u-accept"},
+        ],
+    }
+    package = json.dumps({"objects": objects, "access-layers": [network_layer, apps_layer]}).splitlines()
+
+    with pytest.raises(ParseError, match="2 ordered access layers"):
+        parse_checkpoint_package(package)
+
+    layers = parse_checkpoint_layers(package)
+    assert [name for name, _ in layers] == ["Network", "Apps"]
+    mode = MatchMode(mode="segment", max_hosts=256)
+    hops = [Hop(name, Evaluator(data.policies, data.address_book, data.service_book, mode)) for name, data in layers]
+    blocked = evaluate_chain(hops, ip_network("10.0.0.0/24"), ip_network("10.1.0.10/32"), Protocol.TCP, 443)
+    assert (blocked.decision, blocked.blocking_hop) == (Decision.DENY, "Apps")
+    assert blocked.blocking_detail.matched_policy_id == "Apps:1"
+    allowed = evaluate_chain(hops, ip_network("10.0.0.0/24"), ip_network("10.1.0.10/32"), Protocol.TCP, 22)
+    assert allowed.decision == Decision.ALLOW
+
+
+def test_checkpoint_ipv6_only_objects_are_left_unresolved(evaluate):
+    objects = [
+        *EXPORT["objects-dictionary"],
+        {"uid": "u-v6host", "name": "v6host", "type": "host", "ipv6-address": "2001:db8::10"},
+        {"uid": "u-v6net", "name": "v6net", "type": "network", "subnet6": "2001:db8:1::", "mask-length6": 64},
+        {
+            "uid": "u-dual",
+            "name": "dual",
+            "type": "host",
+            "ipv4-address": "10.1.0.40",
+            "ipv6-address": "2001:db8::40",
+        },
+    ]
+    rulebase = [
+        {"rule-number": 1, "source": ["u-any"], "destination": ["u-v6host"], "action": "u-accept"},
+        {"rule-number": 2, "source": ["u-any"], "destination": ["u-dual"], "action": "u-accept"},
+    ]
+    data = parse_checkpoint_package(json.dumps({"objects-dictionary": objects, "rulebase": rulebase}).splitlines())
+
+    assert data.warnings == [
+        "host v6host: no IPv4 address; IPv6 objects are not supported",
+        "network v6net: no IPv4 address; IPv6 objects are not supported",
+    ]
+    assert "v6host" not in data.address_book.objects
+    unresolved = evaluate(data, "10.1.0.40/32", Protocol.TCP, 443)
+    assert (unresolved.decision, unresolved.matched_policy_id) == (Decision.UNKNOWN, "1")
@@ -0,0 +1,162 @@
+"""Tests for post-run assertions."""
+from __future__ import annotations
+
+import csv
+import sys
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer import cli
+from static_traffic_analyzer.checks import compare_golden, find_denylist_violations, load_denylist, load_golden
+
+
+SAMPLE = Path(__file__).resolve().parents[1] / "samples" / "case01_basic"
+
+
+def _run_cli(monkeypatch, *extra: str, src_csv: Path = SAMPLE / "inputs" / "src.csv") -> None:
+    monkeypatch.setattr(
+        sys,
+        "argv",
+        [
+            "static-traffic-analyzer",
+            "--config",
+            str(SAMPLE / "rules" / "fortigate.conf"),
+            "--src-csv",
+            str(src_csv),
+            "--dst-csv",
+            str(SAMPLE / "inputs" / "dst.csv"),
+            "--ports",
+            str(SAMPLE / "inputs" / "ports.txt"),
+            *extra,
+        ],
+    )
+    cli.main()
+
+
+def test_denylist_matches_allowed_rows(tmp_path: Path):
+    path = tmp_path / "deny.csv"
+    path.write_text("Source,Destination,Service\n192.168.10.0/24,10.0.0.0/24,80/tcp\n10.9.0.0/16,10.0.0.0/24,\n")
+    denylist = load_denylist(path)
+    rows = [
+        {
+            "src_network_segment": "192.168.10.0/24",
+            "dst_network_segment": "10.0.0.0/24",
+            "service_label": "http",
+            "protocol": "tcp",
+            "port": 80,
+            "decision": "ALLOW",
+        },
+        {
+            "src_network_segment": "192.168.10.0/24",
+            "dst_network_segment": "10.0.0.0/24",
+            "service_label": "ssh",
+            "protocol": "tcp",
+            "port": 22,
+            "decision": "ALLOW",
+        },
+    ]
+
+    violations = find_denylist_violations(rows, denylist)
+
+    assert len(violations) == 1
+    assert violations[0][1]["service_label"] == "http"
+
+
+def test_denylist_compares_only_rows_of_the_same_ip_version(tmp_path: Path):
+    path = tmp_path / "deny.csv"
+    path.write_text("Source,Destination,Service\n2001:db8:1::/48,2001:db8:2::/64,\n0.0.0.0/0,0.0.0.0/0,ssh\n")
+    denylist = load_denylist(path)
+    rows = [
+        {
+            "src_network_segment": segments[0],
+            "dst_network_segment": segments[1],
+            "service_label": "ssh",
+            "protocol": "tcp",
+            "port": 22,
+            "decision": "ALLOW",
+        }
+        for segments in (("2001:db8:1:5::/64", "2001:db8:2::10/128"), ("2001:db8:9::/64", "2001:db8:2::10/128"))
+    ]
+
+    violations = find_denylist_violations(rows, denylist)
+
+    assert [(flow.describe(), row["src_network_segment"]) for flow, row in violations] == [
+        ("2001:db8:1::/48 -> 2001:db8:2::/64 (ALL)", "2001:db8:1:5::/64")
+    ]
+
+
+def test_denylist_violation_fails_run(tmp_path: Path, monkeypatch):
+    denylist = tmp_path / "deny.csv"
+    denylist.write_text("Source,Destination,Service\n192.168.10.0/24,10.0.0.0/24,http\n")
+
+    with pytest.raises(SystemExit) as excinfo:
+        _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--denylist", str(denylist))
+
+    assert excinfo.value.code == 1
+    assert (tmp_path / "out.csv").exists()
+
+
+def test_denylist_without_violation_passes(tmp_path: Path, monkeypatch, capsys):
+    denylist = tmp_path / "deny.csv"
+    denylist.write_text("Source,Destination,Service\n192.168.10.0/24,10.0.1.5/32,\n")
+
+    _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--denylist", str(denylist))
+
+    assert (tmp_path / "out.csv").exists()
+    assert "DENYLIST VIOLATION" not in capsys.readouterr().err
+
+
+def test_min_src_prefix_guard_rejects_broad_source(tmp_path: Path, monkeypatch):
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment\n10.0.0.0/8\n")
+
+    with pytest.raises(SystemExit, match="broader than /16"):
+        _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--min-src-prefix", "16", src_csv=src)
+    assert not (tmp_path / "out.csv").exists()
+
+
+def test_compare_golden_matches_sample(tmp_path: Path, monkeypatch):
+    golden = SAMPLE / "expected" / "expected.csv"
+
+    _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--compare-golden", str(golden))
+
+
+def test_compare_golden_reports_divergence(tmp_path: Path, monkeypatch, capsys):
+    lines = (SAMPLE / "expected" / "expected.csv").read_text(encoding="utf-8").splitlines()
+    lines[2] = lines[2].replace("ALLOW,3,", "DENY,2,")
+    golden = tmp_path / "golden.csv"
+    golden.write_text("\n".join(lines[:-1]) + "\n", encoding="utf-8")
+
+    with pytest.raises(SystemExit) as excinfo:
+        _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--compare-golden", str(golden))
+
+    assert excinfo.value.code == 1
+    stderr = capsys.readouterr().err
+    assert "192.168.10.0/24 10.0.0.0/24 http tcp 80: decision expected 'DENY', got 'ALLOW'" in stderr
+    assert "matched_policy_id expected '2', got '3'" in stderr
+    assert "not present in golden file" in stderr
+
+
+def test_compare_golden_keys_exact_mode_subranges(tmp_path: Path):
+    flow = {
+        "src_network_segment": "10.0.0.0/24",
+        "dst_network_segment": "10.0.1.0/24",
+        "dst_subrange": "10.0.1.0/24",
+        "service_label": "https",
+        "protocol": "tcp",
+        "port": "443",
+    }
+    rows = [
+        {**flow, "src_subrange": "10.0.0.0/25", "decision": "ALLOW", "matched_policy_id": "1"},
+        {**flow, "src_subrange": "10.0.0.128/25", "decision": "DENY", "matched_policy_id": ""},
+    ]
+    golden = tmp_path / "golden.csv"
+    with golden.open("w", newline="", encoding="utf-8") as handle:
+        writer = csv.DictWriter(handle, fieldnames=list(rows[0]))
+        writer.writeheader()
+        writer.writerows(rows)
+
+    assert len(load_golden(golden)) == 2
+    assert compare_golden(rows, load_golden(golden)) == []
+
@@ -0,0 +1,1046 @@
+"""End-to-end tests for the command-line interface."""
+from __future__ import annotations
+
+import csv
+import json
+import sqlite3
+import sys
+from ipaddress import ip_network
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer import cli
+
+
+SAMPLE = Path(__file__).resolve().parents[1] / "samples" / "case01_basic"
+
+
+def _run_cli(monkeypatch, *extra: str, src_csv: Path = SAMPLE / "inputs" / "src.csv") -> None:
+    monkeypatch.setattr(
+        sys,
+        "argv",
+        [
+            "static-traffic-analyzer",
+            "--config",
+            str(SAMPLE / "rules" / "fortigate.conf"),
+            "--src-csv",
+            str(src_csv),
+            "--dst-csv",
+            str(SAMPLE / "inputs" / "dst.csv"),
+            "--ports",
+            str(SAMPLE / "inputs" / "ports.txt"),
+            *extra,
+        ],
+    )
+    cli.main()
+
+
+def test_src_metadata_columns_in_output(tmp_path: Path, monkeypatch):
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment,Owner,Zone\n192.168.10.0/24,alice,office\n")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--src-metadata", src_csv=src)
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert rows[0]["src_owner"] == "alice"
+    assert rows[0]["src_zone"] == "office"
+    assert "src_network_segment" in rows[0]
+
+
+def test_src_metadata_collision_rejected_or_prefixed(tmp_path: Path, monkeypatch):
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment,network segment,Owner\n192.168.10.0/24,office-lan,alice\n")
+    out = tmp_path / "out.csv"
+
+    with pytest.raises(SystemExit, match="'network segment' collides with output column 'src_network_segment'"):
+        _run_cli(monkeypatch, "--out", str(out), "--src-metadata", src_csv=src)
+    assert not out.exists()
+
+    _run_cli(monkeypatch, "--out", str(out), "--src-metadata", "--metadata-collision", "prefix", src_csv=src)
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert rows[0]["src_network_segment"] == "192.168.10.0/24"
+    assert (rows[0]["src_meta_network_segment"], rows[0]["src_owner"]) == ("office-lan", "alice")
+
+    database = tmp_path / "out.db"
+    _run_cli(monkeypatch, "--out", str(database), "--src-metadata", "--metadata-collision", "prefix", src_csv=src)
+    with sqlite3.connect(database) as connection:
+        columns = [row[1] for row in connection.execute("PRAGMA table_info(results)")]
+    assert columns.count("src_network_segment") == 1
+    assert "src_meta_network_segment" in columns
+
+
+def test_db_check_reports_problems(monkeypatch, capsys):
+    monkeypatch.setattr(cli, "check_schema", lambda dsn: ["missing column: cfg_policy.priority"])
+
+    with pytest.raises(SystemExit) as excinfo:
+        cli.main(["db-check", "--db-conn", "mysql://example"])
+
+    assert excinfo.value.code == 1
+    assert "missing column: cfg_policy.priority" in capsys.readouterr().err
+
+
+def test_out_dir_without_flat_output(tmp_path: Path, monkeypatch):
+    _run_cli(monkeypatch, "--out-dir", str(tmp_path / "results"))
+
+    with (tmp_path / "results" / "allow" / "part-00000.csv").open(newline="", encoding="utf-8") as handle:
+        allowed = list(csv.DictReader(handle))
+    assert {row["matched_policy_id"] for row in allowed} == {"1", "3", "4"}
+    assert (tmp_path / "results" / "deny" / "part-00000.csv").exists()
+    assert (tmp_path / "results" / "unmatched" / "part-00000.csv").exists()
+
+
+def test_threat_feed_sources_are_evaluated_and_tagged(tmp_path: Path, monkeypatch):
+    feed = tmp_path / "feed.txt"
+    feed.write_text("# known bad\n192.168.10.7  # botnet\n\n203.0.113.9,c2-server\n")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--threat-feed", str(feed))
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    feed_rows = [row for row in rows if row["source_set"] == "threat-feed"]
+    assert {row["src_network_segment"] for row in feed_rows} == {"192.168.10.7/32", "203.0.113.9/32"}
+    assert {row["source_set"] for row in rows if row["src_network_segment"] == "192.168.10.0/24"} == {"src-csv"}
+    allowed = [
+        row
+        for row in feed_rows
+        if row["src_network_segment"] == "192.168.10.7/32"
+        and row["dst_network_segment"] == "10.0.0.0/24"
+        and row["service_label"] == "http"
+    ]
+    assert [row["decision"] for row in allowed] == ["ALLOW"]
+
+
+def test_raw_reference_columns_show_group_names_of_matched_policy(tmp_path: Path, monkeypatch):
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--raw-ref-columns")
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    matched = [row for row in rows if row["matched_policy_id"] == "1"]
+    assert matched
+    assert {
+        (row["matched_policy_srcaddr"], row["matched_policy_dstaddr"], row["matched_policy_service"]) for row in matched
+    } == {("SRC_HOST_20_10", "DB_HOST", "SG_DB_CUSTOM")}
+    implicit = [row for row in rows if row["reason"] == "IMPLICIT_DENY"]
+    assert all(row["matched_policy_service"] == "" for row in implicit)
+
+
+def test_next_hop_config_reports_blocking_hop(tmp_path: Path, monkeypatch):
+    core = tmp_path / "core.conf"
+    core.write_text(
+        "config firewall policy\n"
+        "    edit 50\n"
+        '        set srcaddr "all"\n'
+        '        set dstaddr "all"\n'
+        '        set service "ALL"\n'
+        "        set action deny\n"
+        "    next\n"
+        "end\n"
+    )
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--next-hop-config", str(core))
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    first_hop_allowed = [row for row in rows if row["decision"] == "ALLOW"]
+    assert first_hop_allowed
+    assert {(row["chain_decision"], row["chain_blocking_hop"]) for row in first_hop_allowed} == {("DENY", "2")}
+    assert {row["chain_blocking_policy_id"] for row in first_hop_allowed} == {"50"}
+    first_hop_denied = [row for row in rows if row["decision"] == "DENY"]
+    assert {row["chain_blocking_hop"] for row in first_hop_denied} == {"1"}
+
+
+def test_next_hop_sees_flow_translated_by_previous_hop(tmp_path: Path, monkeypatch):
+    edge = tmp_path / "edge.conf"
+    edge.write_text(
+        "config firewall vip\n"
+        '    edit "WEB_VIP"\n'
+        "        set extip 203.0.113.10\n"
+        '        set mappedip "10.0.0.10"\n'
+        "    next\n"
+        "end\n"
+        "config firewall ippool\n"
+        '    edit "POOL_PUBLIC"\n'
+        "        set startip 198.51.100.1\n"
+        "        set endip 198.51.100.1\n"
+        "    next\n"
+        "end\n"
+        "config firewall policy\n"
+        "    edit 1\n"
+        '        set srcaddr "all"\n'
+        '        set dstaddr "WEB_VIP"\n'
+        '        set service "ALL"\n'
+        "        set action accept\n"
+        "        set nat enable\n"
+        "        set ippool enable\n"
+        '        set poolname "POOL_PUBLIC"\n'
+        "    next\n"
+        "end\n"
+    )
+    core = tmp_path / "core.conf"
+    core.write_text(
+        "config firewall address\n"
+        '    edit "POOL_PUBLIC_IP"\n'
+        "        set subnet 198.51.100.1 255.255.255.255\n"
+        "    next\n"
+        '    edit "WEB_REAL"\n'
+        "        set subnet 10.0.0.10 255.255.255.255\n"
+        "    next\n"
+        "end\n"
+        "config firewall policy\n"
+        "    edit 60\n"
+        '        set srcaddr "POOL_PUBLIC_IP"\n'
+        '        set dstaddr "WEB_REAL"\n'
+        '        set service "ALL"\n'
+        "        set action accept\n"
+        "    next\n"
+        "end\n"
+    )
+    dst_csv = tmp_path / "dst.csv"
+    dst_csv.write_text("Network Segment,GN,Site,Location\n203.0.113.10/32,GN01,HSINCHU,DMZ\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(
+        monkeypatch,
+        "--config",
+        str(edge),
+        "--dst-csv",
+        str(dst_csv),
+        "--next-hop-config",
+        str(core),
+        "--out",
+        str(out),
+    )
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert rows
+    # Hop 2 only knows the mapped destination and the pool address.
+    assert {(row["decision"], row["chain_decision"]) for row in rows} == {("ALLOW", "ALLOW")}
+
+
+def test_routing_reports_flows_without_a_route(tmp_path: Path, monkeypatch):
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--routing")
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    # The sample configuration has no routes or interface addresses.
+    assert {(row["decision"], row["reason"], row["matched_policy_id"], row["egress_route"]) for row in rows} == {
+        ("UNROUTABLE", "NO_ROUTE", "", "")
+    }
+
+
+def test_strict_parse_aborts_on_malformed_config(tmp_path: Path, monkeypatch):
+    config = tmp_path / "typo.conf"
+    config.write_text((SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8") + "sett foo bar\n")
+    out = tmp_path / "out.csv"
+
+    # The later --config overrides the sample configuration passed by _run_cli.
+    with pytest.raises(SystemExit, match="typo.conf line [0-9]+: Unrecognized line: sett foo bar"):
+        _run_cli(monkeypatch, "--config", str(config), "--strict-parse", "--out", str(out))
+    _run_cli(monkeypatch, "--strict-parse", "--out", str(out))
+
+
+def test_match_interfaces_uses_interface_columns(tmp_path: Path, monkeypatch):
+    config = tmp_path / "intf.conf"
+    config.write_text(
+        (SAMPLE / "rules" / "fortigate.conf")
+        .read_text(encoding="utf-8")
+        .replace('set name "allow-web-http-src-net"', 'set name "allow-web-http-src-net"\n        set srcintf "port1"'),
+        encoding="utf-8",
+    )
+    src_csv = tmp_path / "src.csv"
+    src_csv.write_text("Network Segment,Interface\n192.168.10.0/24,port2\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--config", str(config), "--match-interfaces", "--out", str(out), src_csv=src_csv)
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    http = [row for row in rows if row["dst_network_segment"] == "10.0.0.0/24" and row["port"] == "80"]
+    assert [(row["decision"], row["reason"]) for row in http] == [("DENY", "IMPLICIT_DENY")]
+
+
+NAT_PIPELINE_CONFIG = """
+config system settings
+    set central-nat enable
+end
+config firewall vip
+    edit "WEB_VIP"
+        set extip 203.0.113.10
+        set mappedip "10.0.0.10"
+    next
+end
+config firewall ippool
+    edit "POOL_PUBLIC"
+        set startip 198.51.100.1
+        set endip 198.51.100.1
+    next
+end
+config firewall central-snat-map
+    edit 1
+        set orig-addr "all"
+        set dst-addr "all"
+        set nat-ippool "POOL_PUBLIC"
+    next
+end
+"""
+
+
+def test_nat_pipeline_translates_destination_before_lookup(tmp_path: Path, monkeypatch):
+    config = tmp_path / "nat.conf"
+    config.write_text(
+        NAT_PIPELINE_CONFIG + (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8"), encoding="utf-8"
+    )
+    dst_csv = tmp_path / "dst.csv"
+    dst_csv.write_text("Network Segment,GN,Site,Location\n203.0.113.10/32,GN01,HSINCHU,DMZ\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(
+        monkeypatch, "--config", str(config), "--dst-csv", str(dst_csv), "--nat-pipeline", "--out", str(out)
+    )
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = {(row["src_network_segment"], row["port"]): row for row in csv.DictReader(handle)}
+    # Policy 3 names WEB_NET, the mapped side of the VIP.
+    http = rows[("192.168.10.0/24", "80")]
+    assert (http["decision"], http["matched_policy_id"]) == ("ALLOW", "3")
+    assert http["original_flow"] == "192.168.10.0/24 -> 203.0.113.10/32 tcp/80"
+    assert http["translated_flow"] == "198.51.100.1 -> 10.0.0.10 tcp/80"
+    assert http["nat_steps"] == "dnat:WEB_VIP;snat:rule 1"
+    ssh = rows[("192.168.10.0/24", "22")]
+    assert (ssh["decision"], ssh["translated_flow"], ssh["nat_steps"]) == ("DENY", "", "dnat:WEB_VIP")
+
+
+def test_anonymize_covers_nat_columns(tmp_path: Path, monkeypatch):
+    config = tmp_path / "nat.conf"
+    config.write_text(
+        NAT_PIPELINE_CONFIG + (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8"), encoding="utf-8"
+    )
+    dst_csv = tmp_path / "dst.csv"
+    dst_csv.write_text("Network Segment,GN,Site,Location\n203.0.113.10/32,GN01,HSINCHU,DMZ\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(
+        monkeypatch,
+        "--config",
+        str(config),
+        "--dst-csv",
+        str(dst_csv),
+        "--nat-pipeline",
+        "--nat-columns",
+        "--dnat-columns",
+        "--anonymize",
+        "--anon-key",
+        "secret",
+        "--out",
+        str(out),
+    )
+
+    text = out.read_text(encoding="utf-8")
+    assert "dnat:WEB_VIP;snat:rule 1" in text
+    assert "POOL_PUBLIC (" in text
+    for real in ("192.168.10.", "192.168.20.", "203.0.113.10", "198.51.100.1", "10.0.0.10"):
+        assert real not in text
+
+
+def test_sweep_reports_decisions_changed_by_schedules(tmp_path: Path, monkeypatch):
+    config = tmp_path / "schedule.conf"
+    rules = Path(__file__).resolve().parents[1] / "samples" / "case02_schedule" / "rules" / "fortigate.conf"
+    config.write_text(
+        rules.read_text(encoding="utf-8")
+        + "config firewall schedule recurring\n"
+        + '    edit "office-hours"\n'
+        + "        set day monday tuesday wednesday thursday friday\n"
+        + "        set start 08:00\n"
+        + "        set end 18:00\n"
+        + "    next\n"
+        + "end\n",
+        encoding="utf-8",
+    )
+    changes = tmp_path / "changes.csv"
+
+    # 2026-01-05 is a Monday; office-hours starts at 08:00.
+    _run_cli(
+        monkeypatch,
+        "--config",
+        str(config),
+        "--out",
+        str(tmp_path / "out.csv"),
+        "--sweep",
+        "2026-01-05T06:00:00",
+        "2026-01-05T09:00:00",
+        "--sweep-step",
+        "1h",
+        "--sweep-out",
+        str(changes),
+    )
+
+    with changes.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert {(row["src_network_segment"], row["at"], row["previous_decision"], row["decision"]) for row in rows} == {
+        ("192.168.10.0/24", "2026-01-05T08:00:00", "DENY", "ALLOW"),
+        ("192.168.20.10/32", "2026-01-05T08:00:00", "DENY", "ALLOW"),
+    }
+    assert {row["port"] for row in rows} == {"80"}
+
+
+def test_shadow_subcommand_lists_shadowed_policies(tmp_path: Path, capsys):
+    config = tmp_path / "shadow.conf"
+    config.write_text(
+        (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8").replace("edit 4", "edit 5")
+        + "config firewall policy\n"
+        + "    edit 4\n"
+        + '        set srcaddr "SRC_NET_10"\n'
+        + '        set dstaddr "WEB_NET"\n'
+        + '        set service "HTTP"\n'
+        + "        set action deny\n"
+        + "    next\n"
+        + "end\n",
+        encoding="utf-8",
+    )
+    out = tmp_path / "shadow.csv"
+
+    cli.main(["shadow", "--config", str(config), "--out", str(out)])
+
+    assert "HIGH: policy 4" in capsys.readouterr().out
+    with out.open(newline="", encoding="utf-8") as handle:
+        assert [row["policy_id"] for row in csv.DictReader(handle)] == ["4"]
+
+
+def test_overlap_subcommand_prints_conflicts(tmp_path: Path, capsys):
+    out = tmp_path / "overlap.csv"
+
+    cli.main(["overlap", "--config", str(SAMPLE / "rules" / "fortigate.conf"), "--out", str(out)])
+
+    # Policy 2 denies everything to DB_HOST that policy 1 accepts on the custom range.
+    printed = capsys.readouterr().out
+    assert "CONFLICT: policy 1 (accept) and policy 2 (deny) share 192.168.20.10/32 -> 10.0.1.5/32" in printed
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert [(row["later_policy_id"], row["conflict"], row["services"]) for row in rows] == [
+        ("2", "yes", "tcp/8001-8004")
+    ]
+
+
+def test_query_rules_lists_every_candidate_policy(tmp_path: Path, capsys):
+    out = tmp_path / "query.csv"
+
+    cli.main(
+        [
+            "query",
+            "rules",
+            "--config",
+            str(SAMPLE / "rules" / "fortigate.conf"),
+            "--dst",
+            "10.0.1.5",
+            "--port",
+            "8002/tcp",
+            "--out",
+            str(out),
+        ]
+    )
+
+    printed = capsys.readouterr().out
+    assert "policy 1 (allow-db-custom-range) accept: 192.168.20.10/32 -> 10.0.1.5/32 tcp/8002" in printed
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert [(row["policy_id"], row["action"]) for row in rows] == [("1", "accept"), ("2", "deny")]
+
+
+def test_what_if_reports_only_changed_decisions(tmp_path: Path, monkeypatch):
+    patch = tmp_path / "patch.conf"
+    patch.write_text(
+        "config firewall policy\n"
+        "    delete 2\n"
+        "    edit 5\n"
+        '        set srcaddr "SRC_NET_10"\n'
+        '        set dstaddr "all"\n'
+        '        set service "SSH"\n'
+        "        set action accept\n"
+        "    next\n"
+        "end\n",
+        encoding="utf-8",
+    )
+    changes = tmp_path / "what-if.csv"
+
+    _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--what-if", str(patch), "--what-if-out", str(changes))
+
+    with changes.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    changed = {
+        (row["src_network_segment"], row["dst_network_segment"], row["port"], row["decision"], row["matched_policy_id"])
+        for row in rows
+    }
+    assert changed == {
+        ("192.168.10.0/24", "10.0.0.0/24", "22", "ALLOW", "5"),
+        ("192.168.10.0/24", "10.0.1.5/32", "22", "ALLOW", "5"),
+        ("192.168.10.0/24", "10.0.1.5/32", "80", "DENY", ""),
+        ("192.168.10.0/24", "10.0.1.5/32", "8002", "DENY", ""),
+        ("192.168.10.0/24", "10.0.1.5/32", "53", "DENY", ""),
+        ("192.168.20.10/32", "10.0.1.5/32", "22", "DENY", ""),
+        ("192.168.20.10/32", "10.0.1.5/32", "80", "DENY", ""),
+        ("192.168.20.10/32", "10.0.1.5/32", "53", "DENY", ""),
+    }
+    assert all(row["previous_policy_id"] == "2" for row in rows if row["dst_network_segment"] == "10.0.1.5/32")
+
+
+def test_coverage_subcommand_writes_both_reports(tmp_path: Path):
+    policy_out = tmp_path / "policy-coverage.csv"
+    space_out = tmp_path / "space-coverage.csv"
+
+    cli.main(
+        [
+            "coverage",
+            "--config",
+            str(SAMPLE / "rules" / "fortigate.conf"),
+            "--src-csv",
+            str(SAMPLE / "inputs" / "src.csv"),
+            "--dst-csv",
+            str(SAMPLE / "inputs" / "dst.csv"),
+            "--ports",
+            str(SAMPLE / "inputs" / "ports.txt"),
+            "--policy-out",
+            str(policy_out),
+            "--space-out",
+            str(space_out),
+        ]
+    )
+
+    with policy_out.open(newline="", encoding="utf-8") as handle:
+        policy_rows = list(csv.DictReader(handle))
+    sources = {
+        (row["policy_id"], row["network_segment"]): row["fraction"] for row in policy_rows if row["side"] == "src"
+    }
+    assert sources[("3", "192.168.10.0/24")] == "1.0000"
+    # Policy 1 only admits 192.168.20.10, so the /24 source is not listed for it.
+    assert ("1", "192.168.10.0/24") not in sources
+    with space_out.open(newline="", encoding="utf-8") as handle:
+        space_rows = list(csv.DictReader(handle))
+    http_to_lab = [
+        (row["src_network_segment"], row["outcome"], row["matched_policy_id"])
+        for row in space_rows
+        if row["dst_network_segment"] == "10.0.0.0/24" and row["service_label"] == "http"
+    ]
+    assert http_to_lab == [("192.168.10.0/24", "ALLOW", "3"), ("192.168.20.10/32", "ALLOW", "4")]
+
+
+def test_traffic_log_adds_hit_counts_and_flags_unused_policies(tmp_path: Path, monkeypatch, capsys):
+    log = tmp_path / "traffic.log"
+    log.write_text(
+        'date=2026-01-05 time=09:00:01 type="traffic" policyid=3 srcip=192.168.10.5 dstip=10.0.0.8 dstport=80\n'
+        'date=2026-01-05 time=09:00:02 type="traffic" policyid=2 srcip=192.168.10.5 dstip=10.0.1.5 dstport=22\n'
+        'date=2026-01-05 time=09:00:03 type="traffic" policyid=3 srcip=192.168.10.6 dstip=10.0.0.9 dstport=80\n',
+        encoding="utf-8",
+    )
+    out = tmp_path / "out.csv"
+    report = tmp_path / "hits.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--traffic-log", str(log), "--hit-count-report", str(report))
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    hits = {row["matched_policy_id"]: row["matched_policy_observed_hits"] for row in rows}
+    assert hits == {"": "", "1": "0", "2": "1", "3": "2", "4": "0"}
+    with report.open(newline="", encoding="utf-8") as handle:
+        unused = [row["policy_id"] for row in csv.DictReader(handle) if row["unused"] == "yes"]
+    assert unused == ["1", "4"]
+    assert "UNUSED: policy 4 (allow-web-http-src-host)" in capsys.readouterr().err
+
+
+def test_exact_mode_reports_sub_ranges_of_partially_covered_segments(tmp_path: Path, monkeypatch):
+    src_csv = tmp_path / "src.csv"
+    src_csv.write_text("Network Segment\n192.168.20.0/24\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--match-mode", "exact", src_csv=src_csv)
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = [row for row in csv.DictReader(handle) if row["service_label"] == "http"]
+    to_lab = [row for row in rows if row["dst_network_segment"] == "10.0.0.0/24"]
+    assert {row["src_network_segment"] for row in to_lab} == {"192.168.20.0/24"}
+    allowed = [(row["src_subrange"], row["matched_policy_id"]) for row in to_lab if row["decision"] == "ALLOW"]
+    assert allowed == [("192.168.20.10/32", "4")]
+    assert sum(ip_network(row["src_subrange"]).num_addresses for row in to_lab) == 256
+
+
+def test_match_mode_conflicts_with_per_dimension_expansion(tmp_path: Path, monkeypatch):
+    with pytest.raises(SystemExit, match="--match-mode cannot be combined"):
+        _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--match-mode", "exact", "--expand-src")
+
+
+def test_aggregate_collapses_repeated_results_into_flow_counts(tmp_path: Path, monkeypatch):
+    src_csv = tmp_path / "src.csv"
+    src_csv.write_text("Network Segment\n192.168.10.0/24\n192.168.10.0/24\n192.168.20.10/32\n", encoding="utf-8")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--aggregate", src_csv=src_csv)
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    counts = {row["src_network_segment"]: set() for row in rows}
+    for row in rows:
+        counts[row["src_network_segment"]].add(row["flow_count"])
+    assert counts == {"192.168.10.0/24": {"2"}, "192.168.20.10/32": {"1"}}
+    assert len(rows) == 2 * 2 * 4
+
+
+def test_match_all_lists_every_matching_policy(tmp_path: Path, monkeypatch):
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--match-all")
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = {
+            (row["src_network_segment"], row["dst_network_segment"], row["service_label"]): row
+            for row in csv.DictReader(handle)
+        }
+    custom = rows[("192.168.20.10/32", "10.0.1.5/32", "custom8002")]
+    assert custom["matched_policy_id"] == "1"
+    assert (custom["all_matched_policy_count"], custom["all_matched_policies"]) == ("2", "1:accept;2:deny")
+    denied = rows[("192.168.10.0/24", "10.0.1.5/32", "http")]
+    assert (denied["all_matched_policy_count"], denied["all_matched_policies"]) == ("1", "2:deny")
+
+
+def test_query_explain_traces_each_policy_for_one_flow(capsys):
+    cli.main(
+        [
+            "query",
+            "explain",
+            "--config",
+            str(SAMPLE / "rules" / "fortigate.conf"),
+            "--src",
+            "192.168.10.7",
+            "--dst",
+            "10.0.1.5",
+            "--port",
+            "8002/tcp",
+        ]
+    )
+
+    printed = capsys.readouterr().out.splitlines()
+    assert printed == [
+        "policy 1 (allow-db-custom-range): no match on src",
+        "policy 2 (deny-all-to-db): matched",
+        "decision: DENY (MATCHED_POLICY) by policy 2",
+    ]
+
+
+def test_topology_reports_first_blocking_device(tmp_path: Path, monkeypatch):
+    (tmp_path / "core.conf").write_text(
+        "config firewall policy\n"
+        "    edit 9\n"
+        '        set srcaddr "all"\n'
+        '        set dstaddr "all"\n'
+        '        set service "DNS"\n'
+        "        set action accept\n"
+        "    next\n"
+        "end\n",
+        encoding="utf-8",
+    )
+    topology = tmp_path / "topology.csv"
+    topology.write_text(
+        "device,config,networks\n"
+        f"edge,{SAMPLE / 'rules' / 'fortigate.conf'},192.168.0.0/16;172.16.0.0/30\n"
+        "core,core.conf,172.16.0.0/30;10.0.0.0/16\n",
+        encoding="utf-8",
+    )
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--topology", str(topology))
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = {
+            (row["src_network_segment"], row["dst_network_segment"], row["service_label"]): row
+            for row in csv.DictReader(handle)
+        }
+    http = rows[("192.168.10.0/24", "10.0.0.0/24", "http")]
+    assert (http["decision"], http["topology_path"]) == ("ALLOW", "edge>core")
+    assert (http["topology_decision"], http["topology_blocking_device"]) == ("DENY", "core")
+    ssh = rows[("192.168.10.0/24", "10.0.0.0/24", "ssh")]
+    assert (ssh["topology_blocking_device"], ssh["topology_blocking_policy_id"]) == ("edge", "")
+
+
+def test_diff_subcommand_reports_semantic_changes(tmp_path: Path, capsys):
+    before = SAMPLE / "rules" / "fortigate.conf"
+    after = tmp_path / "after.conf"
+    after.write_text(before.read_text(encoding="utf-8").replace("8001-8004", "8001-8010"), encoding="utf-8")
+    out = tmp_path / "diff.csv"
+
+    cli.main(["diff", str(before), str(after), "--out", str(out)])
+
+    printed = capsys.readouterr().out.splitlines()
+    assert "space-changed policy 1: services tcp/8001-8004 -> tcp/8001-8010" in printed
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert ("policy", "1", "space-changed") in [(row["kind"], row["name"], row["change"]) for row in rows]
+
+
+def test_diff_subcommand_reports_identical_configs(capsys):
+    config = str(SAMPLE / "rules" / "fortigate.conf")
+
+    cli.main(["diff", config, config])
+
+    assert capsys.readouterr().out == "no differences\n"
+
+
+def test_consolidate_subcommand_suggests_merging_adjacent_policies(tmp_path: Path, capsys):
+    out = tmp_path / "consolidate.csv"
+
+    cli.main(["consolidate", "--config", str(SAMPLE / "rules" / "fortigate.conf"), "--out", str(out)])
+
+    assert capsys.readouterr().out == (
+        "MERGE: policies 3, 4 (accept, same-other-dimensions) -> "
+        "srcaddr SRC_NET_10,SRC_HOST_20_10 dstaddr WEB_NET service HTTP\n"
+    )
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert [(row["policy_ids"], row["dimension"], row["merged_source"]) for row in rows] == [
+        ("3;4", "src", "192.168.10.0/24;192.168.20.10/32")
+    ]
+
+
+def test_policy_stats_report_counts_flows_per_policy(tmp_path: Path, monkeypatch):
+    out = tmp_path / "out.csv"
+    stats = tmp_path / "stats.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--policy-stats", str(stats))
+
+    with stats.open(newline="", encoding="utf-8") as handle:
+        rows = {row["policy_id"]: row for row in csv.DictReader(handle)}
+    assert (rows["3"]["matches"], rows["3"]["allows"]) == ("1", "1")
+    assert (rows["2"]["matches"], rows["2"]["denies"]) == ("7", "7")
+    with out.open(newline="", encoding="utf-8") as handle:
+        total = sum(1 for _ in csv.DictReader(handle))
+    assert sum(int(row["matches"]) for row in rows.values()) == total
+
+
+def test_least_privilege_report_narrows_broad_policies(tmp_path: Path, monkeypatch):
+    config = tmp_path / "fortigate.conf"
+    text = (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8")
+    config.write_text(text.replace('set srcaddr "SRC_NET_10"', 'set srcaddr "all"'), encoding="utf-8")
+    report = tmp_path / "least.csv"
+
+    _run_cli(
+        monkeypatch,
+        "--config",
+        str(config),
+        "--out",
+        str(tmp_path / "out.csv"),
+        "--least-privilege-report",
+        str(report),
+    )
+
+    with report.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    assert [(row["policy_id"], row["broad"], row["suggested_source"]) for row in rows] == [
+        ("3", "src", "192.168.10.0/24;192.168.20.10/32")
+    ]
+
+
+def test_least_privilege_report_rejects_anonymized_runs(tmp_path: Path, monkeypatch):
+    report = tmp_path / "least.csv"
+
+    with pytest.raises(SystemExit, match="cannot be combined with --anonymize"):
+        _run_cli(
+            monkeypatch,
+            "--out",
+            str(tmp_path / "out.csv"),
+            "--least-privilege-report",
+            str(report),
+            "--anonymize",
+            "--anon-key",
+            "secret",
+        )
+    assert not report.exists()
+
+
+def test_dead_rule_report_separates_shadowed_from_unmatched_policies(tmp_path: Path, monkeypatch):
+    config = tmp_path / "fortigate.conf"
+    text = (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8").rstrip()
+    extra = (
+        '    edit 5\n        set srcaddr "SRC_NET_10"\n        set dstaddr "DB_HOST"\n'
+        '        set service "HTTP"\n        set action accept\n    next\n'
+        '    edit 6\n        set srcaddr "all"\n        set dstaddr "WEB_NET"\n'
+        '        set service "HTTPS"\n        set action accept\n    next\n'
+    )
+    config.write_text(text.removesuffix("end") + extra + "end\n", encoding="utf-8")
+    report = tmp_path / "dead.csv"
+
+    _run_cli(
+        monkeypatch, "--config", str(config), "--out", str(tmp_path / "out.csv"), "--dead-rule-report", str(report)
+    )
+
+    with report.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    # Policy 2 denies everything to DB_HOST first; nothing in the inputs uses HTTPS.
+    assert [(row["policy_id"], row["reason"]) for row in rows] == [("5", "shadowed"), ("6", "no-matching-flows")]
+
+
+def test_rpf_check_marks_spoofed_sources_unroutable(tmp_path: Path, monkeypatch):
+    config = tmp_path / "fortigate.conf"
+    config.write_text(
+        (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8")
+        + "config system interface\n"
+        '    edit "port1"\n        set ip 10.0.0.1 255.255.254.0\n    next\n'
+        '    edit "port2"\n        set ip 192.168.10.1 255.255.255.0\n    next\n'
+        '    edit "port3"\n        set ip 203.0.113.2 255.255.255.252\n    next\n'
+        "end\n"
+        "config router static\n"
+        '    edit 1\n        set gateway 203.0.113.1\n        set device "port3"\n    next\n'
+        "end\n",
+        encoding="utf-8",
+    )
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment,Interface\n192.168.10.0/24,port2\n192.168.20.10/32,port2\n")
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--config", str(config), "--out", str(out), "--routing", "--rpf-check", src_csv=src)
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    # 192.168.20.10 routes back out of port3, not the port2 it claims to arrive on.
+    assert {row["reason"] for row in rows if row["src_network_segment"] == "192.168.20.10/32"} == {
+        "RPF_WRONG_INTERFACE"
+    }
+    assert "UNROUTABLE" not in {row["decision"] for row in rows if row["src_network_segment"] == "192.168.10.0/24"}
+
+
+def test_rpf_check_requires_routing(tmp_path: Path, monkeypatch):
+    with pytest.raises(SystemExit, match="--rpf-check requires --routing"):
+        _run_cli(monkeypatch, "--out", str(tmp_path / "out.csv"), "--rpf-check")
+
+
+def test_bidirectional_flags_flows_allowed_one_way_only(tmp_path: Path, monkeypatch):
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--out", str(out), "--bidirectional")
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    # No policy lets the web or database hosts open connections back to the clients.
+    assert {(row["matched_policy_id"], row["reverse_decision"]) for row in rows if row["asymmetric"] == "yes"} == {
+        ("1", "DENY"),
+        ("3", "DENY"),
+        ("4", "DENY"),
+    }
+    assert all(row["reverse_port"] == row["port"] for row in rows)
+
+
+def test_bidirectional_return_port_models_replies_to_ephemeral_ports(tmp_path: Path, monkeypatch):
+    config = tmp_path / "fortigate.conf"
+    config.write_text(
+        (SAMPLE / "rules" / "fortigate.conf").read_text(encoding="utf-8")
+        + "config firewall service custom\n"
+        '    edit "HTTP-REPLY"\n        set tcp-portrange 49152-65535:80\n    next\n'
+        "end\n"
+        "config firewall policy\n"
+        '    edit 5\n        set srcaddr "WEB_NET"\n        set dstaddr "SRC_NET_10"\n'
+        '        set service "HTTP-REPLY"\n        set action accept\n    next\n'
+        "end\n",
+        encoding="utf-8",
+    )
+    out = tmp_path / "out.csv"
+
+    _run_cli(monkeypatch, "--config", str(config), "--out", str(out), "--bidirectional", "--return-port", "49152")
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = {
+            (row["src_network_segment"], row["dst_network_segment"], row["port"]): row
+            for row in csv.DictReader(handle)
+        }
+    reply = rows[("192.168.10.0/24", "10.0.0.0/24", "80")]
+    assert (reply["reverse_port"], reply["reverse_decision"], reply["reverse_policy_id"]) == ("49152", "ALLOW", "5")
+    assert reply["asymmetric"] == "no"
+    assert rows[("192.168.10.0/24", "10.0.0.0/24", "53")]["reverse_port"] == "49152"
+
+
+VDOM_CONFIG = """
+config vdom
+    edit "root"
+    next
+    edit "dmz"
+    next
+    edit "lab"
+    next
+end
+config global
+    config system vdom-link
+        edit "vlink"
+        next
+    end
+    config system interface
+        edit "vlink0"
+            set vdom "root"
+        next
+        edit "vlink1"
+            set vdom "dmz"
+        next
+    end
+end
+config vdom
+    edit "root"
+        config firewall policy
+            edit 1
+                set dstintf "vlink0"
+                set srcaddr "all"
+                set dstaddr "all"
+                set service "ALL"
+                set action accept
+            next
+        end
+    next
+    edit "dmz"
+        config firewall policy
+            edit 7
+                set srcintf "vlink1"
+                set srcaddr "all"
+                set dstaddr "all"
+                set service "HTTPS"
+                set action accept
+            next
+        end
+    next
+end
+"""
+
+
+def test_vdom_links_chain_flows_through_each_vdom(tmp_path: Path, monkeypatch):
+    config = tmp_path / "vdoms.conf"
+    config.write_text(VDOM_CONFIG, encoding="utf-8")
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment,VDOM\n10.0.0.0/24,\n")
+    dst = tmp_path / "dst.csv"
+    dst.write_text("Network Segment,VDOM\n172.16.0.10/32,dmz\n172.16.0.10/32,lab\n")
+    ports = tmp_path / "ports.txt"
+    ports.write_text("https,443/tcp\nssh,22/tcp\n")
+    out = tmp_path / "out.csv"
+
+    cli.main(
+        [
+            "--config",
+            str(config),
+            "--vdom",
+            "root",
+            "--vdom-links",
+            "--src-csv",
+            str(src),
+            "--dst-csv",
+            str(dst),
+            "--ports",
+            str(ports),
+            "--out",
+            str(out),
+        ]
+    )
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        rows = list(csv.DictReader(handle))
+    summary = [
+        (row["port"], row["decision"], row["vdom_path"], row["vdom_decision"], row["vdom_blocking_vdom"])
+        for row in rows
+    ]
+    assert summary == [
+        ("443", "ALLOW", "root>dmz", "ALLOW", ""),
+        ("22", "ALLOW", "root>dmz", "DENY", "dmz"),
+        ("443", "ALLOW", "", "NO_PATH", ""),
+        ("22", "ALLOW", "", "NO_PATH", ""),
+    ]
+
+
+def test_vdom_link_interfaces_match_policies_through_their_zone(tmp_path: Path, monkeypatch):
+    config = tmp_path / "vdoms.conf"
+    config.write_text(
+        VDOM_CONFIG.replace(
+            '    edit "dmz"\n        config firewall policy\n',
+            '    edit "dmz"\n'
+            "        config system zone\n"
+            '            edit "links"\n'
+            '                set interface "vlink1"\n'
+            "            next\n"
+            "        end\n"
+            "        config firewall policy\n",
+        ).replace('set srcintf "vlink1"', 'set srcintf "links"'),
+        encoding="utf-8",
+    )
+    src = tmp_path / "src.csv"
+    src.write_text("Network Segment,VDOM\n10.0.0.0/24,\n")
+    dst = tmp_path / "dst.csv"
+    dst.write_text("Network Segment,VDOM\n172.16.0.10/32,dmz\n")
+    ports = tmp_path / "ports.txt"
+    ports.write_text("https,443/tcp\n")
+    out = tmp_path / "out.csv"
+
+    cli.main(
+        [
+            "--config",
+            str(config),
+            "--vdom",
+            "root",
+            "--vdom-links",
+            "--src-csv",
+            str(src),
+            "--dst-csv",
+            str(dst),
+            "--ports",
+            str(ports),
+            "--out",
+            str(out),
+        ]
+    )
+
+    with out.open(newline="", encoding="utf-8") as handle:
+        (row,) = list(csv.DictReader(handle))
+    assert (row["vdom_path"], row["vdom_decision"]) == ("root>dmz", "ALLOW")
+
+
+def test_unknown_vdom_is_rejected(tmp_path: Path, monkeypatch):
+    config = tmp_path / "vdoms.conf"
+    config.write_text(VDOM_CONFIG, encoding="utf-8")
+
+    with pytest.raises(SystemExit, match="VDOM edge not found; config has root, dmz, lab"):
+        _run_cli(monkeypatch, "--config", str(config), "--vdom", "edge", "--out", str(tmp_path / "out.csv"))
+
+
+def test_jsonl_format_matches_csv_results(tmp_path: Path, monkeypatch):
+    csv_out = tmp_path / "out.csv"
+    jsonl_out = tmp_path / "out.jsonl"
+
+    _run_cli(monkeypatch, "--out", str(csv_out))
+    _run_cli(monkeypatch, "--out", str(jsonl_out), "--format", "jsonl")
+
+    with csv_out.open(newline="", encoding="utf-8") as handle:
+        expected = list(csv.DictReader(handle))
+    records = [json.loads(line) for line in jsonl_out.read_text(encoding="utf-8").splitlines()]
+    assert [{key: str(value) for key, value in record.items()} for record in records] == expected
+
+
+def test_db_out_path_writes_sqlite_results(tmp_path: Path, monkeypatch):
+    out = tmp_path / "results.db"
+
+    _run_cli(monkeypatch, "--out", str(out))
+
+    connection = sqlite3.connect(out)
+    try:
+        allowed = connection.execute(
+            "SELECT matched_policy_id, COUNT(*) FROM results WHERE decision = 'ALLOW' GROUP BY matched_policy_id"
+        ).fetchall()
+        metadata = dict(connection.execute("SELECT key, value FROM run_metadata"))
+    finally:
+        connection.close()
+    assert sorted(allowed) == [("1", 1), ("3", 1), ("4", 1)]
+    assert (metadata["flows"], metadata["rows"], metadata["match_mode"]) == ("16", "16", "segment")
+    assert metadata["rules"].endswith("fortigate.conf")
+
+    with pytest.raises(SystemExit, match="--format does not apply to the SQLite output"):
+        _run_cli(monkeypatch, "--out", str(tmp_path / "other.db"), "--format", "jsonl")
+    assert not (tmp_path / "other.db").exists()
@@ -0,0 +1,153 @@
+"""Tests for the semantic config diff."""
+from __future__ import annotations
+
+from dataclasses import replace
+
+from static_traffic_analyzer.configdiff import diff_configs
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+BEFORE = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+    edit "OLD"
+        set subnet 198.51.100.0 255.255.255.0
+    next
+end
+config firewall service custom
+    edit "APP"
+        set tcp-portrange 8080
+    next
+end
+config firewall policy
+    edit 1
+        set name "lan-web"
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set name "lan-app"
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "APP"
+        set action accept
+    next
+    edit 3
+        set name "old"
+        set srcaddr "LAN"
+        set dstaddr "OLD"
+        set service "ALL"
+        set action accept
+    next
+    edit 4
+        set name "deny"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+AFTER = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.254.0
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall service custom
+    edit "APP"
+        set tcp-portrange 8080-8081
+    next
+end
+config firewall policy
+    edit 1
+        set name "lan-web"
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+        set logtraffic all
+    next
+    edit 2
+        set name "lan-app"
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "APP"
+        set action accept
+    next
+    edit 4
+        set name "deny"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 5
+        set name "new"
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "SSH"
+        set action accept
+    next
+end
+"""
+
+
+def _diff():
+    before = parse_fortigate_config(BEFORE.splitlines())
+    after = parse_fortigate_config(AFTER.splitlines())
+    return diff_configs(
+        before.policies,
+        before.address_book,
+        before.service_book,
+        after.policies,
+        after.address_book,
+        after.service_book,
+    )
+
+
+def test_diff_reports_objects_added_removed_and_changed():
+    changes = [(change.kind, change.name, change.change) for change in _diff() if change.kind != "policy"]
+
+    assert changes == [
+        ("address", "OLD", "removed"),
+        ("address", "LAN", "changed"),
+        ("service", "APP", "changed"),
+    ]
+    app = next(change for change in _diff() if change.name == "APP")
+    assert app.detail == "entries: tcp/8080 -> tcp/8080-8081"
+
+
+def test_diff_reports_policy_changes_and_match_space():
+    changes = {(change.name, change.change): change.detail for change in _diff() if change.kind == "policy"}
+
+    assert changes[("3", "removed")] == "old"
+    assert changes[("5", "added")] == "new"
+    assert changes[("1", "changed")] == "logtraffic: - -> all"
+    # Policy 2's own fields are unchanged; its space changes through the edited LAN and APP objects.
+    assert ("2", "changed") not in changes
+    assert changes[("2", "space-changed")] == "src +10.0.1.0/24; services tcp/8080 -> tcp/8080-8081"
+    assert ("4", "space-changed") not in changes
+
+
+def test_diff_reports_only_policies_taken_out_of_order_as_moved():
+    data = parse_fortigate_config(BEFORE.splitlines())
+    first, second, third, fourth = data.policies
+    reordered = [replace(third, priority=1), replace(first, priority=2), replace(second, priority=3), fourth]
+
+    changes = diff_configs(
+        data.policies, data.address_book, data.service_book, reordered, data.address_book, data.service_book
+    )
+
+    assert [(change.name, change.change, change.detail) for change in changes] == [("3", "moved", "now first")]
@@ -0,0 +1,128 @@
+"""Tests for rule consolidation suggestions."""
+from __future__ import annotations
+
+from static_traffic_analyzer.consolidate import (
+    CONTIGUOUS_PORTS,
+    SAME_OTHER_DIMENSIONS,
+    SIBLING_CIDRS,
+    find_consolidations,
+)
+from static_traffic_analyzer.overlap import format_services
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "LAN-A"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+    edit "LAN-B"
+        set subnet 10.0.1.0 255.255.255.0
+    next
+    edit "LAN-C"
+        set subnet 10.0.9.0 255.255.255.0
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+    edit "DB"
+        set subnet 198.51.100.0 255.255.255.0
+    next
+end
+config firewall service custom
+    edit "APP-1"
+        set tcp-portrange 8000-8009
+    next
+    edit "APP-2"
+        set tcp-portrange 8010-8019
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN-A"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "LAN-B"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 3
+        set status disable
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 4
+        set srcaddr "LAN-C"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 5
+        set srcaddr "LAN-A"
+        set dstaddr "DB"
+        set service "APP-1"
+        set action accept
+    next
+    edit 6
+        set srcaddr "LAN-A"
+        set dstaddr "DB"
+        set service "APP-2"
+        set action accept
+    next
+    edit 7
+        set srcaddr "LAN-A"
+        set dstaddr "DB"
+        set service "SSH"
+        set action deny
+    next
+    edit 8
+        set srcaddr "LAN-B"
+        set dstaddr "DB"
+        set service "SSH"
+        set action deny
+        set logtraffic disable
+    next
+end
+"""
+
+
+def _consolidations():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    return find_consolidations(data.policies, data.address_book, data.service_book)
+
+
+def test_adjacent_policies_differing_in_one_dimension_are_merged():
+    consolidations = _consolidations()
+
+    assert [
+        ([policy.policy_id for policy in consolidation.policies], consolidation.dimension)
+        for consolidation in consolidations
+    ] == [(["1", "2", "4"], "src"), (["5", "6"], "service")]
+
+    sources, services = consolidations
+    assert sources.reason == SIBLING_CIDRS
+    assert [str(block) for block in sources.merged.source] == ["10.0.0.0/23", "10.0.9.0/24"]
+    assert sources.source_names == ("LAN-A", "LAN-B", "LAN-C")
+    assert services.reason == CONTIGUOUS_PORTS
+    assert format_services(services.merged.services) == "tcp/8000-8019"
+    assert services.service_names == ("APP-1", "APP-2")
+
+
+def test_policies_with_different_settings_are_not_merged():
+    # 7 and 8 differ only in source, but 8 does not log traffic.
+    assert all("8" not in [policy.policy_id for policy in entry.policies] for entry in _consolidations())
+
+
+def test_unrelated_sources_are_merged_as_object_lists():
+    config = CONFIG.replace('set subnet 10.0.1.0 255.255.255.0', 'set subnet 172.16.0.0 255.255.255.0')
+    data = parse_fortigate_config(config.splitlines())
+
+    first = find_consolidations(data.policies, data.address_book, data.service_book)[0]
+
+    assert first.reason == SAME_OTHER_DIMENSIONS
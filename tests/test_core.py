@@ -5,13 +5,14 @@ from ipaddress import ip_network
 
 import pytest
 
-from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode, evaluate_policy
 from static_traffic_analyzer.models import (
     AddressBook,
     AddressGroup,
     AddressObject,
     AddressType,
     Decision,
+    MatchOutcome,
     PolicyRule,
     Protocol,
     ServiceBook,
@@ -19,7 +20,7 @@ from static_traffic_analyzer.models import (
     ServiceObject,
     ServiceEntry,
 )
-from static_traffic_analyzer.utils import ParseError, parse_ports_file
+from static_traffic_analyzer.utils import ParseError, PortSpec, find_broad_networks, parse_ports_file
 
 
 def test_parse_ports_file_valid():
@@ -34,6 +35,34 @@ def test_parse_ports_file_invalid():
         parse_ports_file(["bad-line"])
 
 
+def test_parse_ports_file_sctp_entry():
+    assert parse_ports_file(["diameter,3868/sctp"]) == [PortSpec(label="diameter", protocol=Protocol.SCTP, port=3868)]
+
+
+def test_parse_ports_file_icmp_entries():
+    specs = parse_ports_file(["ping,icmp", "unreachable,icmp/3"])
+    assert [(spec.protocol, spec.port) for spec in specs] == [(Protocol.ICMP, 8), (Protocol.ICMP, 3)]
+    with pytest.raises(ParseError):
+        parse_ports_file(["bad,icmp/256"])
+    with pytest.raises(ParseError):
+        parse_ports_file(["bad,8/icmp"])
+
+
+def test_parse_ports_file_source_port():
+    spec = PortSpec(label="ntp", protocol=Protocol.UDP, port=123, src_port=123)
+    assert parse_ports_file(["ntp,123:123/udp"]) == [spec]
+    with pytest.raises(ParseError):
+        parse_ports_file(["bad,123:0/udp"])
+
+
+def test_min_prefix_guard():
+    networks = [ip_network("10.0.0.0/8"), ip_network("192.168.1.0/24")]
+    assert find_broad_networks(networks, 16) == [ip_network("10.0.0.0/8")]
+    assert find_broad_networks(networks, 8) == []
+    with pytest.raises(ParseError):
+        find_broad_networks(networks, 33)
+
+
 def test_cidr_containment():
     address = AddressObject(name="net", address_type=AddressType.IPMASK, subnet=ip_network("10.0.0.0/16"))
     book = AddressBook(objects={"net": address})
@@ -201,3 +230,202 @@ def test_implicit_deny():
         ignore_schedule=False,
     )
     assert result.decision == Decision.DENY
+
+
+def test_warm_ports_matches_cold_evaluation():
+    address_book = AddressBook(
+        objects={
+            "all": AddressObject("all", AddressType.IPMASK, subnet=ip_network("0.0.0.0/0")),
+            "lan": AddressObject("lan", AddressType.IPMASK, subnet=ip_network("10.0.0.0/16")),
+        }
+    )
+    service_book = ServiceBook(
+        services={
+            "ALL": ServiceObject("ALL", (ServiceEntry(None, None, None),)),
+            "HTTP": ServiceObject("HTTP", (ServiceEntry(Protocol.TCP, 80, 80),)),
+            "DNS": ServiceObject("DNS", (ServiceEntry(Protocol.UDP, 53, 53),)),
+        }
+    )
+
+    def rule(policy_id: str, source: str, service: str, action: str, enabled: bool = True) -> PolicyRule:
+        return PolicyRule(policy_id, policy_id, int(policy_id), (source,), ("all",), (service,), action, enabled, "always")
+
+    policies = [
+        rule("1", "lan", "HTTP", "accept"),
+        rule("2", "all", "DNS", "accept"),
+        rule("3", "all", "HTTP", "deny", enabled=False),
+        rule("4", "lan", "ALL", "deny"),
+    ]
+    ports = [
+        PortSpec("http", Protocol.TCP, 80),
+        PortSpec("dns", Protocol.UDP, 53),
+        PortSpec("ssh", Protocol.TCP, 22),
+    ]
+    mode = MatchMode(mode="segment", max_hosts=256)
+    cold = Evaluator(policies, address_book, service_book, mode)
+    warm = Evaluator(policies, address_book, service_book, mode)
+    warm.warm_ports(ports)
+
+    lan, anywhere = ip_network("10.0.1.0/24"), ip_network("172.16.0.0/24")
+    assert [policy.policy_id for policy in warm.flow_candidates(lan, anywhere, Protocol.TCP, 80)] == ["1", "4"]
+    assert [policy.policy_id for policy in warm.flow_candidates(lan, anywhere, Protocol.TCP, 22)] == ["4"]
+    assert set(warm._candidates) == {(spec.protocol, spec.port) for spec in ports}
+    assert not cold._candidates
+
+    for src in ("10.0.1.0/24", "192.168.0.0/24"):
+        for spec in ports:
+            args = (ip_network(src), ip_network("172.16.0.0/24"), spec.protocol, spec.port)
+            assert warm.evaluate(*args) == cold.evaluate(*args)
+
+
+def test_expand_destination_only_samples_source():
+    address_book = AddressBook(
+        objects={
+            "half": AddressObject("half", AddressType.IPMASK, subnet=ip_network("10.0.0.0/25")),
+            "dst": AddressObject("dst", AddressType.IPMASK, subnet=ip_network("10.1.0.0/24")),
+        }
+    )
+    service_book = ServiceBook(services={"ALL": ServiceObject("ALL", (ServiceEntry(None, None, None),))})
+    rule = PolicyRule("1", "1", 1, ("half",), ("dst",), ("ALL",), "accept", True, "always")
+
+    def decide(mode: MatchMode) -> Decision:
+        evaluator = Evaluator([rule], address_book, service_book, mode)
+        return evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("10.1.0.0/24"), Protocol.TCP, 22).decision
+
+    expand_dst = MatchMode(mode="sample-ip", max_hosts=256, dst_mode="expand")
+    expand_src = MatchMode(mode="expand", max_hosts=256, dst_mode="sample-ip")
+
+    assert expand_dst.for_destination().mode == "expand"
+    assert decide(expand_dst) == Decision.ALLOW
+    assert decide(expand_src) == Decision.DENY
+    assert decide(MatchMode(mode="expand", max_hosts=256)) == Decision.DENY
+
+
+def test_expand_walks_hosts_only_on_partial_overlap():
+    address_book = AddressBook(
+        objects={
+            "usable": AddressObject(
+                "usable",
+                AddressType.IPRANGE,
+                start_ip=ip_network("10.0.0.1/32").network_address,
+                end_ip=ip_network("10.0.0.254/32").network_address,
+            ),
+            "wide": AddressObject("wide", AddressType.IPMASK, subnet=ip_network("172.16.0.0/12")),
+            "all": AddressObject("all", AddressType.IPMASK, subnet=ip_network("0.0.0.0/0")),
+        }
+    )
+    service_book = ServiceBook(services={"ALL": ServiceObject("ALL", (ServiceEntry(None, None, None),))})
+    policies = [
+        PolicyRule("1", "1", 1, ("usable",), ("all",), ("ALL",), "accept", True, "always"),
+        PolicyRule("2", "2", 2, ("wide",), ("all",), ("ALL",), "accept", True, "always"),
+    ]
+    # Large enough that walking a /12 host by host would be prohibitive.
+    evaluator = Evaluator(policies, address_book, service_book, MatchMode(mode="expand", max_hosts=1 << 24))
+
+    def matched(src: str) -> str | None:
+        return evaluator.evaluate(ip_network(src), ip_network("192.0.2.0/24"), Protocol.TCP, 22).matched_policy_id
+
+    # The range skips the network and broadcast addresses, which expand mode never samples.
+    assert matched("10.0.0.0/24") == "1"
+    assert matched("10.0.0.0/23") is None
+    assert matched("172.16.0.0/12") == "2"
+    assert matched("192.168.0.0/16") is None
+
+
+def test_near_miss_reports_policy_differing_only_in_service():
+    address_book = AddressBook(
+        objects={
+            "lan": AddressObject("lan", AddressType.IPMASK, subnet=ip_network("10.0.0.0/24")),
+            "web": AddressObject("web", AddressType.IPMASK, subnet=ip_network("10.1.0.0/24")),
+            "other": AddressObject("other", AddressType.IPMASK, subnet=ip_network("10.9.0.0/24")),
+        }
+    )
+    service_book = ServiceBook(
+        services={
+            "HTTP": ServiceObject("HTTP", (ServiceEntry(Protocol.TCP, 80, 80),)),
+            "SSH": ServiceObject("SSH", (ServiceEntry(Protocol.TCP, 22, 22),)),
+        }
+    )
+    policies = [
+        PolicyRule("1", "web-http", 1, ("lan",), ("web",), ("HTTP",), "accept", True, "always"),
+        PolicyRule("2", "other-ssh", 2, ("lan",), ("other",), ("SSH",), "accept", True, "always"),
+        PolicyRule("3", "other-http", 3, ("other",), ("other",), ("HTTP",), "accept", True, "always"),
+        PolicyRule("4", "deny-web-ssh", 4, ("lan",), ("web",), ("SSH",), "deny", True, "always"),
+    ]
+    evaluator = Evaluator(policies, address_book, service_book, MatchMode(mode="segment", max_hosts=256))
+    flow = (ip_network("10.0.0.0/24"), ip_network("10.1.0.0/24"), Protocol.TCP, 443)
+
+    assert evaluator.evaluate(*flow).decision == Decision.DENY
+    misses = evaluator.near_misses(*flow)
+    assert [(policy.policy_id, dimension) for policy, dimension in misses] == [("1", "service")]
+
+
+def test_all_matches_lists_every_matching_policy_in_priority_order():
+    address_book = AddressBook(
+        objects={
+            "lan": AddressObject("lan", AddressType.IPMASK, subnet=ip_network("10.0.0.0/16")),
+            "host": AddressObject("host", AddressType.IPMASK, subnet=ip_network("10.0.0.5/32")),
+            "web": AddressObject("web", AddressType.IPMASK, subnet=ip_network("10.1.0.0/24")),
+        }
+    )
+    service_book = ServiceBook(
+        services={
+            "HTTP": ServiceObject("HTTP", (ServiceEntry(Protocol.TCP, 80, 80),)),
+            "SSH": ServiceObject("SSH", (ServiceEntry(Protocol.TCP, 22, 22),)),
+        }
+    )
+    policies = [
+        PolicyRule("1", "host-http", 1, ("host",), ("web",), ("HTTP",), "accept", True, "always"),
+        PolicyRule("2", "lan-ssh", 2, ("lan",), ("web",), ("SSH",), "accept", True, "always"),
+        PolicyRule("3", "old", 3, ("lan",), ("web",), ("HTTP",), "accept", False, "always"),
+        PolicyRule("4", "deny-lan-web", 4, ("lan",), ("web",), ("HTTP",), "deny", True, "always"),
+    ]
+    evaluator = Evaluator(policies, address_book, service_book, MatchMode(mode="segment", max_hosts=256))
+
+    flow = (ip_network("10.0.0.5/32"), ip_network("10.1.0.0/24"), Protocol.TCP, 80)
+
+    assert evaluator.evaluate(*flow).matched_policy_id == "1"
+    matches = evaluator.all_matches(*flow)
+    assert [(match.matched_policy_id, match.decision) for match in matches] == [
+        ("1", Decision.ALLOW),
+        ("4", Decision.DENY),
+    ]
+
+
+def test_evaluate_explain_reports_per_dimension_results_for_near_miss():
+    address_book = AddressBook(
+        objects={
+            "lan": AddressObject("lan", AddressType.IPMASK, subnet=ip_network("10.0.0.0/24")),
+            "web": AddressObject("web", AddressType.IPMASK, subnet=ip_network("10.1.0.0/24")),
+        }
+    )
+    service_book = ServiceBook(
+        services={
+            "HTTP": ServiceObject("HTTP", (ServiceEntry(Protocol.TCP, 80, 80),)),
+            "SSH": ServiceObject("SSH", (ServiceEntry(Protocol.TCP, 22, 22),)),
+        }
+    )
+    policies = [
+        PolicyRule("1", "web-http", 1, ("lan",), ("web",), ("HTTP",), "accept", True, "always"),
+        PolicyRule("2", "old", 2, ("lan",), ("web",), ("SSH",), "accept", False, "always"),
+        PolicyRule("3", "deny-web-ssh", 3, ("lan",), ("web",), ("SSH",), "deny", True, "always"),
+        PolicyRule("4", "after", 4, ("all",), ("all",), ("SSH",), "accept", True, "always"),
+    ]
+    evaluator = Evaluator(policies, address_book, service_book, MatchMode(mode="segment", max_hosts=256))
+
+    flow = (ip_network("10.0.0.0/24"), ip_network("10.1.0.0/24"), Protocol.TCP, 22)
+
+    detail, explanation = evaluator.evaluate_explain(*flow)
+
+    assert detail.decision == Decision.DENY
+    assert explanation.reason == "MATCHED_POLICY"
+    assert [check.policy_id for check in explanation.checks] == ["1", "2", "3"]
+    near_miss, disabled, deciding = explanation.checks
+    assert (near_miss.source, near_miss.destination, near_miss.service) == (
+        MatchOutcome.MATCH,
+        MatchOutcome.MATCH,
+        MatchOutcome.NO_MATCH,
+    )
+    assert not near_miss.matched
+    assert disabled.skipped == "disabled"
+    assert deciding.matched
@@ -0,0 +1,78 @@
+"""Tests for address-space coverage analysis."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.coverage import policy_coverage, space_coverage
+from static_traffic_analyzer.models import Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.utils import PortSpec
+
+
+CONFIG = """
+config firewall address
+    edit "LAN_LOW"
+        set subnet 10.0.0.0 255.255.255.128
+    next
+    edit "QUARANTINE"
+        set subnet 10.0.0.64 255.255.255.192
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "QUARANTINE"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 2
+        set srcaddr "LAN_LOW"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+        set schedule "office-hours"
+    next
+end
+"""
+
+LAN = ip_network("10.0.0.0/24")
+WEB = ip_network("192.0.2.0/25")
+HTTPS = PortSpec(label="https", protocol=Protocol.TCP, port=443)
+
+
+def test_policy_coverage_reports_fraction_of_each_input():
+    data = parse_fortigate_config(CONFIG.splitlines())
+
+    coverage = policy_coverage(data.policies, data.address_book, data.service_book, [LAN], [WEB])
+
+    assert [(entry.policy.policy_id, entry.side, entry.covered, entry.fraction) for entry in coverage] == [
+        ("1", "src", 64, 0.25),
+        ("1", "dst", 128, 1.0),
+        ("2", "src", 128, 0.5),
+        ("2", "dst", 128, 1.0),
+    ]
+
+
+def test_space_coverage_splits_the_pair_by_first_matching_policy():
+    data = parse_fortigate_config(CONFIG.splitlines())
+
+    regions = space_coverage(data.policies, data.address_book, data.service_book, [LAN], [WEB], [HTTPS])
+
+    assert [
+        (region.outcome, region.policy.policy_id if region.policy else None, region.src_blocks, region.fraction)
+        for region in regions
+    ] == [
+        ("DENY", "1", (ip_network("10.0.0.64/26"),), 0.25),
+        ("ALLOW", "2", (ip_network("10.0.0.0/26"),), 0.25),
+        ("IMPLICIT_DENY", None, (ip_network("10.0.0.128/25"),), 0.5),
+    ]
+    assert sum(region.fraction for region in regions) == 1.0
@@ -0,0 +1,74 @@
+"""Tests for the CSV rules directory parser."""
+from __future__ import annotations
+
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.csv_rules import parse_csv_rules
+from static_traffic_analyzer.utils import ParseError
+
+
+FILES = {
+    "addresses.csv": (
+        "name,type,subnet,start_ip,end_ip\n"
+        "LAN,ipmask,10.0.0.0/24,,\n"
+        "WEB1,ipmask,10.1.0.10/32,,\n"
+        "WEB2,iprange,,10.1.0.20,10.1.0.29\n"
+    ),
+    "services.csv": "name,protocol,ports\nWEB_PORTS,tcp,443\nWEB_PORTS,tcp,8000-8100\nSYSLOG,udp,514\n",
+    "groups.csv": "name,kind,members\nWEB,address,WEB1;WEB2\nWEB_SVC,service,WEB_PORTS;SYSLOG\n",
+    "policies.csv": (
+        "id,name,source,destination,service,action,enabled,comment\n"
+        "10,lan-to-web,LAN,WEB,WEB_SVC,accept,true,web access\n"
+        "20,old,all,all,ALL,accept,false,\n"
+        "30,deny-rest,all,all,ALL,deny,,\n"
+    ),
+}
+
+
+def _write(directory: Path, files: dict[str, str]) -> Path:
+    for name, content in files.items():
+        (directory / name).write_text(content, encoding="utf-8")
+    return directory
+
+
+def test_csv_rules_objects_groups_and_policies(tmp_path: Path, evaluate):
+    data = parse_csv_rules(_write(tmp_path, FILES))
+
+    assert [policy.policy_id for policy in data.policies] == ["10", "20", "30"]
+    assert [policy.enabled for policy in data.policies] == [True, False, True]
+    assert data.policies[0].comment == "web access"
+
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8050)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "10")
+    syslog = evaluate(data, "10.1.0.10/32", Protocol.UDP, 514)
+    assert (syslog.decision, syslog.matched_policy_id) == (Decision.ALLOW, "10")
+    denied = evaluate(data, "10.1.0.10/32", Protocol.TCP, 22)
+    assert (denied.decision, denied.matched_policy_id) == (Decision.DENY, "30")
+
+
+def test_csv_rules_policies_only(tmp_path: Path, evaluate):
+    policies = "id,source,destination,service,action\n1,all,all,HTTPS,accept\n"
+    data = parse_csv_rules(_write(tmp_path, {"policies.csv": policies}))
+
+    result = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443)
+    assert (result.decision, result.matched_policy_id) == (Decision.ALLOW, "1")
+
+
+def test_csv_rules_reports_file_and_line(tmp_path: Path):
+    files = {**FILES, "services.csv": "name,protocol,ports\nBAD,tcp,70000\n"}
+    with pytest.raises(ParseError, match="services.csv line 2"):
+        parse_csv_rules(_write(tmp_path, files))
+
+
+def test_csv_rules_rejects_unknown_action(tmp_path: Path):
+    policies = "id,source,destination,service,action\n1,all,all,HTTPS,accept\n2,all,all,ALL,allow\n"
+    with pytest.raises(ParseError, match="policies.csv line 3: action must be accept or deny, got 'allow'"):
+        parse_csv_rules(_write(tmp_path, {"policies.csv": policies}))
+
+
+def test_csv_rules_requires_policies_file(tmp_path: Path):
+    with pytest.raises(ParseError, match="policies.csv"):
+        parse_csv_rules(tmp_path)
@@ -0,0 +1,87 @@
+"""Tests for the MariaDB and SQLite parsers."""
+from __future__ import annotations
+
+import os
+import sqlite3
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.db import EXPECTED_SCHEMA, check_schema, diff_schema, parse_sqlite
+from static_traffic_analyzer.utils import ParseError
+
+
+def test_diff_schema_reports_missing_table_and_column():
+    actual = {table: set(columns) for table, columns in EXPECTED_SCHEMA.items()}
+    actual["cfg_policy"].discard("is_enabled")
+    del actual["cfg_service_group"]
+
+    assert diff_schema(actual) == [
+        "missing table: cfg_service_group",
+        "missing column: cfg_policy.is_enabled",
+    ]
+
+
+@pytest.mark.skipif("STA_TEST_DB_DSN" not in os.environ, reason="set STA_TEST_DB_DSN to a disposable MariaDB database")
+def test_check_schema_reports_missing_column():
+    connector = pytest.importorskip("mysql.connector")
+    dsn = os.environ["STA_TEST_DB_DSN"]
+    connection = connector.connect(dsn=dsn)
+    cursor = connection.cursor()
+    cursor.execute("DROP TABLE IF EXISTS cfg_address_group")
+    cursor.execute("CREATE TABLE cfg_address_group (group_name VARCHAR(255))")
+    try:
+        assert "missing column: cfg_address_group.members" in check_schema(dsn)
+    finally:
+        cursor.execute("DROP TABLE IF EXISTS cfg_address_group")
+        cursor.close()
+        connection.close()
+
+
+def test_parse_sqlite_reads_cfg_tables(tmp_path):
+    path = tmp_path / "rules.db"
+    connection = sqlite3.connect(path)
+    connection.executescript(
+        """
+        CREATE TABLE cfg_address (object_name TEXT, address_type TEXT, subnet TEXT, start_ip TEXT, end_ip TEXT);
+        CREATE TABLE cfg_address_group (group_name TEXT, members TEXT);
+        CREATE TABLE cfg_service_group (group_name TEXT, members TEXT);
+        CREATE TABLE cfg_policy (
+            priority INTEGER, src_objects TEXT, dst_objects TEXT, service_object TEXT,
+            action TEXT, is_enabled INTEGER, log_traffic INTEGER, comments TEXT
+        );
+        INSERT INTO cfg_address VALUES ('LAN', 'ipmask', '10.0.0.0/24', NULL, NULL);
+        INSERT INTO cfg_address VALUES ('WEB', 'iprange', NULL, '192.168.1.10', '192.168.1.20');
+        INSERT INTO cfg_address_group VALUES ('SERVERS', '["WEB"]');
+        INSERT INTO cfg_service_group VALUES ('SG_WEB', '["tcp_443"]');
+        INSERT INTO cfg_policy VALUES (20, '["all"]', '["all"]', 'ALL', 'deny', 1, 0, NULL);
+        INSERT INTO cfg_policy VALUES (10, '["LAN"]', '["SERVERS"]', '["SG_WEB"]', 'accept', 1, 1, 'web');
+        """
+    )
+    connection.commit()
+    connection.close()
+
+    data = parse_sqlite(str(path))
+
+    assert [policy.policy_id for policy in data.policies] == ["10", "20"]
+    assert data.policies[0].comment == "web"
+    mode = MatchMode(mode="segment", max_hosts=256)
+    result = evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network("10.0.0.0/24"),
+        ip_network("192.168.1.12/32"),
+        Protocol.TCP,
+        443,
+        mode,
+        ignore_schedule=False,
+    )
+    assert (result.decision, result.matched_policy_id) == (Decision.ALLOW, "10")
+
+
+def test_parse_sqlite_missing_file(tmp_path):
+    with pytest.raises(ParseError, match="not found"):
+        parse_sqlite(str(tmp_path / "missing.db"))
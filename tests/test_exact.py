@@ -0,0 +1,76 @@
+"""Tests for exact evaluation over sub-ranges."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.exact import exact_subranges, split_network
+from static_traffic_analyzer.models import Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "APP"
+        set subnet 10.0.0.0 255.255.255.192
+    next
+    edit "JUMP"
+        set subnet 10.0.0.70 255.255.255.255
+    next
+    edit "DMZ"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "APP"
+        set dstaddr "DMZ"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "JUMP"
+        set dstaddr "DMZ"
+        set service "ALL"
+        set action accept
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "DMZ"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+
+def test_split_network_stops_where_no_boundary_cuts_through():
+    pieces = split_network(ip_network("10.0.0.0/24"), [ip_network("10.0.0.0/26"), ip_network("10.0.0.0/8")])
+
+    assert pieces == [ip_network("10.0.0.0/26"), ip_network("10.0.0.64/26"), ip_network("10.0.0.128/25")]
+
+
+def test_exact_subranges_are_uniform_and_merged_by_outcome():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    evaluator = Evaluator(
+        data.policies, data.address_book, data.service_book, MatchMode(mode="exact", max_hosts=256)
+    )
+
+    subranges = exact_subranges(evaluator, ip_network("10.0.0.0/24"), ip_network("192.0.2.0/24"), Protocol.TCP, 443)
+
+    decided = [
+        (str(src), str(dst), evaluator.evaluate(src, dst, Protocol.TCP, 443).matched_policy_id)
+        for src, dst in subranges
+    ]
+    assert decided == [
+        ("10.0.0.0/26", "192.0.2.0/24", "1"),
+        ("10.0.0.64/30", "192.0.2.0/24", "3"),
+        ("10.0.0.68/31", "192.0.2.0/24", "3"),
+        ("10.0.0.70/32", "192.0.2.0/24", "2"),
+        ("10.0.0.71/32", "192.0.2.0/24", "3"),
+        ("10.0.0.72/29", "192.0.2.0/24", "3"),
+        ("10.0.0.80/28", "192.0.2.0/24", "3"),
+        ("10.0.0.96/27", "192.0.2.0/24", "3"),
+        ("10.0.0.128/25", "192.0.2.0/24", "3"),
+    ]
+    assert sum(src.num_addresses for src, _ in subranges) == 256
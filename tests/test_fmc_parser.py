@@ -0,0 +1,120 @@
+"""Tests for the Cisco FMC access control policy parser."""
+from __future__ import annotations
+
+import json
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.fmc import parse_fmc_export
+
+
+EXPORT = {
+    "hosts": [{"name": "WEB1", "type": "Host", "value": "10.1.0.10"}],
+    "networks": [{"name": "LAN", "type": "Network", "value": "10.0.0.0/24"}],
+    "ranges": {"items": [{"name": "WEB_POOL", "type": "Range", "value": "10.1.0.20-10.1.0.29"}]},
+    "networkgroups": [{"name": "WEB", "objects": [{"name": "WEB1"}, {"name": "WEB_POOL"}]}],
+    "protocolportobjects": [
+        {"name": "HTTPS", "protocol": "TCP", "port": "443"},
+        {"name": "ALT", "protocol": "TCP", "port": "8000-8100"},
+    ],
+    "portobjectgroups": [{"name": "WEB_PORTS", "objects": [{"name": "HTTPS"}, {"name": "ALT"}]}],
+    "accessrules": [
+        {
+            "name": "monitor-all",
+            "action": "MONITOR",
+        },
+        {
+            "name": "lan-to-web",
+            "action": "ALLOW",
+            "enabled": True,
+            "sourceNetworks": {"objects": [{"name": "LAN", "type": "Network"}]},
+            "destinationNetworks": {"objects": [{"name": "WEB", "type": "NetworkGroup"}]},
+            "destinationPorts": {"objects": [{"name": "WEB_PORTS"}]},
+        },
+        {
+            "name": "dns-literal",
+            "action": "TRUST",
+            "sourceNetworks": {"literals": [{"type": "Network", "value": "10.0.0.0/24"}]},
+            "destinationNetworks": {"literals": [{"type": "Host", "value": "192.0.2.53"}]},
+            "destinationPorts": {"literals": [{"type": "PortLiteral", "protocol": "17", "port": "53"}]},
+        },
+        {
+            "name": "social",
+            "action": "BLOCK",
+            "applications": {"applications": [{"name": "Facebook"}]},
+        },
+    ],
+    "defaultAction": {"action": "BLOCK"},
+}
+
+CSV_EXPORT = """Name,Action,Enabled,Source Networks,Destination Networks,Destination Ports
+lan-web,Allow,true,10.0.0.0/24,"10.1.0.10;10.1.0.20-10.1.0.29","TCP (6):443
+TCP (6)/8000-8100"
+old,Allow,false,any,any,any
+deny-all,Block,true,any,any,any
+"""
+
+
+def test_fmc_json_objects_rules_and_default_action(evaluate):
+    data = parse_fmc_export(json.dumps(EXPORT).splitlines(keepends=True))
+
+    assert [policy.policy_id for policy in data.policies] == ["2", "3", "4", "default"]
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8050)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "2")
+    dns = evaluate(data, "192.0.2.53/32", Protocol.UDP, 53)
+    assert (dns.decision, dns.matched_policy_id) == (Decision.ALLOW, "3")
+    app_rule = evaluate(data, "198.51.100.1/32", Protocol.TCP, 443)
+    assert app_rule.decision == Decision.UNKNOWN
+
+
+def test_fmc_csv_export_literals(evaluate):
+    data = parse_fmc_export(CSV_EXPORT.splitlines(keepends=True))
+
+    assert [policy.enabled for policy in data.policies] == [True, False, True]
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8050)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "1")
+    denied = evaluate(data, "10.1.0.10/32", Protocol.TCP, 22)
+    assert (denied.decision, denied.matched_policy_id) == (Decision.DENY, "3")
+
+
+def test_fmc_zones_scope_rules_and_source_ports_stay_unknown(evaluate):
+    export = {
+        "accessrules": [
+            {
+                "name": "inside-out",
+                "action": "ALLOW",
+                "sourceZones": {"objects": [{"name": "inside", "type": "SecurityZone"}]},
+                "destinationZones": {"objects": [{"name": "outside", "type": "SecurityZone"}]},
+            },
+            {
+                "name": "ntp-from-123",
+                "action": "ALLOW",
+                "sourcePorts": {"literals": [{"type": "PortLiteral", "protocol": "17", "port": "123"}]},
+            },
+        ],
+        "defaultAction": {"action": "BLOCK"},
+    }
+    data = parse_fmc_export(json.dumps(export).splitlines(keepends=True))
+
+    assert (data.policies[0].src_interfaces, data.policies[0].dst_interfaces) == (("inside",), ("outside",))
+    outbound = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443, ingress="inside", egress="outside")
+    assert (outbound.decision, outbound.matched_policy_id) == (Decision.ALLOW, "1")
+    inbound = evaluate(data, "192.0.2.1/32", Protocol.UDP, 123, ingress="outside", egress="inside")
+    assert inbound.decision == Decision.UNKNOWN
+
+
+def test_fmc_csv_application_and_source_port_columns_stay_unknown(evaluate):
+    data = parse_fmc_export(
+        """Name,Action,Source Zones,Source Networks,Destination Networks,Destination Ports,Source Ports,Applications
+lan-only,Allow,inside,any,any,TCP (6):22,,
+web-apps,Allow,,any,any,TCP (6):443,,Facebook
+ntp,Allow,,any,any,UDP (17):123,UDP (17):123,
+deny-all,Block,,any,any,any,,
+""".splitlines(keepends=True)
+    )
+
+    assert evaluate(data, "192.0.2.1/32", Protocol.TCP, 443).decision == Decision.UNKNOWN
+    assert evaluate(data, "192.0.2.1/32", Protocol.UDP, 123).decision == Decision.UNKNOWN
+    assert data.policies[0].src_interfaces == ("inside",)
+    ssh = evaluate(data, "192.0.2.1/32", Protocol.TCP, 22, ingress="inside")
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.ALLOW, "1")
+    assert evaluate(data, "192.0.2.1/32", Protocol.TCP, 22, ingress="outside").decision == Decision.UNKNOWN
@@ -0,0 +1,192 @@
+"""Tests for the FortiGate REST API provider."""
+from __future__ import annotations
+
+import json
+import threading
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from ipaddress import ip_network
+from urllib.parse import parse_qs, urlparse
+
+import pytest
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate_api import APIOptions, parse_fortigate_api
+from static_traffic_analyzer.utils import ParseError
+
+
+TOKEN = "test-token"
+
+TABLES = {
+    "system/settings": [{"central-nat": "enable", "opmode": "nat"}],
+    "firewall/address": [
+        {"name": "LAN", "q_origin_key": "LAN", "type": "ipmask", "subnet": "10.0.0.0 255.255.255.0"},
+        {"name": "WEB1", "type": "ipmask", "subnet": "192.168.1.10 255.255.255.255"},
+        {"name": "WEB2", "type": "iprange", "start-ip": "192.168.1.20", "end-ip": "192.168.1.29"},
+    ],
+    "firewall/addrgrp": [
+        {"name": "WEB", "member": [{"name": "WEB1", "q_origin_key": "WEB1"}, {"name": "WEB2"}]},
+    ],
+    "firewall.service/custom": [
+        {"name": "WEB_PORTS", "tcp-portrange": "80 443", "udp-portrange": ""},
+    ],
+    "firewall.service/group": [],
+    "firewall.service/category": [],
+    "firewall/address6": [],
+    "firewall/addrgrp6": [],
+    "firewall/vip": [],
+    "firewall/local-in-policy": [],
+    "firewall/DoS-policy": [],
+    "system/interface": [],
+    "router/static": [],
+    "router/policy": [
+        {"seq-num": 1, "src": [{"subnet": "10.0.0.0/255.255.255.0"}], "dst": [], "output-device": "wan2"},
+    ],
+    "system/zone": [{"name": "INSIDE", "interface": [{"interface-name": "port1"}, {"interface-name": "port2"}]}],
+    "firewall/ippool": [],
+    "firewall.schedule/recurring": [],
+    "firewall.schedule/onetime": [],
+    "firewall.schedule/group": [],
+    "firewall/policy": [
+        {
+            "policyid": 7,
+            "name": "lan-to-web",
+            "srcaddr": [{"name": "LAN"}],
+            "dstaddr": [{"name": "WEB"}],
+            "service": [{"name": "WEB_PORTS"}],
+            "action": "accept",
+            "status": "enable",
+            "schedule": "always",
+        },
+        {
+            "policyid": 8,
+            "name": "deny-rest",
+            "srcaddr": [{"name": "all"}],
+            "dstaddr": [{"name": "all"}],
+            "service": [{"name": "ALL"}],
+            "action": "deny",
+            "status": "enable",
+            "schedule": "always",
+        },
+    ],
+}
+
+
+class _Handler(BaseHTTPRequestHandler):
+    requests: list[str] = []
+    tables: dict[str, list[dict]] = TABLES
+
+    def do_GET(self) -> None:  # noqa: N802 - http.server naming
+        url = urlparse(self.path)
+        type(self).requests.append(self.path)
+        if self.headers.get("Authorization") != f"Bearer {TOKEN}":
+            self.send_response(401)
+            self.end_headers()
+            return
+        table = type(self).tables.get(url.path.removeprefix("/api/v2/cmdb/"))
+        if table is None:
+            self.send_response(404)
+            self.end_headers()
+            return
+        query = parse_qs(url.query)
+        start = int(query["start"][0])
+        count = int(query["count"][0])
+        body = json.dumps({"status": "success", "results": table[start : start + count]}).encode()
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.end_headers()
+        self.wfile.write(body)
+
+    def log_message(self, *args: object) -> None:
+        pass
+
+
+def _serve(tables: dict[str, list[dict]] = TABLES) -> HTTPServer:
+    _Handler.requests = []
+    _Handler.tables = tables
+    server = HTTPServer(("127.0.0.1", 0), _Handler)
+    threading.Thread(target=server.serve_forever, daemon=True).start()
+    return server
+
+
+def _base_url(server: HTTPServer) -> str:
+    host, port = server.server_address[:2]
+    return f"http://{host}:{port}"
+
+
+def test_fortigate_api_builds_policies_across_pages():
+    server = _serve()
+    try:
+        data = parse_fortigate_api(APIOptions(base_url=_base_url(server), token=TOKEN, page_size=1))
+    finally:
+        server.shutdown()
+
+    assert [policy.policy_id for policy in data.policies] == ["7", "8"]
+    assert data.address_book.groups["WEB"].members == ("WEB1", "WEB2")
+    assert data.zones["INSIDE"].interfaces == ("port1", "port2")
+    assert [(route.src, route.output_device) for route in data.policy_routes] == [
+        ((ip_network("10.0.0.0/24"),), "wan2")
+    ]
+    assert any("start=1&count=1" in path for path in _Handler.requests if "firewall/policy" in path)
+
+    mode = MatchMode(mode="segment", max_hosts=256)
+    allowed = evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network("10.0.0.0/24"),
+        ip_network("192.168.1.20/30"),
+        Protocol.TCP,
+        443,
+        mode,
+        ignore_schedule=False,
+    )
+    assert allowed.decision == Decision.ALLOW
+    assert allowed.matched_policy_id == "7"
+
+    denied = evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network("10.0.0.0/24"),
+        ip_network("192.168.1.10/32"),
+        Protocol.TCP,
+        22,
+        mode,
+        ignore_schedule=False,
+    )
+    assert denied.decision == Decision.DENY
+    assert denied.matched_policy_id == "8"
+
+
+def test_fortigate_api_rejects_bad_token():
+    server = _serve()
+    try:
+        with pytest.raises(ParseError, match="401"):
+            parse_fortigate_api(APIOptions(base_url=_base_url(server), token="wrong"))
+    finally:
+        server.shutdown()
+
+
+def test_fortigate_api_reads_vdom_and_optional_tables():
+    tables = {
+        **TABLES,
+        "firewall/central-snat-map": [
+            {
+                "policyid": 3,
+                "orig-addr": [{"name": "LAN"}],
+                "dst-addr": [{"name": "all"}],
+                "nat-ippool": [{"name": "P1"}],
+            }
+        ],
+    }
+    server = _serve(tables)
+    try:
+        data = parse_fortigate_api(APIOptions(base_url=_base_url(server), token=TOKEN, vdom="edge"))
+    finally:
+        server.shutdown()
+
+    assert all("vdom=edge" in path for path in _Handler.requests)
+    assert [(rule.rule_id, rule.nat_ippool) for rule in data.snat_rules] == [("3", ("P1",))]
+    assert data.central_nat
+    assert data.multicast_policies == []
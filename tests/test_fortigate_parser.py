@@ -0,0 +1,1161 @@
+"""Tests for the FortiGate CLI configuration parser."""
+from __future__ import annotations
+
+from datetime import datetime
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.evaluator import (
+    Evaluator,
+    MatchMode,
+    evaluate_local_in_policy,
+    evaluate_multicast_policy,
+    evaluate_policy,
+    find_dos_policy,
+    find_local_interface,
+    find_snat_rule,
+    find_vip,
+)
+from static_traffic_analyzer.models import Decision, Protocol, expand_zones
+from static_traffic_analyzer.output import (
+    comment_columns,
+    dnat_columns,
+    dos_columns,
+    interface_columns,
+    nat_columns,
+    policy_nat_columns,
+    session_columns,
+    utm_columns,
+)
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.utils import ParseError
+
+
+MULTICAST_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+end
+config firewall multicast-address
+    edit "MC_STREAM"
+        set type multicastrange
+        set start-ip 239.1.1.0
+        set end-ip 239.1.1.255
+    next
+end
+config firewall multicast-policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "MC_STREAM"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def test_multicast_policy_matches_multicast_destination():
+    data = parse_fortigate_config(MULTICAST_CONFIG.splitlines())
+
+    assert data.policies == []
+    assert [policy.policy_id for policy in data.multicast_policies] == ["1"]
+
+    result = evaluate_multicast_policy(
+        policies=data.multicast_policies,
+        address_book=data.address_book,
+        service_book=data.service_book,
+        src_network=ip_network("10.0.0.0/24"),
+        dst_network=ip_network("239.1.1.10/32"),
+        protocol=Protocol.UDP,
+        port=5000,
+        match_mode=MatchMode(mode="segment", max_hosts=256),
+        ignore_schedule=False,
+    )
+    assert result.decision == Decision.ALLOW
+    assert result.matched_policy_id == "1"
+    assert result.reason == "MULTICAST_MATCHED_POLICY"
+
+
+SESSION_CONFIG = """
+config firewall policy
+    edit 7
+        set name "legacy-app"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set tcp-session-without-syn all
+        set anti-replay disable
+    next
+end
+"""
+
+
+def test_policy_session_flags_in_output_columns():
+    data = parse_fortigate_config(SESSION_CONFIG.splitlines())
+    policy = data.policies[0]
+
+    assert policy.tcp_session_without_syn == "all"
+    assert policy.anti_replay == "disable"
+    assert policy.session_ttl is None
+
+    result = evaluate_policy(
+        policies=data.policies,
+        address_book=data.address_book,
+        service_book=data.service_book,
+        src_network=ip_network("10.0.0.0/24"),
+        dst_network=ip_network("10.1.0.0/24"),
+        protocol=Protocol.TCP,
+        port=443,
+        match_mode=MatchMode(mode="segment", max_hosts=256),
+        ignore_schedule=False,
+    )
+    columns = session_columns(result.policy)
+    assert columns["matched_policy_tcp_session_without_syn"] == "all"
+    assert columns["matched_policy_anti_replay"] == "disable"
+    assert columns["matched_policy_session_ttl"] == ""
+
+
+SNAT_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+end
+config firewall central-snat-map
+    edit 1
+        set orig-addr "LAN"
+        set dst-addr "all"
+        set nat-ippool "POOL_PUBLIC"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def test_central_snat_map_reports_translated_source():
+    data = parse_fortigate_config(SNAT_CONFIG.splitlines())
+
+    assert data.snat_rules[0].orig_addr == ("LAN",)
+    assert data.snat_rules[0].nat_ippool == ("POOL_PUBLIC",)
+
+    mode = MatchMode(mode="segment", max_hosts=256)
+    internet = ip_network("8.8.8.8/32")
+    rule = find_snat_rule(
+        data.snat_rules, data.address_book, ip_network("10.0.0.0/25"), internet, mode, Protocol.TCP
+    )
+    assert nat_columns(rule) == {"snat_rule_id": "1", "translated_source": "POOL_PUBLIC"}
+
+    other = find_snat_rule(
+        data.snat_rules, data.address_book, ip_network("10.9.0.0/24"), internet, mode, Protocol.TCP
+    )
+    assert other is None
+
+
+def test_central_snat_map_honours_protocol_and_original_port():
+    config = SNAT_CONFIG.replace(
+        """        set nat-ippool "POOL_PUBLIC"
+    next
+""",
+        """        set nat-ippool "POOL_SIP"
+        set protocol 17
+        set orig-port 5060
+    next
+    edit 2
+        set orig-addr "LAN"
+        set dst-addr "all"
+        set nat-ippool "POOL_PUBLIC"
+    next
+""",
+    )
+    data = parse_fortigate_config(config.splitlines())
+    assert data.snat_rules[0].protocol == 17
+    assert data.snat_rules[0].orig_port == (5060, 5060)
+
+    mode = MatchMode(mode="segment", max_hosts=256)
+    lan, internet = ip_network("10.0.0.0/25"), ip_network("8.8.8.8/32")
+
+    def rule_id(protocol: Protocol, src_port=None):
+        rule = find_snat_rule(data.snat_rules, data.address_book, lan, internet, mode, protocol, src_port)
+        return rule.rule_id if rule is not None else None
+
+    assert rule_id(Protocol.UDP, 5060) == "1"
+    assert rule_id(Protocol.UDP, 40000) == "2"
+    assert rule_id(Protocol.UDP) == "2"
+    assert rule_id(Protocol.TCP, 5060) == "2"
+
+
+def test_interface_subnet_address_records_subnet_and_interface():
+    config = """
+config firewall address
+    edit "port1 address"
+        set type interface-subnet
+        set subnet 192.168.1.99 255.255.255.0
+        set interface "port1"
+    next
+    edit "DMZ"
+        set subnet 172.16.0.0 255.255.0.0
+        set associated-interface "dmz"
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+
+    address = data.address_book.objects["port1 address"]
+    assert address.subnet == ip_network("192.168.1.0/24")
+    assert address.interface == "port1"
+    assert address.contains_network(ip_network("192.168.1.128/25"))
+    assert data.address_book.objects["DMZ"].interface == "dmz"
+
+
+def test_interface_subnet_address_follows_system_interface():
+    config = """
+config firewall address
+    edit "port2 address"
+        set type interface-subnet
+        set interface "port2"
+    next
+    edit "stale"
+        set type interface-subnet
+        set subnet 10.9.9.1 255.255.255.0
+        set interface "port3"
+    next
+end
+config system interface
+    edit "port2"
+        set ip 172.20.5.1 255.255.254.0
+    next
+    edit "port3"
+        set ip 10.30.0.1 255.255.255.0
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+
+    assert data.address_book.objects["port2 address"].subnet == ip_network("172.20.4.0/23")
+    assert data.address_book.objects["port2 address"].interface == "port2"
+    assert data.address_book.objects["stale"].subnet == ip_network("10.30.0.0/24")
+    assert data.warnings == []
+
+
+def test_subnet_accepts_cidr_and_host_forms_and_reports_bad_masks():
+    config = """
+config firewall address
+    edit "CIDR"
+        set subnet 10.0.0.0/24
+    next
+    edit "MASK_SLASH"
+        set subnet 10.1.0.0/255.255.0.0
+    next
+    edit "HOST"
+        set subnet 10.2.0.5
+    next
+    edit "BAD"
+        set subnet 10.3.0.0 255.0.255.0
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+    objects = data.address_book.objects
+
+    assert objects["CIDR"].subnet == ip_network("10.0.0.0/24")
+    assert objects["MASK_SLASH"].subnet == ip_network("10.1.0.0/16")
+    assert objects["HOST"].subnet == ip_network("10.2.0.5/32")
+    assert objects["BAD"].subnet is None
+    assert data.warnings == [
+        "line 12: address BAD: Invalid subnet mask '255.0.255.0' in subnet: 10.3.0.0 255.0.255.0"
+    ]
+
+
+def test_policy_interfaces_are_parsed_and_reported():
+    config = """
+config firewall policy
+    edit 5
+        set srcintf "port1" "vlan10"
+        set dstintf "wan1"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+    policy = parse_fortigate_config(config.splitlines()).policies[0]
+
+    assert policy.src_interfaces == ("port1", "vlan10")
+    assert policy.dst_interfaces == ("wan1",)
+    assert interface_columns(policy) == {"matched_policy_srcintf": "port1,vlan10", "matched_policy_dstintf": "wan1"}
+    assert interface_columns(None) == {"matched_policy_srcintf": "", "matched_policy_dstintf": ""}
+
+
+def test_zones_resolve_to_member_interfaces():
+    config = """
+config system zone
+    edit "INSIDE"
+        set intrazone allow
+        set interface "port1" "vlan10"
+    next
+    edit "EMPTY"
+    next
+end
+config firewall policy
+    edit 1
+        set srcintf "INSIDE"
+        set dstintf "wan1"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+
+    assert data.zones["INSIDE"].intrazone == "allow"
+    assert data.zones["EMPTY"].interfaces == ()
+    assert expand_zones(data.policies[0].src_interfaces, data.zones) == ("port1", "vlan10")
+    assert expand_zones(("wan1", "INSIDE", "port1"), data.zones) == ("wan1", "port1", "vlan10")
+
+
+VIP_CONFIG = """
+config firewall vip
+    edit "WEB_VIP"
+        set extip 203.0.113.10
+        set mappedip "10.1.0.10"
+        set extintf "wan1"
+        set portforward enable
+        set extport 443
+        set mappedport 8443
+    next
+    edit "POOL_VIP"
+        set extip 203.0.113.20-203.0.113.29
+        set mappedip "10.2.0.20-10.2.0.29"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "WEB_VIP"
+        set service "ALL"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "POOL_VIP"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def test_vip_matches_external_address_and_reports_translation():
+    data = parse_fortigate_config(VIP_CONFIG.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+
+    def evaluate(dst: str, port: int):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network("198.51.100.0/24"),
+            ip_network(dst),
+            Protocol.TCP,
+            port,
+            mode,
+            ignore_schedule=False,
+        )
+
+    forwarded = evaluate("203.0.113.10/32", 443)
+    assert forwarded.decision == Decision.ALLOW
+    vip = find_vip(data.address_book, forwarded.policy, ip_network("203.0.113.10/32"), Protocol.TCP, 443, mode)
+    assert dnat_columns(vip, ip_network("203.0.113.10/32"), 443) == {
+        "dnat_vip": "WEB_VIP",
+        "translated_destination": "10.1.0.10",
+        "translated_port": 8443,
+    }
+    # Port forwarding only translates the configured external port.
+    assert evaluate("203.0.113.10/32", 22).reason == "IMPLICIT_DENY"
+
+    pooled = evaluate("203.0.113.24/31", 22)
+    vip = find_vip(data.address_book, pooled.policy, ip_network("203.0.113.24/31"), Protocol.TCP, 22, mode)
+    assert dnat_columns(vip, ip_network("203.0.113.24/31"), 22)["translated_destination"] == "10.2.0.24-10.2.0.25"
+
+
+def test_policy_ippool_reported_as_translated_source():
+    config = """
+config firewall ippool
+    edit "POOL_PUBLIC"
+        set startip 203.0.113.10
+        set endip 203.0.113.20
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set nat enable
+        set ippool enable
+        set poolname "POOL_PUBLIC"
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set nat enable
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+
+    assert data.ippools["POOL_PUBLIC"].end_ip == ip_network("203.0.113.20/32").network_address
+    pooled, interface = data.policies
+    assert (pooled.nat, pooled.ip_pools) == (True, ("POOL_PUBLIC",))
+    assert policy_nat_columns(pooled, data.ippools)["translated_source"] == "POOL_PUBLIC (203.0.113.10-203.0.113.20)"
+    assert policy_nat_columns(interface, data.ippools)["translated_source"] == "egress-interface"
+
+
+def test_central_nat_setting_is_parsed():
+    settings = ["config system settings", "    set central-nat enable", "end"]
+    enabled = parse_fortigate_config([*settings, *SNAT_CONFIG.splitlines()])
+    assert enabled.central_nat
+    assert not parse_fortigate_config(SNAT_CONFIG.splitlines()).central_nat
+
+
+SCHEDULE_CONFIG = """
+config firewall schedule recurring
+    edit "office-hours"
+        set day monday tuesday wednesday thursday friday
+        set start 08:00
+        set end 18:00
+    next
+    edit "overnight"
+        set day friday
+        set start 22:00
+        set end 06:00
+    next
+end
+config firewall schedule onetime
+    edit "maintenance"
+        set start "09:00 2024/06/01"
+        set end "17:00 2024/06/01"
+    next
+end
+config firewall schedule group
+    edit "any-window"
+        set member "overnight" "maintenance"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set schedule "office-hours"
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set schedule "any-window"
+    next
+end
+"""
+
+
+def test_schedules_are_evaluated_at_the_given_time():
+    data = parse_fortigate_config(SCHEDULE_CONFIG.splitlines())
+    assert data.policies[0].schedule == "office-hours"
+    assert data.schedules["overnight"].days == ("friday",)
+
+    def matched(at, ignore_schedule=False):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network("10.0.0.0/24"),
+            ip_network("10.1.0.0/24"),
+            Protocol.TCP,
+            443,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=ignore_schedule,
+            schedules=data.schedules,
+            at=at,
+        ).matched_policy_id
+
+    assert matched(datetime(2024, 6, 3, 9, 30)) == "1"  # Monday office hours
+    assert matched(datetime(2024, 6, 1, 10, 0)) == "2"  # Saturday maintenance window
+    assert matched(datetime(2024, 6, 8, 5, 0)) == "2"  # Friday overnight runs into Saturday
+    assert matched(datetime(2024, 6, 9, 12, 0)) is None
+    assert matched(None) is None
+    assert matched(None, ignore_schedule=True) == "1"
+
+
+IPV6_CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+end
+config firewall address6
+    edit "LAN6"
+        set ip6 2001:db8:1::/64
+    next
+    edit "WEB6"
+        set type iprange
+        set start-ip 2001:db8:2::10
+        set end-ip 2001:db8:2::1f
+    next
+end
+config firewall addrgrp6
+    edit "SERVERS6"
+        set member "WEB6"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "all"
+        set srcaddr6 "LAN6"
+        set dstaddr6 "SERVERS6"
+        set service "HTTPS"
+        set action accept
+    next
+end
+config firewall policy6
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+
+def test_ipv6_addresses_and_policies_are_evaluated():
+    data = parse_fortigate_config(IPV6_CONFIG.splitlines())
+    assert data.address_book.objects6["all"].subnet == ip_network("::/0")
+    assert data.policies[1].source == () and data.policies[1].source6 == ("all",)
+
+    def evaluate(src: str, dst: str):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network(src),
+            ip_network(dst),
+            Protocol.TCP,
+            443,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=False,
+        )
+
+    assert evaluate("2001:db8:1::/64", "2001:db8:2::10/124").matched_policy_id == "1"
+    assert evaluate("2001:db8:1::/64", "2001:db8:3::1/128").matched_policy_id == "2"
+    # IPv4 traffic never matches the IPv6-only policy6 entry.
+    assert evaluate("10.0.0.0/24", "192.0.2.1/32").matched_policy_id == "1"
+    assert evaluate("10.9.0.0/24", "192.0.2.1/32").matched_policy_id is None
+
+
+PROTOCOL_CONFIG = """
+config firewall service custom
+    edit "ECHO"
+        set protocol ICMP
+        set icmptype 8
+        set icmpcode 0
+    next
+    edit "ANY_ICMP"
+        set protocol ICMP
+    next
+    edit "SIGTRAN"
+        set sctp-portrange 2905 3868
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ECHO"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ANY_ICMP"
+        set action deny
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "SIGTRAN"
+        set action accept
+    next
+end
+"""
+
+
+def test_icmp_and_sctp_services_match_their_protocol():
+    data = parse_fortigate_config(PROTOCOL_CONFIG.splitlines())
+
+    def matched(protocol: Protocol, port: int):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network("10.0.0.0/24"),
+            ip_network("10.1.0.0/24"),
+            protocol,
+            port,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=False,
+        ).matched_policy_id
+
+    assert matched(Protocol.ICMP, 8) == "1"
+    assert matched(Protocol.ICMP, 0) == "2"
+    assert matched(Protocol.TCP, 8) is None
+    assert matched(Protocol.SCTP, 3868) == "3"
+    assert matched(Protocol.TCP, 3868) is None
+
+
+def test_service_source_port_ranges_apply_when_the_flow_has_a_source_port():
+    config = """
+config firewall service custom
+    edit "NTP-SYMMETRIC"
+        set udp-portrange 123:123
+    next
+    edit "WEB-HIGH-SRC"
+        set tcp-portrange 80:1024-65535 443:1024-65535
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "NTP-SYMMETRIC"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "WEB-HIGH-SRC"
+        set action accept
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+    entries = data.service_book.services["WEB-HIGH-SRC"].entries
+    assert [(entry.start_port, entry.src_start_port, entry.src_end_port) for entry in entries] == [
+        (80, 1024, 65535),
+        (443, 1024, 65535),
+    ]
+    evaluator = Evaluator(data.policies, data.address_book, data.service_book, MatchMode(mode="segment", max_hosts=256))
+
+    def matched(protocol: Protocol, port: int, src_port=None):
+        return evaluator.evaluate(
+            ip_network("10.0.0.0/24"), ip_network("10.1.0.0/24"), protocol, port, src_port
+        ).matched_policy_id
+
+    assert matched(Protocol.UDP, 123, 123) == "1"
+    assert matched(Protocol.UDP, 123, 40000) is None
+    assert matched(Protocol.UDP, 123) == "1"
+    assert matched(Protocol.TCP, 443, 51000) == "2"
+    assert matched(Protocol.TCP, 443, 80) is None
+
+
+CATEGORY_CONFIG = """
+config firewall service category
+    edit "Remote Access"
+    next
+end
+config firewall service custom
+    edit "SSH-ALT"
+        set category "Remote Access"
+        set tcp-portrange 2222
+    next
+    edit "RDP"
+        set category "Remote Access"
+        set tcp-portrange 3389
+    next
+end
+config firewall service group
+    edit "ADMIN"
+        set member "Remote Access" "HTTPS"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ADMIN"
+        set action accept
+    next
+end
+"""
+
+
+def test_service_categories_flatten_to_their_services():
+    data = parse_fortigate_config(CATEGORY_CONFIG.splitlines())
+    assert data.service_book.services["RDP"].category == "Remote Access"
+    members = {service.name for service in data.service_book.resolve_group_members("ADMIN")}
+    assert members == {"SSH-ALT", "RDP", "HTTPS"}
+    assert list(data.service_book.resolve_group_members("Unknown Category")) == []
+
+
+LOCAL_IN_CONFIG = """
+config system interface
+    edit "port1"
+        set ip 192.0.2.1 255.255.255.0
+        set allowaccess ping https ssh
+    next
+    edit "port2"
+        set ip 10.0.0.1 255.255.255.0
+    next
+end
+config firewall address
+    edit "BLOCKED"
+        set subnet 203.0.113.0 255.255.255.0
+    next
+end
+config firewall local-in-policy
+    edit 1
+        set intf "port1"
+        set srcaddr "BLOCKED"
+        set dstaddr "all"
+        set service "ALL"
+        set schedule "always"
+        set action deny
+    next
+end
+"""
+
+
+def test_local_in_policies_and_allowaccess():
+    data = parse_fortigate_config(LOCAL_IN_CONFIG.splitlines())
+    assert data.interfaces["port1"].allowaccess == ("ping", "https", "ssh")
+    assert data.local_in_policies[0].src_interfaces == ("port1",)
+    assert find_local_interface(data.interfaces, ip_network("192.0.2.0/24")) is None
+
+    def evaluate(src: str, dst: str, protocol: Protocol, port: int):
+        dst_network = ip_network(dst)
+        return evaluate_local_in_policy(
+            data.local_in_policies,
+            data.address_book,
+            data.service_book,
+            find_local_interface(data.interfaces, dst_network),
+            ip_network(src),
+            dst_network,
+            protocol,
+            port,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=False,
+        )
+
+    blocked = evaluate("203.0.113.0/24", "192.0.2.1/32", Protocol.TCP, 443)
+    assert (blocked.decision, blocked.matched_policy_id) == (Decision.DENY, "1")
+    assert blocked.reason == "LOCAL_IN_MATCHED_POLICY"
+    admin = evaluate("198.51.100.0/24", "192.0.2.1/32", Protocol.TCP, 443)
+    assert (admin.decision, admin.reason) == (Decision.ALLOW, "LOCAL_IN_ALLOWACCESS")
+    assert evaluate("198.51.100.0/24", "192.0.2.1/32", Protocol.ICMP, 8).decision == Decision.ALLOW
+    closed = evaluate("10.0.0.0/24", "10.0.0.1/32", Protocol.TCP, 22)
+    assert (closed.decision, closed.reason) == (Decision.DENY, "LOCAL_IN_ACCESS_DISABLED")
+
+
+DOS_CONFIG = """
+config firewall address
+    edit "WEB"
+        set subnet 10.1.0.0 255.255.255.0
+    next
+end
+config firewall DoS-policy
+    edit 1
+        set name "protect-web"
+        set interface "wan1"
+        set srcaddr "all"
+        set dstaddr "WEB"
+        set service "ALL"
+        config anomaly
+            edit "tcp_syn_flood"
+                set status enable
+                set threshold 2000
+            next
+        end
+    next
+end
+"""
+
+
+def test_dos_policy_reported_for_matching_flows():
+    data = parse_fortigate_config(DOS_CONFIG.splitlines())
+    assert data.dos_policies[0].src_interfaces == ("wan1",)
+
+    def dos_policy(dst: str):
+        return find_dos_policy(
+            data.dos_policies,
+            data.address_book,
+            data.service_book,
+            ip_network("198.51.100.0/24"),
+            ip_network(dst),
+            Protocol.TCP,
+            443,
+            MatchMode(mode="segment", max_hosts=256),
+        )
+
+    assert dos_columns(dos_policy("10.1.0.10/32")) == {"dos_policy_id": "1", "dos_policy_name": "protect-web"}
+    assert dos_columns(dos_policy("10.2.0.10/32")) == {"dos_policy_id": "", "dos_policy_name": ""}
+
+
+def test_policy_comments_and_uuid_in_output_columns():
+    config = """
+config firewall policy
+    edit 1
+        set uuid 5f0c8a2e-1b7a-51ee-9d1c-0a1b2c3d4e5f
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set comments "CHG-1234 \\"temporary\\" web access"
+    next
+end
+"""
+    policy = parse_fortigate_config(config.splitlines()).policies[0]
+    assert comment_columns(policy) == {
+        "matched_policy_comments": 'CHG-1234 "temporary" web access',
+        "matched_policy_uuid": "5f0c8a2e-1b7a-51ee-9d1c-0a1b2c3d4e5f",
+    }
+    assert comment_columns(None) == {"matched_policy_comments": "", "matched_policy_uuid": ""}
+
+
+def test_security_profiles_in_output_columns():
+    config = """
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set utm-status enable
+        set ssl-ssh-profile "certificate-inspection"
+        set av-profile "default"
+        set ips-sensor "high_security"
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set utm-status disable
+        set webfilter-profile "monitor-all"
+    next
+end
+"""
+    inspected, plain = parse_fortigate_config(config.splitlines()).policies
+    assert utm_columns(inspected) == {
+        "matched_policy_av_profile": "default",
+        "matched_policy_ips_sensor": "high_security",
+        "matched_policy_webfilter_profile": "",
+        "matched_policy_ssl_ssh_profile": "certificate-inspection",
+    }
+    assert set(utm_columns(plain).values()) == {""}
+
+
+TYPO_CONFIG = """
+config firewall service custom
+    edit "APP"
+        set tcp-portrange 8080 70000
+    next
+end
+config firewall policy
+    edit 1
+        sett srcaddr "all"
+        set dstaddr "all
+        set service
+        set action accept
+    next
+end
+"""
+
+
+def test_unrecognized_and_malformed_lines_are_reported_with_line_numbers():
+    data = parse_fortigate_config(TYPO_CONFIG.splitlines())
+
+    assert data.warnings == [
+        "line 3: service APP: Port out of range: tcp_70000",
+        'line 9: Unrecognized line: sett srcaddr "all"',
+        'line 10: Unbalanced quotes: set dstaddr "all',
+        "line 11: `set` without a value: set service",
+    ]
+    with pytest.raises(ParseError, match=r"fw\.conf line 9: Unrecognized line"):
+        parse_fortigate_config(TYPO_CONFIG.splitlines(), strict=True, source="fw.conf")
+
+
+EDIT_COMMANDS_CONFIG = """
+config firewall addrgrp
+    edit "SERVERS"
+        set member "WEB1" "WEB2"
+        append member "DB1"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        append srcaddr "DMZ" "VPN"
+        set dstaddr "WEB1"
+        set dstaddr "SERVERS"
+        set service "HTTPS"
+        clear service
+        set action accept
+        set schedule "weekdays"
+        unset schedule
+    next
+end
+"""
+
+
+def test_append_unset_and_clear_edit_commands():
+    data = parse_fortigate_config(EDIT_COMMANDS_CONFIG.splitlines())
+
+    assert data.address_book.groups["SERVERS"].members == ("WEB1", "WEB2", "DB1")
+    policy = data.policies[0]
+    assert policy.source == ("LAN", "DMZ", "VPN")
+    assert policy.destination == ("SERVERS",)
+    assert policy.services == ()
+    assert policy.schedule is None
+    assert data.warnings == []
+
+
+NESTED_CONFIG = """
+config firewall vip
+    edit "WEB-VIP"
+        set extip 203.0.113.10
+        config realservers
+            edit 1
+                set ip 10.0.0.10
+                config monitor
+                end
+            next
+        end
+        set mappedip "10.0.0.10"
+    next
+end
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.255.0
+    next
+end
+"""
+
+
+def test_nested_config_blocks_do_not_end_the_outer_section():
+    data = parse_fortigate_config(NESTED_CONFIG.splitlines())
+
+    vip = data.address_book.vips["WEB-VIP"]
+    assert (str(vip.ext_start), str(vip.mapped_start)) == ("203.0.113.10", "10.0.0.10")
+    assert str(data.address_book.objects["LAN"].subnet) == "10.0.0.0/24"
+    assert data.warnings == []
+
+    truncated = parse_fortigate_config(NESTED_CONFIG.splitlines()[:10])
+    assert truncated.warnings == ["line 5: Nested config block is missing its `end`"]
+
+
+
+def test_every_vdom_edit_keeps_its_sections():
+    data = parse_fortigate_config(
+        """
+config vdom
+edit root
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+next
+edit dmz
+config firewall policy
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+next
+end
+""".splitlines(),
+        strict=True,
+    )
+
+    assert [policy.policy_id for policy in data.policies] == ["1", "2"]
+    assert data.warnings == []
+
+
+CONSOLIDATED_CONFIG = """
+config firewall address6
+    edit "LAN6"
+        set ip6 2001:db8:1::/64
+    next
+end
+config firewall consolidated policy
+    edit 1
+        set srcaddr4 "LAN"
+        set dstaddr4 "all"
+        set srcaddr6 "LAN6"
+        set dstaddr6 "all"
+        set service "HTTPS"
+        set action accept
+    next
+end
+config firewall security-policy
+    edit 2
+        set srcaddr4 "all"
+        set dstaddr4 "all"
+        set application 15832
+        set action deny
+    next
+    edit 3
+        set srcaddr4 "all"
+        set dstaddr4 "all"
+        set action accept
+    next
+end
+"""
+
+
+def test_consolidated_and_security_policies_use_srcaddr4_fields():
+    data = parse_fortigate_config(CONSOLIDATED_CONFIG.splitlines())
+
+    consolidated, app_rule, any_service = data.policies
+    assert (consolidated.source, consolidated.destination) == (("LAN",), ("all",))
+    assert (consolidated.source6, consolidated.destination6) == (("LAN6",), ("all",))
+    assert app_rule.services == ("2 application filter",)
+    assert any_service.services == ("ALL",)
+    assert data.warnings == []
+
+
+def test_multiple_port_ranges_on_one_set_line():
+    config = """
+config firewall service custom
+    edit "WEB"
+        set tcp-portrange 80 443 8000-8100
+        set udp-portrange 53 5000-5001:1024-65535
+    next
+end
+"""
+    entries = parse_fortigate_config(config.splitlines()).service_book.services["WEB"].entries
+
+    assert [(entry.protocol, entry.start_port, entry.end_port) for entry in entries] == [
+        (Protocol.TCP, 80, 80),
+        (Protocol.TCP, 443, 443),
+        (Protocol.TCP, 8000, 8100),
+        (Protocol.UDP, 53, 53),
+        (Protocol.UDP, 5000, 5001),
+    ]
+    assert entries[-1].src_start_port == 1024
+
+
+def test_vip_group_members_are_flattened_and_translated():
+    config = VIP_CONFIG.replace(
+        "config firewall policy",
+        """config firewall vipgrp
+    edit "PUBLIC"
+        set interface "wan1"
+        set member "WEB_VIP" "POOL_VIP"
+    next
+end
+config firewall policy""",
+    ).replace('set dstaddr "WEB_VIP"', 'set dstaddr "PUBLIC"')
+    data = parse_fortigate_config(config.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+    assert data.address_book.vip_groups["PUBLIC"].members == ("WEB_VIP", "POOL_VIP")
+
+    def evaluate(dst: str, port: int):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network("198.51.100.0/24"),
+            ip_network(dst),
+            Protocol.TCP,
+            port,
+            mode,
+            ignore_schedule=False,
+        )
+
+    forwarded = evaluate("203.0.113.10/32", 443)
+    assert forwarded.matched_policy_id == "1"
+    vip = find_vip(data.address_book, forwarded.policy, ip_network("203.0.113.10/32"), Protocol.TCP, 443, mode)
+    assert vip is not None and vip.name == "WEB_VIP"
+    # The port-forwarding member still only translates its external port.
+    assert evaluate("203.0.113.10/32", 22).reason == "IMPLICIT_DENY"
+    assert evaluate("203.0.113.24/31", 22).matched_policy_id == "1"
+
+
+INTERFACE_CONFIG = """
+config system zone
+    edit "INSIDE"
+        set interface "port1" "port2"
+    next
+end
+config firewall policy
+    edit 1
+        set srcintf "port1"
+        set dstintf "wan1"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 2
+        set srcintf "INSIDE"
+        set dstintf "wan1"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def test_policies_are_skipped_when_flow_interfaces_do_not_match():
+    data = parse_fortigate_config(INTERFACE_CONFIG.splitlines())
+
+    def evaluate(ingress, egress):
+        return evaluate_policy(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            ip_network("10.0.0.0/24"),
+            ip_network("192.0.2.0/24"),
+            Protocol.TCP,
+            443,
+            MatchMode(mode="segment", max_hosts=256),
+            ignore_schedule=False,
+            ingress=ingress,
+            egress=egress,
+            zones=data.zones,
+        )
+
+    assert evaluate(None, None).matched_policy_id == "1"
+    assert evaluate("port2", "wan1").matched_policy_id == "2"
+    assert evaluate("port3", "wan1").reason == "IMPLICIT_DENY"
+    assert evaluate("port2", "wan2").reason == "IMPLICIT_DENY"
+    # The zone may carry the flow on port1 (policy 1) or port2 (policy 2).
+    assert evaluate("INSIDE", "wan1").decision == Decision.UNKNOWN
@@ -0,0 +1,77 @@
+"""Tests for fetching the FortiGate configuration over SSH."""
+from __future__ import annotations
+
+import io
+
+import pytest
+
+from static_traffic_analyzer.parsers.fortigate_ssh import SSHOptions, parse_fortigate_ssh
+from static_traffic_analyzer.utils import ParseError
+
+
+CONFIG = (
+    "config firewall address\r\n"
+    '    edit "LAN"\r\n'
+    "        set subnet 10.0.0.0 255.255.255.0\r\n"
+    "    next\r\n"
+    "end\r\n"
+    "config firewall policy\r\n"
+    "    edit 4\r\n"
+    '        set srcaddr "LAN"\r\n'
+    '        set dstaddr "all"\r\n'
+    '        set service "ALL"\r\n'
+    "        set action accept\r\n"
+    "    next\r\n"
+    "end\r\n"
+)
+
+
+class _Stream(io.BytesIO):
+    def __init__(self, data: bytes, status: int) -> None:
+        super().__init__(data)
+        self.channel = self
+        self._status = status
+
+    def recv_exit_status(self) -> int:
+        return self._status
+
+
+class _FakeClient:
+    def __init__(self, output: str, status: int = 0) -> None:
+        self.output = output
+        self.status = status
+        self.connected: dict = {}
+        self.commands: list[str] = []
+        self.closed = False
+
+    def connect(self, host: str, **kwargs) -> None:
+        self.connected = {"host": host, **kwargs}
+
+    def exec_command(self, command: str, timeout: float):
+        self.commands.append(command)
+        return None, _Stream(self.output.encode(), self.status), _Stream(b"Command fail", self.status)
+
+    def close(self) -> None:
+        self.closed = True
+
+
+def test_ssh_fetch_feeds_config_parser():
+    client = _FakeClient(CONFIG)
+    options = SSHOptions(host="fw.example", username="audit", key_file="/keys/id_ed25519")
+
+    data = parse_fortigate_ssh(options, client_factory=lambda _: client)
+
+    assert client.commands == ["show full-configuration"]
+    assert client.connected["username"] == "audit"
+    assert client.connected["key_filename"] == "/keys/id_ed25519"
+    assert client.closed
+    assert [policy.policy_id for policy in data.policies] == ["4"]
+    assert "LAN" in data.address_book.objects
+
+
+def test_ssh_command_failure_is_reported():
+    client = _FakeClient("", status=1)
+
+    with pytest.raises(ParseError, match="Command fail"):
+        parse_fortigate_ssh(SSHOptions(host="fw.example", username="audit"), client_factory=lambda _: client)
+    assert client.closed
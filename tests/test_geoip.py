@@ -0,0 +1,81 @@
+"""Tests for geography address objects and GeoIP lookups."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+from pathlib import Path
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.geoip import MaxMindGeoIP, load_geoip
+from static_traffic_analyzer.models import AddressType, Decision, MatchOutcome, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "Country-JP"
+        set type geography
+        set country "JP"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "Country-JP"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def _evaluate(data, src: str, geoip, mode: str = "segment"):
+    return evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network(src),
+        ip_network("10.0.0.0/24"),
+        Protocol.TCP,
+        443,
+        MatchMode(mode=mode, max_hosts=256),
+        ignore_schedule=False,
+        geoip=geoip,
+    )
+
+
+def test_geography_object_matches_with_csv_database(tmp_path: Path):
+    db = tmp_path / "geo.csv"
+    db.write_text("network,country\n203.0.113.0/25,JP\n203.0.113.128/25,US\n", encoding="utf-8")
+    geoip = load_geoip(str(db))
+    data = parse_fortigate_config(CONFIG.splitlines())
+
+    address = data.address_book.objects["Country-JP"]
+    assert (address.address_type, address.country) == (AddressType.GEOGRAPHY, "JP")
+
+    assert _evaluate(data, "203.0.113.0/26", geoip).matched_policy_id == "1"
+    assert _evaluate(data, "203.0.113.128/26", geoip).matched_policy_id == "2"
+    # Straddles JP and US blocks: only part of the source is in Japan.
+    assert _evaluate(data, "203.0.113.0/24", geoip).decision == Decision.UNKNOWN
+    assert _evaluate(data, "203.0.113.0/24", geoip, mode="sample-ip").matched_policy_id == "1"
+    assert _evaluate(data, "203.0.113.0/26", None).decision == Decision.UNKNOWN
+
+
+class _FakeReader:
+    def get_with_prefix_len(self, ip: str):
+        if ip.startswith("198.51.100."):
+            return {"country": {"iso_code": "JP"}}, 24
+        return None, 8
+
+
+def test_maxmind_lookup_uses_prefix_length():
+    geoip = MaxMindGeoIP(_FakeReader())
+
+    assert geoip.match_country(ip_network("198.51.100.0/25"), "jp") == MatchOutcome.MATCH
+    assert geoip.match_country(ip_network("198.51.100.0/23"), "JP") == MatchOutcome.UNKNOWN
+    assert geoip.match_country(ip_network("8.8.8.0/24"), "JP") == MatchOutcome.NO_MATCH
@@ -0,0 +1,140 @@
+"""Tests for MAC address objects, user identity policies and source host mappings."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.identity import load_identity_map, load_mac_map
+from static_traffic_analyzer.models import AddressType, Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.utils import ParseError
+
+
+CONFIG = """
+config firewall address
+    edit "PRINTER"
+        set type mac
+        set macaddr "00:11:22:33:44:55"
+    next
+    edit "LAB-NICS"
+        set type mac
+        set start-mac 00:aa:00:00:00:00
+        set end-mac 00:aa:00:00:00:ff
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "PRINTER"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 2
+        set srcaddr "LAB-NICS"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def _evaluator(data, mac_map=None) -> Evaluator:
+    return Evaluator(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        MatchMode(mode="segment", max_hosts=256),
+        mac_map=mac_map,
+    )
+
+
+def test_mac_objects_match_through_the_mapping(tmp_path: Path):
+    mapping = tmp_path / "macs.csv"
+    mapping.write_text("ip,mac\n10.0.0.5,00-11-22-33-44-55\n10.0.0.6,00:AA:00:00:00:10\n", encoding="utf-8")
+    data = parse_fortigate_config(CONFIG.splitlines())
+    printer = data.address_book.objects["PRINTER"]
+    assert (printer.address_type, printer.macaddrs) == (AddressType.MAC, ("00:11:22:33:44:55",))
+
+    evaluator = _evaluator(data, load_mac_map(mapping))
+
+    def evaluate(src: str):
+        return evaluator.evaluate(ip_network(src), ip_network("192.0.2.1/32"), Protocol.TCP, 443)
+
+    assert evaluate("10.0.0.5/32").matched_policy_id == "1"
+    assert evaluate("10.0.0.6/32").matched_policy_id == "2"
+    assert evaluate("10.0.0.7/32").decision == Decision.UNKNOWN
+    # Only two of the four addresses are mapped.
+    assert evaluate("10.0.0.4/30").decision == Decision.UNKNOWN
+
+
+def test_mac_objects_without_mapping_are_unsupported():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    result = _evaluator(data).evaluate(ip_network("10.0.0.5/32"), ip_network("192.0.2.1/32"), Protocol.TCP, 443)
+    assert (result.decision, result.reason) == (Decision.UNKNOWN, "UNSUPPORTED_OBJECT")
+
+
+def test_mac_mapping_rejects_malformed_addresses(tmp_path: Path):
+    mapping = tmp_path / "macs.csv"
+    mapping.write_text("ip,mac\n10.0.0.5,not-a-mac\n", encoding="utf-8")
+    with pytest.raises(ParseError, match="line 2"):
+        load_mac_map(mapping)
+
+
+IDENTITY_CONFIG = """
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set groups "Engineering"
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set users "auditor"
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+
+def test_user_and_group_policies_match_through_the_identity_map(tmp_path: Path):
+    mapping = tmp_path / "identities.csv"
+    mapping.write_text(
+        "ip,user,groups\n10.0.0.5,alice,engineering;vpn\n10.0.0.6,Auditor,\n10.0.0.7,bob,sales\n",
+        encoding="utf-8",
+    )
+    data = parse_fortigate_config(IDENTITY_CONFIG.splitlines())
+    assert (data.policies[0].groups, data.policies[1].users) == (("Engineering",), ("auditor",))
+
+    evaluator = Evaluator(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        MatchMode(mode="segment", max_hosts=256),
+        identities=load_identity_map(mapping),
+    )
+
+    def evaluate(src: str):
+        return evaluator.evaluate(ip_network(src), ip_network("192.0.2.1/32"), Protocol.TCP, 443)
+
+    assert evaluate("10.0.0.5/32").matched_policy_id == "1"
+    assert evaluate("10.0.0.6/32").matched_policy_id == "2"
+    assert evaluate("10.0.0.7/32").matched_policy_id == "3"
+    # An unmapped source could still authenticate as anyone.
+    assert evaluate("10.0.0.8/32").decision == Decision.UNKNOWN
+    assert _evaluator(data).evaluate(
+        ip_network("10.0.0.5/32"), ip_network("192.0.2.1/32"), Protocol.TCP, 443
+    ).decision == Decision.UNKNOWN
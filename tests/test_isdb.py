@@ -0,0 +1,78 @@
+"""Tests for Internet Service Database policies and mapping files."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.isdb import load_isdb
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.utils import ParseError
+
+
+CONFIG = """
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set internet-service enable
+        set internet-service-name "Google-Web"
+        set action accept
+    next
+    edit 2
+        set internet-service-src enable
+        set internet-service-src-name "Scanner-Botnet"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 3
+        set srcaddr "all"
+        set internet-service enable
+        set internet-service-name "Unmapped-Service"
+        set action accept
+    next
+end
+"""
+
+MAPPING = """name,network,protocol,ports
+Google-Web,142.250.0.0/15,tcp,80
+Google-Web,142.250.0.0/15,tcp,443
+Scanner-Botnet,198.51.100.10-198.51.100.20,,
+"""
+
+
+def test_isdb_policies_match_mapped_ranges_and_ports(tmp_path: Path):
+    mapping = tmp_path / "isdb.csv"
+    mapping.write_text(MAPPING, encoding="utf-8")
+    isdb = load_isdb(mapping)
+    data = parse_fortigate_config(CONFIG.splitlines())
+    assert data.policies[0].internet_services == ("Google-Web",)
+    assert data.policies[1].internet_services_src == ("Scanner-Botnet",)
+
+    evaluator = Evaluator(
+        data.policies, data.address_book, data.service_book, MatchMode(mode="segment", max_hosts=256), isdb=isdb
+    )
+
+    def evaluate(src: str, dst: str, port: int):
+        return evaluator.evaluate(ip_network(src), ip_network(dst), Protocol.TCP, port)
+
+    assert evaluate("10.0.0.0/24", "142.250.1.0/24", 443).matched_policy_id == "1"
+    assert evaluate("198.51.100.12/32", "10.0.0.1/32", 22).matched_policy_id == "2"
+    # Not a Google port, so the flow falls through to the unmapped entry.
+    unmapped = evaluate("10.0.0.0/24", "142.250.1.0/24", 22)
+    assert (unmapped.decision, unmapped.matched_policy_id) == (Decision.UNKNOWN, "3")
+
+    evaluator.isdb = None
+    assert evaluate("10.0.0.0/24", "142.250.1.0/24", 443).decision == Decision.UNKNOWN
+
+
+def test_isdb_mapping_rejects_bad_rows(tmp_path: Path):
+    mapping = tmp_path / "isdb.csv"
+    mapping.write_text("name,network,protocol,ports\nX,not-an-ip,tcp,443\n", encoding="utf-8")
+    with pytest.raises(ParseError, match="line 2"):
+        load_isdb(mapping)
+    with pytest.raises(ParseError, match="not found"):
+        load_isdb(tmp_path / "missing.csv")
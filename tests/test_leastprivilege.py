@@ -0,0 +1,106 @@
+"""Tests for least-privilege suggestions on broad accept policies."""
+from __future__ import annotations
+
+from static_traffic_analyzer.leastprivilege import broad_dimensions, recommend_least_privilege
+from static_traffic_analyzer.overlap import format_services, policy_space
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "CAMPUS"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "CAMPUS"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "WEB"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+    edit 3
+        set srcaddr "WEB"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 4
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+end
+"""
+
+
+def _row(policy_id: str, src: str, dst: str, protocol: str, port: int, decision: str = "ALLOW") -> dict:
+    return {
+        "src_network_segment": src,
+        "dst_network_segment": dst,
+        "protocol": protocol,
+        "port": port,
+        "decision": decision,
+        "matched_policy_id": policy_id,
+    }
+
+
+def test_broad_dimensions_flags_large_blocks_and_any_service():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    spaces = [policy_space(policy, data.address_book, data.service_book) for policy in data.policies]
+
+    assert [broad_dimensions(space) for space in spaces] == [
+        ("src",),
+        ("dst", "service"),
+        (),
+        ("src", "dst", "service"),
+    ]
+
+
+def test_broad_accept_policies_are_narrowed_to_allowed_flows():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    rows = [
+        _row("1", "10.0.1.0/24", "192.0.2.10/32", "tcp", 443),
+        _row("1", "10.0.0.0/24", "192.0.2.10/32", "tcp", 443),
+        _row("2", "192.0.2.0/24", "198.51.100.5/32", "tcp", 22),
+        _row("2", "192.0.2.0/24", "198.51.100.6/32", "tcp", 23),
+        _row("2", "192.0.2.0/24", "203.0.113.0/24", "udp", 53),
+        _row("3", "192.0.2.0/24", "192.0.2.0/24", "tcp", 443),
+        _row("4", "172.16.0.0/24", "192.0.2.0/24", "tcp", 80, decision="DENY"),
+    ]
+
+    suggestions = recommend_least_privilege(rows, data.policies, data.address_book, data.service_book)
+
+    assert [(item.policy.policy_id, item.broad, item.allowed_flows) for item in suggestions] == [
+        ("1", ("src",), 2),
+        ("2", ("dst", "service"), 3),
+    ]
+    campus, any_destination = suggestions
+    assert [str(block) for block in campus.suggested.source] == ["10.0.0.0/23"]
+    assert campus.suggested.destination == campus.current.destination
+    assert [str(block) for block in any_destination.suggested.destination] == [
+        "198.51.100.5/32",
+        "198.51.100.6/32",
+        "203.0.113.0/24",
+    ]
+    assert format_services(any_destination.suggested.services) == "tcp/22-23;udp/53"
+
+
+def test_suggestion_never_reaches_past_the_policy():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    # A sampled segment wider than the policy's own source.
+    rows = [_row("1", "10.0.0.0/8", "192.0.2.10/32", "tcp", 443)]
+
+    (suggestion,) = recommend_least_privilege(rows, data.policies, data.address_book, data.service_book)
+
+    assert [str(block) for block in suggestion.suggested.source] == ["10.0.0.0/16"]
@@ -0,0 +1,26 @@
+"""Tests for OpenMetrics run statistics."""
+from __future__ import annotations
+
+from pathlib import Path
+
+from static_traffic_analyzer.metrics import RunMetrics
+
+
+def test_metrics_render_counts(tmp_path: Path):
+    metrics = RunMetrics()
+    metrics.record({"decision": "ALLOW", "matched_policy_id": "3"})
+    metrics.record({"decision": "DENY", "matched_policy_id": "2"})
+    metrics.record({"decision": "DENY", "matched_policy_id": ""})
+    metrics.record({"decision": "ALLOW", "matched_policy_id": "3"})
+
+    path = tmp_path / "metrics.prom"
+    metrics.write(path)
+    text = path.read_text(encoding="utf-8")
+
+    assert "static_traffic_analyzer_flows_total 4" in text
+    assert 'static_traffic_analyzer_decisions_total{decision="ALLOW"} 2' in text
+    assert 'static_traffic_analyzer_decisions_total{decision="DENY"} 2' in text
+    assert 'static_traffic_analyzer_decisions_total{decision="UNKNOWN"} 0' in text
+    assert 'static_traffic_analyzer_policy_hits_total{policy_id="3"} 2' in text
+    assert 'static_traffic_analyzer_policy_hits_total{policy_id="2"} 1' in text
+    assert text.endswith("# EOF\n")
@@ -0,0 +1,47 @@
+"""Tests for NAT translation helpers."""
+from __future__ import annotations
+
+from ipaddress import ip_address, ip_network
+
+from static_traffic_analyzer.models import IPPool, Protocol, VirtualIP
+from static_traffic_analyzer.nat import find_dnat_vip, snat_source, vip_destination
+
+
+VIPS = {
+    "WEB": VirtualIP(
+        name="WEB",
+        ext_start=ip_address("203.0.113.10"),
+        ext_end=ip_address("203.0.113.10"),
+        mapped_start=ip_address("10.0.0.10"),
+        mapped_end=ip_address("10.0.0.10"),
+        port_forward=True,
+        ext_ports=(443, 443),
+        mapped_ports=(8443, 8443),
+    ),
+    "POOL": VirtualIP(
+        name="POOL",
+        ext_start=ip_address("203.0.113.16"),
+        ext_end=ip_address("203.0.113.31"),
+        mapped_start=ip_address("10.2.0.16"),
+        mapped_end=ip_address("10.2.0.31"),
+    ),
+}
+
+
+def test_dnat_vip_covers_whole_destination_and_port():
+    assert find_dnat_vip(VIPS, ip_network("203.0.113.10/32"), Protocol.TCP, 443).name == "WEB"
+    assert find_dnat_vip(VIPS, ip_network("203.0.113.10/32"), Protocol.TCP, 80) is None
+    assert find_dnat_vip(VIPS, ip_network("203.0.113.0/24"), Protocol.TCP, 80) is None
+
+    pool = find_dnat_vip(VIPS, ip_network("203.0.113.20/30"), Protocol.UDP, 53)
+    assert vip_destination(pool, ip_network("203.0.113.20/30")) == ip_network("10.2.0.20/30")
+
+
+def test_snat_source_prefers_pool_then_egress_address():
+    pool = IPPool(name="P", pool_type="overload", start_ip=ip_address("198.51.100.1"), end_ip=ip_address("198.51.100.4"))
+    pools = {"P": pool}
+
+    assert snat_source(False, ("P",), pools) is None
+    assert snat_source(True, ("P",), pools) == "198.51.100.1-198.51.100.4"
+    assert snat_source(True, (), pools, ip_address("192.0.2.1")) == "192.0.2.1"
+    assert snat_source(True, (), pools) == "egress-interface"
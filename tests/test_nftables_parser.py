@@ -0,0 +1,198 @@
+"""Tests for the nftables JSON ruleset parser."""
+from __future__ import annotations
+
+import json
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.chain import Hop, evaluate_chain
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.nftables import parse_nftables_chains, parse_nftables_ruleset
+from static_traffic_analyzer.utils import ParseError
+
+
+def _match(protocol: str, field: str, right, op: str = "==") -> dict:
+    return {"match": {"op": op, "left": {"payload": {"protocol": protocol, "field": field}}, "right": right}}
+
+
+RULESET = {
+    "nftables": [
+        {"metainfo": {"json_schema_version": 1}},
+        {"table": {"family": "inet", "name": "filter"}},
+        {"chain": {"family": "inet", "table": "filter", "name": "input", "hook": "input", "policy": "drop"}},
+        {"chain": {"family": "inet", "table": "filter", "name": "forward", "hook": "forward", "policy": "drop"}},
+        {
+            "set": {
+                "family": "inet",
+                "table": "filter",
+                "name": "web_servers",
+                "type": "ipv4_addr",
+                "flags": ["interval"],
+                "elem": ["10.1.0.10", {"prefix": {"addr": "10.1.1.0", "len": 24}}],
+            }
+        },
+        {"set": {"family": "inet", "table": "filter", "name": "web_ports", "type": "inet_service", "elem": [80, 443]}},
+        {
+            "rule": {
+                "family": "inet",
+                "table": "filter",
+                "chain": "forward",
+                "handle": 4,
+                "expr": [
+                    {"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": ["established", "related"]}},
+                    {"accept": None},
+                ],
+            }
+        },
+        {
+            "rule": {
+                "family": "inet",
+                "table": "filter",
+                "chain": "forward",
+                "handle": 5,
+                "expr": [
+                    _match("ip", "saddr", {"prefix": {"addr": "10.0.0.0", "len": 24}}),
+                    _match("ip", "daddr", "@web_servers"),
+                    _match("tcp", "dport", "@web_ports"),
+                    {"counter": {"packets": 0, "bytes": 0}},
+                    {"accept": None},
+                ],
+            }
+        },
+        {
+            "rule": {
+                "family": "inet",
+                "table": "filter",
+                "chain": "forward",
+                "handle": 6,
+                "expr": [_match("udp", "dport", {"range": [5000, 5010]}), {"accept": None}],
+            }
+        },
+        {
+            "rule": {
+                "family": "inet",
+                "table": "filter",
+                "chain": "input",
+                "handle": 7,
+                "expr": [{"accept": None}],
+            }
+        },
+    ]
+}
+
+
+def test_nftables_forward_chain_with_named_sets(evaluate):
+    data = parse_nftables_ruleset(json.dumps(RULESET).splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "filter/forward:5",
+        "filter/forward:6",
+        "filter/forward:policy",
+    ]
+    assert data.address_book.groups["@web_servers"].members == ("10.1.0.10", "10.1.1.0/24")
+
+    web = evaluate(data, "10.1.1.0/28", Protocol.TCP, 443)
+    assert (web.decision, web.matched_policy_id) == (Decision.ALLOW, "filter/forward:5")
+    udp = evaluate(data, "192.0.2.1/32", Protocol.UDP, 5003)
+    assert (udp.decision, udp.matched_policy_id) == (Decision.ALLOW, "filter/forward:6")
+    ssh = evaluate(data, "10.1.0.10/32", Protocol.TCP, 22)
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.DENY, "filter/forward:policy")
+
+
+ACCEPT = {"accept": None}
+
+
+def _rule(handle: int, chain: str, *expr) -> dict:
+    return {"rule": {"family": "inet", "table": "filter", "chain": chain, "handle": handle, "expr": list(expr)}}
+
+
+def _forward(*rules) -> list[str]:
+    chain = {"chain": {"family": "inet", "table": "filter", "name": "forward", "hook": "forward", "policy": "drop"}}
+    web = {"chain": {"family": "inet", "table": "filter", "name": "web"}}
+    return json.dumps({"nftables": [chain, web, *rules]}).splitlines()
+
+
+def test_nftables_unmodelled_matches_never_widen_rules(evaluate):
+    mark = {"match": {"op": "==", "left": {"meta": {"key": "mark"}}, "right": 1}}
+    iifname = {"match": {"op": "==", "left": {"meta": {"key": "iifname"}}, "right": "lan"}}
+    data = parse_nftables_ruleset(
+        _forward(
+            _rule(1, "forward", _match("ip6", "saddr", {"prefix": {"addr": "2001:db8::", "len": 32}}), ACCEPT),
+            _rule(2, "forward", iifname, {"accept": None}),
+            _rule(3, "forward", mark, {"accept": None}),
+        )
+    )
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "filter/forward:2",
+        "filter/forward:3",
+        "filter/forward:policy",
+    ]
+    assert data.policies[0].src_interfaces == ("lan",)
+    from_wan = evaluate(data, "192.0.2.1/32", Protocol.TCP, 22, ingress="wan")
+    assert (from_wan.decision, from_wan.matched_policy_id) == (Decision.UNKNOWN, "filter/forward:3")
+
+
+def test_nftables_jump_and_goto_inline_target_chain(evaluate):
+    data = parse_nftables_ruleset(
+        _forward(
+            _rule(1, "forward", _match("ip", "daddr", "10.1.0.0/16"), {"jump": {"target": "web"}}),
+            _rule(2, "forward", _match("udp", "dport", 53), {"goto": {"target": "web"}}),
+            _rule(3, "forward", {"accept": None}),
+            _rule(4, "web", _match("tcp", "dport", 443), {"accept": None}),
+        )
+    )
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "filter/forward:1>web:4",
+        "filter/forward:2>web:end",
+        "filter/forward:3",
+        "filter/forward:policy",
+    ]
+    web = evaluate(data, "10.1.2.3/32", Protocol.TCP, 443)
+    assert (web.decision, web.matched_policy_id) == (Decision.ALLOW, "filter/forward:1>web:4")
+    outside = evaluate(data, "192.0.2.1/32", Protocol.TCP, 443)
+    assert (outside.decision, outside.matched_policy_id) == (Decision.ALLOW, "filter/forward:3")
+    dns = evaluate(data, "192.0.2.1/32", Protocol.UDP, 53)
+    assert (dns.decision, dns.matched_policy_id) == (Decision.DENY, "filter/forward:2>web:end")
+
+
+def test_nftables_goto_falls_through_to_chain_policy(evaluate):
+    data = parse_nftables_ruleset(
+        _forward(
+            _rule(1, "forward", _match("ip", "daddr", "10.1.0.0/16"), {"goto": {"target": "web"}}),
+            _rule(2, "forward", {"accept": None}),
+            _rule(3, "web", _match("tcp", "dport", 443), {"accept": None}),
+        )
+    )
+
+    ssh = evaluate(data, "10.1.2.3/32", Protocol.TCP, 22)
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.DENY, "filter/forward:1>web:end")
+    web = evaluate(data, "10.1.2.3/32", Protocol.TCP, 443)
+    assert (web.decision, web.matched_policy_id) == (Decision.ALLOW, "filter/forward:1>web:3")
+
+
+def test_nftables_forward_chains_in_several_tables_must_all_accept():
+    def table(name: str, prio: int, *expr) -> list[dict]:
+        chain = {"family": "inet", "table": name, "name": "fwd", "hook": "forward", "prio": prio, "policy": "accept"}
+        rule = {"family": "inet", "table": name, "chain": "fwd", "handle": 2, "expr": list(expr)}
+        return [{"chain": chain}, {"rule": rule}]
+
+    ssh_drop = table("b", 0, _match("tcp", "dport", 22), {"drop": None})
+    web_accept = table("a", 10, _match("tcp", "dport", 443), ACCEPT)
+    ruleset = json.dumps({"nftables": [*web_accept, *ssh_drop]}).splitlines()
+
+    with pytest.raises(ParseError, match="2 forward base chains"):
+        parse_nftables_ruleset(ruleset)
+
+    chains = parse_nftables_chains(ruleset)
+    assert [name for name, _ in chains] == ["b/fwd", "a/fwd"]
+    mode = MatchMode(mode="segment", max_hosts=256)
+    hops = [Hop(name, Evaluator(data.policies, data.address_book, data.service_book, mode)) for name, data in chains]
+    ssh = evaluate_chain(hops, ip_network("10.0.0.0/24"), ip_network("10.1.0.10/32"), Protocol.TCP, 22)
+    assert (ssh.decision, ssh.blocking_hop) == (Decision.DENY, "b/fwd")
+    assert ssh.blocking_detail.matched_policy_id == "b/fwd:2"
+    web = evaluate_chain(hops, ip_network("10.0.0.0/24"), ip_network("10.1.0.10/32"), Protocol.TCP, 443)
+    assert web.decision == Decision.ALLOW
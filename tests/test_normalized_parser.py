@@ -0,0 +1,83 @@
+"""Tests for the normalized JSON/YAML rules provider."""
+from __future__ import annotations
+
+import json
+
+import pytest
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.normalized import parse_normalized_rules
+from static_traffic_analyzer.utils import ParseError
+
+
+DOCUMENT = {
+    "version": 1,
+    "addresses": [
+        {"name": "LAN", "type": "ipmask", "subnet": "10.0.0.0/24"},
+        {"name": "WEB", "type": "iprange", "start_ip": "10.1.0.20", "end_ip": "10.1.0.29"},
+    ],
+    "address_groups": [{"name": "SERVERS", "members": ["WEB"]}],
+    "services": [
+        {"name": "WEB_PORTS", "entries": [{"protocol": "tcp", "ports": "443"}, {"protocol": "tcp", "ports": "8000-8100"}]}
+    ],
+    "service_groups": [{"name": "WEB_SVC", "members": ["WEB_PORTS", "DNS"]}],
+    "policies": [
+        {
+            "id": "10",
+            "name": "lan-to-web",
+            "source": ["LAN"],
+            "destination": ["SERVERS"],
+            "services": ["WEB_SVC"],
+            "action": "accept",
+        },
+        {"id": "20", "source": "all", "destination": "all", "services": "ALL", "action": "deny", "comment": "default"},
+    ],
+}
+
+YAML_DOCUMENT = """
+version: 1
+addresses:
+  - {name: LAN, type: ipmask, subnet: 10.0.0.0/24}
+policies:
+  - id: "1"
+    source: [LAN]
+    destination: [all]
+    services: [HTTPS]
+    action: accept
+    enabled: false
+"""
+
+
+def test_normalized_json_rules(evaluate):
+    data = parse_normalized_rules(json.dumps(DOCUMENT).splitlines(keepends=True))
+
+    assert [(policy.policy_id, policy.name) for policy in data.policies] == [("10", "lan-to-web"), ("20", "20")]
+    assert data.policies[1].comment == "default"
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8050)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "10")
+    dns = evaluate(data, "10.1.0.25/32", Protocol.UDP, 53)
+    assert (dns.decision, dns.matched_policy_id) == (Decision.ALLOW, "10")
+    denied = evaluate(data, "10.1.0.25/32", Protocol.TCP, 22)
+    assert (denied.decision, denied.matched_policy_id) == (Decision.DENY, "20")
+
+
+def test_normalized_yaml_rules():
+    pytest.importorskip("yaml")
+    data = parse_normalized_rules(YAML_DOCUMENT.splitlines(keepends=True))
+
+    assert data.policies[0].source == ("LAN",)
+    assert not data.policies[0].enabled
+
+
+def test_normalized_rejects_unknown_version_and_missing_fields():
+    with pytest.raises(ParseError, match="version"):
+        parse_normalized_rules([json.dumps({"version": 2, "policies": []})])
+    with pytest.raises(ParseError, match="missing 'action'"):
+        parse_normalized_rules([json.dumps({"policies": [{"id": "1"}]})])
+
+
+def test_normalized_rejects_invalid_action_and_enabled():
+    with pytest.raises(ParseError, match="action must be accept or deny, got 'allow'"):
+        parse_normalized_rules([json.dumps({"policies": [{"id": "1", "action": "allow"}]})])
+    with pytest.raises(ParseError, match="enabled must be true or false, got 'false'"):
+        parse_normalized_rules([json.dumps({"policies": [{"id": "1", "action": "deny", "enabled": "false"}]})])
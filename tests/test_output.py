@@ -0,0 +1,109 @@
+"""Tests for output writers."""
+from __future__ import annotations
+
+import csv
+import gzip
+import json
+import sqlite3
+from pathlib import Path
+
+from static_traffic_analyzer.output import (
+    OUTPUT_FIELDS,
+    aggregate_rows,
+    write_output,
+    write_partitioned_output,
+    write_sqlite_output,
+)
+
+
+def _row(decision: str, policy_id: str, port: int) -> dict[str, str | int | None]:
+    row: dict[str, str | int | None] = {field: "" for field in OUTPUT_FIELDS}
+    row.update({"decision": decision, "matched_policy_id": policy_id, "port": port})
+    return row
+
+
+def test_partitioned_output_routes_rows_by_decision(tmp_path: Path):
+    rows = [
+        _row("ALLOW", "1", 80),
+        _row("DENY", "2", 22),
+        _row("DENY", "", 23),
+        _row("ALLOW", "1", 443),
+        _row("ALLOW", "1", 8080),
+        _row("UNKNOWN", "3", 53),
+    ]
+
+    write_partitioned_output(tmp_path, rows, shard_size=2, compress=True)
+
+    def read(path: Path) -> list[dict[str, str]]:
+        with gzip.open(path, "rt", newline="", encoding="utf-8") as handle:
+            return list(csv.DictReader(handle))
+
+    allow_shards = sorted((tmp_path / "allow").iterdir())
+    assert [path.name for path in allow_shards] == ["part-00000.csv.gz", "part-00001.csv.gz"]
+    assert [row["port"] for path in allow_shards for row in read(path)] == ["80", "443", "8080"]
+    assert [row["port"] for row in read(tmp_path / "deny" / "part-00000.csv.gz")] == ["22"]
+    assert [row["port"] for row in read(tmp_path / "unmatched" / "part-00000.csv.gz")] == ["23"]
+    assert [row["port"] for row in read(tmp_path / "unknown" / "part-00000.csv.gz")] == ["53"]
+
+
+def test_partitioned_output_removes_shards_of_an_earlier_run(tmp_path: Path):
+    rows = [_row("ALLOW", "1", 80), _row("ALLOW", "1", 443), _row("DENY", "2", 22)]
+    write_partitioned_output(tmp_path, rows, shard_size=1)
+    (tmp_path / "deny" / "notes.txt").write_text("keep", encoding="utf-8")
+
+    write_partitioned_output(tmp_path, [_row("ALLOW", "1", 8080)], shard_size=1)
+
+    assert [path.name for path in (tmp_path / "allow").iterdir()] == ["part-00000.csv"]
+    assert [path.name for path in (tmp_path / "deny").iterdir()] == ["notes.txt"]
+
+
+def test_aggregate_rows_collapses_identical_results_and_sums_counts():
+    rows = [
+        _row("ALLOW", "1", 80),
+        _row("DENY", "2", 22),
+        _row("ALLOW", "1", 80),
+        {**_row("ALLOW", "1", 80), "flow_count": 5},
+    ]
+
+    aggregated = aggregate_rows(rows, [*OUTPUT_FIELDS, "flow_count"])
+
+    assert [(row["port"], row["flow_count"]) for row in aggregated] == [(80, 7), (22, 1)]
+
+
+def test_jsonl_output_writes_one_typed_object_per_row(tmp_path: Path):
+    path = tmp_path / "out.jsonl"
+
+    write_output(path, [_row("ALLOW", "1", 80), _row("DENY", "", 23)], output_format="jsonl")
+
+    lines = path.read_text(encoding="utf-8").splitlines()
+    first, second = (json.loads(line) for line in lines)
+    assert list(first) == OUTPUT_FIELDS
+    assert (first["decision"], first["port"]) == ("ALLOW", 80)
+    assert second["matched_policy_id"] == ""
+
+
+def test_partitioned_jsonl_shards_have_no_header(tmp_path: Path):
+    write_partitioned_output(tmp_path, [_row("ALLOW", "1", 80)], output_format="jsonl")
+
+    lines = (tmp_path / "allow" / "part-00000.jsonl").read_text(encoding="utf-8").splitlines()
+    assert [json.loads(line)["port"] for line in lines] == [80]
+
+
+def test_sqlite_output_keeps_types_and_records_run_metadata(tmp_path: Path):
+    path = tmp_path / "results.db"
+    path.write_text("stale")
+    rows = [{**_row("ALLOW", "1", 80), "src_Owner Team": "net"}, _row("DENY", "", 23)]
+
+    write_sqlite_output(path, rows, ["src_Owner Team"], {"rules": "fw.conf"})
+
+    connection = sqlite3.connect(path)
+    try:
+        assert connection.execute(
+            'SELECT decision, port, "src_Owner Team" FROM results WHERE matched_policy_id = ?', ("1",)
+        ).fetchall() == [("ALLOW", 80, "net")]
+        metadata = dict(connection.execute("SELECT key, value FROM run_metadata"))
+        indexes = {row[0] for row in connection.execute("SELECT name FROM sqlite_master WHERE type = 'index'")}
+    finally:
+        connection.close()
+    assert (metadata["rules"], metadata["rows"]) == ("fw.conf", "2")
+    assert {"results_decision", "results_policy"} <= indexes
@@ -0,0 +1,65 @@
+"""Tests for policy match spaces and pairwise overlaps."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+from static_traffic_analyzer.overlap import find_overlaps, format_services
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+
+
+CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+    edit "OPS"
+        set type iprange
+        set start-ip 10.0.1.0
+        set end-ip 10.0.2.255
+    next
+    edit "DMZ"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall service custom
+    edit "WEB"
+        set tcp-portrange 80 443 8000-8100
+    next
+    edit "ALT"
+        set tcp-portrange 8080-9000
+        set udp-portrange 53
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "DMZ"
+        set service "WEB"
+        set action accept
+    next
+    edit 2
+        set srcaddr "OPS"
+        set dstaddr "all"
+        set service "ALT"
+        set action deny
+    next
+    edit 3
+        set srcaddr "OPS"
+        set dstaddr "DMZ"
+        set service "SSH"
+        set action accept
+    next
+end
+"""
+
+
+def test_overlaps_report_shared_addresses_and_ports():
+    data = parse_fortigate_config(CONFIG.splitlines())
+
+    overlaps = find_overlaps(data.policies, data.address_book, data.service_book)
+
+    assert [(o.earlier.policy_id, o.later.policy_id, o.conflict) for o in overlaps] == [("1", "2", True)]
+    shared = overlaps[0].shared
+    assert shared.source == (ip_network("10.0.1.0/24"), ip_network("10.0.2.0/24"))
+    assert shared.destination == (ip_network("192.0.2.0/24"),)
+    assert format_services(shared.services) == "tcp/8080-8100"
@@ -0,0 +1,126 @@
+"""Tests for the PAN-OS configuration parser."""
+from __future__ import annotations
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.panos import parse_panos_config
+
+
+SET_CONFIG = """
+set address LAN ip-netmask 10.0.0.0/24
+set address WEB01 ip-netmask 10.1.0.10/32
+set address WEB02 ip-range 10.1.0.20-10.1.0.29
+set address-group WEB static [ WEB01 WEB02 ]
+set service tcp-8443 protocol tcp port 8443
+set service-group WEB-PORTS members [ service-https tcp-8443 ]
+set rulebase security rules allow-web from trust
+set rulebase security rules allow-web to dmz
+set rulebase security rules allow-web source LAN
+set rulebase security rules allow-web destination WEB
+set rulebase security rules allow-web service WEB-PORTS
+set rulebase security rules allow-web action allow
+set rulebase security rules app-rule source any
+set rulebase security rules app-rule destination any
+set rulebase security rules app-rule application ssh
+set rulebase security rules app-rule service application-default
+set rulebase security rules app-rule action allow
+set rulebase security rules old-rule source any
+set rulebase security rules old-rule destination any
+set rulebase security rules old-rule service any
+set rulebase security rules old-rule action allow
+set rulebase security rules old-rule disabled yes
+"""
+
+XML_CONFIG = """<config><devices><entry name="localhost"><vsys><entry name="vsys1">
+  <address>
+    <entry name="LAN"><ip-netmask>10.0.0.0/24</ip-netmask></entry>
+    <entry name="DB"><ip-netmask>10.2.0.5/32</ip-netmask></entry>
+  </address>
+  <service>
+    <entry name="mysql"><protocol><tcp><port>3306</port></tcp></protocol></entry>
+  </service>
+  <rulebase><security><rules>
+    <entry name="db">
+      <source><member>LAN</member></source>
+      <destination><member>DB</member></destination>
+      <service><member>mysql</member></service>
+      <application><member>any</member></application>
+      <action>allow</action>
+    </entry>
+    <entry name="block">
+      <source><member>any</member></source>
+      <destination><member>any</member></destination>
+      <service><member>any</member></service>
+      <action>deny</action>
+    </entry>
+  </rules></security></rulebase>
+</entry></vsys></entry></devices></config>
+"""
+
+
+def test_panos_set_format_rules(evaluate):
+    data = parse_panos_config(SET_CONFIG.splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == ["allow-web", "app-rule", "old-rule"]
+    assert not data.policies[2].enabled
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 443)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "allow-web")
+    app_default = evaluate(data, "10.9.0.1/32", Protocol.TCP, 22)
+    assert (app_default.decision, app_default.matched_policy_id) == (Decision.UNKNOWN, "app-rule")
+
+
+
+def test_panos_application_rules_stay_unknown_for_any_service(evaluate):
+    data = parse_panos_config(
+        """
+set rulebase security rules tls application ssl
+set rulebase security rules tls service any
+set rulebase security rules tls action allow
+set rulebase security rules rest application any
+set rulebase security rules rest service any
+set rulebase security rules rest action deny
+""".splitlines()
+    )
+
+    ssh = evaluate(data, "10.9.0.1/32", Protocol.TCP, 22)
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.UNKNOWN, "tls")
+    assert data.policies[1].services == ("ALL",)
+
+
+def test_panos_xml_export(evaluate):
+    data = parse_panos_config(XML_CONFIG.splitlines())
+
+    allowed = evaluate(data, "10.2.0.5/32", Protocol.TCP, 3306)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "db")
+    denied = evaluate(data, "10.2.0.5/32", Protocol.TCP, 22)
+    assert (denied.decision, denied.matched_policy_id) == (Decision.DENY, "block")
+
+
+def test_panos_negation_bad_addresses_and_source_ports(evaluate):
+    data = parse_panos_config(
+        """
+set address LAN ip-netmask 10.0.0.0/24
+set address WILD ip-wildcard 10.0.0.0/0.0.255.0
+set address TYPO ip-netmask 10.0.0.999/24
+set service hi-src protocol tcp port 443 source-port 1024-65535
+set rulebase security rules not-lan source LAN
+set rulebase security rules not-lan negate-source yes
+set rulebase security rules not-lan service any
+set rulebase security rules not-lan action deny
+set rulebase security rules wild source any
+set rulebase security rules wild destination [ WILD TYPO ]
+set rulebase security rules wild service any
+set rulebase security rules wild action allow
+set rulebase security rules web source any
+set rulebase security rules web service hi-src
+set rulebase security rules web action allow
+""".splitlines()
+    )
+
+    assert data.policies[0].source == ("not LAN",)
+    assert len(data.warnings) == 2
+    assert "WILD" not in data.address_book.objects and "TYPO" not in data.address_book.objects
+    assert [(entry.start_port, entry.src_start_port) for entry in data.service_book.services["hi-src"].entries] == [
+        (443, 1024)
+    ]
+    negated = evaluate(data, "10.3.0.1/32", Protocol.TCP, 443)
+    assert (negated.decision, negated.matched_policy_id) == (Decision.UNKNOWN, "not-lan")
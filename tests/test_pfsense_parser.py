@@ -0,0 +1,91 @@
+"""Tests for the pfSense config.xml parser."""
+from __future__ import annotations
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.pfsense import parse_pfsense_config
+
+
+CONFIG_XML = """<?xml version="1.0"?>
+<pfsense>
+  <aliases>
+    <alias><name>WEB</name><type>host</type><address>10.1.0.10 10.1.0.20-10.1.0.29</address></alias>
+    <alias><name>LAN_NETS</name><type>network</type><address>10.0.0.0/24</address></alias>
+    <alias><name>WEB_PORTS</name><type>port</type><address>443 8000:8100</address></alias>
+  </aliases>
+  <filter>
+    <rule>
+      <tracker>1001</tracker>
+      <type>pass</type>
+      <interface>lan</interface>
+      <protocol>tcp</protocol>
+      <source><address>LAN_NETS</address></source>
+      <destination><address>WEB</address><port>WEB_PORTS</port></destination>
+      <descr>lan to web</descr>
+    </rule>
+    <rule>
+      <tracker>1002</tracker>
+      <type>pass</type>
+      <protocol>udp</protocol>
+      <source><any/></source>
+      <destination><any/><port>53</port></destination>
+      <disabled/>
+    </rule>
+    <rule>
+      <tracker>1003</tracker>
+      <type>pass</type>
+      <protocol>icmp</protocol>
+      <source><any/></source>
+      <destination><any/></destination>
+    </rule>
+    <rule>
+      <tracker>1004</tracker>
+      <type>block</type>
+      <source><any/></source>
+      <destination><any/></destination>
+    </rule>
+  </filter>
+</pfsense>
+"""
+
+
+def test_pfsense_aliases_and_rules(evaluate):
+    data = parse_pfsense_config(CONFIG_XML.splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == ["1001", "1002", "1004"]
+    assert not data.policies[1].enabled
+
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8050)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "1001")
+    dns = evaluate(data, "192.0.2.53/32", Protocol.UDP, 53)
+    assert (dns.decision, dns.matched_policy_id) == (Decision.DENY, "1004")
+
+
+def test_pfsense_rules_are_scoped_to_their_interface(evaluate):
+    data = parse_pfsense_config(CONFIG_XML.splitlines())
+
+    assert data.policies[0].src_interfaces == ("lan",)
+    assert data.policies[2].src_interfaces == ()
+    from_lan = evaluate(data, "10.1.0.10/32", Protocol.TCP, 443, ingress="lan")
+    assert (from_lan.decision, from_lan.matched_policy_id) == (Decision.ALLOW, "1001")
+    from_wan = evaluate(data, "10.1.0.10/32", Protocol.TCP, 443, ingress="wan")
+    assert (from_wan.decision, from_wan.matched_policy_id) == (Decision.DENY, "1004")
+
+
+def test_pfsense_source_port_narrows_service(evaluate):
+    data = parse_pfsense_config(
+        """<pfsense><filter>
+    <rule>
+      <tracker>2001</tracker>
+      <type>pass</type>
+      <protocol>udp</protocol>
+      <source><any/><port>123</port></source>
+      <destination><any/><port>123</port></destination>
+    </rule>
+  </filter></pfsense>""".splitlines()
+    )
+
+    (service,) = data.policies[0].services
+    entries = data.service_book.services[service].entries
+    assert [(entry.start_port, entry.src_start_port, entry.src_end_port) for entry in entries] == [(123, 123, 123)]
+    ntp = evaluate(data, "192.0.2.1/32", Protocol.UDP, 123, src_port=40000)
+    assert (ntp.decision, ntp.matched_policy_id) == (Decision.DENY, None)
@@ -0,0 +1,71 @@
+"""Tests for the protocol/port lookup table."""
+from __future__ import annotations
+
+from static_traffic_analyzer.models import Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.portindex import PortIndex
+
+
+CONFIG = """
+config firewall service custom
+    edit "WEB"
+        set tcp-portrange 80 443
+    next
+    edit "HIGH"
+        set tcp-portrange 1024-65535
+        set udp-portrange 5000-5010
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "WEB"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "HIGH"
+        set action accept
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 4
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "WEB"
+        set service-negate enable
+        set action deny
+    next
+    edit 5
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "MISSING"
+        set action accept
+    next
+    edit 6
+        set status disable
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "WEB"
+        set action accept
+    next
+end
+"""
+
+
+def test_port_index_buckets_ports_and_keeps_broad_policies():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    index = PortIndex(data.policies, data.service_book)
+
+    # Any-protocol, negated and unresolvable services always stay candidates; disabled policies never do.
+    assert index.candidates(Protocol.TCP, 443) == {0, 2, 3, 4}
+    assert index.candidates(Protocol.TCP, 8080) == {1, 2, 3, 4}
+    assert index.candidates(Protocol.UDP, 5005) == {1, 2, 3, 4}
+    assert index.candidates(Protocol.UDP, 53) == {2, 3, 4}
+    assert index.candidates(Protocol.TCP, 22) == {2, 3, 4}
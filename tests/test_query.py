@@ -0,0 +1,161 @@
+"""Tests for the reverse rule search."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode, PolicyCheck
+from static_traffic_analyzer.models import MatchOutcome, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.query import (
+    find_candidate_rules,
+    format_check,
+    parse_flow_port,
+    parse_query_port,
+    query_space,
+)
+from static_traffic_analyzer.utils import ParseError
+
+
+CONFIG = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+    edit "WEB"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+    edit "PORTAL"
+        set type fqdn
+        set fqdn "portal.example.com"
+    next
+end
+config firewall service custom
+    edit "HTTPS"
+        set tcp-portrange 443
+    next
+    edit "DNS"
+        set udp-portrange 53
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "DNS"
+        set action accept
+    next
+    edit 3
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 4
+        set srcaddr "LAN"
+        set dstaddr "PORTAL"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 5
+        set status disable
+        set srcaddr "LAN"
+        set dstaddr "WEB"
+        set service "HTTPS"
+        set action accept
+    next
+end
+"""
+
+
+def _candidates(src=None, dst=None, port=None, include_disabled=False):
+    data = parse_fortigate_config(CONFIG.splitlines())
+    query = query_space(src, dst, port)
+    return find_candidate_rules(
+        data.policies, data.address_book, data.service_book, query, include_disabled=include_disabled
+    )
+
+
+def test_every_covering_policy_is_listed_not_just_the_first():
+    candidates = _candidates(dst="192.0.2.10", port="443/tcp")
+
+    # Policy 4 uses an FQDN and cannot be ruled out statically.
+    assert [(candidate.policy.policy_id, candidate.shared is None) for candidate in candidates] == [
+        ("1", False),
+        ("3", False),
+        ("4", True),
+    ]
+    deny_all = candidates[1].shared
+    assert deny_all.destination == (ip_network("192.0.2.10/32"),)
+    assert deny_all.services == {Protocol.TCP: ((443, 443),)}
+
+
+def test_unspecified_dimensions_are_wildcards():
+    assert [candidate.policy.policy_id for candidate in _candidates(src="10.0.5.0/24")] == ["1", "2", "3", "4"]
+    assert [candidate.policy.policy_id for candidate in _candidates(port="53")] == ["2", "3", "4"]
+    assert [candidate.policy.policy_id for candidate in _candidates(port="tcp", include_disabled=True)] == [
+        "1",
+        "3",
+        "4",
+        "5",
+    ]
+
+
+def test_parse_query_port():
+    assert parse_query_port("8000-8080/udp") == {Protocol.UDP: ((8000, 8080),)}
+    assert parse_query_port("tcp") == {Protocol.TCP: ((0, 65535),)}
+    assert set(parse_query_port("443")) == {Protocol.TCP, Protocol.UDP, Protocol.SCTP}
+    with pytest.raises(ParseError):
+        parse_query_port("https/tcp")
+    with pytest.raises(ParseError):
+        parse_query_port("443/gre")
+
+
+def test_parse_flow_port_needs_one_port_and_protocol():
+    assert parse_flow_port("53/udp") == (Protocol.UDP, 53)
+    for value in ("53", "1000-2000/tcp", "tcp"):
+        with pytest.raises(ParseError):
+            parse_flow_port(value)
+
+
+def test_explain_names_the_failing_criterion_of_each_policy():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    evaluator = Evaluator(
+        data.policies, data.address_book, data.service_book, MatchMode(mode="segment", max_hosts=256)
+    )
+
+    detail, explanation = evaluator.evaluate_explain(
+        ip_network("10.0.0.5/32"), ip_network("192.0.2.10/32"), Protocol.TCP, 53
+    )
+
+    assert detail.matched_policy_id == "3"
+    assert [format_check(check) for check in explanation.checks] == [
+        "policy 1 (no-name): no match on service",
+        "policy 2 (no-name): no match on service",
+        "policy 3 (no-name): matched",
+    ]
+
+
+def test_format_check_reports_skips_interfaces_and_undecided_criteria():
+    assert format_check(PolicyCheck("7", "old", skipped="disabled")) == "policy 7 (old): skipped, disabled"
+    interface_miss = PolicyCheck(
+        "8",
+        "wan-only",
+        source=MatchOutcome.MATCH,
+        destination=MatchOutcome.MATCH,
+        service=MatchOutcome.MATCH,
+        interface=MatchOutcome.NO_MATCH,
+    )
+    assert interface_miss.failed == ("interface",)
+    assert format_check(interface_miss) == "policy 8 (wan-only): no match on interface"
+    fqdn = PolicyCheck(
+        "9", "portal", source=MatchOutcome.MATCH, destination=MatchOutcome.UNKNOWN, service=MatchOutcome.MATCH
+    )
+    assert format_check(fqdn) == "policy 9 (portal): may match, undecided on dst"
@@ -0,0 +1,193 @@
+"""Tests for reports derived from output rows."""
+from __future__ import annotations
+
+import csv
+from pathlib import Path
+
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.reports import (
+    NO_MATCHING_FLOWS,
+    SHADOWED,
+    build_dead_rule_report,
+    build_hit_count_report,
+    build_logging_report,
+    build_policy_stats,
+    build_section_report,
+    build_service_matrix,
+    write_logging_report,
+    write_section_report,
+    write_service_matrix,
+)
+
+
+def _row(src: str, dst: str, label: str, decision: str) -> dict[str, str]:
+    return {
+        "src_network_segment": src,
+        "dst_network_segment": dst,
+        "service_label": label,
+        "decision": decision,
+    }
+
+
+def test_service_matrix_distinct_pairs(tmp_path: Path):
+    rows = [
+        _row("10.0.0.0/24", "10.1.0.0/24", "ssh", "ALLOW"),
+        _row("10.0.0.0/24", "10.2.0.0/24", "ssh", "DENY"),
+        _row("10.0.0.0/24", "10.2.0.0/24", "http", "ALLOW"),
+        _row("10.0.1.0/24", "10.2.0.0/24", "http", "ALLOW"),
+        _row("10.0.1.0/24", "10.2.0.0/24", "http", "ALLOW"),
+    ]
+    matrix = build_service_matrix(rows)
+
+    assert matrix["ssh"] == [("10.0.0.0/24", "10.1.0.0/24")]
+    assert matrix["http"] == [("10.0.0.0/24", "10.2.0.0/24"), ("10.0.1.0/24", "10.2.0.0/24")]
+
+    path = tmp_path / "matrix.csv"
+    write_service_matrix(path, matrix)
+    with path.open(newline="", encoding="utf-8") as handle:
+        written = list(csv.DictReader(handle))
+    assert [row["service_label"] for row in written] == ["http", "http", "ssh"]
+
+
+LOGGING_CONFIG = """
+config firewall policy
+    edit 1
+        set name "quiet"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "SSH"
+        set action accept
+        set logtraffic disable
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+        set logtraffic all
+    next
+end
+"""
+
+
+def test_logging_report_lists_allowed_flows_without_logging(tmp_path: Path):
+    data = parse_fortigate_config(LOGGING_CONFIG.splitlines())
+    assert [policy.logtraffic for policy in data.policies] == ["disable", "all"]
+
+    flow = {"protocol": "tcp", "port": 22, "matched_policy_id": "1"}
+    rows = [
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "ALLOW"), **flow},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "DENY"), **flow},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), **flow, "port": 443, "matched_policy_id": "2"},
+    ]
+    report = build_logging_report(rows, data.policies)
+
+    assert [(row["port"], row["matched_policy_name"]) for row in report] == [(22, "quiet")]
+
+    path = tmp_path / "logging.csv"
+    write_logging_report(path, report)
+    with path.open(newline="", encoding="utf-8") as handle:
+        written = list(csv.DictReader(handle))
+    assert written[0]["logtraffic"] == "disable"
+
+
+SECTION_CONFIG = """
+config firewall policy
+    edit 1
+        set global-label "Internet"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "SSH"
+        set action deny
+    next
+    edit 3
+        set global-label "Servers"
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+
+def test_section_report_groups_policies_by_global_label(tmp_path: Path):
+    data = parse_fortigate_config(SECTION_CONFIG.splitlines())
+    assert [policy.section for policy in data.policies] == ["Internet", "Internet", "Servers"]
+
+    rows = [
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "DENY"), "matched_policy_id": "2"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "other", "DENY"), "matched_policy_id": None},
+    ]
+    report = build_section_report(rows, data.policies)
+
+    assert report == [
+        {"section": "Internet", "policies": 2, "allowed_flows": 2, "denied_flows": 1},
+        {"section": "Servers", "policies": 1, "allowed_flows": 0, "denied_flows": 0},
+    ]
+    path = tmp_path / "sections.csv"
+    write_section_report(path, report)
+    with path.open(newline="", encoding="utf-8") as handle:
+        assert [row["section"] for row in csv.DictReader(handle)] == ["Internet", "Servers"]
+
+
+def test_hit_count_report_flags_enabled_policies_without_observed_hits():
+    data = parse_fortigate_config(SECTION_CONFIG.splitlines())
+    rows = [
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "DENY"), "matched_policy_id": "2"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "other", "DENY"), "matched_policy_id": ""},
+    ]
+
+    report = build_hit_count_report(rows, data.policies, {"1": 12, "0": 40})
+
+    summary = [
+        (entry["policy_id"], entry["observed_hits"], entry["simulated_flows"], entry["unused"]) for entry in report
+    ]
+    assert summary == [("1", 12, 1, "no"), ("2", 0, 1, "yes"), ("3", 0, 0, "yes")]
+
+
+def test_policy_stats_count_flows_per_policy_and_implicit_deny():
+    data = parse_fortigate_config(SECTION_CONFIG.splitlines())
+    rows = [
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "UNKNOWN"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "ssh", "DENY"), "matched_policy_id": "2"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "other", "DENY"), "matched_policy_id": None},
+        {**_row("10.0.0.0/24", "10.9.0.0/24", "other", "UNROUTABLE"), "matched_policy_id": None},
+    ]
+
+    report = build_policy_stats(rows, data.policies)
+
+    assert [
+        (entry["policy_id"], entry["matches"], entry["allows"], entry["denies"], entry["unknown"])
+        for entry in report
+    ] == [("1", 3, 2, 0, 1), ("2", 1, 0, 1, 0), ("3", 0, 0, 0, 0), ("", 1, 0, 1, 0)]
+    assert report[-1]["policy_name"] == "(implicit deny)"
+
+
+def test_dead_rule_report_lists_enabled_policies_without_simulated_flows():
+    config = SECTION_CONFIG.replace('edit 2\n', 'edit 2\n        set status disable\n')
+    data = parse_fortigate_config(config.splitlines())
+    rows = [
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "web", "ALLOW"), "matched_policy_id": "1"},
+        {**_row("10.0.0.0/24", "10.1.0.0/24", "other", "DENY"), "matched_policy_id": None},
+    ]
+
+    report = build_dead_rule_report(rows, data.policies, {})
+    shadowed = build_dead_rule_report(rows, data.policies, {"3": "never matches"})
+
+    # Policy 2 is disabled and never counts as dead.
+    assert [(entry["policy_id"], entry["reason"]) for entry in report] == [("3", NO_MATCHING_FLOWS)]
+    assert [(entry["policy_id"], entry["reason"], entry["detail"]) for entry in shadowed] == [
+        ("3", SHADOWED, "never matches")
+    ]
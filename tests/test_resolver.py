@@ -0,0 +1,163 @@
+"""Tests for FQDN resolution."""
+from __future__ import annotations
+
+import time
+from ipaddress import IPv4Address, ip_network
+
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import (
+    AddressBook,
+    AddressObject,
+    AddressType,
+    Decision,
+    PolicyRule,
+    Protocol,
+    ServiceBook,
+)
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.resolver import FQDNResolver, load_hosts_file, no_lookup
+from static_traffic_analyzer.utils import make_any_service
+
+
+def _evaluator(resolver: FQDNResolver | None) -> Evaluator:
+    address_book = AddressBook(
+        objects={
+            "all": AddressObject("all", AddressType.IPMASK, subnet=ip_network("0.0.0.0/0")),
+            "site": AddressObject("site", AddressType.FQDN, fqdn="www.example.com"),
+        }
+    )
+    service_book = ServiceBook(services={"ALL": make_any_service()})
+    rule = PolicyRule("1", "to-site", 1, ("all",), ("site",), ("ALL",), "accept", True, "always")
+    return Evaluator([rule], address_book, service_book, MatchMode(mode="segment", max_hosts=256), resolver=resolver)
+
+
+def test_resolved_fqdn_matches_its_addresses():
+    resolver = FQDNResolver(lookup=lambda name: frozenset({IPv4Address("93.184.216.34")}))
+    evaluator = _evaluator(resolver)
+
+    allowed = evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("93.184.216.34/32"), Protocol.TCP, 443)
+    other = evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("93.184.216.35/32"), Protocol.TCP, 443)
+
+    assert allowed.decision == Decision.ALLOW
+    assert other.reason == "IMPLICIT_DENY"
+
+
+def test_slow_lookup_times_out_as_non_matching():
+    calls = []
+
+    def slow_lookup(name: str) -> frozenset[IPv4Address]:
+        calls.append(name)
+        time.sleep(0.5)
+        return frozenset({IPv4Address("93.184.216.34")})
+
+    evaluator = _evaluator(FQDNResolver(timeout=0.05, lookup=slow_lookup))
+
+    started = time.monotonic()
+    first = evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("93.184.216.34/32"), Protocol.TCP, 443)
+    second = evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("93.184.216.34/32"), Protocol.TCP, 80)
+    elapsed = time.monotonic() - started
+
+    assert first.decision == Decision.DENY
+    assert first.reason == "IMPLICIT_DENY"
+    assert second.reason == "IMPLICIT_DENY"
+    assert calls == ["www.example.com"]
+    assert elapsed < 0.4
+
+
+def test_exhausted_budget_skips_lookups():
+    calls = []
+
+    def lookup(name: str) -> frozenset[IPv4Address]:
+        calls.append(name)
+        return frozenset({IPv4Address("192.0.2.1")})
+
+    resolver = FQDNResolver(budget=0.0, lookup=lookup)
+
+    assert resolver.resolve("www.example.com") is None
+    assert calls == []
+
+
+def test_unresolved_fqdn_without_resolver_is_unknown():
+    evaluator = _evaluator(None)
+
+    result = evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("93.184.216.34/32"), Protocol.TCP, 443)
+
+    assert result.decision == Decision.UNKNOWN
+
+
+def test_ipv6_fqdn_stays_unknown_with_ipv4_resolver():
+    calls = []
+
+    def lookup(name: str) -> frozenset[IPv4Address]:
+        calls.append(name)
+        return frozenset({IPv4Address("93.184.216.34")})
+
+    address_book = AddressBook(
+        objects6={
+            "all": AddressObject("all", AddressType.IPMASK, subnet=ip_network("::/0")),
+            "site": AddressObject("site", AddressType.FQDN, fqdn="www.example.com"),
+        }
+    )
+    service_book = ServiceBook(services={"ALL": make_any_service()})
+    rule = PolicyRule(
+        "1", "to-site", 1, (), (), ("ALL",), "accept", True, "always", source6=("all",), destination6=("site",)
+    )
+    mode = MatchMode(mode="segment", max_hosts=256)
+    evaluator = Evaluator([rule], address_book, service_book, mode, resolver=FQDNResolver(lookup=lookup))
+
+    result = evaluator.evaluate(ip_network("2001:db8::/64"), ip_network("2606:2800::1/128"), Protocol.TCP, 443)
+
+    assert result.decision == Decision.UNKNOWN
+    assert calls == []
+
+
+def test_hosts_file_resolves_fqdn_and_wildcard(tmp_path):
+    hosts = tmp_path / "hosts"
+    hosts.write_text(
+        "# static entries\n"
+        "203.0.113.5 www.example.com\n"
+        "203.0.113.6 api.svc.example.com cdn.example.com\n"
+        "::1 localhost\n",
+        encoding="utf-8",
+    )
+    resolver = FQDNResolver(lookup=no_lookup, static_hosts=load_hosts_file(hosts))
+
+    assert resolver.resolve("WWW.example.com.") == frozenset({IPv4Address("203.0.113.5")})
+    assert resolver.resolve("mail.example.com") is None
+    assert resolver.resolve_wildcard("*.svc.example.com") == frozenset({IPv4Address("203.0.113.6")})
+    assert resolver.resolve_wildcard("*.example.org") is None
+
+
+def test_wildcard_fqdn_object_matches_hosts_entries_and_is_otherwise_unknown():
+    data = parse_fortigate_config(
+        """
+config firewall wildcard-fqdn custom
+    edit "any-svc"
+        set wildcard-fqdn "*.svc.example.com"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "any-svc"
+        set service "ALL"
+        set action accept
+    next
+end
+""".splitlines()
+    )
+    assert data.address_book.objects["any-svc"].fqdn == "*.svc.example.com"
+
+    def evaluate(resolver):
+        evaluator = Evaluator(
+            data.policies,
+            data.address_book,
+            data.service_book,
+            MatchMode(mode="segment", max_hosts=256),
+            resolver=resolver,
+        )
+        return evaluator.evaluate(ip_network("10.0.0.0/24"), ip_network("203.0.113.6/32"), Protocol.TCP, 443)
+
+    hosts = {"api.svc.example.com": frozenset({IPv4Address("203.0.113.6")})}
+    assert evaluate(FQDNResolver(lookup=no_lookup, static_hosts=hosts)).decision == Decision.ALLOW
+    assert evaluate(FQDNResolver(lookup=no_lookup)).decision == Decision.UNKNOWN
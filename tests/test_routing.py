@@ -0,0 +1,167 @@
+"""Tests for static route parsing and routing-aware evaluation."""
+from __future__ import annotations
+
+from ipaddress import ip_address, ip_network
+
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.models import Protocol
+from static_traffic_analyzer.routing import (
+    connected_routes,
+    find_route,
+    route_flow,
+    route_label,
+    rpf_reason,
+    unroutable_reason,
+)
+
+
+CONFIG = """
+config system interface
+    edit "port1"
+        set ip 10.0.0.1 255.255.255.0
+    next
+    edit "wan1"
+        set ip 198.51.100.2 255.255.255.252
+    next
+end
+config firewall address
+    edit "PARTNER"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config router static
+    edit 1
+        set dst 172.16.0.0 255.240.0.0
+        set gateway 10.0.0.254
+        set device "port1"
+    next
+    edit 2
+        set dst 172.16.99.0/24
+        set blackhole enable
+    next
+    edit 3
+        set dstaddr "PARTNER"
+        set device "wan1"
+        set status disable
+    next
+    edit 4
+        set dst 10.50.0.0 255.255.0.0
+        set blackhole enable
+    next
+    edit 5
+        set dst 10.50.7.0 255.255.255.0
+        set device "port1"
+    next
+end
+"""
+
+
+def test_static_routes_and_connected_subnets_decide_reachability():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    assert [route.seq_num for route in data.static_routes] == ["1", "2", "3", "4", "5"]
+    assert data.static_routes[0].gateway == ip_address("10.0.0.254")
+    assert (data.static_routes[2].dst, data.static_routes[2].enabled) == (ip_network("192.0.2.0/24"), False)
+
+    routes = [*data.static_routes, *connected_routes(data.interfaces)]
+
+    assert find_route(routes, ip_network("172.16.5.0/24")).device == "port1"
+    assert route_label(find_route(routes, ip_network("10.0.0.0/25"))) == "connected:port1"
+    assert unroutable_reason(routes, ip_network("172.16.5.0/24")) is None
+    assert unroutable_reason(routes, ip_network("172.16.99.0/25")) == "BLACKHOLE_ROUTE"
+    assert unroutable_reason(routes, ip_network("192.0.2.0/24")) == "NO_ROUTE"
+    # Part of 10.50.0.0/16 is routed through port1, so policies still decide.
+    assert unroutable_reason(routes, ip_network("10.50.0.0/16")) is None
+    assert unroutable_reason(routes, ip_network("10.50.8.0/24")) == "BLACKHOLE_ROUTE"
+
+
+def test_route_to_unknown_address_is_reported():
+    config = """
+config router static
+    edit 1
+        set dstaddr "MISSING"
+    next
+end
+"""
+    data = parse_fortigate_config(config.splitlines())
+
+    assert data.static_routes == []
+    assert data.warnings == ["line 3: static route 1: Route destination is not a subnet address: MISSING"]
+
+
+POLICY_ROUTE_CONFIG = """
+config system interface
+    edit "port1"
+        set ip 10.0.0.1 255.255.255.0
+    next
+    edit "wan1"
+        set ip 198.51.100.2 255.255.255.252
+    next
+    edit "wan2"
+        set ip 203.0.113.2 255.255.255.252
+    next
+end
+config router static
+    edit 1
+        set gateway 198.51.100.1
+        set device "wan1"
+    next
+end
+config router policy
+    edit 1
+        set input-device "port1"
+        set src "10.0.0.0/255.255.255.128"
+        set dst "192.0.2.0/255.255.255.0"
+        set action deny
+    next
+    edit 2
+        set input-device "port1"
+        set src "10.0.0.0/255.255.255.0"
+        set protocol 6
+        set start-port 443
+        set end-port 443
+        set gateway 203.0.113.1
+        set output-device "wan2"
+    next
+end
+"""
+
+
+def test_policy_routes_select_egress_before_the_routing_table():
+    data = parse_fortigate_config(POLICY_ROUTE_CONFIG.splitlines())
+    assert [(entry.seq_num, entry.action) for entry in data.policy_routes] == [("1", "deny"), ("2", "permit")]
+    routes = [*data.static_routes, *connected_routes(data.interfaces)]
+
+    def route(src: str, dst: str, protocol: Protocol, port: int):
+        return route_flow(routes, data.policy_routes, ip_network(src), ip_network(dst), protocol, port)
+
+    https = route("10.0.0.128/25", "8.8.8.0/24", Protocol.TCP, 443)
+    assert (https.egress_interface, https.route) == ("wan2", "policy-route:2")
+    # Other ports follow the default route.
+    assert route("10.0.0.128/25", "8.8.8.0/24", Protocol.TCP, 80).route == "static:1"
+    # The deny entry hands matching flows back to the routing table.
+    assert route("10.0.0.0/25", "192.0.2.0/24", Protocol.TCP, 443).egress_interface == "wan1"
+    # Sources outside port1's subnet do not arrive on the input device.
+    assert route("172.16.0.0/24", "8.8.8.0/24", Protocol.TCP, 443).egress_interface == "wan1"
+
+
+def test_strict_rpf_requires_the_route_back_to_use_the_ingress_interface():
+    data = parse_fortigate_config(CONFIG.splitlines())
+    routes = [*data.static_routes, *connected_routes(data.interfaces)]
+
+    assert rpf_reason(routes, ip_network("172.16.5.0/24"), "port1") is None
+    assert rpf_reason(routes, ip_network("172.16.5.0/24"), "wan1") == "RPF_WRONG_INTERFACE"
+    assert rpf_reason(routes, ip_network("192.0.2.0/24"), None) == "RPF_NO_ROUTE"
+    assert rpf_reason(routes, ip_network("172.16.99.0/24"), "port1") == "RPF_NO_ROUTE"
+    # Without a known ingress only a missing route back is caught.
+    assert rpf_reason(routes, ip_network("172.16.5.0/24"), None) is None
+
+
+def test_strict_rpf_accepts_any_equal_cost_route():
+    config = CONFIG.replace(
+        "    edit 2\n",
+        '    edit 6\n        set dst 172.16.0.0 255.240.0.0\n        set device "wan1"\n    next\n    edit 2\n',
+    )
+    data = parse_fortigate_config(config.splitlines())
+
+    assert rpf_reason(data.static_routes, ip_network("172.16.5.0/24"), "wan1") is None
+    assert rpf_reason(data.static_routes, ip_network("172.16.5.0/24"), "port1") is None
@@ -0,0 +1,79 @@
+"""Tests for dynamic (SDN connector) address objects."""
+from __future__ import annotations
+
+import json
+from ipaddress import ip_network
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.models import AddressType, Decision, Protocol
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.sdn import apply_dynamic_map, load_dynamic_map
+from static_traffic_analyzer.utils import ParseError
+
+
+CONFIG = """
+config firewall address
+    edit "aws-web"
+        set type dynamic
+        set sdn "aws"
+        set filter "Tag.Role=web"
+    next
+    edit "k8s-pods"
+        set type dynamic
+        set sdn "k8s"
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "aws-web"
+        set service "HTTPS"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "k8s-pods"
+        set service "HTTPS"
+        set action accept
+    next
+end
+"""
+
+
+def _evaluate(data, dst: str):
+    return evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network("10.0.0.0/24"),
+        ip_network(dst),
+        Protocol.TCP,
+        443,
+        MatchMode(mode="segment", max_hosts=256),
+        ignore_schedule=False,
+    )
+
+
+def test_dynamic_objects_expand_from_mapping(tmp_path: Path):
+    mapping = tmp_path / "dynamic.json"
+    mapping.write_text(json.dumps({"aws-web": ["172.31.0.10", "172.31.1.0/24", "172.31.2.5-172.31.2.9"]}))
+    data = parse_fortigate_config(CONFIG.splitlines())
+    assert data.address_book.objects["aws-web"].address_type == AddressType.DYNAMIC
+    assert _evaluate(data, "172.31.0.10/32").decision == Decision.UNKNOWN
+
+    apply_dynamic_map(data.address_book, load_dynamic_map(mapping))
+
+    assert data.address_book.groups["aws-web"].members[0] == "aws-web[172.31.0.10]"
+    assert _evaluate(data, "172.31.2.7/32").matched_policy_id == "1"
+    # Unmapped dynamic objects stay unresolved.
+    assert _evaluate(data, "172.31.9.1/32").decision == Decision.UNKNOWN
+
+
+def test_dynamic_mapping_must_list_addresses(tmp_path: Path):
+    mapping = tmp_path / "dynamic.json"
+    mapping.write_text(json.dumps({"aws-web": "172.31.0.10"}))
+    with pytest.raises(ParseError, match="list of addresses"):
+        load_dynamic_map(mapping)
@@ -0,0 +1,77 @@
+"""Tests for the Juniper SRX configuration parser."""
+from __future__ import annotations
+
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.srx import parse_srx_config
+
+
+SRX_CONFIG = """
+set security address-book global address LAN 10.0.0.0/24
+set security address-book global address WEB1 10.1.0.10/32
+set security address-book global address POOL range-address 10.1.0.20 to 10.1.0.29
+set security address-book global address-set WEB address WEB1
+set security address-book global address-set WEB address POOL
+set applications application tcp-8443 protocol tcp
+set applications application tcp-8443 destination-port 8443
+set applications application-set WEB-APPS application junos-https
+set applications application-set WEB-APPS application tcp-8443
+set security policies from-zone trust to-zone dmz policy allow-web match source-address LAN
+set security policies from-zone trust to-zone dmz policy allow-web match destination-address WEB
+set security policies from-zone trust to-zone dmz policy allow-web match application WEB-APPS
+set security policies from-zone trust to-zone dmz policy allow-web then permit
+set security policies from-zone trust to-zone dmz policy legacy match source-address any
+set security policies from-zone trust to-zone dmz policy legacy match destination-address any
+set security policies from-zone trust to-zone dmz policy legacy match application junos-ssh
+set security policies from-zone trust to-zone dmz policy legacy then permit
+deactivate security policies from-zone trust to-zone dmz policy legacy
+set security policies global policy default-deny match source-address any
+set security policies global policy default-deny match destination-address any
+set security policies global policy default-deny match application any
+set security policies global policy default-deny then deny
+"""
+
+
+def test_srx_zone_and_global_policies(evaluate):
+    data = parse_srx_config(SRX_CONFIG.splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == [
+        "trust->dmz:allow-web",
+        "trust->dmz:legacy",
+        "global:default-deny",
+    ]
+    assert not data.policies[1].enabled
+
+    allowed = evaluate(data, "10.1.0.25/32", Protocol.TCP, 8443)
+    assert (allowed.decision, allowed.matched_policy_id) == (Decision.ALLOW, "trust->dmz:allow-web")
+    ssh = evaluate(data, "10.1.0.10/32", Protocol.TCP, 22)
+    assert (ssh.decision, ssh.matched_policy_id) == (Decision.DENY, "global:default-deny")
+
+
+def test_srx_application_terms_and_zones(evaluate):
+    data = parse_srx_config(
+        """
+set applications application alt-web term t1 protocol tcp destination-port 8080
+set applications application alt-web term t2 protocol udp
+set applications application alt-web term t2 destination-port 8081
+set applications application odd term t1 destination-port 9000
+set security policies from-zone trust to-zone dmz policy alt match source-address any
+set security policies from-zone trust to-zone dmz policy alt match destination-address any
+set security policies from-zone trust to-zone dmz policy alt match application alt-web
+set security policies from-zone trust to-zone dmz policy alt then permit
+set security policies from-zone trust to-zone dmz policy odd match application odd
+set security policies from-zone trust to-zone dmz policy odd then permit
+""".splitlines()
+    )
+
+    entries = data.service_book.services["alt-web"].entries
+    assert [(entry.protocol, entry.start_port) for entry in entries] == [(Protocol.TCP, 8080), (Protocol.UDP, 8081)]
+    assert "odd" not in data.service_book.services
+    assert data.warnings == ["application odd: term without a protocol"]
+    assert (data.policies[0].src_interfaces, data.policies[0].dst_interfaces) == (("trust",), ("dmz",))
+
+    alt = evaluate(data, "10.1.0.10/32", Protocol.TCP, 8080)
+    assert (alt.decision, alt.matched_policy_id) == (Decision.ALLOW, "trust->dmz:alt")
+    odd = evaluate(data, "10.1.0.10/32", Protocol.TCP, 9000)
+    assert (odd.decision, odd.matched_policy_id) == (Decision.UNKNOWN, "trust->dmz:odd")
+    from_untrust = evaluate(data, "10.1.0.10/32", Protocol.TCP, 8080, ingress="untrust")
+    assert from_untrust.decision == Decision.DENY
@@ -0,0 +1,22 @@
+"""Tests for time-window sweeps."""
+from __future__ import annotations
+
+from datetime import datetime, timedelta
+
+import pytest
+
+from static_traffic_analyzer.sweep import parse_step, sweep_times
+from static_traffic_analyzer.utils import ParseError
+
+
+def test_sweep_times_include_both_ends():
+    start = datetime(2026, 1, 5, 0, 0)
+
+    assert parse_step("90m") == timedelta(minutes=90)
+    assert parse_step("1D") == timedelta(days=1)
+    assert sweep_times(start, start + timedelta(hours=3), parse_step("1h"))[-1] == datetime(2026, 1, 5, 3, 0)
+    assert len(sweep_times(start, start + timedelta(days=7), parse_step("1h"))) == 169
+    with pytest.raises(ParseError):
+        parse_step("0h")
+    with pytest.raises(ParseError):
+        sweep_times(start, start - timedelta(hours=1), timedelta(hours=1))
@@ -0,0 +1,78 @@
+"""Tests for the Terraform fortios importer."""
+from __future__ import annotations
+
+import json
+from ipaddress import ip_network
+
+from static_traffic_analyzer.evaluator import MatchMode, evaluate_policy
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.parsers.terraform import parse_terraform_fortios
+
+
+def _resource(kind: str, name: str, values: dict) -> dict:
+    return {"address": f"{kind}.{name}", "mode": "managed", "type": kind, "name": name, "values": values}
+
+
+PLAN = {
+    "format_version": "1.2",
+    "planned_values": {
+        "root_module": {
+            "resources": [
+                _resource(
+                    "fortios_firewall_address",
+                    "lan",
+                    {"name": "LAN", "type": "ipmask", "subnet": "10.0.0.0 255.255.255.0", "fqdn": None},
+                ),
+                _resource(
+                    "fortios_firewallservice_custom",
+                    "app",
+                    {"name": "APP", "tcp_portrange": "8443 9000-9100", "udp_portrange": ""},
+                ),
+            ],
+            "child_modules": [
+                {
+                    "address": "module.web",
+                    "resources": [
+                        _resource(
+                            "fortios_firewall_address",
+                            "web",
+                            {"name": "WEB", "type": "iprange", "start_ip": "10.1.0.10", "end_ip": "10.1.0.20"},
+                        ),
+                        _resource(
+                            "fortios_firewall_policy",
+                            "allow_app",
+                            {
+                                "policyid": 12,
+                                "name": "allow-app",
+                                "srcaddr": [{"name": "LAN"}],
+                                "dstaddr": [{"name": "WEB"}],
+                                "service": [{"name": "APP"}],
+                                "action": "accept",
+                                "status": "enable",
+                                "schedule": "always",
+                            },
+                        ),
+                    ],
+                }
+            ],
+        }
+    },
+}
+
+
+def test_terraform_plan_policies_are_simulated():
+    data = parse_terraform_fortios(json.dumps(PLAN).splitlines())
+
+    assert [policy.policy_id for policy in data.policies] == ["12"]
+    match = evaluate_policy(
+        data.policies,
+        data.address_book,
+        data.service_book,
+        ip_network("10.0.0.0/24"),
+        ip_network("10.1.0.15/32"),
+        Protocol.TCP,
+        9050,
+        MatchMode(mode="segment", max_hosts=256),
+        ignore_schedule=False,
+    )
+    assert (match.decision, match.matched_policy_id) == (Decision.ALLOW, "12")
@@ -0,0 +1,91 @@
+"""Tests for multi-firewall topologies."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.chain import Hop, evaluate_chain
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.topology import load_topology
+from static_traffic_analyzer.utils import ParseError
+
+
+EDGE_CONFIG = """
+config firewall policy
+    edit 1
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+CORE_CONFIG = """
+config firewall address
+    edit "DC"
+        set subnet 10.20.0.0 255.255.0.0
+    next
+end
+config firewall policy
+    edit 7
+        set srcaddr "all"
+        set dstaddr "DC"
+        set service "HTTPS"
+        set action accept
+    next
+end
+"""
+
+
+def _write_topology(tmp_path: Path) -> Path:
+    (tmp_path / "edge.conf").write_text(EDGE_CONFIG, encoding="utf-8")
+    (tmp_path / "core.conf").write_text(CORE_CONFIG, encoding="utf-8")
+    topology = tmp_path / "topology.csv"
+    topology.write_text(
+        "device,config,networks\n"
+        "edge,edge.conf,10.1.0.0/16;172.16.0.0/30\n"
+        "core,core.conf,172.16.0.0/30;10.20.0.0/16;10.30.0.0/16\n",
+        encoding="utf-8",
+    )
+    return topology
+
+
+def test_path_lists_devices_between_segments_in_order(tmp_path: Path):
+    topology = load_topology(_write_topology(tmp_path))
+
+    def names(src: str, dst: str):
+        path = topology.path(ip_network(src), ip_network(dst))
+        return None if path is None else [device.name for device in path]
+
+    assert names("10.1.2.0/24", "10.20.5.0/24") == ["edge", "core"]
+    assert names("10.20.5.0/24", "10.1.2.0/24") == ["core", "edge"]
+    assert names("10.20.5.0/24", "10.30.0.1/32") == ["core"]
+    assert names("10.1.2.0/24", "10.1.3.0/24") == []
+    assert names("10.1.2.0/24", "192.0.2.0/24") is None
+
+
+def test_flow_is_blocked_by_first_denying_device_on_path(tmp_path: Path):
+    topology = load_topology(_write_topology(tmp_path))
+    mode = MatchMode(mode="segment", max_hosts=256)
+    src, dst = ip_network("10.1.2.0/24"), ip_network("10.20.5.0/24")
+    hops = [
+        Hop(device.name, Evaluator(device.data.policies, device.data.address_book, device.data.service_book, mode))
+        for device in topology.path(src, dst)
+    ]
+
+    blocked = evaluate_chain(hops, src, dst, Protocol.TCP, 22)
+    assert (blocked.decision, blocked.blocking_hop) == (Decision.DENY, "core")
+    assert evaluate_chain(hops, src, dst, Protocol.TCP, 443).decision == Decision.ALLOW
+
+
+def test_topology_rejects_devices_on_a_single_network(tmp_path: Path):
+    (tmp_path / "edge.conf").write_text(EDGE_CONFIG, encoding="utf-8")
+    topology = tmp_path / "topology.csv"
+    topology.write_text("device,config,networks\nedge,edge.conf,10.1.0.0/16\n", encoding="utf-8")
+
+    with pytest.raises(ParseError, match="at least two networks"):
+        load_topology(topology)
@@ -0,0 +1,33 @@
+"""Tests for traffic log hit counting."""
+from __future__ import annotations
+
+from pathlib import Path
+
+import pytest
+
+from static_traffic_analyzer.trafficlog import count_hits, load_hit_counts
+from static_traffic_analyzer.utils import ParseError
+
+
+def test_counts_key_value_traffic_logs():
+    lines = [
+        'date=2026-01-05 time=09:00:01 type="traffic" subtype="forward" policyid=3 srcip=192.168.10.5 action="accept"',
+        'date=2026-01-05 time=09:00:02 type="traffic" subtype="forward" policyid=3 srcip=192.168.10.6 action="accept"',
+        'date=2026-01-05 time=09:00:03 type="traffic" subtype="forward" policyid=0 srcip=192.168.10.7 action="deny"',
+        'date=2026-01-05 time=09:00:04 type="event" subtype="system" logdesc="Admin login successful"',
+        "",
+    ]
+
+    assert count_hits(lines) == {"3": 2, "0": 1}
+
+
+def test_counts_fortianalyzer_csv_export(tmp_path: Path):
+    export = tmp_path / "traffic.csv"
+    export.write_text("date,time,Policy ID,srcip\n2026-01-05,09:00,1,10.0.0.1\n2026-01-05,09:01,1,10.0.0.2\n")
+
+    assert load_hit_counts(export) == {"1": 2}
+
+
+def test_csv_export_without_policy_column_is_rejected():
+    with pytest.raises(ParseError, match="policyid"):
+        count_hits(["date,time,srcip", "2026-01-05,09:00,10.0.0.1"])
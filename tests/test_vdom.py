@@ -0,0 +1,143 @@
+"""Tests for multi-VDOM configs and inter-VDOM links."""
+from __future__ import annotations
+
+from ipaddress import ip_network
+
+import pytest
+
+from static_traffic_analyzer.chain import Hop, evaluate_chain
+from static_traffic_analyzer.evaluator import Evaluator, MatchMode
+from static_traffic_analyzer.models import Decision, Protocol
+from static_traffic_analyzer.utils import ParseError
+from static_traffic_analyzer.vdom import VdomHop, parse_vdom_config
+
+
+CONFIG = """
+config vdom
+    edit "root"
+    next
+    edit "dmz"
+    next
+    edit "lab"
+    next
+end
+config global
+    config system vdom-link
+        edit "vlink"
+        next
+    end
+    config system interface
+        edit "port1"
+            set vdom "root"
+            set ip 10.0.0.1 255.255.255.0
+        next
+        edit "port2"
+            set vdom "dmz"
+            set ip 172.16.0.1 255.255.255.0
+        next
+        edit "vlink0"
+            set vdom "root"
+        next
+        edit "vlink1"
+            set vdom "dmz"
+        next
+    end
+end
+config vdom
+    edit "root"
+        config firewall address
+            edit "DMZ"
+                set subnet 172.16.0.0 255.255.255.0
+            next
+        end
+        config firewall policy
+            edit 1
+                set srcintf "port1"
+                set dstintf "vlink0"
+                set srcaddr "all"
+                set dstaddr "DMZ"
+                set service "ALL"
+                set action accept
+            next
+        end
+    next
+    edit "dmz"
+        config firewall address
+            edit "WEB"
+                set subnet 172.16.0.10 255.255.255.255
+            next
+        end
+        config firewall policy
+            edit 7
+                set srcintf "vlink1"
+                set dstintf "port2"
+                set srcaddr "all"
+                set dstaddr "WEB"
+                set service "HTTPS"
+                set action accept
+            next
+        end
+    next
+end
+"""
+
+
+def test_each_vdom_keeps_its_own_tables_and_interfaces():
+    config = parse_vdom_config(CONFIG.splitlines())
+
+    assert list(config.vdoms) == ["root", "dmz", "lab"]
+    assert [policy.policy_id for policy in config.vdoms["root"].policies] == ["1"]
+    assert [policy.policy_id for policy in config.vdoms["dmz"].policies] == ["7"]
+    assert "DMZ" not in config.vdoms["dmz"].address_book.objects
+    assert sorted(config.vdoms["dmz"].interfaces) == ["port2", "vlink1"]
+    assert [(link.name, link.vdoms) for link in config.links] == [("vlink", ("root", "dmz"))]
+
+
+def test_path_crosses_inter_vdom_links_with_their_interfaces():
+    config = parse_vdom_config(CONFIG.splitlines())
+
+    assert config.path("root", "dmz") == [VdomHop("root", egress="vlink0"), VdomHop("dmz", ingress="vlink1")]
+    assert config.path("dmz", "root") == [VdomHop("dmz", egress="vlink1"), VdomHop("root", ingress="vlink0")]
+    assert config.path("root", "root") == [VdomHop("root")]
+    assert config.path("root", "lab") is None
+    assert config.path("root", "missing") is None
+
+
+def test_flow_is_evaluated_through_both_vdoms():
+    config = parse_vdom_config(CONFIG.splitlines())
+    mode = MatchMode(mode="segment", max_hosts=256)
+
+    def chain(port: int):
+        hops = [
+            Hop(
+                hop.vdom,
+                Evaluator(
+                    config.vdoms[hop.vdom].policies,
+                    config.vdoms[hop.vdom].address_book,
+                    config.vdoms[hop.vdom].service_book,
+                    mode,
+                ),
+                ingress=hop.ingress,
+                egress=hop.egress,
+            )
+            for hop in config.path("root", "dmz")
+        ]
+        return evaluate_chain(hops, ip_network("10.0.0.0/24"), ip_network("172.16.0.10/32"), Protocol.TCP, port)
+
+    assert chain(443).decision == Decision.ALLOW
+    blocked = chain(22)
+    assert (blocked.decision, blocked.blocking_hop) == (Decision.DENY, "dmz")
+
+
+def test_line_numbers_in_warnings_match_the_whole_file():
+    config = CONFIG.replace('            edit 7\n', '            edit 7\n                bogus line\n')
+
+    parsed = parse_vdom_config(config.splitlines())
+
+    line = config.splitlines().index("                bogus line") + 1
+    assert parsed.vdoms["dmz"].warnings == [f"vdom dmz: line {line}: Unrecognized line: bogus line"]
+
+
+def test_config_without_vdoms_is_rejected():
+    with pytest.raises(ParseError, match="no `config vdom` entries"):
+        parse_vdom_config(["config firewall policy", "end"])
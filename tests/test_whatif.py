@@ -0,0 +1,94 @@
+"""Tests for applying policy patches in what-if analysis."""
+from __future__ import annotations
+
+import pytest
+
+from static_traffic_analyzer.parsers.fortigate import parse_fortigate_config
+from static_traffic_analyzer.utils import ParseError
+from static_traffic_analyzer.whatif import apply_policy_patch, parse_policy_patch
+
+
+BASE = """
+config firewall address
+    edit "LAN"
+        set subnet 10.0.0.0 255.255.0.0
+    next
+end
+config firewall service custom
+    edit "HTTP"
+        set tcp-portrange 8080
+    next
+end
+config firewall policy
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "all"
+        set service "HTTP"
+        set action accept
+    next
+    edit 2
+        set srcaddr "all"
+        set dstaddr "all"
+        set service "ALL"
+        set action deny
+    next
+    edit 3
+        set srcaddr "LAN"
+        set dstaddr "all"
+        set service "ALL"
+        set action accept
+    next
+end
+"""
+
+PATCH = """
+config firewall address
+    edit "DMZ"
+        set subnet 192.0.2.0 255.255.255.0
+    next
+end
+config firewall policy
+    delete 2
+    edit 1
+        set srcaddr "LAN"
+        set dstaddr "DMZ"
+        set service "HTTP"
+        set action deny
+    next
+    edit 4
+        set srcaddr "DMZ"
+        set dstaddr "LAN"
+        set service "HTTP"
+        set action accept
+    next
+end
+"""
+
+
+def _apply(patch_text: str):
+    base = parse_fortigate_config(BASE.splitlines())
+    patch = parse_policy_patch(patch_text.splitlines())
+    return base, apply_policy_patch(base.policies, base.address_book, base.service_book, patch)
+
+
+def test_patch_replaces_deletes_and_adds_policies():
+    base, (policies, address_book, service_book) = _apply(PATCH)
+
+    assert [(policy.policy_id, policy.action) for policy in policies] == [
+        ("1", "deny"),
+        ("3", "accept"),
+        ("4", "accept"),
+    ]
+    assert policies[0].destination == ("DMZ",)
+    assert "DMZ" in address_book.objects and "LAN" in address_book.objects
+    # The built-in HTTP the patch parser adds must not replace the base redefinition.
+    assert service_book.services["HTTP"].entries[0].start_port == 8080
+    assert [policy.policy_id for policy in base.policies] == ["1", "2", "3"]
+    assert "DMZ" not in base.address_book.objects
+
+
+def test_patch_rejects_unknown_deletes():
+    with pytest.raises(ParseError, match="unknown policies: 9"):
+        _apply("config firewall policy\n    delete 9\nend\n")
+    with pytest.raises(ParseError, match="only supported for firewall policies"):
+        parse_policy_patch(["config firewall address", "    delete LAN", "end"])